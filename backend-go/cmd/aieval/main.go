@@ -0,0 +1,124 @@
+// Command aieval scores how well the chat model extracts actions from a corpus of Indonesian
+// messages and receipts.
+//
+//	go run ./cmd/aieval -mode record              # live model, saves answers to the cassette
+//	go run ./cmd/aieval                           # replays the cassette, no model server needed
+//	go run ./cmd/aieval -mode live -prompt-version chat-v1 -out eval/v1.json
+//	go run ./cmd/aieval -mode live -baseline eval/v1.json
+//
+// Replay scores the recorded answers, so it checks the scoring and the parsing around the model.
+// To judge a new chat prompt version, run live with the old version and then the new one, and
+// pass the first report as -baseline.
+package main
+
+import (
+	"cuan-backend/internal/eval"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/service"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	corpusPath := flag.String("corpus", "eval/corpus.yaml", "YAML corpus of messages and expected actions")
+	mode := flag.String("mode", "replay", "replay (cassette), live (model server) or record (live, then save the cassette)")
+	cassettePath := flag.String("cassette", "eval/cassette.json", "recorded provider answers")
+	profiles := flag.String("profiles", os.Getenv("AI_PROFILES_FILE"), "AI profiles file for live runs; empty uses -url")
+	url := flag.String("url", os.Getenv("LOCAL_LLM_URL"), "llama.cpp server for live runs")
+	promptVersion := flag.String("prompt-version", os.Getenv("CHAT_PROMPT_VERSION"), "chat prompt template version; empty uses the latest")
+	timeout := flag.Duration("timeout", 120*time.Second, "per-request timeout for live runs")
+	outPath := flag.String("out", "", "write the JSON report here")
+	baselinePath := flag.String("baseline", "", "JSON report to diff against")
+	minAction := flag.Float64("min-action", 0, "exit 1 when action accuracy is below this (0-1)")
+	verbose := flag.Bool("v", false, "show service logs")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	if *verbose {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	corpus, err := eval.LoadCorpus(*corpusPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid corpus")
+	}
+
+	prompts, err := service.LoadPromptTemplates(*promptVersion)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid prompt version")
+	}
+
+	var report *eval.Report
+	switch *mode {
+	case "replay":
+		cassette, err := eval.LoadCassette(*cassettePath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot load cassette; record one with -mode record")
+		}
+		report = eval.Run(corpus, *mode, prompts, cassette.Replay())
+	case "live", "record":
+		var live aiprovider.Provider
+		cfg := aiprovider.DefaultCompositeConfig()
+		if *profiles != "" {
+			router, err := aiprovider.LoadRouter(*profiles, cfg)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Invalid AI profiles")
+			}
+			live = router
+		} else {
+			if *url == "" {
+				log.Fatal().Msg("Live runs need -url or -profiles")
+			}
+			live = aiprovider.NewCompositeProvider(cfg, aiprovider.Backend{
+				Name:     "local",
+				Provider: aiprovider.NewLocalProvider(*url),
+				Timeout:  *timeout,
+			})
+		}
+		if *mode == "live" {
+			report = eval.Run(corpus, *mode, prompts, func(string) (aiprovider.Provider, error) { return live, nil })
+			break
+		}
+		cassette := eval.Cassette{}
+		if existing, err := eval.LoadCassette(*cassettePath); err == nil {
+			// Cases missing from the corpus keep their recordings.
+			cassette = existing
+		}
+		factory, flush := cassette.Record(live)
+		report = eval.Run(corpus, *mode, prompts, factory)
+		flush()
+		if err := cassette.Save(*cassettePath); err != nil {
+			log.Fatal().Err(err).Msg("Cannot save cassette")
+		}
+		fmt.Fprintf(os.Stderr, "cassette saved to %s\n", *cassettePath)
+	default:
+		log.Fatal().Str("mode", *mode).Msg("Unknown mode")
+	}
+
+	report.WriteText(os.Stdout)
+
+	if *baselinePath != "" {
+		baseline, err := eval.LoadReport(*baselinePath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot load baseline report")
+		}
+		fmt.Printf("\n=== diff against %s ===\n", *baselinePath)
+		eval.Compare(baseline, report).WriteText(os.Stdout)
+	}
+	if *outPath != "" {
+		if err := report.Save(*outPath); err != nil {
+			log.Fatal().Err(err).Msg("Cannot save report")
+		}
+	}
+	if acc := report.Metrics[eval.MetricAction].Accuracy(); *minAction > 0 && !(acc >= *minAction) {
+		os.Exit(1)
+	}
+}
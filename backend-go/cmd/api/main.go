@@ -4,15 +4,22 @@ import (
 	"context"
 	"flag"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"cuan-backend/internal/audit"
 	"cuan-backend/internal/config"
 	"cuan-backend/internal/handler"
 	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/provider/mail"
+	"cuan-backend/internal/provider/storage"
+	waprovider "cuan-backend/internal/provider/whatsapp"
 	"cuan-backend/internal/repository"
+	"cuan-backend/internal/scheduler"
 	"cuan-backend/internal/seeder"
 	"cuan-backend/internal/service"
 	"cuan-backend/pkg/middleware"
@@ -51,6 +58,10 @@ func main() {
 		log.Fatal().Err(err).Msg("Database connection failed")
 	}
 
+	if err := audit.Register(db); err != nil {
+		log.Fatal().Err(err).Msg("Audit log registration failed")
+	}
+
 	if *freshPtr {
 		config.MigrateFresh(db)
 	} else {
@@ -65,23 +76,150 @@ func main() {
 
 	frontendURL := os.Getenv("FRONTEND_URL")
 
+	// AI_PROVIDER may list a fallback chain such as "local,external": the next provider is used
+	// when the previous one fails or its circuit breaker is open.
+	envDuration := func(key string, fallback time.Duration) time.Duration {
+		d, err := time.ParseDuration(os.Getenv(key))
+		if err != nil {
+			return fallback
+		}
+		return d
+	}
+	envInt := func(key string, fallback int) int {
+		n, err := strconv.Atoi(os.Getenv(key))
+		if err != nil {
+			return fallback
+		}
+		return n
+	}
+
+	providerCfg := aiprovider.DefaultCompositeConfig()
+	providerCfg.MaxRetries = envInt("AI_MAX_RETRIES", providerCfg.MaxRetries)
+	providerCfg.RetryBackoff = envDuration("AI_RETRY_BACKOFF", providerCfg.RetryBackoff)
+	providerCfg.MaxBackoff = envDuration("AI_RETRY_MAX_BACKOFF", providerCfg.MaxBackoff)
+	providerCfg.FailureThreshold = envInt("AI_BREAKER_THRESHOLD", providerCfg.FailureThreshold)
+	providerCfg.OpenDuration = envDuration("AI_BREAKER_COOLDOWN", providerCfg.OpenDuration)
+
+	// AI_PROFILES_FILE switches to per-task routing over named model profiles (see
+	// ai-profiles.example.yaml); without it AI_PROVIDER serves every task.
 	var llmProvider aiprovider.Provider
-	switch os.Getenv("AI_PROVIDER") {
-	case "external":
-		externalURL := os.Getenv("EXTERNAL_AI_URL")
-		apiKey := os.Getenv("EXTERNAL_AI_API_KEY")
-		model := os.Getenv("EXTERNAL_AI_MODEL")
-		llmProvider = aiprovider.NewExternalProvider(externalURL, apiKey, model)
-		log.Info().Str("provider", "External").Str("url", externalURL).Str("model", model).Msg("AI Provider Configured")
+	if profilesFile := os.Getenv("AI_PROFILES_FILE"); profilesFile != "" {
+		router, err := aiprovider.LoadRouter(profilesFile, providerCfg)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid AI profiles")
+		}
+		llmProvider = router
+		log.Info().Str("file", profilesFile).Msg("AI Provider profiles configured")
+	} else {
+		var backends []aiprovider.Backend
+		for _, name := range strings.Split(os.Getenv("AI_PROVIDER"), ",") {
+			switch strings.TrimSpace(name) {
+			case "external":
+				externalURL := os.Getenv("EXTERNAL_AI_URL")
+				model := os.Getenv("EXTERNAL_AI_MODEL")
+				backends = append(backends, aiprovider.Backend{
+					Name:     "external",
+					Provider: aiprovider.NewExternalProvider(externalURL, os.Getenv("EXTERNAL_AI_API_KEY"), model),
+					Timeout:  envDuration("EXTERNAL_AI_TIMEOUT", 60*time.Second),
+				})
+				log.Info().Str("provider", "External").Str("url", externalURL).Str("model", model).Msg("AI Provider Configured")
+			case "local", "":
+				localLLMURL := os.Getenv("LOCAL_LLM_URL")
+				backends = append(backends, aiprovider.Backend{
+					Name:     "local",
+					Provider: aiprovider.NewLocalProvider(localLLMURL),
+					Timeout:  envDuration("LOCAL_LLM_TIMEOUT", 120*time.Second),
+				})
+				log.Info().Str("provider", "Local").Str("url", localLLMURL).Msg("AI Provider Configured")
+			default:
+				log.Fatal().Str("provider", name).Msg("Unknown AI provider")
+			}
+		}
+		llmProvider = aiprovider.NewCompositeProvider(providerCfg, backends...)
+	}
+
+	// ffmpeg normalizes voice notes before Whisper and converts spoken replies to OGG/Opus.
+	// FFMPEG_PATH=off disables both conversions.
+	ffmpeg := "ffmpeg"
+	switch path := os.Getenv("FFMPEG_PATH"); path {
+	case "":
+	case "off":
+		ffmpeg = ""
 	default:
-		localLLMURL := os.Getenv("LOCAL_LLM_URL")
-		llmProvider = aiprovider.NewLocalProvider(localLLMURL)
-		log.Info().Str("provider", "Local").Str("url", localLLMURL).Msg("AI Provider Configured")
+		ffmpeg = path
 	}
 
-	whisperURL := os.Getenv("LOCAL_WHISPER_URL")
+	// Voice notes go through ffmpeg normalization and Whisper with language hints; words below
+	// WHISPER_MIN_WORD_CONFIDENCE hold the transactions from that message for confirmation.
+	var voicePipeline *service.VoicePipeline
+	if whisperURL := os.Getenv("LOCAL_WHISPER_URL"); whisperURL != "" {
+		voiceCfg := service.DefaultVoiceConfig()
+		if langs := os.Getenv("WHISPER_LANGUAGES"); langs != "" {
+			voiceCfg.Languages = strings.Split(strings.ReplaceAll(langs, " ", ""), ",")
+		}
+		if minConf, err := strconv.ParseFloat(os.Getenv("WHISPER_MIN_WORD_CONFIDENCE"), 64); err == nil {
+			voiceCfg.MinWordConfidence = minConf
+		}
+		voiceCfg.FFmpeg = ffmpeg
+		voicePipeline = service.NewVoicePipeline(aiprovider.NewWhisperClient(whisperURL, os.Getenv("WHISPER_MODEL")), voiceCfg)
+		log.Info().Str("url", whisperURL).Strs("languages", voiceCfg.Languages).Str("ffmpeg", voiceCfg.FFmpeg).Msg("Voice transcription configured")
+	}
 
-	aiSvc := service.NewAIService(llmProvider, whisperURL)
+	// Spoken replies come from a Piper HTTP server and are sent back when the user's message was a
+	// voice note (or the web client asks for voice_reply).
+	var speechPipeline *service.SpeechPipeline
+	if ttsURL := os.Getenv("TTS_URL"); ttsURL != "" {
+		speechCfg := service.DefaultSpeechConfig()
+		speechCfg.FFmpeg = ffmpeg
+		if maxChars, err := strconv.Atoi(os.Getenv("TTS_MAX_CHARS")); err == nil && maxChars > 0 {
+			speechCfg.MaxChars = maxChars
+		}
+		speechPipeline = service.NewSpeechPipeline(aiprovider.NewPiperClient(ttsURL, os.Getenv("TTS_VOICE")), speechCfg)
+		log.Info().Str("url", ttsURL).Str("voice", os.Getenv("TTS_VOICE")).Msg("Voice replies configured")
+	}
+
+	var blobStore storage.BlobStore
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "s3":
+		s3Store, err := storage.NewS3Store(
+			os.Getenv("S3_ENDPOINT"), os.Getenv("S3_BUCKET"), os.Getenv("S3_REGION"),
+			os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"),
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid S3 storage configuration")
+		}
+		blobStore = s3Store
+		log.Info().Str("driver", "s3").Str("endpoint", os.Getenv("S3_ENDPOINT")).Str("bucket", os.Getenv("S3_BUCKET")).Msg("Storage Configured")
+	default:
+		localDir := os.Getenv("STORAGE_LOCAL_DIR")
+		if localDir == "" {
+			localDir = "./uploads"
+		}
+		blobStore = storage.NewLocalStore(localDir)
+		log.Info().Str("driver", "local").Str("dir", localDir).Msg("Storage Configured")
+	}
+
+	fileURLSecret := os.Getenv("FILE_URL_SECRET")
+	if fileURLSecret == "" {
+		fileURLSecret = os.Getenv("JWT_SECRET")
+	}
+	fileURLTTL, err := time.ParseDuration(os.Getenv("FILE_URL_TTL"))
+	if err != nil {
+		fileURLTTL = time.Hour
+	}
+	attachmentSvc := service.NewAttachmentService(blobStore, storage.NewURLSigner(fileURLSecret, fileURLTTL), repository.NewAttachmentRepository(db))
+	fileHandler := handler.NewFileHandler(attachmentSvc)
+
+	// One limiter for every LLM call: per-user token buckets for chat messages and a fair queue
+	// over AI_CONCURRENCY slots. Each request's tokens and latency are stored in ai_usages.
+	limiterCfg := service.DefaultAILimiterConfig()
+	limiterCfg.Concurrency = envInt("AI_CONCURRENCY", limiterCfg.Concurrency)
+	limiterCfg.Burst = envInt("AI_RATE_BURST", limiterCfg.Burst)
+	limiterCfg.Refill = envDuration("AI_RATE_REFILL", limiterCfg.Refill)
+	aiUsageSvc := service.NewAIUsageService(repository.NewAIUsageRepository(db))
+	aiSvc := service.NewAIServiceWithLimits(llmProvider, voicePipeline, speechPipeline, service.NewAILimiter(limiterCfg), aiUsageSvc)
+	aiUsageHandler := handler.NewAIUsageHandler(aiUsageSvc)
+	adminIDs := middleware.ParseAdminIDs(os.Getenv("ADMIN_USER_IDS"))
 
 	userRepo := repository.NewUserRepository(db)
 	userSvc := service.NewUserService(userRepo)
@@ -92,9 +230,9 @@ func main() {
 	savingGoalRepo := repository.NewSavingGoalRepository(db)
 
 	svc := service.NewTransactionService(repo, walletRepo, db)
-	h := handler.NewTransactionHandler(svc)
+	h := handler.NewTransactionHandler(svc, attachmentSvc)
 	
-	walletSvc := service.NewWalletService(walletRepo, savingGoalRepo)
+	walletSvc := service.NewWalletService(walletRepo, savingGoalRepo, db)
 	walletHandler := handler.NewWalletHandler(walletSvc)
 
 	categoryRepo := repository.NewCategoryRepository(db)
@@ -102,7 +240,7 @@ func main() {
 	categoryHandler := handler.NewCategoryHandler(categorySvc)
 
 	dashboardSvc := service.NewDashboardService(repo, walletRepo, savingGoalRepo, userRepo)
-	dashboardHandler := handler.NewDashboardHandler(dashboardSvc)
+	dashboardHandler := handler.NewDashboardHandler(dashboardSvc, attachmentSvc)
 
 	debtRepo := repository.NewDebtRepository(db)
 	debtSvc := service.NewDebtService(debtRepo, repo, walletRepo, db)
@@ -117,22 +255,95 @@ func main() {
 	financialHealthSvc := service.NewFinancialHealthService(repo, walletRepo, debtRepo, userRepo, savingGoalRepo)
 	financialHealthHandler := handler.NewFinancialHealthHandler(financialHealthSvc)
 
+	aiBatchRepo := repository.NewAIBatchRepository(db)
+	aiBatchSvc := service.NewAIBatchService(aiBatchRepo, db)
+	aiDraftSvc := service.NewAIDraftService(repository.NewAIDraftRepository(db))
+
 	chatbotSvc := service.NewChatbotService(
 		walletRepo, categoryRepo, svc,
 		repo, debtRepo, savingGoalRepo,
 		dashboardSvc, financialHealthSvc, userRepo,
+		aiBatchSvc, debtSvc, savingGoalSvc, wishlistSvc,
+		aiDraftSvc,
 	)
 
+	userAliasRepo := repository.NewUserAliasRepository(db)
+	userAliasHandler := handler.NewUserAliasHandler(service.NewUserAliasService(userAliasRepo, walletRepo, categoryRepo))
+	chatbotSvc = chatbotSvc.WithUserAliases(userAliasRepo)
+
+	// CHAT_PROMPT_VERSION pins the chat prompt template (e.g. chat-v1); empty uses the latest.
+	prompts, err := service.LoadPromptTemplates(os.Getenv("CHAT_PROMPT_VERSION"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid chat prompt version")
+	}
+	chatbotSvc = chatbotSvc.WithPromptTemplates(prompts)
+	log.Info().Str("version", prompts.Active()).Msg("Chat prompt configured")
+
+	// EMBEDDING_URL enables semantic search over transaction history for chat questions that
+	// reach beyond the recent transactions in the context.
+	var searchSvc service.TransactionSearchService
+	if embeddingURL := os.Getenv("EMBEDDING_URL"); embeddingURL != "" {
+		embedder := aiprovider.NewHTTPEmbedder(embeddingURL, os.Getenv("EMBEDDING_API_KEY"), os.Getenv("EMBEDDING_MODEL"))
+		minSimilarity, err := strconv.ParseFloat(os.Getenv("EMBEDDING_MIN_SIMILARITY"), 64)
+		if err != nil {
+			minSimilarity = 0.55
+		}
+		searchSvc = service.NewTransactionSearchService(repository.NewTransactionEmbeddingRepository(db), embedder, minSimilarity)
+		chatbotSvc = chatbotSvc.WithTransactionSearch(searchSvc)
+		log.Info().Str("url", embeddingURL).Str("model", embedder.Model()).Msg("Transaction search configured")
+	}
+
 	chatRepo := repository.NewChatRepository(db)
-	chatHistSvc := service.NewChatHistoryService(chatRepo)
+	chatHistSvc := service.NewChatHistoryService(chatRepo, aiSvc, attachmentSvc, userRepo)
 
-	aiHandler := handler.NewAIHandler(aiSvc, chatbotSvc, chatHistSvc)
+	aiHandler := handler.NewAIHandler(aiSvc, chatbotSvc, chatHistSvc, attachmentSvc)
 
 	waGatewayURL := os.Getenv("WA_GATEWAY_URL")
 	waWebhookSecret := os.Getenv("WHATSAPP_WEBHOOK_SECRET")
-	waSvc := service.NewWhatsAppService(userRepo, aiSvc, chatbotSvc, chatHistSvc, waGatewayURL)
+	waGateway := waprovider.NewGatewayClient(waGatewayURL, os.Getenv("WA_GATEWAY_USERNAME"), os.Getenv("WA_GATEWAY_PASSWORD"))
+	waSvc := service.NewWhatsAppService(userRepo, aiSvc, chatbotSvc, chatHistSvc, waGateway, attachmentSvc)
 	waHandler := handler.NewWhatsAppHandler(waSvc, waWebhookSecret)
 
+	var mailer mail.Mailer
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		mailer = mail.NewSMTPMailer(smtpHost, os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM"))
+	}
+
+	reportScheduleRepo := repository.NewReportScheduleRepository(db)
+	reportDigestSvc := service.NewReportDigestService(reportScheduleRepo, userRepo, debtRepo, dashboardSvc, svc, waGateway, mailer)
+	reportScheduleHandler := handler.NewReportScheduleHandler(reportDigestSvc)
+
+	insightRepo := repository.NewInsightRepository(db)
+	insightSvc := service.NewInsightService(insightRepo, userRepo, repo, savingGoalRepo, debtRepo, reportScheduleRepo, aiSvc, waGateway)
+	insightHandler := handler.NewInsightHandler(insightSvc)
+
+	auditRepo := repository.NewAuditRepository(db)
+	auditSvc := service.NewAuditService(auditRepo)
+	auditHandler := handler.NewAuditHandler(auditSvc)
+
+	schedulerCtx := audit.WithActor(context.Background(), audit.ActorScheduler)
+	scheduler.Every(schedulerCtx, "report-digest", time.Hour, reportDigestSvc.RunDue)
+	scheduler.Every(schedulerCtx, "cycle-insights", time.Hour, insightSvc.RunDue)
+	scheduler.Every(schedulerCtx, "chat-retention", 6*time.Hour, chatHistSvc.PruneExpired)
+	scheduler.Every(schedulerCtx, "attachment-gc", 6*time.Hour, func(time.Time) {
+		removed, err := attachmentSvc.CollectGarbage()
+		if err != nil {
+			log.Error().Err(err).Msg("Attachment GC failed")
+			return
+		}
+		log.Info().Int("removed", removed).Msg("Attachment GC finished")
+	})
+	if searchSvc != nil {
+		scheduler.Every(schedulerCtx, "transaction-embeddings", 10*time.Minute, func(time.Time) {
+			indexed, err := searchSvc.IndexPending(schedulerCtx, 0, 2000)
+			if err != nil {
+				log.Error().Err(err).Int("indexed", indexed).Msg("Transaction embedding failed")
+				return
+			}
+			log.Info().Int("indexed", indexed).Msg("Transaction embedding finished")
+		})
+	}
+
 	app := fiber.New(fiber.Config{
 		BodyLimit: 10 * 1024 * 1024, // 10MB
 	})
@@ -149,6 +360,7 @@ func main() {
 			Msg("Incoming Request")
 
 		ctx := context.WithValue(c.Context(), "request_id", reqID)
+		ctx = audit.WithActor(ctx, audit.ActorWeb)
 		c.SetUserContext(ctx)
 
 		start := time.Now()
@@ -185,7 +397,10 @@ func main() {
 
 	api := app.Group("/api")
 
-	app.Static("/uploads", "./uploads")
+	// Uploads are never served statically; legacy /uploads links go through the
+	// same ownership-checked handler as /api/files.
+	app.Get("/uploads/*", middleware.OptionalAuth(), fileHandler.ServeFile)
+	api.Get("/files/*", middleware.OptionalAuth(), fileHandler.ServeFile)
 
 	api.Post("/webhook", h.WebhookReceiver)
 	api.Post("/webhook/whatsapp", waHandler.HandleWebhook)
@@ -259,11 +474,38 @@ func main() {
 
 	api.Get("/financial-health", middleware.Protected(), financialHealthHandler.GetFinancialHealth)
 
+	api.Get("/audit", middleware.Protected(), auditHandler.GetAuditEvents)
+
+	reportSchedule := api.Group("/report-schedule", middleware.Protected())
+	reportSchedule.Get("/", reportScheduleHandler.GetSchedule)
+	reportSchedule.Put("/", reportScheduleHandler.UpdateSchedule)
+	reportSchedule.Get("/preview", reportScheduleHandler.PreviewDigest)
+	reportSchedule.Post("/send", reportScheduleHandler.SendDigest)
+
+	insights := api.Group("/insights", middleware.Protected())
+	insights.Get("/", insightHandler.GetFeed)
+	insights.Put("/read-all", insightHandler.MarkAllRead)
+	insights.Put("/:id/read", insightHandler.MarkRead)
+
+	admin := api.Group("/admin", middleware.Protected(), middleware.AdminOnly(adminIDs))
+	admin.Get("/ai-usage", aiUsageHandler.GetDailyUsage)
+	admin.Get("/ai/health", aiHandler.GetProviderHealth)
+
 	ai := api.Group("/ai", middleware.Protected())
 	ai.Post("/chat", aiHandler.ChatMessage)
 	ai.Post("/chat/stream", aiHandler.ChatMessageStream)
 	ai.Get("/chat/history", aiHandler.GetChatHistory)
 	ai.Delete("/chat/history", aiHandler.ClearChatHistory)
+	ai.Get("/chat/messages", aiHandler.GetChatMessages)
+	ai.Delete("/chat/messages/:id", aiHandler.DeleteChatMessage)
+	ai.Get("/chat/export", aiHandler.ExportChatHistory)
+	ai.Post("/undo", aiHandler.UndoLastAction)
+	ai.Get("/drafts", aiHandler.GetDrafts)
+	ai.Post("/drafts/:id/confirm", aiHandler.ConfirmDraft)
+	ai.Post("/drafts/:id/reject", aiHandler.RejectDraft)
+	ai.Get("/aliases", userAliasHandler.FindAll)
+	ai.Put("/aliases", userAliasHandler.Set)
+	ai.Delete("/aliases/:id", userAliasHandler.Delete)
 
 	app.Get("/swagger/*", swagger.New(swagger.Config{
 		PersistAuthorization: true,
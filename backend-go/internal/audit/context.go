@@ -0,0 +1,53 @@
+// Package audit records mutations of the financial tables as append-only audit events.
+//
+// Services that write those tables expose WithContext(ctx), which returns a copy bound to
+// db.WithContext(ctx); the GORM plugin then reads the actor and request_id set with WithActor
+// and WithRequestID from the statement context.
+package audit
+
+import "context"
+
+const (
+	ActorWeb       = "web"
+	ActorAI        = "ai"
+	ActorWhatsApp  = "whatsapp"
+	ActorScheduler = "scheduler"
+	ActorSystem    = "system"
+)
+
+type actorKey struct{}
+
+// requestIDKey matches the key used by the request-id middleware in cmd/api.
+const requestIDKey = "request_id"
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func ActorFrom(ctx context.Context) string {
+	if ctx != nil {
+		if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+			return actor
+		}
+	}
+	return ActorSystem
+}
+
+func RequestIDFrom(ctx context.Context) string {
+	if ctx != nil {
+		if reqID, ok := ctx.Value(requestIDKey).(string); ok {
+			return reqID
+		}
+	}
+	return ""
+}
+
+// Detach copies the audit values onto a fresh background context, for work that
+// outlives the HTTP request (goroutines, SSE stream writers).
+func Detach(ctx context.Context) context.Context {
+	return WithRequestID(WithActor(context.Background(), ActorFrom(ctx)), RequestIDFrom(ctx))
+}
@@ -0,0 +1,321 @@
+package audit
+
+import (
+	"cuan-backend/internal/entity"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultTables are the financial tables whose mutations are recorded.
+var DefaultTables = []string{
+	"wallets",
+	"transactions",
+	"debts",
+	"debt_payments",
+	"saving_goals",
+	"saving_contributions",
+}
+
+var ErrAppendOnly = errors.New("audit_events is append-only")
+
+const (
+	auditTable = "audit_events"
+	beforeKey  = "audit:before_rows"
+)
+
+type row = map[string]interface{}
+
+type plugin struct {
+	tables map[string]bool
+}
+
+// Register installs GORM callbacks that write an AuditEvent for every create,
+// update and delete on the given tables. Events are inserted through the same
+// connection as the mutation, so they commit or roll back together with it.
+func Register(db *gorm.DB, tables ...string) error {
+	if len(tables) == 0 {
+		tables = DefaultTables
+	}
+	p := &plugin{tables: map[string]bool{}}
+	for _, t := range tables {
+		p.tables[t] = true
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("audit:after_create", p.afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("audit:before_update", p.beforeUpdate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("audit:after_update", p.afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("audit:before_delete", p.beforeDelete); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("audit:after_delete", p.afterDelete)
+}
+
+func (p *plugin) audited(db *gorm.DB) bool {
+	return db.Error == nil && db.Statement.Schema != nil && p.tables[db.Statement.Table]
+}
+
+// session returns a handle that shares the statement's connection (and thus its
+// transaction) and context, without the statement's clauses.
+func session(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+}
+
+func (p *plugin) afterCreate(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	// Association upserts (ON CONFLICT) re-save existing children; the parent row's
+	// own update event already captures the change.
+	if _, ok := db.Statement.Clauses["ON CONFLICT"]; ok {
+		return
+	}
+
+	ids := primaryKeys(db)
+	if len(ids) == 0 {
+		return
+	}
+	after, err := loadByIDs(db, ids)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	p.write(db, "create", nil, after)
+}
+
+func (p *plugin) beforeUpdate(db *gorm.DB) {
+	if db.Error == nil && db.Statement.Table == auditTable {
+		db.AddError(ErrAppendOnly)
+		return
+	}
+	p.captureBefore(db)
+}
+
+func (p *plugin) beforeDelete(db *gorm.DB) {
+	if db.Error == nil && db.Statement.Table == auditTable {
+		db.AddError(ErrAppendOnly)
+		return
+	}
+	p.captureBefore(db)
+}
+
+func (p *plugin) captureBefore(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	query, ok := targetQuery(db)
+	if !ok {
+		return
+	}
+
+	var before []row
+	if err := query.Find(&before).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(beforeKey, before)
+}
+
+func (p *plugin) afterUpdate(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	before := beforeRows(db)
+	if len(before) == 0 {
+		return
+	}
+
+	ids := make([]interface{}, 0, len(before))
+	for _, r := range before {
+		ids = append(ids, r["id"])
+	}
+	after, err := loadByIDs(db, ids)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	p.write(db, "update", before, after)
+}
+
+func (p *plugin) afterDelete(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	if before := beforeRows(db); len(before) > 0 {
+		p.write(db, "delete", before, nil)
+	}
+}
+
+func (p *plugin) write(db *gorm.DB, action string, before, after []row) {
+	ctx := db.Statement.Context
+	actor, reqID := ActorFrom(ctx), RequestIDFrom(ctx)
+
+	afterByID := map[uint]row{}
+	for _, r := range after {
+		afterByID[toUint(r["id"])] = r
+	}
+
+	var events []entity.AuditEvent
+	add := func(b, a row) {
+		ref := a
+		if ref == nil {
+			ref = b
+		}
+		beforeJSON, afterJSON := marshalRow(b), marshalRow(a)
+		if action == "update" && beforeJSON == afterJSON {
+			return
+		}
+		events = append(events, entity.AuditEvent{
+			UserID:     p.ownerOf(db, ref),
+			Actor:      actor,
+			RequestID:  reqID,
+			Action:     action,
+			EntityType: db.Statement.Table,
+			EntityID:   toUint(ref["id"]),
+			Before:     beforeJSON,
+			After:      afterJSON,
+		})
+	}
+
+	if before == nil {
+		for _, a := range after {
+			add(nil, a)
+		}
+	} else {
+		for _, b := range before {
+			add(b, afterByID[toUint(b["id"])])
+		}
+	}
+
+	if len(events) == 0 {
+		return
+	}
+	if err := session(db).Create(&events).Error; err != nil {
+		db.AddError(fmt.Errorf("failed to write audit event: %w", err))
+	}
+}
+
+// ownerOf resolves the user of a row; child tables without user_id inherit it from their wallet.
+func (p *plugin) ownerOf(db *gorm.DB, r row) uint {
+	if id := toUint(r["user_id"]); id != 0 {
+		return id
+	}
+	walletID := toUint(r["wallet_id"])
+	if walletID == 0 {
+		return 0
+	}
+	var userID uint
+	session(db).Table("wallets").Select("user_id").Where("id = ?", walletID).Scan(&userID)
+	return userID
+}
+
+// targetQuery rebuilds the rows a pending update/delete will touch: the statement's
+// WHERE clause plus the primary key of the model value, if set.
+func targetQuery(db *gorm.DB) (*gorm.DB, bool) {
+	query := session(db).Table(db.Statement.Table)
+	hasCondition := false
+
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			query = query.Clauses(where)
+			hasCondition = true
+		}
+	}
+
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	if rv.Kind() == reflect.Struct {
+		for _, field := range db.Statement.Schema.PrimaryFields {
+			if value, zero := field.ValueOf(db.Statement.Context, rv); !zero {
+				query = query.Where(clause.Eq{Column: clause.Column{Table: db.Statement.Table, Name: field.DBName}, Value: value})
+				hasCondition = true
+			}
+		}
+	}
+	return query, hasCondition
+}
+
+func primaryKeys(db *gorm.DB) []interface{} {
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+
+	var ids []interface{}
+	collect := func(v reflect.Value) {
+		v = reflect.Indirect(v)
+		if v.Kind() != reflect.Struct {
+			return
+		}
+		if value, zero := field.ValueOf(db.Statement.Context, v); !zero {
+			ids = append(ids, value)
+		}
+	}
+
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			collect(rv.Index(i))
+		}
+	case reflect.Struct:
+		collect(rv)
+	}
+	return ids
+}
+
+func loadByIDs(db *gorm.DB, ids []interface{}) ([]row, error) {
+	var rows []row
+	err := session(db).Table(db.Statement.Table).Where("id IN ?", ids).Find(&rows).Error
+	return rows, err
+}
+
+func beforeRows(db *gorm.DB) []row {
+	v, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := v.([]row)
+	return rows
+}
+
+func marshalRow(r row) entity.JSONText {
+	if r == nil {
+		return ""
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+	return entity.JSONText(data)
+}
+
+func toUint(v interface{}) uint {
+	switch n := v.(type) {
+	case int:
+		return uint(n)
+	case int32:
+		return uint(n)
+	case int64:
+		return uint(n)
+	case uint:
+		return n
+	case uint32:
+		return uint(n)
+	case uint64:
+		return uint(n)
+	case float64:
+		return uint(n)
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"context"
+	"cuan-backend/internal/entity"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupAuditDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Wallet{}, &entity.DebtPayment{}, &entity.AuditEvent{}))
+	require.NoError(t, Register(db))
+	return db
+}
+
+func TestAudit_CreateUpdateDelete(t *testing.T) {
+	db := setupAuditDB(t)
+	ctx := WithRequestID(WithActor(context.Background(), ActorWhatsApp), "req-1")
+	tx := db.WithContext(ctx)
+
+	wallet := entity.Wallet{UserID: 7, Name: "BCA", Balance: 1000}
+	require.NoError(t, tx.Create(&wallet).Error)
+
+	wallet.Balance = 750
+	require.NoError(t, tx.Save(&wallet).Error)
+
+	require.NoError(t, tx.Where("id = ? AND user_id = ?", wallet.ID, 7).Delete(&entity.Wallet{}).Error)
+
+	var events []entity.AuditEvent
+	require.NoError(t, db.Order("id").Find(&events).Error)
+	require.Len(t, events, 3)
+
+	for _, e := range events {
+		assert.Equal(t, uint(7), e.UserID)
+		assert.Equal(t, ActorWhatsApp, e.Actor)
+		assert.Equal(t, "req-1", e.RequestID)
+		assert.Equal(t, "wallets", e.EntityType)
+		assert.Equal(t, wallet.ID, e.EntityID)
+	}
+
+	assert.Equal(t, "create", events[0].Action)
+	assert.Empty(t, events[0].Before)
+
+	assert.Equal(t, "update", events[1].Action)
+	var before, after map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(events[1].Before), &before))
+	require.NoError(t, json.Unmarshal([]byte(events[1].After), &after))
+	assert.EqualValues(t, 1000, before["balance"])
+	assert.EqualValues(t, 750, after["balance"])
+
+	assert.Equal(t, "delete", events[2].Action)
+	assert.Empty(t, events[2].After)
+}
+
+func TestAudit_RollbackDiscardsEvents(t *testing.T) {
+	db := setupAuditDB(t)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entity.Wallet{UserID: 1, Name: "Cash"}).Error; err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	assert.Error(t, err)
+
+	var count int64
+	db.Model(&entity.AuditEvent{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestAudit_ChildRowInheritsWalletOwner(t *testing.T) {
+	db := setupAuditDB(t)
+
+	wallet := entity.Wallet{UserID: 3, Name: "Cash"}
+	require.NoError(t, db.Create(&wallet).Error)
+	require.NoError(t, db.Omit("Debt", "Transaction", "Wallet").Create(&entity.DebtPayment{DebtID: 1, TransactionID: 1, WalletID: wallet.ID, Amount: 10}).Error)
+
+	var event entity.AuditEvent
+	require.NoError(t, db.Where("entity_type = ?", "debt_payments").First(&event).Error)
+	assert.Equal(t, uint(3), event.UserID)
+	assert.Equal(t, ActorSystem, event.Actor)
+}
+
+func TestAudit_AppendOnly(t *testing.T) {
+	db := setupAuditDB(t)
+	require.NoError(t, db.Create(&entity.Wallet{UserID: 1, Name: "Cash"}).Error)
+
+	err := db.Model(&entity.AuditEvent{}).Where("id = ?", 1).Update("actor", "web").Error
+	assert.ErrorIs(t, err, ErrAppendOnly)
+
+	err = db.Where("id = ?", 1).Delete(&entity.AuditEvent{}).Error
+	assert.ErrorIs(t, err, ErrAppendOnly)
+}
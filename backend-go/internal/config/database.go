@@ -47,6 +47,7 @@ func Connect() (*gorm.DB, error) {
 
 func MigrateFresh(db *gorm.DB) {
 	log.Info().Msg("🚧 Dropping all tables...")
+	db.Migrator().DropTable(&entity.TransactionEmbedding{})
 	db.Migrator().DropTable(&entity.SavingContribution{})
 	db.Migrator().DropTable(&entity.SavingGoal{})
 	db.Migrator().DropTable(&entity.WishlistItem{})
@@ -57,13 +58,32 @@ func MigrateFresh(db *gorm.DB) {
 	db.Migrator().DropTable(&entity.Wallet{})
 	db.Migrator().DropTable(&entity.User{})
 	db.Migrator().DropTable(&entity.ChatMessage{})
+	db.Migrator().DropTable(&entity.ChatSummary{})
+	db.Migrator().DropTable(&entity.ReportSchedule{})
+	db.Migrator().DropTable(&entity.AuditEvent{})
+	db.Migrator().DropTable(&entity.AIBatchItem{})
+	db.Migrator().DropTable(&entity.AIBatch{})
+	db.Migrator().DropTable(&entity.AIDraft{})
+	db.Migrator().DropTable(&entity.UserAlias{})
+	db.Migrator().DropTable(&entity.Insight{})
+	db.Migrator().DropTable(&entity.AIUsage{})
 
 	log.Info().Msg("✅ All tables dropped!")
 	log.Info().Msg("🆕 Re-running Auto Migration...")
-	db.AutoMigrate(&entity.Transaction{}, &entity.User{}, &entity.Wallet{}, &entity.Category{}, &entity.Debt{}, &entity.DebtPayment{}, &entity.WishlistItem{}, &entity.SavingGoal{}, &entity.SavingContribution{}, &entity.ChatMessage{})
+	db.AutoMigrate(&entity.Transaction{}, &entity.User{}, &entity.Wallet{}, &entity.Category{}, &entity.Debt{}, &entity.DebtPayment{}, &entity.WishlistItem{}, &entity.SavingGoal{}, &entity.SavingContribution{}, &entity.ChatMessage{}, &entity.ReportSchedule{}, &entity.AuditEvent{}, &entity.AIBatch{}, &entity.AIBatchItem{}, &entity.ChatSummary{}, &entity.AIDraft{}, &entity.TransactionEmbedding{}, &entity.UserAlias{}, &entity.Insight{}, &entity.AIUsage{})
+	createChatSearchIndex(db)
 }
 
 func RunMigration(db *gorm.DB) error {
 	log.Info().Msg("Running Auto Migration...")
-	return db.AutoMigrate(&entity.Transaction{}, &entity.User{}, &entity.Wallet{}, &entity.Category{}, &entity.Debt{}, &entity.DebtPayment{}, &entity.WishlistItem{}, &entity.SavingGoal{}, &entity.SavingContribution{}, &entity.ChatMessage{})
+	if err := db.AutoMigrate(&entity.Transaction{}, &entity.User{}, &entity.Wallet{}, &entity.Category{}, &entity.Debt{}, &entity.DebtPayment{}, &entity.WishlistItem{}, &entity.SavingGoal{}, &entity.SavingContribution{}, &entity.ChatMessage{}, &entity.ReportSchedule{}, &entity.AuditEvent{}, &entity.AIBatch{}, &entity.AIBatchItem{}, &entity.ChatSummary{}, &entity.AIDraft{}, &entity.TransactionEmbedding{}, &entity.UserAlias{}, &entity.Insight{}, &entity.AIUsage{}); err != nil {
+		return err
+	}
+	return createChatSearchIndex(db)
+}
+
+// createChatSearchIndex adds the GIN index behind full-text search over chat messages. The
+// expression must match the one in chatRepository.FindPage.
+func createChatSearchIndex(db *gorm.DB) error {
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_chat_messages_content_fts ON chat_messages USING GIN (to_tsvector('simple', content))").Error
 }
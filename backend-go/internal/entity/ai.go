@@ -1,9 +1,13 @@
 package entity
 
+import "encoding/json"
+
 type ChatAIResponse struct {
-	Reply         string              `json:"reply"`
-	IsTransaction bool                `json:"is_transaction"`
-	Transactions  []TransactionItemAI `json:"transactions"`
+	Reply      string `json:"reply"`
+	ToolRounds int    `json:"tool_rounds"` // jumlah ronde tool call sebelum jawaban akhir
+	FastPath   bool   `json:"fast_path"`   // dicatat parser deterministik tanpa memanggil LLM
+
+	Receipt *ReceiptExtraction `json:"receipt,omitempty"` // hasil pipeline struk jika gambar adalah struk
 }
 type TransactionItemAI struct {
 	Action       string  `json:"action"` // create, update, delete
@@ -13,13 +17,27 @@ type TransactionItemAI struct {
 	Description  string  `json:"description"`
 	CategoryName string  `json:"category_name"`
 	WalletName   string  `json:"wallet_name"`
+	Date         string  `json:"date,omitempty"`       // YYYY-MM-DD (WIB); kosong berarti hari ini / tanggal lama untuk update
+	Attachment   string  `json:"attachment,omitempty"` // key gambar struk yang dilampirkan ke transaksi baru
+
+	// Tool dan Arguments hanya diisi untuk item draft ber-Action DraftToolAction: aksi non-transaksi
+	// (utang, transfer, tabungan) yang ditahan dan dijalankan ulang saat draft dikonfirmasi.
+	Tool      string          `json:"tool,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
 }
 
+// DraftToolAction menandai item draft yang berisi pemanggilan tool non-transaksi.
+const DraftToolAction = "tool"
+
 type ChatResponse struct {
-	Reply        string             `json:"reply"`
-	Transactions []SavedTransaction `json:"transactions,omitempty"`
-	AudioURL     string             `json:"audio_url,omitempty"`
-	ImageURL     string             `json:"image_url,omitempty"`
+	Reply         string             `json:"reply"`
+	Transactions  []SavedTransaction `json:"transactions,omitempty"`
+	Draft         *AIDraft           `json:"draft,omitempty"` // transaksi yang menunggu konfirmasi user
+	Receipt       *ReceiptExtraction `json:"receipt,omitempty"`
+	Transcript    *VoiceTranscript   `json:"transcript,omitempty"` // hasil transkripsi jika pesan berupa suara
+	AudioURL      string             `json:"audio_url,omitempty"`
+	ImageURL      string             `json:"image_url,omitempty"`
+	ReplyAudioURL string             `json:"reply_audio_url,omitempty"` // balasan suara (OGG/Opus) jika TTS aktif
 }
 type SavedTransaction struct {
 	ID           uint    `json:"id"`
@@ -29,4 +47,5 @@ type SavedTransaction struct {
 	Type         string  `json:"type"`
 	CategoryName string  `json:"category_name"`
 	WalletName   string  `json:"wallet_name"`
-}
\ No newline at end of file
+	Date         string  `json:"date,omitempty"` // YYYY-MM-DD
+}
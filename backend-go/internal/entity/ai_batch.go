@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// AIBatch groups every transaction change made by a single AI response so it can be undone as a unit.
+type AIBatch struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	UserID    uint          `gorm:"not null;index" json:"user_id"`
+	Actor     string        `gorm:"type:varchar(20);not null" json:"actor"`
+	RequestID string        `gorm:"type:varchar(64)" json:"request_id"`
+	UndoneAt  *time.Time    `json:"undone_at"`
+	CreatedAt time.Time     `json:"created_at"`
+	Items     []AIBatchItem `gorm:"foreignKey:BatchID;constraint:OnDelete:CASCADE" json:"items"`
+}
+
+// AIBatchItem is one SavedTransaction of a batch. Before holds the transaction (and its
+// transfer pair, if any) as it was prior to an update or delete.
+//
+// Non-transaction actions (debt, debt_pay, saving, transfer, wishlist) are recorded as marker
+// items without a TransactionID so the batch reflects the newest AI action; they cannot be undone.
+type AIBatchItem struct {
+	ID            uint     `gorm:"primaryKey" json:"id"`
+	BatchID       uint     `gorm:"not null;index" json:"batch_id"`
+	TransactionID uint     `gorm:"not null" json:"transaction_id"`
+	Action        string   `gorm:"type:varchar(10);not null" json:"action"`
+	Description   string   `json:"description"`
+	Amount        float64  `json:"amount"`
+	Type          string   `json:"type"`
+	Before        JSONText `gorm:"type:jsonb" json:"before"`
+}
+
+// Undoable reports whether UndoLast knows how to revert this item.
+func (i AIBatchItem) Undoable() bool {
+	switch i.Action {
+	case "create", "update", "delete":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+const (
+	AIDraftPending   = "pending"
+	AIDraftConfirmed = "confirmed"
+	AIDraftRejected  = "rejected"
+)
+
+// AIDraft holds AI-detected transactions that need the user's confirmation before they are saved.
+// Items is the JSON array of TransactionItemAI as proposed (or as edited on confirmation).
+type AIDraft struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Status     string     `gorm:"type:varchar(20);not null;default:pending;index" json:"status"`
+	Reason     string     `gorm:"type:varchar(255)" json:"reason"`
+	Items      JSONText   `gorm:"type:jsonb;not null" json:"items"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
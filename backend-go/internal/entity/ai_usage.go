@@ -0,0 +1,52 @@
+package entity
+
+import "time"
+
+const (
+	AIChannelWeb        = "web"
+	AIChannelWhatsApp   = "whatsapp"
+	AIChannelBackground = "background" // ringkasan percakapan, insight siklus
+)
+
+const (
+	AIUsageOK          = "ok"
+	AIUsageError       = "error"
+	AIUsageBusy        = "busy"         // antrean LLM penuh sampai batas tunggu
+	AIUsageRateLimited = "rate_limited" // token bucket user habis, provider tidak dipanggil
+)
+
+// AIUsage mencatat satu request ke LLM: token prompt/completion (0 jika provider tidak
+// melaporkan), latensi, provider yang menjawab dan jenis input. Day adalah tanggal WIB
+// (YYYY-MM-DD) agar ringkasan harian tidak bergantung fungsi tanggal database.
+type AIUsage struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	UserID           uint      `gorm:"not null;index:idx_ai_usage_user_day" json:"user_id"` // 0 = tanpa user (eval, sistem)
+	Day              string    `gorm:"type:varchar(10);not null;index:idx_ai_usage_user_day;index" json:"day"`
+	Task             string    `gorm:"type:varchar(30);not null" json:"task"`
+	Channel          string    `gorm:"type:varchar(20)" json:"channel"`
+	Provider         string    `gorm:"type:varchar(50)" json:"provider"`
+	Status           string    `gorm:"type:varchar(20);not null" json:"status"`
+	PromptTokens     int       `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int       `gorm:"not null;default:0" json:"completion_tokens"`
+	LatencyMs        int64     `gorm:"not null;default:0" json:"latency_ms"` // termasuk waktu antre
+	QueueMs          int64     `gorm:"not null;default:0" json:"queue_ms"`
+	HasImage         bool      `gorm:"not null;default:false" json:"has_image"`
+	HasVoice         bool      `gorm:"not null;default:false" json:"has_voice"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// AIUsageDaily adalah ringkasan pemakaian AI satu user dalam satu hari (WIB).
+type AIUsageDaily struct {
+	Day              string  `json:"day"`
+	UserID           uint    `json:"user_id"`
+	UserName         string  `json:"user_name,omitempty"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+	ImageRequests    int64   `json:"image_requests"`
+	VoiceRequests    int64   `json:"voice_requests"`
+	Errors           int64   `json:"errors"`
+	Busy             int64   `json:"busy"`
+	RateLimited      int64   `json:"rate_limited"`
+}
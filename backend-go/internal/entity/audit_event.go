@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// JSONText is stored as jsonb and emitted verbatim (not as an escaped string) in API responses.
+type JSONText string
+
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// AuditEvent is an append-only record of a single row mutation on a financial table.
+type AuditEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index" json:"user_id"`
+	Actor      string    `gorm:"type:varchar(20);not null;index" json:"actor"`
+	RequestID  string    `gorm:"type:varchar(64);index" json:"request_id"`
+	Action     string    `gorm:"type:varchar(10);not null" json:"action"`
+	EntityType string    `gorm:"type:varchar(50);not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID   uint      `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Before     JSONText  `gorm:"type:jsonb" json:"before"`
+	After      JSONText  `gorm:"type:jsonb" json:"after"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+type AuditFilterParams struct {
+	Page       int
+	Limit      int
+	EntityType string
+	EntityID   uint
+	Actor      string
+	Action     string
+	RequestID  string
+	StartDate  string
+	EndDate    string
+}
@@ -3,11 +3,38 @@ package entity
 import "time"
 
 type ChatMessage struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID    uint      `gorm:"not null;index"           json:"user_id"`
-	Role      string    `gorm:"not null;type:varchar(20)" json:"role"` // "user" | "assistant"
-	Content   string    `gorm:"type:text"                json:"content"`
-	AudioURL  string    `gorm:"type:varchar(500)"        json:"audio_url,omitempty"`
-	ImageURL  string    `gorm:"type:varchar(500)"        json:"image_url,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        uint      `gorm:"not null;index"           json:"user_id"`
+	Role          string    `gorm:"not null;type:varchar(20)" json:"role"` // "user" | "assistant"
+	Content       string    `gorm:"type:text"                json:"content"`
+	AudioURL      string    `gorm:"type:varchar(500)"        json:"audio_url,omitempty"`
+	ImageURL      string    `gorm:"type:varchar(500)"        json:"image_url,omitempty"`
+	Transactions  JSONText  `gorm:"type:jsonb"               json:"transactions,omitempty"`   // []SavedTransaction dari balasan ini
+	PromptVersion string    `gorm:"type:varchar(30)"         json:"prompt_version,omitempty"` // versi template prompt chat yang menghasilkan balasan ini
+	Transcript    JSONText  `gorm:"type:jsonb"               json:"transcript,omitempty"`     // VoiceTranscript untuk pesan suara user
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ChatSummary adalah ringkasan bergulir dari giliran chat lama yang sudah keluar dari jendela percakapan.
+type ChatSummary struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	Content       string    `gorm:"type:text" json:"content"`
+	LastMessageID uint      `gorm:"not null" json:"last_message_id"` // pesan terakhir yang sudah masuk ringkasan
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ChatHistoryQuery memilih satu halaman riwayat chat. Before adalah cursor: ID pesan tertua dari
+// halaman sebelumnya (0 = mulai dari pesan terbaru). Search mencari di isi pesan.
+type ChatHistoryQuery struct {
+	Before uint
+	Limit  int
+	Search string
+}
+
+// ChatHistoryPage adalah satu halaman riwayat chat, urut lama → baru seperti tampilan chat.
+type ChatHistoryPage struct {
+	Messages   []ChatMessage `json:"messages"`
+	NextBefore uint          `json:"next_before,omitempty"` // cursor untuk halaman pesan yang lebih lama
+	HasMore    bool          `json:"has_more"`
 }
@@ -0,0 +1,54 @@
+package entity
+
+import "time"
+
+type InsightKind string
+
+const (
+	InsightCategoryIncrease InsightKind = "category_increase" // pengeluaran kategori naik dibanding siklus sebelumnya
+	InsightGoalPace         InsightKind = "goal_pace"         // laju setoran target menabung terhadap tenggatnya
+	InsightDebtDue          InsightKind = "debt_due"          // utang/piutang jatuh tempo di siklus berikutnya
+	InsightSavingsRate      InsightKind = "savings_rate"      // rasio tabungan siklus vs siklus sebelumnya
+)
+
+const (
+	InsightPositive = "positive"
+	InsightInfo     = "info"
+	InsightWarning  = "warning"
+)
+
+// InsightFact adalah fakta yang dihitung deterministik dari data user. LLM hanya merangkai
+// kalimatnya; Summary dipakai apa adanya jika LLM tidak tersedia.
+type InsightFact struct {
+	Kind     InsightKind `json:"kind"`
+	Subject  string      `json:"subject,omitempty"` // nama kategori/target/utang
+	Severity string      `json:"severity"`
+	Current  float64     `json:"current"`
+	Previous float64     `json:"previous"`
+	Target   float64     `json:"target,omitempty"`   // setoran per siklus yang dibutuhkan / target rasio
+	Change   float64     `json:"change,omitempty"`   // persen perubahan terhadap siklus sebelumnya
+	DueDate  string      `json:"due_date,omitempty"` // YYYY-MM-DD
+	Status   string      `json:"status,omitempty"`   // status FinancialHealth untuk rasio tabungan
+	Summary  string      `json:"summary"`
+}
+
+// Insight adalah satu item feed insight untuk siklus tagihan yang sudah selesai.
+type Insight struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	UserID      uint        `gorm:"not null;uniqueIndex:idx_insight_fact" json:"user_id"`
+	PeriodStart string      `gorm:"type:varchar(10);not null;uniqueIndex:idx_insight_fact" json:"period_start"` // YYYY-MM-DD
+	PeriodEnd   string      `gorm:"type:varchar(10);not null" json:"period_end"`
+	Kind        InsightKind `gorm:"type:varchar(30);not null;uniqueIndex:idx_insight_fact" json:"kind"`
+	Subject     string      `gorm:"type:varchar(100);uniqueIndex:idx_insight_fact" json:"subject"`
+	Severity    string      `gorm:"type:varchar(10);not null" json:"severity"`
+	Message     string      `gorm:"type:text;not null" json:"message"`
+	Fact        JSONText    `gorm:"type:jsonb" json:"fact"` // InsightFact sumber pesan
+	ReadAt      *time.Time  `gorm:"index" json:"read_at"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// InsightFeed adalah halaman feed insight beserta jumlah yang belum dibaca.
+type InsightFeed struct {
+	Insights []Insight `json:"insights"`
+	Unread   int64     `json:"unread"`
+}
@@ -0,0 +1,31 @@
+package entity
+
+// ReceiptExtraction adalah hasil pipeline struk: data yang dibaca model dari gambar yang sudah
+// diproses, ditambah hasil rekonsiliasi item terhadap total dan skor keyakinan.
+type ReceiptExtraction struct {
+	IsReceipt     bool          `json:"is_receipt"`
+	Merchant      string        `json:"merchant"`
+	Date          string        `json:"date"` // YYYY-MM-DD, kosong jika tidak terbaca
+	Items         []ReceiptItem `json:"items"`
+	Subtotal      float64       `json:"subtotal"`
+	Tax           float64       `json:"tax"`
+	ServiceCharge float64       `json:"service_charge"`
+	Discount      float64       `json:"discount"`
+	Total         float64       `json:"total"`
+	PaymentMethod string        `json:"payment_method"`
+
+	ItemsTotal  float64  `json:"items_total"`  // jumlah total per item
+	Difference  float64  `json:"difference"`   // total struk dikurangi hasil hitung ulang
+	Reconciled  bool     `json:"reconciled"`   // item + pajak - diskon cocok dengan total
+	TaxIncluded bool     `json:"tax_included"` // harga item sudah termasuk pajak
+	Confidence  float64  `json:"confidence"`   // 0..1
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+type ReceiptItem struct {
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Total     float64 `json:"total"`
+	Category  string  `json:"category"`
+}
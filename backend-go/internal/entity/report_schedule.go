@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// ReportSchedule menyimpan preferensi digest laporan berkala per user.
+type ReportSchedule struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	User         User       `gorm:"foreignKey:UserID" json:"-"`
+	Weekly       bool       `gorm:"default:false" json:"weekly"`    // dikirim setiap Senin
+	OnPayday     bool       `gorm:"default:false" json:"on_payday"` // dikirim saat tanggal gajian
+	ViaWhatsApp  bool       `gorm:"default:true" json:"via_whatsapp"`
+	ViaEmail     bool       `gorm:"default:false" json:"via_email"`
+	Email        string     `gorm:"type:varchar(100)" json:"email"`     // kosong = pakai email akun
+	PushInsights bool       `gorm:"default:false" json:"push_insights"` // insight tiap akhir siklus dikirim ke WhatsApp
+	LastSentAt   *time.Time `json:"last_sent_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// DigestDelivery adalah hasil pengiriman digest lewat satu channel.
+type DigestDelivery struct {
+	Channel string `json:"channel"` // whatsapp, email
+	Sent    bool   `json:"sent"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReportDigest adalah ringkasan keuangan yang dikirim lewat WhatsApp/email.
+type ReportDigest struct {
+	PeriodStart   string              `json:"period_start"`
+	PeriodEnd     string              `json:"period_end"`
+	TotalBalance  float64             `json:"total_balance"`
+	TotalIncome   float64             `json:"total_income"`
+	TotalExpense  float64             `json:"total_expense"`
+	TopCategories []CategoryBreakdown `json:"top_categories"`
+	BudgetStatus  []CategoryBreakdown `json:"budget_status"`
+	UpcomingDebts []Debt              `json:"upcoming_debts"`
+}
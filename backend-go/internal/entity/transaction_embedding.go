@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// TransactionEmbedding is the vector of one transaction's description and category name, used to
+// find past transactions similar to a chat question. Vector holds little-endian float32 values;
+// it is only comparable with vectors of the same Model. SourceUpdatedAt is the transaction's
+// UpdatedAt when it was embedded, so edited transactions are embedded again.
+type TransactionEmbedding struct {
+	TransactionID   uint        `gorm:"primaryKey;autoIncrement:false" json:"transaction_id"`
+	Transaction     Transaction `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE" json:"-"`
+	UserID          uint        `gorm:"not null;index" json:"user_id"`
+	Model           string      `gorm:"type:varchar(100);not null" json:"model"`
+	Vector          []byte      `gorm:"type:bytea;not null" json:"-"`
+	SourceUpdatedAt time.Time   `gorm:"not null" json:"source_updated_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+}
+
+// TransactionMatch is a transaction found by semantic search with its cosine similarity.
+type TransactionMatch struct {
+	Transaction Transaction `json:"transaction"`
+	Score       float64     `json:"score"`
+}
+
+// TransactionMatchMonth aggregates the matches of one calendar month ("2006-01").
+type TransactionMatchMonth struct {
+	Month   string  `json:"month"`
+	Count   int     `json:"count"`
+	Expense float64 `json:"expense"`
+	Income  float64 `json:"income"`
+}
+
+// TransactionSearchResult is what the chatbot gets for a question: the closest transactions and
+// monthly aggregates over every transaction similar enough to count.
+type TransactionSearchResult struct {
+	Matches []TransactionMatch      `json:"matches"`
+	Monthly []TransactionMatchMonth `json:"monthly"`
+	Total   int                     `json:"total"`
+}
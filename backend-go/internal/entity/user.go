@@ -5,14 +5,22 @@ import (
 )
 
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Name         string    `gorm:"type:varchar(100);not null" json:"name"`
-	Email        string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"`
-	GoogleID     *string   `gorm:"type:varchar(100);uniqueIndex" json:"google_id,omitempty"`
-	Phone        *string   `gorm:"type:varchar(20);uniqueIndex" json:"phone,omitempty"`
-	RefreshToken string    `gorm:"type:text" json:"-"`
-	Password     string    `gorm:"type:varchar(255)" json:"-"`
-	Payday       *int      `gorm:"default:1" json:"payday"` // Tanggal gajian, default hari ke-1
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint    `gorm:"primaryKey" json:"id"`
+	Name         string  `gorm:"type:varchar(100);not null" json:"name"`
+	Email        string  `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"`
+	GoogleID     *string `gorm:"type:varchar(100);uniqueIndex" json:"google_id,omitempty"`
+	Phone        *string `gorm:"type:varchar(20);uniqueIndex" json:"phone,omitempty"`
+	RefreshToken string  `gorm:"type:text" json:"-"`
+	Password     string  `gorm:"type:varchar(255)" json:"-"`
+	Payday       *int    `gorm:"default:1" json:"payday"` // Tanggal gajian, default hari ke-1
+	// AIAutoCommitLimit: transaksi AI di atas nominal ini disimpan sebagai draft dan menunggu konfirmasi.
+	// 0 = semua transaksi AI perlu konfirmasi.
+	AIAutoCommitLimit *float64 `gorm:"default:1000000" json:"ai_auto_commit_limit"`
+	// AIConfirmReceipts: transaksi hasil membaca gambar/struk selalu menunggu konfirmasi.
+	AIConfirmReceipts *bool `gorm:"default:true" json:"ai_confirm_receipts"`
+	// ChatRetentionDays: pesan chat (beserta audio/gambarnya) yang lebih tua dari ini dihapus
+	// otomatis. 0 = disimpan selamanya.
+	ChatRetentionDays *int      `gorm:"default:0" json:"chat_retention_days"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
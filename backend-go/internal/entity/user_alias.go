@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// UserAlias maps a name the user says ("jago") to one of their wallets or categories
+// ("Jago Utama"). Aliases are listed in the chat prompt so the model writes the real name.
+type UserAlias struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_alias" json:"user_id"`
+	Alias     string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_alias" json:"alias"`
+	Target    string    `gorm:"type:varchar(100);not null" json:"target"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
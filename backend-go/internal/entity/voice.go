@@ -0,0 +1,26 @@
+package entity
+
+// VoiceTranscript adalah hasil pipeline pesan suara: teks akhir (setelah koreksi), bahasa yang
+// terdeteksi, confidence per segmen, dan kata-kata yang kurang yakin untuk dikonfirmasi user.
+type VoiceTranscript struct {
+	Text          string         `json:"text"`
+	RawText       string         `json:"raw_text,omitempty"` // hasil Whisper sebelum koreksi, jika berbeda
+	Language      string         `json:"language,omitempty"` // kode ISO-639-1
+	Duration      float64        `json:"duration"`           // detik, setelah hening dipangkas
+	Normalized    bool           `json:"normalized"`         // audio sudah dinormalisasi ffmpeg
+	Confidence    float64        `json:"confidence"`         // rata-rata confidence segmen, 0..1
+	Segments      []VoiceSegment `json:"segments,omitempty"`
+	LowConfidence []string       `json:"low_confidence,omitempty"` // kata di bawah ambang confidence
+}
+
+type VoiceSegment struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+}
+
+// NeedsConfirmation true jika ada kata yang kurang yakin sehingga transaksi dari pesan ini perlu dikonfirmasi.
+func (t *VoiceTranscript) NeedsConfirmation() bool {
+	return t != nil && len(t.LowConfidence) > 0
+}
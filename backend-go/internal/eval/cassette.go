@@ -0,0 +1,65 @@
+package eval
+
+import (
+	aiprovider "cuan-backend/internal/provider/ai"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoRecording is returned for a case that has no exchanges in the cassette.
+var ErrNoRecording = errors.New("no recording for case")
+
+// Cassette holds the provider answers recorded for each case ID, so a corpus can be scored again
+// without a model server. Replayed answers ignore the request: after a prompt change, record again
+// to measure the new prompt.
+type Cassette map[string][]aiprovider.Exchange
+
+func LoadCassette(path string) (Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Replay returns a provider factory for Run that answers from the cassette.
+func (c Cassette) Replay() ProviderFactory {
+	return func(caseID string) (aiprovider.Provider, error) {
+		exchanges, ok := c[caseID]
+		if !ok {
+			return nil, ErrNoRecording
+		}
+		return aiprovider.NewReplayProvider(exchanges), nil
+	}
+}
+
+// Record returns a provider factory for Run that calls live, and a flush func that copies the
+// answers recorded for each case into c. A case that errors halfway keeps what was recorded.
+func (c Cassette) Record(live aiprovider.Provider) (ProviderFactory, func()) {
+	recorders := map[string]*aiprovider.RecordingProvider{}
+	factory := func(caseID string) (aiprovider.Provider, error) {
+		r := aiprovider.NewRecordingProvider(live)
+		recorders[caseID] = r
+		return r, nil
+	}
+	flush := func() {
+		for id, r := range recorders {
+			c[id] = r.Exchanges()
+		}
+	}
+	return factory, flush
+}
@@ -0,0 +1,91 @@
+// Package eval replays a corpus of user messages through the chat pipeline and scores the actions
+// the model extracts, so prompt and model changes can be compared offline.
+package eval
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Corpus is the evaluation set. Context stands in for the DATA KEUANGAN block that
+// ChatbotService.GetUserContext builds from the database; a case may override it. Wallets,
+// Categories and Aliases fill the matching chat prompt template variables.
+type Corpus struct {
+	Context    string            `yaml:"context"`
+	Wallets    []string          `yaml:"wallets"`
+	Categories []string          `yaml:"categories"`
+	Aliases    map[string]string `yaml:"aliases"` // alias -> wallet or category name
+	Cases      []Case            `yaml:"cases"`
+}
+
+// Case is one user message and the actions a correct model would take for it. An empty Expect
+// means the model should answer without calling any tool.
+type Case struct {
+	ID      string   `yaml:"id"`
+	Message string   `yaml:"message"`
+	Image   string   `yaml:"image"` // receipt fixture, relative to the corpus file
+	Context string   `yaml:"context"`
+	Expect  []Action `yaml:"expect"`
+
+	imageBase64 string
+}
+
+// Action is a tool call reduced to the fields that are scored. Receipt items extracted by the
+// receipt pipeline use Tool "receipt_item". Empty fields in an expectation are not scored.
+type Action struct {
+	Tool     string  `yaml:"tool" json:"tool"`
+	Type     string  `yaml:"type,omitempty" json:"type,omitempty"`
+	Amount   float64 `yaml:"amount,omitempty" json:"amount,omitempty"`
+	Category string  `yaml:"category,omitempty" json:"category,omitempty"`
+	Wallet   string  `yaml:"wallet,omitempty" json:"wallet,omitempty"`
+}
+
+// LoadCorpus reads a YAML corpus and the receipt fixtures it references.
+func LoadCorpus(path string) (*Corpus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Corpus
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(c.Cases) == 0 {
+		return nil, fmt.Errorf("%s: no cases", path)
+	}
+
+	seen := map[string]bool{}
+	for i := range c.Cases {
+		tc := &c.Cases[i]
+		if tc.ID == "" {
+			return nil, fmt.Errorf("%s: case %d has no id", path, i+1)
+		}
+		if seen[tc.ID] {
+			return nil, fmt.Errorf("%s: duplicate case id %q", path, tc.ID)
+		}
+		seen[tc.ID] = true
+		if tc.Message == "" && tc.Image == "" {
+			return nil, fmt.Errorf("%s: case %q needs a message or an image", path, tc.ID)
+		}
+		for _, a := range tc.Expect {
+			if a.Tool == "" {
+				return nil, fmt.Errorf("%s: case %q has an expected action without tool", path, tc.ID)
+			}
+		}
+		if tc.Image != "" {
+			img, err := os.ReadFile(filepath.Join(filepath.Dir(path), tc.Image))
+			if err != nil {
+				return nil, fmt.Errorf("%s: case %q: %w", path, tc.ID, err)
+			}
+			tc.imageBase64 = base64.StdEncoding.EncodeToString(img)
+		}
+		if tc.Context == "" {
+			tc.Context = c.Context
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,179 @@
+package eval
+
+import (
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/service"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCorpus = `
+context: "DATA KEUANGAN: Tunai, BCA, GoPay"
+cases:
+  - id: kopi
+    message: kopi 18rb pakai gopay
+    expect:
+      - {tool: create_transaction, type: expense, amount: 18000, category: Makan, wallet: GoPay}
+  - id: bakso
+    message: bakso 20rb
+    expect:
+      - {tool: create_transaction, amount: 20000, wallet: Tunai}
+  - id: saldo
+    message: berapa saldo saya?
+  - id: struk
+    message: struk tadi
+    image: struk.jpg
+    expect:
+      - {tool: receipt_item, amount: 7000, category: Makan, wallet: GoPay}
+  - id: belum-direkam
+    message: halo
+`
+
+func loadTestCorpus(t *testing.T) *Corpus {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "struk.jpg"), []byte("bukan gambar"), 0o600))
+	path := filepath.Join(dir, "corpus.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testCorpus), 0o600))
+	corpus, err := LoadCorpus(path)
+	require.NoError(t, err)
+	return corpus
+}
+
+func toolAnswer(calls ...string) aiprovider.Exchange {
+	resp := &aiprovider.AIResponse{}
+	for i, c := range calls {
+		name, args, _ := strings.Cut(c, " ")
+		resp.ToolCalls = append(resp.ToolCalls, aiprovider.ToolCall{ID: "call_" + string(rune('0'+i)), Name: name, Arguments: json.RawMessage(args)})
+	}
+	return aiprovider.Exchange{Response: resp}
+}
+
+func replyAnswer(content string) aiprovider.Exchange {
+	return aiprovider.Exchange{Response: &aiprovider.AIResponse{Content: content}}
+}
+
+func TestRun_ScoresReplayedAnswers(t *testing.T) {
+	corpus := loadTestCorpus(t)
+	cassette := Cassette{
+		"kopi": {
+			toolAnswer(`create_transaction {"type":"expense","amount":18000,"description":"Kopi","category_name":"Makan","wallet_name":"gopay"}`),
+			replyAnswer("Dicatat!"),
+		},
+		"bakso": {
+			// Nominal salah baca, lalu satu tool call dengan JSON rusak.
+			toolAnswer(`create_transaction {"type":"expense","amount":2000,"description":"Bakso","category_name":"Makan"}`, `create_transaction {"amount":`),
+			replyAnswer("Dicatat!"),
+		},
+		"saldo": {replyAnswer("Total saldo kamu Rp7.250.000")},
+		"struk": {toolAnswer(`submit_receipt {"is_receipt":true,"items":[{"name":"Indomie","quantity":2,"unit_price":3500,"total":7000,"category":"Makan"}],"total":7000,"payment_method":"GoPay"}`)},
+	}
+
+	report := Run(corpus, "replay", service.DefaultPromptTemplates(), cassette.Replay())
+	require.Len(t, report.Cases, 5)
+	assert.Equal(t, service.DefaultPromptTemplates().Active(), report.PromptVersion)
+
+	assert.Equal(t, Metric{Correct: 4, Total: 5}, report.Metrics[MetricAction])
+	assert.Equal(t, Metric{Correct: 2, Total: 3}, report.Metrics[MetricAmount])
+	assert.Equal(t, Metric{Correct: 2, Total: 2}, report.Metrics[MetricCategory])
+	assert.Equal(t, Metric{Correct: 3, Total: 3}, report.Metrics[MetricWallet])
+	assert.Equal(t, Metric{Correct: 2, Total: 3}, report.Metrics[MetricJSON])
+	assert.Equal(t, 3, report.Passed())
+
+	bakso := report.Cases[1]
+	assert.False(t, bakso.Passed)
+	assert.Contains(t, bakso.Mismatches, "create_transaction #1 amount: want 20000, got 2000")
+	assert.Equal(t, "Tunai", bakso.Actions[0].Wallet)
+
+	assert.True(t, report.Cases[3].Passed, report.Cases[3].Mismatches)
+	assert.Equal(t, ErrNoRecording.Error(), report.Cases[4].Error)
+
+	var out strings.Builder
+	report.WriteText(&out)
+	assert.Contains(t, out.String(), "amount       2/3    66.7%")
+	assert.Contains(t, out.String(), "FAIL bakso")
+}
+
+func TestCompare_ReportsRegressionsAndFixes(t *testing.T) {
+	baseline := &Report{
+		PromptHash: "lama",
+		Metrics:    map[string]Metric{MetricAction: {Correct: 1, Total: 2}},
+		Cases: []CaseResult{
+			{ID: "a", Passed: true, Actions: []Action{{Tool: "create_transaction", Amount: 15000}}},
+			{ID: "b", Passed: false},
+			{ID: "c", Passed: false, Actions: []Action{{Tool: "pay_debt", Amount: 1}}},
+			{ID: "hilang", Passed: true},
+		},
+	}
+	current := &Report{
+		PromptHash: PromptHash(service.DefaultPromptTemplates()),
+		Metrics:    map[string]Metric{MetricAction: {Correct: 2, Total: 3}},
+		Cases: []CaseResult{
+			{ID: "a", Passed: false, Actions: []Action{{Tool: "create_transaction", Amount: 1500}}, Mismatches: []string{"create_transaction #1 amount: want 15000, got 1500"}},
+			{ID: "b", Passed: true, Actions: []Action{{Tool: "delete_transaction"}}},
+			{ID: "c", Passed: false, Actions: []Action{{Tool: "pay_debt", Amount: 2}}},
+			{ID: "baru", Passed: true},
+		},
+	}
+
+	d := Compare(baseline, current)
+	assert.True(t, d.PromptChanged)
+	require.Len(t, d.Regressed, 1)
+	assert.Equal(t, "a", d.Regressed[0].ID)
+	require.Len(t, d.Fixed, 1)
+	assert.Equal(t, "b", d.Fixed[0].ID)
+	require.Len(t, d.Changed, 1)
+	assert.Equal(t, "c", d.Changed[0].ID)
+	assert.Equal(t, []string{"baru"}, d.Added)
+	assert.Equal(t, []string{"hilang"}, d.Removed)
+
+	var out strings.Builder
+	d.WriteText(&out)
+	assert.Contains(t, out.String(), "action    50.0% -> 66.7% (+16.7)")
+	assert.Contains(t, out.String(), "REGRESSED a\n  - create_transaction(15000)\n  + create_transaction(1500)\n")
+}
+
+func TestLoadCorpus_ShippedCorpus(t *testing.T) {
+	corpus, err := LoadCorpus("../../eval/corpus.yaml")
+	require.NoError(t, err)
+	assert.NotEmpty(t, corpus.Cases)
+	for _, c := range corpus.Cases {
+		assert.NotEmpty(t, c.Context, c.ID)
+	}
+	for alias, target := range corpus.Aliases {
+		assert.True(t, containsName(corpus.Wallets, target) || containsName(corpus.Categories, target), "alias %s -> %s", alias, target)
+	}
+}
+
+func TestLoadCorpus_Validation(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"no cases":     "context: x\n",
+		"duplicate id": "cases:\n  - {id: a, message: x}\n  - {id: a, message: y}\n",
+		"no tool":      "cases:\n  - {id: a, message: x, expect: [{amount: 1}]}\n",
+		"no image":     "cases:\n  - {id: a, image: hilang.jpg}\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			_, err := LoadCorpus(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
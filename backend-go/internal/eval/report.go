@@ -0,0 +1,235 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Report struct {
+	Mode          string            `json:"mode"`
+	PromptVersion string            `json:"prompt_version"`
+	PromptHash    string            `json:"prompt_hash"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+	Metrics       map[string]Metric `json:"metrics"`
+	Cases         []CaseResult      `json:"cases"`
+}
+
+type CaseResult struct {
+	ID         string   `json:"id"`
+	Passed     bool     `json:"passed"`
+	Error      string   `json:"error,omitempty"`
+	Reply      string   `json:"reply,omitempty"`
+	Actions    []Action `json:"actions"`
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// Passed counts the cases without any mismatch or error.
+func (r *Report) Passed() int {
+	n := 0
+	for _, c := range r.Cases {
+		if c.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
+
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// WriteText prints the metrics and the mismatches of every failed case.
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "prompt %s (%s), mode %s, %d cases, %d passed\n\n", r.PromptVersion, r.PromptHash, r.Mode, len(r.Cases), r.Passed())
+	for _, name := range Metrics {
+		m := r.Metrics[name]
+		fmt.Fprintf(w, "  %-9s %4d/%-4d %s\n", name, m.Correct, m.Total, percent(m.Accuracy()))
+	}
+	for _, c := range r.Cases {
+		if c.Passed {
+			continue
+		}
+		fmt.Fprintf(w, "\nFAIL %s\n", c.ID)
+		if c.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", c.Error)
+		}
+		for _, m := range c.Mismatches {
+			fmt.Fprintf(w, "  - %s\n", m)
+		}
+	}
+}
+
+// MetricDelta is the change of one metric between two reports.
+type MetricDelta struct {
+	Name          string
+	Before, After Metric
+	Change        float64 // accuracy points, NaN if either side scored nothing
+}
+
+// CaseChange is a case whose outcome or actions differ between two reports.
+type CaseChange struct {
+	ID            string
+	Before, After *CaseResult
+}
+
+// Diff compares a report against a baseline, typically produced before a prompt change.
+type Diff struct {
+	PromptChanged   bool
+	BaselineVersion string
+	Version         string
+	Metrics         []MetricDelta
+	Regressed       []CaseChange
+	Fixed           []CaseChange
+	Changed         []CaseChange // still passing or still failing, but with different actions
+	Added           []string
+	Removed         []string
+}
+
+func Compare(baseline, current *Report) *Diff {
+	d := &Diff{
+		PromptChanged:   baseline.PromptHash != current.PromptHash,
+		BaselineVersion: baseline.PromptVersion,
+		Version:         current.PromptVersion,
+	}
+	for _, name := range Metrics {
+		before, after := baseline.Metrics[name], current.Metrics[name]
+		d.Metrics = append(d.Metrics, MetricDelta{
+			Name:   name,
+			Before: before,
+			After:  after,
+			Change: after.Accuracy() - before.Accuracy(),
+		})
+	}
+
+	old := map[string]*CaseResult{}
+	for i := range baseline.Cases {
+		old[baseline.Cases[i].ID] = &baseline.Cases[i]
+	}
+	for i := range current.Cases {
+		after := &current.Cases[i]
+		before, ok := old[after.ID]
+		if !ok {
+			d.Added = append(d.Added, after.ID)
+			continue
+		}
+		delete(old, after.ID)
+		change := CaseChange{ID: after.ID, Before: before, After: after}
+		switch {
+		case before.Passed && !after.Passed:
+			d.Regressed = append(d.Regressed, change)
+		case !before.Passed && after.Passed:
+			d.Fixed = append(d.Fixed, change)
+		case !sameActions(before.Actions, after.Actions):
+			d.Changed = append(d.Changed, change)
+		}
+	}
+	for id := range old {
+		d.Removed = append(d.Removed, id)
+	}
+	sort.Strings(d.Removed)
+	return d
+}
+
+func (d *Diff) WriteText(w io.Writer) {
+	if d.PromptChanged {
+		fmt.Fprintf(w, "prompt or tool definitions changed since the baseline (%s -> %s)\n", orDash(d.BaselineVersion), orDash(d.Version))
+	}
+	for _, m := range d.Metrics {
+		fmt.Fprintf(w, "  %-9s %s -> %s (%s)\n", m.Name, percent(m.Before.Accuracy()), percent(m.After.Accuracy()), signedPercent(m.Change))
+	}
+	writeChanges(w, "REGRESSED", d.Regressed)
+	writeChanges(w, "FIXED", d.Fixed)
+	writeChanges(w, "CHANGED", d.Changed)
+	if len(d.Added) > 0 {
+		fmt.Fprintf(w, "\nnew cases: %s\n", strings.Join(d.Added, ", "))
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintf(w, "\nremoved cases: %s\n", strings.Join(d.Removed, ", "))
+	}
+}
+
+func writeChanges(w io.Writer, label string, changes []CaseChange) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "\n%s %s\n", label, c.ID)
+		fmt.Fprintf(w, "  - %s\n", formatActions(c.Before.Actions))
+		fmt.Fprintf(w, "  + %s\n", formatActions(c.After.Actions))
+		for _, m := range c.After.Mismatches {
+			fmt.Fprintf(w, "    %s\n", m)
+		}
+		if c.After.Error != "" {
+			fmt.Fprintf(w, "    error: %s\n", c.After.Error)
+		}
+	}
+}
+
+func sameActions(a, b []Action) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func formatActions(actions []Action) string {
+	if len(actions) == 0 {
+		return "(no tool call)"
+	}
+	parts := make([]string, len(actions))
+	for i, a := range actions {
+		var fields []string
+		if a.Type != "" {
+			fields = append(fields, a.Type)
+		}
+		if a.Amount != 0 {
+			fields = append(fields, fmt.Sprintf("%.0f", a.Amount))
+		}
+		if a.Category != "" {
+			fields = append(fields, a.Category)
+		}
+		if a.Wallet != "" {
+			fields = append(fields, a.Wallet)
+		}
+		parts[i] = a.Tool + "(" + strings.Join(fields, ", ") + ")"
+	}
+	return strings.Join(parts, " ")
+}
+
+func percent(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", v*100)
+}
+
+func signedPercent(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f", v*100)
+}
@@ -0,0 +1,175 @@
+package eval
+
+import (
+	"crypto/sha256"
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/service"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProviderFactory returns the provider that answers one case. Each case gets its own provider so
+// replayed exchanges stay in call order.
+type ProviderFactory func(caseID string) (aiprovider.Provider, error)
+
+// Run sends every case through AIService.Chat with a recorder in place of the tool executor, so
+// nothing touches a database, and scores the recorded actions against the expectations. The
+// system prompt is rendered from the active version of prompts.
+func Run(corpus *Corpus, mode string, prompts *service.PromptTemplates, providerFor ProviderFactory) *Report {
+	report := &Report{
+		Mode:          mode,
+		PromptVersion: prompts.Active(),
+		PromptHash:    PromptHash(prompts),
+		GeneratedAt:   time.Now(),
+		Metrics:       map[string]Metric{},
+	}
+	data := service.ChatPromptData{Wallets: corpus.Wallets, Categories: corpus.Categories}
+	for alias, target := range corpus.Aliases {
+		data.Aliases = append(data.Aliases, entity.UserAlias{Alias: alias, Target: target})
+	}
+	sort.Slice(data.Aliases, func(i, j int) bool { return data.Aliases[i].Alias < data.Aliases[j].Alias })
+
+	for _, tc := range corpus.Cases {
+		data.Context = tc.Context
+		prompt, err := prompts.RenderChat(data)
+		var result CaseResult
+		var metrics map[string]Metric
+		if err != nil {
+			result, metrics = failCase(tc, err)
+		} else {
+			result, metrics = runCase(tc, prompt, providerFor)
+		}
+		report.Cases = append(report.Cases, result)
+		for name, m := range metrics {
+			total := report.Metrics[name]
+			total.Correct += m.Correct
+			total.Total += m.Total
+			report.Metrics[name] = total
+		}
+	}
+	return report
+}
+
+func failCase(tc Case, err error) (CaseResult, map[string]Metric) {
+	metrics, mismatches := score(tc.Expect, nil)
+	metrics[MetricAction] = Metric{Total: 1}
+	return CaseResult{ID: tc.ID, Error: err.Error(), Mismatches: mismatches}, metrics
+}
+
+func runCase(tc Case, prompt service.ChatPrompt, providerFor ProviderFactory) (CaseResult, map[string]Metric) {
+	result := CaseResult{ID: tc.ID}
+	rec := &recorder{}
+	provider, err := providerFor(tc.ID)
+	if err == nil {
+		var resp *entity.ChatAIResponse
+		resp, err = service.NewAIService(provider, "").Chat(tc.Message, tc.imageBase64, prompt, service.Conversation{}, rec)
+		if err == nil {
+			result.Reply = resp.Reply
+		}
+	}
+	result.Actions = rec.actions
+
+	metrics, mismatches := score(tc.Expect, rec.actions)
+	metrics[MetricJSON] = Metric{Correct: rec.validCalls, Total: rec.calls}
+	result.Mismatches = mismatches
+	if err != nil {
+		// A case that did not finish never counts as the right action, even when nothing was expected.
+		result.Error = err.Error()
+		metrics[MetricAction] = Metric{Total: 1}
+	}
+	result.Passed = result.Error == "" && len(mismatches) == 0 && rec.validCalls == rec.calls
+	return result, metrics
+}
+
+// PromptHash identifies the chat prompt template and tool definitions a report was produced with.
+func PromptHash(prompts *service.PromptTemplates) string {
+	h := sha256.New()
+	h.Write([]byte(prompts.Source()))
+	tools, _ := json.Marshal(service.ChatTools())
+	h.Write(tools)
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// recorder stands in for AIToolSession: every tool call succeeds and is kept as an Action, and a
+// receipt read by the receipt pipeline becomes one "receipt_item" action per item.
+type recorder struct {
+	actions    []Action
+	calls      int
+	validCalls int
+}
+
+func (r *recorder) Tools() []aiprovider.Tool {
+	return service.ChatTools()
+}
+
+func (r *recorder) Execute(call aiprovider.ToolCall) string {
+	r.calls++
+	var args map[string]interface{}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil || args == nil {
+		return `{"ok":false,"error":"argumen harus berupa JSON object"}`
+	}
+	r.validCalls++
+	r.actions = append(r.actions, actionFromArgs(call.Name, args))
+	return `{"ok":true,"result":{}}`
+}
+
+func (r *recorder) CaptureReceipt(receipt *entity.ReceiptExtraction, message string) (string, bool) {
+	if len(receipt.Items) == 0 {
+		return "", false
+	}
+	for _, item := range receipt.Items {
+		r.actions = append(r.actions, Action{
+			Tool:     "receipt_item",
+			Type:     "expense",
+			Amount:   item.Total,
+			Category: item.Category,
+			Wallet:   receipt.PaymentMethod,
+		})
+	}
+	return "Struk dicatat", true
+}
+
+func actionFromArgs(tool string, args map[string]interface{}) Action {
+	a := Action{
+		Tool:     tool,
+		Type:     stringArg(args, "type"),
+		Amount:   numberArg(args, "amount"),
+		Category: stringArg(args, "category_name"),
+		Wallet:   stringArg(args, "wallet_name"),
+	}
+	switch tool {
+	case "create_transaction":
+		// The tool records an empty wallet_name to Tunai.
+		if a.Wallet == "" {
+			a.Wallet = "Tunai"
+		}
+	case "transfer_between_wallets":
+		a.Wallet = stringArg(args, "from_wallet")
+	case "add_wishlist_item":
+		a.Amount = numberArg(args, "estimated_price")
+	}
+	return a
+}
+
+func stringArg(args map[string]interface{}, key string) string {
+	s, _ := args[key].(string)
+	return strings.TrimSpace(s)
+}
+
+// numberArg also accepts numbers sent as strings; the amount is still scored, the schema
+// violation is the model's to fix.
+func numberArg(args map[string]interface{}, key string) float64 {
+	switch v := args[key].(type) {
+	case float64:
+		return v
+	case string:
+		n, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n
+	}
+	return 0
+}
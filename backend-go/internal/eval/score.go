@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	MetricAction   = "action"
+	MetricAmount   = "amount"
+	MetricCategory = "category"
+	MetricWallet   = "wallet"
+	MetricJSON     = "json"
+)
+
+// Metrics lists the metrics in report order.
+var Metrics = []string{MetricAction, MetricAmount, MetricCategory, MetricWallet, MetricJSON}
+
+type Metric struct {
+	Correct int `json:"correct"`
+	Total   int `json:"total"`
+}
+
+// Accuracy is Correct/Total, or NaN when nothing was scored.
+func (m Metric) Accuracy() float64 {
+	if m.Total == 0 {
+		return math.NaN()
+	}
+	return float64(m.Correct) / float64(m.Total)
+}
+
+// score compares the actions of one case. The action metric counts one point per case: the model
+// called exactly the expected tools, with the expected transaction types. Amount, category and
+// wallet count one point per expected action that sets them; each expected action is paired with
+// the first unused actual action of the same tool, in order.
+func score(expect, actual []Action) (map[string]Metric, []string) {
+	metrics := map[string]Metric{}
+	var mismatches []string
+	field := func(name string, ok bool, msg string) {
+		m := metrics[name]
+		m.Total++
+		if ok {
+			m.Correct++
+		} else {
+			mismatches = append(mismatches, msg)
+		}
+		metrics[name] = m
+	}
+
+	actionOK := sameTools(expect, actual)
+	if !actionOK {
+		mismatches = append(mismatches, fmt.Sprintf("actions: want [%s], got [%s]", toolList(expect), toolList(actual)))
+	}
+
+	used := make([]bool, len(actual))
+	for i, want := range expect {
+		label := fmt.Sprintf("%s #%d", want.Tool, i+1)
+		var got *Action
+		for j := range actual {
+			if !used[j] && actual[j].Tool == want.Tool {
+				used[j] = true
+				got = &actual[j]
+				break
+			}
+		}
+		if got == nil {
+			got = &Action{}
+		} else if want.Type != "" && !strings.EqualFold(want.Type, got.Type) {
+			actionOK = false
+			mismatches = append(mismatches, fmt.Sprintf("%s type: want %s, got %s", label, want.Type, orDash(got.Type)))
+		}
+
+		if want.Amount != 0 {
+			field(MetricAmount, math.Abs(want.Amount-got.Amount) < 0.5,
+				fmt.Sprintf("%s amount: want %.0f, got %.0f", label, want.Amount, got.Amount))
+		}
+		if want.Category != "" {
+			field(MetricCategory, strings.EqualFold(want.Category, got.Category),
+				fmt.Sprintf("%s category: want %s, got %s", label, want.Category, orDash(got.Category)))
+		}
+		if want.Wallet != "" {
+			field(MetricWallet, strings.EqualFold(want.Wallet, got.Wallet),
+				fmt.Sprintf("%s wallet: want %s, got %s", label, want.Wallet, orDash(got.Wallet)))
+		}
+	}
+
+	m := Metric{Total: 1}
+	if actionOK {
+		m.Correct = 1
+	}
+	metrics[MetricAction] = m
+	return metrics, mismatches
+}
+
+func sameTools(expect, actual []Action) bool {
+	if len(expect) != len(actual) {
+		return false
+	}
+	a, b := toolNames(expect), toolNames(actual)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func toolNames(actions []Action) []string {
+	names := make([]string, len(actions))
+	for i, a := range actions {
+		names[i] = a.Tool
+	}
+	sort.Strings(names)
+	return names
+}
+
+func toolList(actions []Action) string {
+	names := make([]string, len(actions))
+	for i, a := range actions {
+		names[i] = a.Tool
+	}
+	return strings.Join(names, " ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
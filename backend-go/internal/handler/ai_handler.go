@@ -2,17 +2,22 @@ package handler
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"cuan-backend/internal/audit"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/service"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -29,19 +34,29 @@ type AIHandler interface {
 	ChatMessageStream(c *fiber.Ctx) error
 	GetChatHistory(c *fiber.Ctx) error
 	ClearChatHistory(c *fiber.Ctx) error
+	GetChatMessages(c *fiber.Ctx) error
+	DeleteChatMessage(c *fiber.Ctx) error
+	ExportChatHistory(c *fiber.Ctx) error
+	UndoLastAction(c *fiber.Ctx) error
+	GetDrafts(c *fiber.Ctx) error
+	ConfirmDraft(c *fiber.Ctx) error
+	RejectDraft(c *fiber.Ctx) error
+	GetProviderHealth(c *fiber.Ctx) error
 }
 
 type aiHandler struct {
 	aiService      service.AIService
 	chatbotService *service.ChatbotService
 	chatHistorySvc service.ChatHistoryService
+	attachments    service.AttachmentService
 }
 
-func NewAIHandler(aiService service.AIService, chatbotService *service.ChatbotService, chatHistorySvc service.ChatHistoryService) AIHandler {
+func NewAIHandler(aiService service.AIService, chatbotService *service.ChatbotService, chatHistorySvc service.ChatHistoryService, attachments service.AttachmentService) AIHandler {
 	return &aiHandler{
 		aiService:      aiService,
 		chatbotService: chatbotService,
 		chatHistorySvc: chatHistorySvc,
+		attachments:    attachments,
 	}
 }
 
@@ -55,14 +70,17 @@ func NewAIHandler(aiService service.AIService, chatbotService *service.ChatbotSe
 // @Param message formData string false "Text message"
 // @Param image formData file false "Image attachment"
 // @Param voice formData file false "Voice attachment"
+// @Param voice_reply formData bool false "Also reply with synthesized speech (always on for voice messages)"
 // @Success 200 {object} entity.ChatResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 401 {object} map[string]interface{}
 // @Failure 413 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/ai/chat [post]
 func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 	message := c.FormValue("message")
+	voiceReply := c.FormValue("voice_reply") == "true"
 	var imageBase64 string
 
 	userID, ok := c.Locals("userID").(uint)
@@ -74,15 +92,24 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 
 	var audioURL string
 	var savedImageURL string
+	var transcript *entity.VoiceTranscript
 
 	voiceFile, err := c.FormFile("voice")
-	if err == nil && voiceFile != nil {
+	hasVoice := err == nil && voiceFile != nil
+	scope := service.AIScope{UserID: userID, Channel: entity.AIChannelWeb, Voice: hasVoice}
+	// Cek batas sebelum transkripsi dan penyimpanan history: pesan yang ditolak tidak boleh
+	// memakai waktu Whisper atau meninggalkan giliran user tanpa jawaban.
+	if err := h.aiService.WithScope(scope).CheckRateLimit(); err != nil {
+		return rateLimitResponse(c, err)
+	}
+
+	if hasVoice {
 		if voiceFile.Size > MaxAudioSize {
 			return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{
 				"error": fmt.Sprintf("Ukuran audio maksimal %dMB", MaxAudioSize>>20),
 			})
 		}
-		savedPath, err := processAndSaveFile(voiceFile)
+		stored, err := h.attachments.SaveUpload(userID, voiceFile)
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Failed to save audio")
@@ -90,10 +117,12 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 				"error": "Gagal menyimpan audio: " + err.Error(),
 			})
 		}
-		audioURL = savedPath
+		audioURL = stored.Key
 
-		diskPath := "." + savedPath
-		transcription, err := h.aiService.ProcessVoice(diskPath)
+		transcript, err = h.transcribe(userID, stored)
+		if errors.Is(err, service.ErrNoSpeech) {
+			return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Failed to process audio")
@@ -101,11 +130,7 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 				"error": "Gagal memproses audio: " + err.Error(),
 			})
 		}
-		if message == "" {
-			message = "[TRANSKRIPSI SUARA - mungkin ada kesalahan fonetik, tolong koreksi]: " + transcription
-		} else {
-			message = message + "\n\n[TRANSKRIPSI SUARA - mungkin ada kesalahan fonetik, tolong koreksi]: " + transcription
-		}
+		message = service.AppendTranscript(message, transcript)
 	}
 
 	imageFile, err := c.FormFile("image")
@@ -115,7 +140,7 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 				"error": fmt.Sprintf("Ukuran gambar maksimal %dMB", MaxImageSize>>20),
 			})
 		}
-		savedPath, err := processAndSaveFile(imageFile)
+		stored, err := h.attachments.SaveUpload(userID, imageFile)
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Failed to save image")
@@ -123,18 +148,8 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 				"error": "Gagal menyimpan gambar: " + err.Error(),
 			})
 		}
-		savedImageURL = savedPath
-
-		diskPath := "." + savedPath
-		b64, err := readFileAsBase64(diskPath)
-		if err != nil {
-			reqID, _ := c.Locals("requestid").(string)
-			log.Error().Str("request_id", reqID).Err(err).Msg("Failed to process image")
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Gagal memproses gambar: " + err.Error(),
-			})
-		}
-		imageBase64 = b64
+		savedImageURL = stored.Key
+		imageBase64 = base64.StdEncoding.EncodeToString(stored.Data)
 
 		if message == "" {
 			message = "Tolong analisis gambar ini. Jika ini struk belanja, identifikasi item dan harganya."
@@ -149,12 +164,28 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 
 	// Simpan pesan user ke history
 	userContent := message
-	if err := h.chatHistorySvc.SaveMessage(userID, "user", userContent, audioURL, savedImageURL); err != nil {
+	if err := h.chatHistorySvc.SaveMessage(userID, "user", userContent, audioURL, savedImageURL, transcript); err != nil {
 		log.Warn().Err(err).Msg("Gagal menyimpan pesan user ke history")
 	}
 
-	userContext := h.chatbotService.GetUserContext(userID, message)
-	aiResponse, err := h.aiService.Chat(message, imageBase64, userContext)
+	prompt := h.chatbotService.ChatPrompt(userID, message)
+	conv := h.chatHistorySvc.BuildConversation(userID, message)
+	ctx := audit.WithActor(c.UserContext(), audit.ActorAI)
+	tools := h.chatbotService.WithContext(ctx).NewToolSession(userID, message, imageBase64 != "").AttachImage(savedImageURL)
+	if transcript.NeedsConfirmation() {
+		tools.RequireConfirmation(service.VoiceConfirmationReason(transcript))
+	}
+	aiResponse, err := h.aiService.WithScope(scope).Chat(message, imageBase64, prompt, conv, tools)
+	saved := tools.Finish()
+	if err != nil && tools.Committed() {
+		// Transaksi sudah tersimpan: kirim ringkasannya bersama pesan error, bukan 500.
+		reqID, _ := c.Locals("requestid").(string)
+		log.Warn().Str("request_id", reqID).Err(err).Int("saved", len(saved)).Msg("AI Chat failed after tool calls")
+		aiResponse, err = &entity.ChatAIResponse{Reply: service.InterruptedReply(err)}, nil
+	}
+	if errors.Is(err, service.ErrAIRateLimited) {
+		return rateLimitResponse(c, err)
+	}
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("AI Chat failed")
@@ -164,56 +195,28 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 	}
 
 	response := entity.ChatResponse{
-		Reply:    aiResponse.Reply,
-		AudioURL: audioURL,
-		ImageURL: savedImageURL,
+		Reply:      aiResponse.Reply,
+		AudioURL:   h.attachments.SignedURL(userID, audioURL),
+		ImageURL:   h.attachments.SignedURL(userID, savedImageURL),
+		Receipt:    aiResponse.Receipt,
+		Transcript: transcript,
 	}
 
-	if aiResponse.IsTransaction && len(aiResponse.Transactions) > 0 {
-		saved, err := h.chatbotService.SaveTransactions(userID, aiResponse.Transactions)
-		if err != nil {
-			reqID, _ := c.Locals("requestid").(string)
-			log.Error().Str("request_id", reqID).Err(err).Msg("SaveTransactions failed")
-			response.Reply += "\n\n⚠️ Transaksi terdeteksi tapi gagal diproses: " + err.Error()
-		} else if len(saved) > 0 {
-			response.Transactions = saved
-			summary := "\n\n"
-
-			hasCreate, hasUpdate, hasDelete := false, false, false
-			for _, s := range saved {
-				switch s.Action {
-				case "update":
-					hasUpdate = true
-				case "delete":
-					hasDelete = true
-				default:
-					hasCreate = true
-				}
-			}
-
-			if hasCreate {
-				summary += "✅ Transaksi berhasil dicatat!"
-			} else if hasUpdate {
-				summary += "✅ Transaksi berhasil diperbarui!"
-			} else if hasDelete {
-				summary += "✅ Transaksi berhasil dihapus!"
-			}
+	if len(saved) > 0 {
+		response.Transactions = saved
+		response.Reply += transactionSummary(saved)
+	}
+	response.Reply += tools.Summary()
+	response.Draft = tools.Draft()
 
-			for _, s := range saved {
-				if s.Action == "update" {
-					summary += fmt.Sprintf("\n✏️ %s — Rp%s", s.Description, formatCurrency(s.Amount))
-				} else if s.Action == "delete" {
-					summary += fmt.Sprintf("\n🗑️ %s (Dihapus)", s.Description)
-				} else {
-					summary += fmt.Sprintf("\n📝 %s — Rp%s", s.Description, formatCurrency(s.Amount))
-				}
-			}
-			response.Reply += summary
-		}
+	var replyAudio string
+	if audioURL != "" || voiceReply {
+		replyAudio = h.speakReply(userID, aiResponse.Reply)
+		response.ReplyAudioURL = h.attachments.SignedURL(userID, replyAudio)
 	}
 
 	// Simpan balasan AI ke history
-	if err := h.chatHistorySvc.SaveMessage(userID, "assistant", response.Reply, "", ""); err != nil {
+	if err := h.chatHistorySvc.SaveReply(userID, response.Reply, response.Transactions, prompt.Version, replyAudio); err != nil {
 		log.Warn().Err(err).Msg("Gagal menyimpan balasan AI ke history")
 	}
 
@@ -230,10 +233,15 @@ func (h *aiHandler) ChatMessage(c *fiber.Ctx) error {
 // @Param message formData string false "Text message"
 // @Param image formData file false "Image attachment"
 // @Param voice formData file false "Voice attachment"
+// @Param voice_reply formData bool false "Also reply with synthesized speech (always on for voice messages)"
 // @Success 200 {string} string "SSE stream"
+// @Failure 401 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
 // @Router /api/ai/chat/stream [post]
 func (h *aiHandler) ChatMessageStream(c *fiber.Ctx) error {
 	message := c.FormValue("message")
+	voiceReply := c.FormValue("voice_reply") == "true"
 	var imageBase64 string
 	userID, ok := c.Locals("userID").(uint)
 	if !ok {
@@ -242,22 +250,28 @@ func (h *aiHandler) ChatMessageStream(c *fiber.Ctx) error {
 
 	var audioURL string
 	var savedImageURL string
-	var diskVoicePath string
-	var diskImagePath string
+	var storedVoice *service.StoredFile
+	var storedImage *service.StoredFile
 
 	voiceFile, err := c.FormFile("voice")
-	if err == nil && voiceFile != nil {
+	hasVoice := err == nil && voiceFile != nil
+	scope := service.AIScope{UserID: userID, Channel: entity.AIChannelWeb, Voice: hasVoice}
+	if err := h.aiService.WithScope(scope).CheckRateLimit(); err != nil {
+		return rateLimitResponse(c, err)
+	}
+
+	if hasVoice {
 		if voiceFile.Size > MaxAudioSize {
 			return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "Audio too large"})
 		}
-		savedPath, err := processAndSaveFile(voiceFile)
+		stored, err := h.attachments.SaveUpload(userID, voiceFile)
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Failed to save audio")
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Gagal menyimpan audio: %s", err.Error())})
 		}
-		audioURL = savedPath
-		diskVoicePath = "." + savedPath
+		audioURL = stored.Key
+		storedVoice = stored
 	}
 
 	imageFile, err := c.FormFile("image")
@@ -265,14 +279,14 @@ func (h *aiHandler) ChatMessageStream(c *fiber.Ctx) error {
 		if imageFile.Size > MaxImageSize {
 			return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "Image too large"})
 		}
-		savedPath, err := processAndSaveFile(imageFile)
+		stored, err := h.attachments.SaveUpload(userID, imageFile)
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Failed to save image")
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Gagal menyimpan gambar: %s", err.Error())})
 		}
-		savedImageURL = savedPath
-		diskImagePath = "." + savedPath
+		savedImageURL = stored.Key
+		storedImage = stored
 	}
 
 	c.Set("Content-Type", "text/event-stream")
@@ -280,32 +294,39 @@ func (h *aiHandler) ChatMessageStream(c *fiber.Ctx) error {
 	c.Set("Connection", "keep-alive")
 	c.Set("Transfer-Encoding", "chunked")
 
-	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-		writeSSE(w, "status", "Mempersiapkan...")
+	// Stream writer berjalan setelah handler selesai; bawa actor & request_id lewat context terpisah.
+	auditCtx := audit.Detach(audit.WithActor(c.UserContext(), audit.ActorAI))
 
-		if diskVoicePath != "" {
-			writeSSE(w, "status", "Mentranskripsi suara...")
-			transcription, err := h.aiService.ProcessVoice(diskVoicePath)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// ctx dibatalkan saat tulisan ke client gagal (tab ditutup), sehingga request ke LLM ikut berhenti.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream := &sseStream{w: w, cancel: cancel}
+		defer stream.keepAlive(ctx, sseKeepAliveInterval)()
+
+		stream.send("status", "Mempersiapkan...")
+
+		var transcript *entity.VoiceTranscript
+		if storedVoice != nil {
+			stream.send("status", "Mentranskripsi suara...")
+			var err error
+			transcript, err = h.transcribe(userID, storedVoice)
 			if err != nil {
-				safeError, _ := json.Marshal(map[string]string{"error": "Gagal memproses audio: " + err.Error()})
-				writeSSE(w, "error", string(safeError))
+				errMsg := "Gagal memproses audio: " + err.Error()
+				if errors.Is(err, service.ErrNoSpeech) {
+					errMsg = err.Error()
+				}
+				safeError, _ := json.Marshal(map[string]string{"error": errMsg})
+				stream.send("error", string(safeError))
 				return
 			}
-			if message == "" {
-				message = "[TRANSKRIPSI SUARA - mungkin ada kesalahan fonetik, tolong koreksi]: " + transcription
-			} else {
-				message = message + "\n\n[TRANSKRIPSI SUARA - mungkin ada kesalahan fonetik, tolong koreksi]: " + transcription
-			}
+			message = service.AppendTranscript(message, transcript)
+			safeTranscript, _ := json.Marshal(transcript)
+			stream.send("transcript", string(safeTranscript))
 		}
 
-		if diskImagePath != "" {
-			b64, err := readFileAsBase64(diskImagePath)
-			if err != nil {
-				safeError, _ := json.Marshal(map[string]string{"error": "Gagal memproses gambar: " + err.Error()})
-				writeSSE(w, "error", string(safeError))
-				return
-			}
-			imageBase64 = b64
+		if storedImage != nil {
+			imageBase64 = base64.StdEncoding.EncodeToString(storedImage.Data)
 			if message == "" {
 				message = "Tolong analisis gambar ini. Jika ini struk belanja, identifikasi item dan harganya."
 			}
@@ -313,101 +334,88 @@ func (h *aiHandler) ChatMessageStream(c *fiber.Ctx) error {
 
 		if message == "" {
 			safeError, _ := json.Marshal(map[string]string{"error": "Message required"})
-			writeSSE(w, "error", string(safeError))
+			stream.send("error", string(safeError))
 			return
 		}
 
 		// Simpan pesan user ke history
-		if err := h.chatHistorySvc.SaveMessage(userID, "user", message, audioURL, savedImageURL); err != nil {
+		if err := h.chatHistorySvc.SaveMessage(userID, "user", message, audioURL, savedImageURL, transcript); err != nil {
 			log.Warn().Err(err).Msg("Gagal menyimpan pesan user ke history")
 		}
 
 		botStatus := "Sedang berpikir..."
-		if diskImagePath != "" {
+		if storedImage != nil {
 			botStatus = "Menganalisis gambar..."
 		}
-		writeSSE(w, "status", botStatus)
+		stream.send("status", botStatus)
 
-		userContext := h.chatbotService.GetUserContext(userID, message)
+		prompt := h.chatbotService.ChatPrompt(userID, message)
+		conv := h.chatHistorySvc.BuildConversation(userID, message)
 
-		aiResponse, err := h.aiService.ChatStream(message, imageBase64, userContext, func(token string) error {
+		tools := h.chatbotService.WithContext(auditCtx).NewToolSession(userID, message, imageBase64 != "").AttachImage(savedImageURL)
+		if transcript.NeedsConfirmation() {
+			tools.RequireConfirmation(service.VoiceConfirmationReason(transcript))
+		}
+		aiResponse, err := h.aiService.WithScope(scope).ChatStream(ctx, message, imageBase64, prompt, conv, tools, func(token string) error {
 			safeToken, _ := json.Marshal(map[string]string{"content": token})
-			return writeSSE(w, "token", string(safeToken))
+			return stream.send("token", string(safeToken))
 		})
+		saved := tools.Finish()
 
+		if err != nil && ctx.Err() != nil {
+			reqID, _ := c.Locals("requestid").(string)
+			log.Info().Str("request_id", reqID).Int("saved", len(saved)).Msg("Client terputus, stream dibatalkan")
+			return
+		}
+		if err != nil && tools.Committed() {
+			reqID, _ := c.Locals("requestid").(string)
+			log.Warn().Str("request_id", reqID).Err(err).Int("saved", len(saved)).Msg("Stream failed after tool calls")
+			aiResponse, err = &entity.ChatAIResponse{Reply: service.InterruptedReply(err)}, nil
+			safeToken, _ := json.Marshal(map[string]string{"content": aiResponse.Reply})
+			stream.send("token", string(safeToken))
+		}
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Stream failed")
 			safeError, _ := json.Marshal(map[string]string{"error": err.Error()})
-			writeSSE(w, "error", string(safeError))
+			stream.send("error", string(safeError))
 			return
 		}
 
 		response := entity.ChatResponse{
-			Reply:    aiResponse.Reply,
-			AudioURL: audioURL,
-			ImageURL: savedImageURL,
+			Reply:      aiResponse.Reply,
+			AudioURL:   h.attachments.SignedURL(userID, audioURL),
+			ImageURL:   h.attachments.SignedURL(userID, savedImageURL),
+			Receipt:    aiResponse.Receipt,
+			Transcript: transcript,
 		}
 
-		if aiResponse.IsTransaction && len(aiResponse.Transactions) > 0 {
-			writeSSE(w, "status", "Memproses transaksi...")
-			saved, err := h.chatbotService.SaveTransactions(userID, aiResponse.Transactions)
-			if err != nil {
-				errMsg := "\n\n(Gagal memproses transaksi: " + err.Error() + ")"
-				response.Reply += errMsg
-				safeToken, _ := json.Marshal(map[string]string{"content": errMsg})
-				writeSSE(w, "token", string(safeToken))
-			} else if len(saved) > 0 {
-				response.Transactions = saved
-				
-				summary := "\n\n"
-				hasCreate, hasUpdate, hasDelete := false, false, false
-				
-				for _, s := range saved {
-					switch s.Action {
-					case "update":
-						hasUpdate = true
-					case "delete":
-						hasDelete = true
-					default:
-						hasCreate = true
-					}
-				}
-				
-				if hasCreate {
-					summary += "✅ Transaksi berhasil dicatat!"
-				} else if hasUpdate {
-					summary += "✅ Transaksi berhasil diperbarui!"
-				} else if hasDelete {
-					summary += "✅ Transaksi berhasil dihapus!"
-				}
-				
-				for _, s := range saved {
-					if s.Action == "update" {
-						summary += fmt.Sprintf("\n✏️ %s — Rp%s", s.Description, formatCurrency(s.Amount))
-					} else if s.Action == "delete" {
-						summary += fmt.Sprintf("\n🗑️ %s (Dihapus)", s.Description)
-					} else {
-						summary += fmt.Sprintf("\n📝 %s — Rp%s", s.Description, formatCurrency(s.Amount))
-					}
-				}
-
-				for _, char := range summary {
-					safeToken, _ := json.Marshal(map[string]string{"content": string(char)})
-					writeSSE(w, "token", string(safeToken))
-				}
-
-				response.Reply += summary
-			}
+		summary := tools.Summary()
+		if len(saved) > 0 {
+			response.Transactions = saved
+			summary = transactionSummary(saved) + summary
+		}
+		if summary != "" {
+			safeToken, _ := json.Marshal(map[string]string{"content": summary})
+			stream.send("token", string(safeToken))
+		}
+		response.Reply += summary
+		response.Draft = tools.Draft()
+
+		var replyAudio string
+		if audioURL != "" || voiceReply {
+			stream.send("status", "Membuat balasan suara...")
+			replyAudio = h.speakReply(userID, aiResponse.Reply)
+			response.ReplyAudioURL = h.attachments.SignedURL(userID, replyAudio)
 		}
 
 		// Simpan balasan AI ke history setelah streaming selesai
-		if err := h.chatHistorySvc.SaveMessage(userID, "assistant", response.Reply, "", ""); err != nil {
+		if err := h.chatHistorySvc.SaveReply(userID, response.Reply, response.Transactions, prompt.Version, replyAudio); err != nil {
 			log.Warn().Err(err).Msg("Gagal menyimpan balasan AI ke history")
 		}
 
 		finalJSON, _ := json.Marshal(response)
-		writeSSE(w, "done", string(finalJSON))
+		stream.send("done", string(finalJSON))
 	})
 
 	return nil
@@ -441,6 +449,7 @@ func (h *aiHandler) GetChatHistory(c *fiber.Ctx) error {
 		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to retrieve chat history")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal mengambil riwayat chat"})
 	}
+	h.attachments.SignChatMessages(userID, messages)
 
 	return c.JSON(messages)
 }
@@ -468,6 +477,234 @@ func (h *aiHandler) ClearChatHistory(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Riwayat chat berhasil dihapus"})
 }
 
+// GetChatMessages godoc
+// @Summary Browse and search chat history
+// @Description Get one page of chat messages (oldest first within the page). Pass next_before from the previous page as before to load older messages; q searches the message content
+// @Tags ai
+// @Produce json
+// @Security BearerAuth
+// @Param before query int false "Only messages with an ID lower than this cursor"
+// @Param limit query int false "Page size (default 100, max 200)"
+// @Param q query string false "Full-text search over message content"
+// @Success 200 {object} entity.ChatHistoryPage
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/ai/chat/messages [get]
+func (h *aiHandler) GetChatMessages(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	before, err := strconv.ParseUint(c.Query("before", "0"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Cursor before tidak valid"})
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "0"))
+	if err != nil || limit < 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Limit tidak valid"})
+	}
+
+	page, err := h.chatHistorySvc.GetPage(userID, entity.ChatHistoryQuery{
+		Before: uint(before),
+		Limit:  limit,
+		Search: c.Query("q"),
+	})
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to retrieve chat messages")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal mengambil riwayat chat"})
+	}
+	h.attachments.SignChatMessages(userID, page.Messages)
+
+	return c.JSON(page)
+}
+
+// DeleteChatMessage godoc
+// @Summary Delete a chat message
+// @Description Delete a single chat message together with its uploaded audio/image
+// @Tags ai
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/ai/chat/messages/{id} [delete]
+func (h *aiHandler) DeleteChatMessage(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "ID pesan tidak valid"})
+	}
+
+	err = h.chatHistorySvc.DeleteMessage(userID, uint(id))
+	if errors.Is(err, service.ErrChatMessageNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to delete chat message")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal menghapus pesan"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Pesan berhasil dihapus"})
+}
+
+// ExportChatHistory godoc
+// @Summary Export chat history
+// @Description Download the whole conversation as JSON or Markdown
+// @Tags ai
+// @Produce json
+// @Produce text/markdown
+// @Security BearerAuth
+// @Param format query string false "json (default) or md"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/ai/chat/export [get]
+func (h *aiHandler) ExportChatHistory(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	format := c.Query("format", service.ChatExportJSON)
+	data, err := h.chatHistorySvc.Export(userID, format)
+	if errors.Is(err, service.ErrInvalidExportFormat) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to export chat history")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal mengekspor riwayat chat"})
+	}
+
+	contentType := fiber.MIMEApplicationJSONCharsetUTF8
+	if format == service.ChatExportMarkdown {
+		contentType = "text/markdown; charset=utf-8"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="chat_history.%s"`, format))
+	return c.Send(data)
+}
+
+// UndoLastAction godoc
+// @Summary Undo last AI action
+// @Description Revert every transaction created, updated or deleted by the latest AI response, including wallet balances
+// @Tags ai
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/ai/undo [post]
+func (h *aiHandler) UndoLastAction(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	batch, err := h.chatbotService.WithContext(c.UserContext()).UndoLastBatch(userID)
+	if errors.Is(err, service.ErrNothingToUndo) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrNotUndoable) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to undo AI batch")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membatalkan aksi AI: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": service.FormatUndoSummary(batch),
+		"data":    batch,
+	})
+}
+
+// rateLimitResponse menjawab 429 dengan Retry-After dari RateLimitError.
+func rateLimitResponse(c *fiber.Ctx, err error) error {
+	var rateLimited *service.RateLimitError
+	if errors.As(err, &rateLimited) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(rateLimited.RetryAfter.Seconds()))))
+	}
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+}
+
+// sseKeepAliveInterval: komentar SSE kosong dikirim berkala agar client yang sudah pergi
+// terdeteksi walau model belum menghasilkan token (misal saat memproses prompt panjang).
+const sseKeepAliveInterval = 5 * time.Second
+
+// sseStream menyerialkan tulisan dari callback token dan keep-alive. Tulisan pertama yang gagal
+// berarti client sudah terputus dan membatalkan ctx request.
+type sseStream struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	cancel context.CancelFunc
+	closed bool
+}
+
+func (s *sseStream) send(event, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return context.Canceled
+	}
+	if err := writeSSE(s.w, event, data); err != nil {
+		s.closed = true
+		s.cancel()
+		return err
+	}
+	return nil
+}
+
+// keepAlive berjalan sampai ctx selesai; fungsi yang dikembalikan menghentikannya dan menunggu
+// goroutine-nya keluar sebelum writer dilepas fasthttp.
+func (s *sseStream) keepAlive(ctx context.Context, interval time.Duration) func() {
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				if !s.closed {
+					_, err := s.w.WriteString(": keep-alive\n\n")
+					if err == nil {
+						err = s.w.Flush()
+					}
+					if err != nil {
+						s.closed = true
+						s.cancel()
+					}
+				}
+				s.mu.Unlock()
+			}
+		}
+	}()
+	return func() {
+		stop()
+		<-done
+	}
+}
+
 func writeSSE(w *bufio.Writer, event, data string) error {
 	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
 	if err != nil {
@@ -492,29 +729,41 @@ func formatCurrency(amount float64) string {
 	return string(result)
 }
 
-func readFileAsBase64(path string) (string, error) {
-	file, err := os.Open(path)
+// transcribe menulis audio ke file sementara karena whisper membaca dari path,
+// sementara file aslinya bisa saja berada di object storage.
+func (h *aiHandler) transcribe(userID uint, stored *service.StoredFile) (*entity.VoiceTranscript, error) {
+	tmp, err := os.CreateTemp("", "voice_*"+filepath.Ext(stored.Key))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer file.Close()
+	defer os.Remove(tmp.Name())
 
-	info, err := file.Stat()
-	if err != nil {
-		return "", err
+	if _, err := tmp.Write(stored.Data); err != nil {
+		tmp.Close()
+		return nil, err
 	}
+	tmp.Close()
 
-	estimatedSize := int(info.Size()*4/3) + 4
-	var buf bytes.Buffer
-	buf.Grow(estimatedSize)
+	return h.aiService.WithScope(service.AIScope{UserID: userID, Channel: entity.AIChannelWeb, Voice: true}).ProcessVoice(tmp.Name())
+}
 
-	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
-	if _, err := io.Copy(encoder, file); err != nil {
-		return "", err
+// speakReply membacakan balasan AI dan menyimpan audionya sebagai attachment. Mengembalikan key
+// audio, atau kosong jika TTS tidak aktif atau gagal (balasan teks tetap dikirim).
+func (h *aiHandler) speakReply(userID uint, reply string) string {
+	audio, err := h.aiService.Speak(reply)
+	if errors.Is(err, service.ErrSpeechDisabled) {
+		return ""
 	}
-	encoder.Close()
-
-	return buf.String(), nil
+	if err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal membuat balasan suara")
+		return ""
+	}
+	key, err := h.attachments.SaveBytes(userID, "reply.ogg", "audio/ogg", audio)
+	if err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal menyimpan balasan suara")
+		return ""
+	}
+	return key
 }
 
 func removeTempWavFile(path string) {
@@ -523,3 +772,186 @@ func removeTempWavFile(path string) {
 	wavPath = strings.TrimSuffix(path, ".webm") + ".wav"
 	os.Remove(wavPath)
 }
+
+// GetDrafts godoc
+// @Summary Get pending AI drafts
+// @Description List AI-detected transactions waiting for the user's confirmation
+// @Tags ai
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} entity.AIDraft
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/ai/drafts [get]
+func (h *aiHandler) GetDrafts(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	drafts, err := h.chatbotService.PendingDrafts(userID)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to get AI drafts")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal mengambil draft"})
+	}
+	return c.JSON(drafts)
+}
+
+type confirmDraftRequest struct {
+	// Transactions menggantikan item usulan AI jika user mengeditnya; kosongkan untuk menyimpan apa adanya.
+	Transactions []entity.TransactionItemAI `json:"transactions"`
+}
+
+// ConfirmDraft godoc
+// @Summary Confirm an AI draft
+// @Description Save the transactions of a pending AI draft, optionally replacing them with the user's edits
+// @Tags ai
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Draft ID"
+// @Param request body confirmDraftRequest false "Edited transactions"
+// @Success 200 {object} entity.ChatResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/ai/drafts/{id}/confirm [post]
+func (h *aiHandler) ConfirmDraft(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, _ := strconv.Atoi(c.Params("id"))
+
+	var req confirmDraftRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	saved, summary, err := h.chatbotService.WithContext(c.UserContext()).ConfirmDraft(userID, uint(id), req.Transactions)
+	switch {
+	case errors.Is(err, service.ErrDraftNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidDraftItem):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil && len(saved) == 0 && summary == "":
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to confirm AI draft")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal menyimpan draft: " + err.Error()})
+	}
+
+	response := entity.ChatResponse{Reply: fmt.Sprintf("Draft #%d dikonfirmasi.", id), Transactions: saved}
+	if len(saved) > 0 {
+		response.Reply += transactionSummary(saved)
+	}
+	response.Reply += summary
+	if err != nil {
+		response.Reply += "\n\n⚠️ " + err.Error()
+	}
+	if err := h.chatHistorySvc.SaveReply(userID, response.Reply, saved, "", ""); err != nil {
+		log.Warn().Err(err).Msg("Gagal menyimpan konfirmasi draft ke history")
+	}
+	return c.JSON(response)
+}
+
+// RejectDraft godoc
+// @Summary Reject an AI draft
+// @Description Discard a pending AI draft without saving anything
+// @Tags ai
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Draft ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/ai/drafts/{id}/reject [post]
+func (h *aiHandler) RejectDraft(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, _ := strconv.Atoi(c.Params("id"))
+
+	err := h.chatbotService.RejectDraft(userID, uint(id))
+	if errors.Is(err, service.ErrDraftNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to reject AI draft")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Gagal membatalkan draft"})
+	}
+	return c.JSON(fiber.Map{"message": fmt.Sprintf("Draft #%d dibatalkan", id)})
+}
+
+// GetProviderHealth godoc
+// @Summary Get LLM provider health
+// @Description Admin only. Circuit breaker state of every LLM provider in the fallback chain
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/ai/health [get]
+func (h *aiHandler) GetProviderHealth(c *fiber.Ctx) error {
+	providers := h.aiService.ProviderHealth()
+	available := 0
+	for _, p := range providers {
+		if p.Available {
+			available++
+		}
+	}
+
+	status := "ok"
+	switch {
+	case providers == nil:
+		status = "unknown"
+	case available == 0:
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{"status": "down", "providers": providers})
+	case available < len(providers):
+		status = "degraded"
+	}
+	return c.JSON(fiber.Map{"status": status, "providers": providers})
+}
+
+// transactionSummary menyusun ringkasan transaksi yang disimpan AI untuk ditambahkan ke balasan.
+func transactionSummary(saved []entity.SavedTransaction) string {
+	summary := "\n\n"
+
+	hasCreate, hasUpdate, hasDelete := false, false, false
+	for _, s := range saved {
+		switch s.Action {
+		case "update":
+			hasUpdate = true
+		case "delete":
+			hasDelete = true
+		default:
+			hasCreate = true
+		}
+	}
+
+	if hasCreate {
+		summary += "✅ Transaksi berhasil dicatat!"
+	} else if hasUpdate {
+		summary += "✅ Transaksi berhasil diperbarui!"
+	} else if hasDelete {
+		summary += "✅ Transaksi berhasil dihapus!"
+	}
+
+	for _, s := range saved {
+		if s.Action == "update" {
+			summary += fmt.Sprintf("\n✏️ %s — Rp%s%s", s.Description, formatCurrency(s.Amount), service.FormatDateLabel(s.Date))
+		} else if s.Action == "delete" {
+			summary += fmt.Sprintf("\n🗑️ %s (Dihapus)", s.Description)
+		} else {
+			summary += fmt.Sprintf("\n📝 %s — Rp%s%s", s.Description, formatCurrency(s.Amount), service.FormatDateLabel(s.Date))
+		}
+	}
+	return summary
+}
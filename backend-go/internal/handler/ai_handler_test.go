@@ -6,48 +6,116 @@ import (
 	"cuan-backend/internal/entity"
 	aiprovider "cuan-backend/internal/provider/ai"
 	"cuan-backend/internal/service"
+	"io"
 	"mime/multipart"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
 )
 
-type mockAIService struct{}
+type mockAIService struct {
+	health     []aiprovider.ProviderHealth
+	rateLimit  error
+	voiceCalls int
+}
 
-func (m *mockAIService) Chat(_ string, _ string, _ string) (*entity.ChatAIResponse, error) {
+func (m *mockAIService) Chat(_ string, _ string, _ service.ChatPrompt, _ service.Conversation, _ service.ToolExecutor) (*entity.ChatAIResponse, error) {
 	return nil, nil
 }
 
-func (m *mockAIService) ChatStream(_ string, _ string, _ string, _ func(string) error) (*entity.ChatAIResponse, error) {
+func (m *mockAIService) ChatStream(_ context.Context, _ string, _ string, _ service.ChatPrompt, _ service.Conversation, _ service.ToolExecutor, _ func(string) error) (*entity.ChatAIResponse, error) {
 	return nil, nil
 }
 
-func (m *mockAIService) ProcessVoice(_ string) (string, error) {
+func (m *mockAIService) ProcessVoice(_ string) (*entity.VoiceTranscript, error) {
+	m.voiceCalls++
+	return &entity.VoiceTranscript{}, nil
+}
+
+func (m *mockAIService) Speak(_ string) ([]byte, error) {
+	return nil, service.ErrSpeechDisabled
+}
+
+func (m *mockAIService) Summarize(_ uint, _ string, _ []aiprovider.Message) (string, error) {
 	return "", nil
 }
 
+func (m *mockAIService) PhraseInsights(_ uint, _ []entity.InsightFact) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockAIService) CheckRateLimit() error {
+	return m.rateLimit
+}
+
+func (m *mockAIService) WithScope(_ service.AIScope) service.AIService {
+	return m
+}
+
+func (m *mockAIService) ProviderHealth() []aiprovider.ProviderHealth {
+	return m.health
+}
+
 type mockAIProvider struct{}
 
 func (m *mockAIProvider) GenerateCompletion(_ context.Context, _ aiprovider.AIRequest) (string, error) {
 	return "", nil
 }
 
-type mockChatHistoryService struct{}
+func (m *mockAIProvider) GenerateWithTools(_ context.Context, _ aiprovider.AIRequest) (*aiprovider.AIResponse, error) {
+	return &aiprovider.AIResponse{}, nil
+}
+
+func (m *mockAIProvider) StreamWithTools(_ context.Context, _ aiprovider.AIRequest, _ func(string) error) (*aiprovider.AIResponse, error) {
+	return &aiprovider.AIResponse{}, nil
+}
+
+type mockChatHistoryService struct{ savedMessages int }
+
+func (m *mockChatHistoryService) SaveMessage(_ uint, _, _, _, _ string, _ *entity.VoiceTranscript) error {
+	m.savedMessages++
+	return nil
+}
 
-func (m *mockChatHistoryService) SaveMessage(_ uint, _, _, _, _ string) error {
+func (m *mockChatHistoryService) SaveReply(_ uint, _ string, _ []entity.SavedTransaction, _, _ string) error {
 	return nil
 }
 
+func (m *mockChatHistoryService) BuildConversation(_ uint, _ string) service.Conversation {
+	return service.Conversation{}
+}
+
 func (m *mockChatHistoryService) GetHistory(_ uint, _ int) ([]entity.ChatMessage, error) {
 	return nil, nil
 }
 
+func (m *mockChatHistoryService) GetPage(_ uint, _ entity.ChatHistoryQuery) (*entity.ChatHistoryPage, error) {
+	return &entity.ChatHistoryPage{}, nil
+}
+
+func (m *mockChatHistoryService) DeleteMessage(_ uint, id uint) error {
+	if id != 1 {
+		return service.ErrChatMessageNotFound
+	}
+	return nil
+}
+
 func (m *mockChatHistoryService) ClearHistory(_ uint) error {
 	return nil
 }
 
+func (m *mockChatHistoryService) Export(_ uint, format string) ([]byte, error) {
+	if format != service.ChatExportJSON && format != service.ChatExportMarkdown {
+		return nil, service.ErrInvalidExportFormat
+	}
+	return []byte("# Riwayat Chat\n"), nil
+}
+
+func (m *mockChatHistoryService) PruneExpired(_ time.Time) {}
+
 func setupAIApp() (*fiber.App, AIHandler) {
 	app := fiber.New()
 
@@ -55,7 +123,7 @@ func setupAIApp() (*fiber.App, AIHandler) {
 	chatbotSvc := &service.ChatbotService{}
 	chatHistSvc := &mockChatHistoryService{}
 
-	h := NewAIHandler(aiSvc, chatbotSvc, chatHistSvc)
+	h := NewAIHandler(aiSvc, chatbotSvc, chatHistSvc, nil)
 
 	app.Post("/api/ai/chat", func(c *fiber.Ctx) error {
 		c.Locals("userID", uint(1))
@@ -75,6 +143,26 @@ func setupAIApp() (*fiber.App, AIHandler) {
 		return h.ChatMessageStream(c)
 	})
 
+	app.Post("/api/ai/undo", func(c *fiber.Ctx) error {
+		c.Locals("userID", uint(1))
+		return h.UndoLastAction(c)
+	})
+
+	app.Post("/api/ai/drafts/:id/confirm", func(c *fiber.Ctx) error {
+		c.Locals("userID", uint(1))
+		return h.ConfirmDraft(c)
+	})
+
+	app.Delete("/api/ai/chat/messages/:id", func(c *fiber.Ctx) error {
+		c.Locals("userID", uint(1))
+		return h.DeleteChatMessage(c)
+	})
+
+	app.Get("/api/ai/chat/export", func(c *fiber.Ctx) error {
+		c.Locals("userID", uint(1))
+		return h.ExportChatHistory(c)
+	})
+
 	return app, h
 }
 
@@ -102,6 +190,36 @@ func TestAIHandler_ChatMessage_EmptyMessage(t *testing.T) {
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 }
 
+func TestAIHandler_ChatMessage_RateLimitedBeforeTranscription(t *testing.T) {
+	aiSvc := &mockAIService{rateLimit: &service.RateLimitError{RetryAfter: 7500 * time.Millisecond}}
+	chatHistSvc := &mockChatHistoryService{}
+	h := NewAIHandler(aiSvc, &service.ChatbotService{}, chatHistSvc, nil)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", uint(1))
+		return c.Next()
+	})
+	app.Post("/api/ai/chat", h.ChatMessage)
+	app.Post("/api/ai/chat/stream", h.ChatMessageStream)
+
+	for _, path := range []string{"/api/ai/chat", "/api/ai/chat/stream"} {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("voice", "pesan.ogg")
+		_, _ = part.Write([]byte("OggS"))
+		_ = writer.Close()
+		req := httptest.NewRequest("POST", path, body)
+		req.Header.Add("Content-Type", writer.FormDataContentType())
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode, path)
+		assert.Equal(t, "8", resp.Header.Get(fiber.HeaderRetryAfter), path)
+	}
+	// Pesan yang ditolak tidak ditranskripsi dan tidak masuk history.
+	assert.Zero(t, aiSvc.voiceCalls)
+	assert.Zero(t, chatHistSvc.savedMessages)
+}
+
 func TestAIHandler_ChatMessageStream_Unauthorized(t *testing.T) {
 	app, _ := setupAIApp()
 
@@ -110,3 +228,63 @@ func TestAIHandler_ChatMessageStream_Unauthorized(t *testing.T) {
 
 	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
 }
+
+func TestAIHandler_UndoLastAction_NothingToUndo(t *testing.T) {
+	app, _ := setupAIApp()
+
+	req := httptest.NewRequest("POST", "/api/ai/undo", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestAIHandler_ConfirmDraft_NotFound(t *testing.T) {
+	app, _ := setupAIApp()
+
+	req := httptest.NewRequest("POST", "/api/ai/drafts/7/confirm", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestAIHandler_DeleteChatMessage(t *testing.T) {
+	app, _ := setupAIApp()
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/api/ai/chat/messages/1", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/api/ai/chat/messages/7", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("DELETE", "/api/ai/chat/messages/abc", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAIHandler_ExportChatHistory(t *testing.T) {
+	app, _ := setupAIApp()
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/ai/chat/export?format=md", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/markdown; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="chat_history.md"`, resp.Header.Get("Content-Disposition"))
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/api/ai/chat/export?format=pdf", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAIHandler_GetProviderHealth(t *testing.T) {
+	aiSvc := &mockAIService{}
+	h := NewAIHandler(aiSvc, &service.ChatbotService{}, &mockChatHistoryService{}, nil)
+	app := fiber.New()
+	app.Get("/api/admin/ai/health", h.GetProviderHealth)
+
+	aiSvc.health = []aiprovider.ProviderHealth{{Name: "local", State: "open"}, {Name: "external", State: "closed", Available: true}}
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/admin/ai/health", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"status":"degraded"`)
+
+	aiSvc.health[1].Available = false
+	resp, _ = app.Test(httptest.NewRequest("GET", "/api/admin/ai/health", nil))
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"cuan-backend/internal/service"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type AIUsageHandler interface {
+	GetDailyUsage(c *fiber.Ctx) error
+}
+
+type aiUsageHandler struct {
+	service service.AIUsageService
+}
+
+func NewAIUsageHandler(service service.AIUsageService) AIUsageHandler {
+	return &aiUsageHandler{service}
+}
+
+// GetDailyUsage godoc
+// @Summary Get AI usage per user and day
+// @Description Admin only. Summarizes AI requests per user and day (WIB): requests, prompt/completion tokens, average latency, image/voice requests, errors, busy and rate-limited requests
+// @Tags admin
+// @Produce json
+// @Param from query string false "Start day YYYY-MM-DD (default: 6 days before to)"
+// @Param to query string false "End day YYYY-MM-DD, inclusive (default: today)"
+// @Param user_id query int false "Only this user"
+// @Success 200 {array} entity.AIUsageDaily
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/ai-usage [get]
+func (h *aiUsageHandler) GetDailyUsage(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Query("user_id", "0"))
+	if err != nil || userID < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user_id"})
+	}
+
+	usage, err := h.service.DailySummary(c.Query("from"), c.Query("to"), uint(userID))
+	if errors.Is(err, service.ErrInvalidUsageRange) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(usage)
+}
@@ -0,0 +1,69 @@
+package handler_test
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/handler"
+	"cuan-backend/internal/service"
+	"cuan-backend/pkg/middleware"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAIUsageService struct {
+	mock.Mock
+}
+
+func (m *MockAIUsageService) Record(usage entity.AIUsage) {
+	m.Called(usage)
+}
+
+func (m *MockAIUsageService) DailySummary(from, to string, userID uint) ([]entity.AIUsageDaily, error) {
+	args := m.Called(from, to, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.AIUsageDaily), args.Error(1)
+}
+
+func TestGetDailyUsage_Handler_AdminOnly(t *testing.T) {
+	mockService := new(MockAIUsageService)
+	h := handler.NewAIUsageHandler(mockService)
+
+	app := fiber.New()
+	admins := middleware.ParseAdminIDs("1, x")
+	app.Get("/api/admin/ai-usage", mockAuthMiddleware(1), middleware.AdminOnly(admins), h.GetDailyUsage)
+	app.Get("/api/other/ai-usage", mockAuthMiddleware(2), middleware.AdminOnly(admins), h.GetDailyUsage)
+
+	mockService.On("DailySummary", "2026-10-01", "", uint(5)).Return([]entity.AIUsageDaily{
+		{Day: "2026-10-18", UserID: 5, Requests: 12, PromptTokens: 3400},
+	}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/admin/ai-usage?from=2026-10-01&user_id=5", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var usage []entity.AIUsageDaily
+	_ = json.NewDecoder(resp.Body).Decode(&usage)
+	assert.Equal(t, int64(12), usage[0].Requests)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/api/other/ai-usage", nil))
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDailyUsage_Handler_InvalidRange(t *testing.T) {
+	mockService := new(MockAIUsageService)
+	h := handler.NewAIUsageHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/api/admin/ai-usage", mockAuthMiddleware(1), h.GetDailyUsage)
+
+	mockService.On("DailySummary", "kemarin", "", uint(0)).Return(nil, service.ErrInvalidUsageRange)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/admin/ai-usage?from=kemarin", nil))
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
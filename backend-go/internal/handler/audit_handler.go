@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/service"
+	"cuan-backend/pkg/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type AuditHandler interface {
+	GetAuditEvents(c *fiber.Ctx) error
+}
+
+type auditHandler struct {
+	service service.AuditService
+}
+
+func NewAuditHandler(service service.AuditService) AuditHandler {
+	return &auditHandler{service}
+}
+
+// GetAuditEvents godoc
+// @Summary Get audit events
+// @Description Get the append-only audit trail of financial mutations (wallets, transactions, debts, saving goals) for the logged in user
+// @Tags audit
+// @Produce json
+// @Param page query int false "Page Number" default(1)
+// @Param limit query int false "Items per Page" default(50)
+// @Param entity_type query string false "Table name, e.g. wallets, transactions, debts"
+// @Param entity_id query int false "Entity ID"
+// @Param actor query string false "web, ai, whatsapp, scheduler or system"
+// @Param action query string false "create, update or delete"
+// @Param request_id query string false "Request ID"
+// @Param start_date query string false "Start Date (inclusive)"
+// @Param end_date query string false "End Date (exclusive)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/audit [get]
+func (h *auditHandler) GetAuditEvents(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", strconv.Itoa(service.DefaultAuditPageLimit)))
+	entityID, _ := strconv.Atoi(c.Query("entity_id", "0"))
+
+	params := entity.AuditFilterParams{
+		Page:       page,
+		Limit:      limit,
+		EntityType: c.Query("entity_type"),
+		EntityID:   uint(entityID),
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		RequestID:  c.Query("request_id"),
+		StartDate:  c.Query("start_date"),
+		EndDate:    c.Query("end_date"),
+	}
+
+	events, total, err := h.service.GetEvents(userID, params)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   events,
+		"meta": fiber.Map{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/handler"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) GetEvents(userID uint, params entity.AuditFilterParams) ([]entity.AuditEvent, int64, error) {
+	args := m.Called(userID, params)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]entity.AuditEvent), args.Get(1).(int64), args.Error(2)
+}
+
+func TestGetAuditEvents_Handler(t *testing.T) {
+	mockService := new(MockAuditService)
+	h := handler.NewAuditHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/api/audit", mockAuthMiddleware(1), h.GetAuditEvents)
+
+	events := []entity.AuditEvent{
+		{ID: 2, UserID: 1, Actor: "ai", Action: "create", EntityType: "transactions", EntityID: 7, After: `{"amount":15000}`},
+	}
+	mockService.On("GetEvents", uint(1), mock.MatchedBy(func(p entity.AuditFilterParams) bool {
+		return p.EntityType == "transactions" && p.EntityID == 7 && p.Actor == "ai" && p.Page == 1
+	})).Return(events, int64(1), nil)
+
+	req := httptest.NewRequest("GET", "/api/audit?entity_type=transactions&entity_id=7&actor=ai", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Data []struct {
+			Actor  string          `json:"actor"`
+			After  json.RawMessage `json:"after"`
+			Before json.RawMessage `json:"before"`
+		} `json:"data"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	assert.Len(t, body.Data, 1)
+	assert.Equal(t, "ai", body.Data[0].Actor)
+	assert.JSONEq(t, `{"amount":15000}`, string(body.Data[0].After))
+	assert.Equal(t, "null", string(body.Data[0].Before))
+	mockService.AssertExpectations(t)
+}
+
+func TestGetAuditEvents_Handler_Error(t *testing.T) {
+	mockService := new(MockAuditService)
+	h := handler.NewAuditHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/api/audit", mockAuthMiddleware(1), h.GetAuditEvents)
+
+	mockService.On("GetEvents", uint(1), mock.Anything).Return(nil, int64(0), errors.New("db down"))
+
+	req := httptest.NewRequest("GET", "/api/audit", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+}
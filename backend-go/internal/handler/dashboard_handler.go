@@ -13,11 +13,12 @@ type DashboardHandler interface {
 }
 
 type dashboardHandler struct {
-	service service.DashboardService
+	service     service.DashboardService
+	attachments service.AttachmentService
 }
 
-func NewDashboardHandler(service service.DashboardService) DashboardHandler {
-	return &dashboardHandler{service}
+func NewDashboardHandler(service service.DashboardService, attachments service.AttachmentService) DashboardHandler {
+	return &dashboardHandler{service, attachments}
 }
 
 // GetDashboard godoc
@@ -45,6 +46,7 @@ func (h *dashboardHandler) GetDashboard(c *fiber.Ctx) error {
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	h.attachments.SignTransactions(userID, data.RecentTransactions)
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -27,7 +27,7 @@ func (m *MockDashboardService) GetDashboardData(userID uint) (*entity.DashboardD
 
 func TestGetDashboard(t *testing.T) {
 	mockService := new(MockDashboardService)
-	dashboardHandler := handler.NewDashboardHandler(mockService)
+	dashboardHandler := handler.NewDashboardHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Get("/api/dashboard", mockAuthMiddleware(1), dashboardHandler.GetDashboard)
@@ -52,7 +52,7 @@ func TestGetDashboard(t *testing.T) {
 
 func TestGetDashboard_Error(t *testing.T) {
 	mockService := new(MockDashboardService)
-	dashboardHandler := handler.NewDashboardHandler(mockService)
+	dashboardHandler := handler.NewDashboardHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Get("/api/dashboard", mockAuthMiddleware(1), dashboardHandler.GetDashboard)
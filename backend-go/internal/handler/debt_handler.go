@@ -50,7 +50,7 @@ func (h *debtHandler) CreateDebt(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	debt, err := h.service.CreateDebt(userID, input)
+	debt, err := h.service.WithContext(c.UserContext()).CreateDebt(userID, input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -131,7 +131,7 @@ func (h *debtHandler) PayDebt(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	debt, err := h.service.PayDebt(uint(id), userID, input)
+	debt, err := h.service.WithContext(c.UserContext()).PayDebt(uint(id), userID, input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -166,7 +166,7 @@ func (h *debtHandler) UpdateDebt(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	debt, err := h.service.UpdateDebt(uint(id), userID, input)
+	debt, err := h.service.WithContext(c.UserContext()).UpdateDebt(uint(id), userID, input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -190,7 +190,7 @@ func (h *debtHandler) DeleteDebt(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 	id, _ := strconv.Atoi(c.Params("id"))
 
-	if err := h.service.DeleteDebt(uint(id), userID); err != nil {
+	if err := h.service.WithContext(c.UserContext()).DeleteDebt(uint(id), userID); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -211,7 +211,7 @@ func (h *debtHandler) DeletePayment(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 	id, _ := strconv.Atoi(c.Params("id"))
 
-	if err := h.service.DeletePayment(uint(id), userID); err != nil {
+	if err := h.service.WithContext(c.UserContext()).DeletePayment(uint(id), userID); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -1,6 +1,7 @@
 package handler_test
 
 import (
+	"context"
 	"bytes"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/handler"
@@ -21,6 +22,10 @@ type MockDebtService struct {
 	mock.Mock
 }
 
+func (m *MockDebtService) WithContext(ctx context.Context) service.DebtService {
+	return m
+}
+
 func (m *MockDebtService) CreateDebt(userID uint, input service.CreateDebtInput) (*entity.Debt, error) {
 	args := m.Called(userID, input)
 	if args.Get(0) == nil {
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"cuan-backend/internal/provider/storage"
+	"cuan-backend/internal/service"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type FileHandler interface {
+	ServeFile(c *fiber.Ctx) error
+}
+
+type fileHandler struct {
+	attachments service.AttachmentService
+}
+
+func NewFileHandler(attachments service.AttachmentService) FileHandler {
+	return &fileHandler{attachments}
+}
+
+// ServeFile godoc
+// @Summary Download an attachment
+// @Description Serve a transaction or chat attachment to its owner. Accepts either a signed, time-limited URL or a Bearer token. Supports single byte-range requests for audio seeking.
+// @Tags files
+// @Produce octet-stream
+// @Param key path string true "Attachment key"
+// @Param uid query int false "Owner user ID (signed URL)"
+// @Param exp query int false "Expiry in unix seconds (signed URL)"
+// @Param sig query string false "Signature (signed URL)"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 416 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/files/{key} [get]
+func (h *fileHandler) ServeFile(c *fiber.Ctx) error {
+	key, err := storage.CleanKey(c.Params("*"))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+	}
+
+	var userID uint
+	if c.Query("sig") != "" {
+		userID, err = h.attachments.Verify(key, c.Query("uid"), c.Query("exp"), c.Query("sig"))
+		if err != nil {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Link tidak valid atau sudah kedaluwarsa"})
+		}
+	} else if id, ok := c.Locals("userID").(uint); ok {
+		userID = id
+	} else {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	owned, err := h.attachments.IsOwnedBy(userID, key)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Str("key", key).Msg("Failed to check attachment ownership")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+	}
+	if !owned {
+		// Same response as a missing file so keys cannot be probed
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+	}
+
+	// Object storage handles range requests natively on the presigned URL
+	if url, ok := h.attachments.PresignedURL(key); ok {
+		return c.Redirect(url, http.StatusFound)
+	}
+
+	reader, info, err := h.attachments.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+		}
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Str("key", key).Msg("Failed to open attachment")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+	}
+
+	c.Set("Content-Type", info.ContentType)
+	c.Set("Cache-Control", "private, max-age=3600")
+
+	seeker, seekable := reader.(io.ReadSeeker)
+	if !seekable {
+		return c.SendStream(reader, int(info.Size))
+	}
+	c.Set("Accept-Ranges", "bytes")
+
+	rangeHeader := c.Get("Range")
+	if rangeHeader == "" {
+		return c.SendStream(reader, int(info.Size))
+	}
+
+	start, end, err := parseByteRange(rangeHeader, info.Size)
+	if err != nil {
+		reader.Close()
+		c.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		return c.Status(http.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{"error": "Invalid range"})
+	}
+
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		reader.Close()
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+	}
+
+	length := end - start + 1
+	c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+	c.Status(http.StatusPartialContent)
+	return c.SendStream(struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, int(length))
+}
+
+// parseByteRange parses a single "bytes=" range (RFC 7233). Multi-range requests
+// are rejected since audio players never send them.
+func parseByteRange(header string, size int64) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") || size == 0 {
+		return 0, 0, errors.New("unsupported range")
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, errors.New("malformed range")
+	}
+
+	if startStr == "" {
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, errors.New("malformed range")
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, size - 1, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, errors.New("range out of bounds")
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errors.New("malformed range")
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, nil
+}
@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"cuan-backend/internal/handler"
+	"cuan-backend/internal/provider/storage"
+	"cuan-backend/internal/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubAttachmentRepository struct {
+	owners map[string]uint
+}
+
+func (r *stubAttachmentRepository) IsReferenced(refs []string) (bool, error) {
+	for _, ref := range refs {
+		if _, ok := r.owners[ref]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *stubAttachmentRepository) IsOwnedBy(userID uint, refs []string) (bool, error) {
+	for _, ref := range refs {
+		if owner, ok := r.owners[ref]; ok && owner == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *stubAttachmentRepository) ReferencedKeys() ([]string, error) {
+	var keys []string
+	for k := range r.owners {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func newTestAttachmentService(t *testing.T) service.AttachmentService {
+	t.Helper()
+	return service.NewAttachmentService(storage.NewLocalStore(t.TempDir()), storage.NewURLSigner("test-secret", time.Hour), &stubAttachmentRepository{})
+}
+
+func setupFileApp(t *testing.T) (*fiber.App, service.AttachmentService, *stubAttachmentRepository, string) {
+	t.Helper()
+	dir := t.TempDir()
+	repo := &stubAttachmentRepository{owners: map[string]uint{}}
+	attachments := service.NewAttachmentService(storage.NewLocalStore(dir), storage.NewURLSigner("test-secret", time.Hour), repo)
+
+	fileHandler := handler.NewFileHandler(attachments)
+	app := fiber.New()
+	app.Get("/api/files/*", fileHandler.ServeFile)
+	app.Get("/auth/files/*", mockAuthMiddleware(1), fileHandler.ServeFile)
+	return app, attachments, repo, dir
+}
+
+func TestServeFile_ValidSignature(t *testing.T) {
+	app, attachments, repo, _ := setupFileApp(t)
+
+	key, err := attachments.SaveBytes(1, "struk.txt", "text/plain", []byte("hello"))
+	assert.NoError(t, err)
+	repo.owners[key] = 1
+
+	req := httptest.NewRequest("GET", attachments.SignedURL(1, key), nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestServeFile_LegacyUploadPath(t *testing.T) {
+	app, attachments, repo, dir := setupFileApp(t)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "images"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "images", "old.jpg"), []byte("jpeg"), 0644))
+	repo.owners["/uploads/images/old.jpg"] = 1
+
+	url := attachments.SignedURL(1, "/uploads/images/old.jpg")
+	assert.Contains(t, url, "/api/files/images/old.jpg?")
+
+	resp, _ := app.Test(httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServeFile_TamperedSignature(t *testing.T) {
+	app, attachments, repo, _ := setupFileApp(t)
+
+	key, err := attachments.SaveBytes(1, "struk.txt", "text/plain", []byte("hello"))
+	assert.NoError(t, err)
+	repo.owners[key] = 1
+
+	// Signature for user 1 must not be reusable by user 2
+	url := strings.Replace(attachments.SignedURL(1, key), "uid=1", "uid=2", 1)
+	resp, _ := app.Test(httptest.NewRequest("GET", url, nil))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/api/files/"+key, nil))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestServeFile_OtherUsersFile(t *testing.T) {
+	app, attachments, repo, _ := setupFileApp(t)
+
+	key, err := attachments.SaveBytes(2, "wa_image.jpg", "image/jpeg", []byte("jpeg"))
+	assert.NoError(t, err)
+	repo.owners[key] = 2
+
+	// A valid signature for user 1 does not grant access to user 2's receipt
+	resp, _ := app.Test(httptest.NewRequest("GET", attachments.SignedURL(1, key), nil))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/auth/files/"+key, nil))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServeFile_BearerAuth(t *testing.T) {
+	app, attachments, repo, _ := setupFileApp(t)
+
+	key, err := attachments.SaveBytes(1, "struk.txt", "text/plain", []byte("hello"))
+	assert.NoError(t, err)
+	repo.owners[key] = 1
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/auth/files/"+key, nil))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServeFile_RangeRequest(t *testing.T) {
+	app, attachments, repo, _ := setupFileApp(t)
+
+	key, err := attachments.SaveBytes(1, "voice.ogg", "audio/ogg", []byte("0123456789"))
+	assert.NoError(t, err)
+	repo.owners[key] = 1
+	url := attachments.SignedURL(1, key)
+
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set("Range", "bytes=2-5")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 2-5/10", resp.Header.Get("Content-Range"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "2345", string(body))
+
+	req = httptest.NewRequest("GET", url, nil)
+	req.Header.Set("Range", "bytes=-3")
+	resp, _ = app.Test(req)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "789", string(body))
+
+	req = httptest.NewRequest("GET", url, nil)
+	req.Header.Set("Range", "bytes=20-")
+	resp, _ = app.Test(req)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	assert.Equal(t, "bytes */10", resp.Header.Get("Content-Range"))
+}
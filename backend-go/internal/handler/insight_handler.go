@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"cuan-backend/internal/service"
+	"cuan-backend/pkg/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type InsightHandler interface {
+	GetFeed(c *fiber.Ctx) error
+	MarkRead(c *fiber.Ctx) error
+	MarkAllRead(c *fiber.Ctx) error
+}
+
+type insightHandler struct {
+	service service.InsightService
+}
+
+func NewInsightHandler(service service.InsightService) InsightHandler {
+	return &insightHandler{service}
+}
+
+// GetFeed godoc
+// @Summary Get insight feed
+// @Description Get the insights generated after each billing cycle, newest first, with the unread count
+// @Tags insights
+// @Produce json
+// @Param unread query bool false "Only unread insights"
+// @Param limit query int false "Number of insights to return (default 50)"
+// @Success 200 {object} entity.InsightFeed
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/insights [get]
+func (h *insightHandler) GetFeed(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	feed, err := h.service.GetFeed(userID, c.QueryBool("unread"), limit)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(feed)
+}
+
+// MarkRead godoc
+// @Summary Mark an insight as read
+// @Tags insights
+// @Produce json
+// @Param id path int true "Insight ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/insights/{id}/read [put]
+func (h *insightHandler) MarkRead(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	err = h.service.MarkRead(uint(id), userID)
+	if errors.Is(err, service.ErrInsightNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Insight ditandai sudah dibaca"})
+}
+
+// MarkAllRead godoc
+// @Summary Mark all insights as read
+// @Tags insights
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/insights/read-all [put]
+func (h *insightHandler) MarkAllRead(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.service.MarkAllRead(userID); err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Semua insight ditandai sudah dibaca"})
+}
@@ -0,0 +1,86 @@
+package handler_test
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/handler"
+	"cuan-backend/internal/service"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInsightService struct {
+	mock.Mock
+}
+
+func (m *MockInsightService) GetFeed(userID uint, unreadOnly bool, limit int) (*entity.InsightFeed, error) {
+	args := m.Called(userID, unreadOnly, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.InsightFeed), args.Error(1)
+}
+
+func (m *MockInsightService) MarkRead(id uint, userID uint) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockInsightService) MarkAllRead(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockInsightService) Generate(userID uint, now time.Time) ([]entity.Insight, error) {
+	args := m.Called(userID, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.Insight), args.Error(1)
+}
+
+func (m *MockInsightService) RunDue(now time.Time) {
+	m.Called(now)
+}
+
+func TestGetInsightFeed_Handler(t *testing.T) {
+	mockService := new(MockInsightService)
+	h := handler.NewInsightHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/api/insights", mockAuthMiddleware(1), h.GetFeed)
+
+	mockService.On("GetFeed", uint(1), true, 10).Return(&entity.InsightFeed{
+		Insights: []entity.Insight{{ID: 3, UserID: 1, Kind: entity.InsightSavingsRate, Message: "Rasio tabungan naik"}},
+		Unread:   1,
+	}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/insights?unread=true&limit=10", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var feed entity.InsightFeed
+	_ = json.NewDecoder(resp.Body).Decode(&feed)
+	assert.Equal(t, int64(1), feed.Unread)
+	assert.Equal(t, "Rasio tabungan naik", feed.Insights[0].Message)
+	mockService.AssertExpectations(t)
+}
+
+func TestMarkInsightRead_Handler_NotFound(t *testing.T) {
+	mockService := new(MockInsightService)
+	h := handler.NewInsightHandler(mockService)
+
+	app := fiber.New()
+	app.Put("/api/insights/:id/read", mockAuthMiddleware(1), h.MarkRead)
+
+	mockService.On("MarkRead", uint(7), uint(1)).Return(service.ErrInsightNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("PUT", "/api/insights/7/read", nil))
+
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"cuan-backend/internal/service"
+	"cuan-backend/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type ReportScheduleHandler interface {
+	GetSchedule(c *fiber.Ctx) error
+	UpdateSchedule(c *fiber.Ctx) error
+	PreviewDigest(c *fiber.Ctx) error
+	SendDigest(c *fiber.Ctx) error
+}
+
+type reportScheduleHandler struct {
+	service service.ReportDigestService
+}
+
+func NewReportScheduleHandler(service service.ReportDigestService) ReportScheduleHandler {
+	return &reportScheduleHandler{service}
+}
+
+// GetSchedule godoc
+// @Summary Get report digest schedule
+// @Description Get the weekly/payday digest schedule and delivery channels of the logged in user
+// @Tags report-schedule
+// @Produce json
+// @Success 200 {object} entity.ReportSchedule
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/report-schedule [get]
+func (h *reportScheduleHandler) GetSchedule(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	schedule, err := h.service.GetSchedule(userID)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(schedule)
+}
+
+// UpdateSchedule godoc
+// @Summary Update report digest schedule
+// @Description Opt in or out of the weekly (Monday) and payday digest and choose WhatsApp/email delivery
+// @Tags report-schedule
+// @Accept json
+// @Produce json
+// @Param schedule body service.UpdateReportScheduleInput true "Schedule Input"
+// @Success 200 {object} entity.ReportSchedule
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/report-schedule [put]
+func (h *reportScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var input service.UpdateReportScheduleInput
+	if err := c.BodyParser(&input); err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Warn().Str("request_id", reqID).Err(err).Msg("Invalid request body payload")
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	schedule, err := h.service.UpdateSchedule(userID, input)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(schedule)
+}
+
+// PreviewDigest godoc
+// @Summary Preview report digest
+// @Description Build the digest (top categories, budget status, upcoming debts) without sending it
+// @Tags report-schedule
+// @Produce json
+// @Success 200 {object} entity.ReportDigest
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/report-schedule/preview [get]
+func (h *reportScheduleHandler) PreviewDigest(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	digest, err := h.service.BuildDigest(userID, time.Now())
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(digest)
+}
+
+// SendDigest godoc
+// @Summary Send report digest now
+// @Description Immediately deliver the digest through the configured channels. Succeeds when at least one channel delivered; "channels" lists the status of each channel
+// @Tags report-schedule
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/report-schedule/send [post]
+func (h *reportScheduleHandler) SendDigest(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	deliveries, err := h.service.SendDigest(userID)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Failed to send digest")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error(), "channels": deliveries})
+	}
+
+	message := "Digest berhasil dikirim"
+	for _, d := range deliveries {
+		if !d.Sent {
+			message = "Digest terkirim sebagian"
+		}
+	}
+	return c.JSON(fiber.Map{"message": message, "channels": deliveries})
+}
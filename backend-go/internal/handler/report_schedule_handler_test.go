@@ -0,0 +1,136 @@
+package handler_test
+
+import (
+	"bytes"
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/handler"
+	"cuan-backend/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReportDigestService struct {
+	mock.Mock
+}
+
+func (m *MockReportDigestService) GetSchedule(userID uint) (*entity.ReportSchedule, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.ReportSchedule), args.Error(1)
+}
+
+func (m *MockReportDigestService) UpdateSchedule(userID uint, input service.UpdateReportScheduleInput) (*entity.ReportSchedule, error) {
+	args := m.Called(userID, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.ReportSchedule), args.Error(1)
+}
+
+func (m *MockReportDigestService) BuildDigest(userID uint, now time.Time) (*entity.ReportDigest, error) {
+	args := m.Called(userID, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.ReportDigest), args.Error(1)
+}
+
+func (m *MockReportDigestService) SendDigest(userID uint) ([]entity.DigestDelivery, error) {
+	args := m.Called(userID)
+	deliveries, _ := args.Get(0).([]entity.DigestDelivery)
+	return deliveries, args.Error(1)
+}
+
+func (m *MockReportDigestService) RunDue(now time.Time) {
+	m.Called(now)
+}
+
+func TestGetReportSchedule_Handler(t *testing.T) {
+	mockService := new(MockReportDigestService)
+	h := handler.NewReportScheduleHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/api/report-schedule", mockAuthMiddleware(1), h.GetSchedule)
+
+	mockService.On("GetSchedule", uint(1)).Return(&entity.ReportSchedule{UserID: 1, Weekly: true}, nil)
+
+	req := httptest.NewRequest("GET", "/api/report-schedule", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var got entity.ReportSchedule
+	json.NewDecoder(resp.Body).Decode(&got)
+	assert.True(t, got.Weekly)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateReportSchedule_Handler(t *testing.T) {
+	mockService := new(MockReportDigestService)
+	h := handler.NewReportScheduleHandler(mockService)
+
+	app := fiber.New()
+	app.Put("/api/report-schedule", mockAuthMiddleware(1), h.UpdateSchedule)
+
+	input := service.UpdateReportScheduleInput{Weekly: true, OnPayday: true, ViaWhatsApp: true}
+	mockService.On("UpdateSchedule", uint(1), input).Return(&entity.ReportSchedule{UserID: 1, Weekly: true, OnPayday: true, ViaWhatsApp: true}, nil)
+
+	body, _ := json.Marshal(input)
+	req := httptest.NewRequest("PUT", "/api/report-schedule", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestSendDigest_Handler_Error(t *testing.T) {
+	mockService := new(MockReportDigestService)
+	h := handler.NewReportScheduleHandler(mockService)
+
+	app := fiber.New()
+	app.Post("/api/report-schedule/send", mockAuthMiddleware(1), h.SendDigest)
+
+	mockService.On("SendDigest", uint(1)).Return(nil, errors.New("nomor WhatsApp belum diisi di profil"))
+
+	req := httptest.NewRequest("POST", "/api/report-schedule/send", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+func TestSendDigest_Handler_PartialDelivery(t *testing.T) {
+	mockService := new(MockReportDigestService)
+	h := handler.NewReportScheduleHandler(mockService)
+
+	app := fiber.New()
+	app.Post("/api/report-schedule/send", mockAuthMiddleware(1), h.SendDigest)
+
+	mockService.On("SendDigest", uint(1)).Return([]entity.DigestDelivery{
+		{Channel: "whatsapp", Sent: true},
+		{Channel: "email", Error: "SMTP belum dikonfigurasi"},
+	}, nil)
+
+	req := httptest.NewRequest("POST", "/api/report-schedule/send", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var got struct {
+		Message  string                  `json:"message"`
+		Channels []entity.DigestDelivery `json:"channels"`
+	}
+	json.NewDecoder(resp.Body).Decode(&got)
+	assert.Equal(t, "Digest terkirim sebagian", got.Message)
+	assert.Len(t, got.Channels, 2)
+	mockService.AssertExpectations(t)
+}
@@ -72,7 +72,7 @@ func (h *SavingGoalHandler) CreateGoal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	goal, err := h.service.CreateGoal(userID, input)
+	goal, err := h.service.WithContext(c.UserContext()).CreateGoal(userID, input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -118,7 +118,7 @@ func (h *SavingGoalHandler) AddContribution(c *fiber.Ctx) error {
 		input.Date = time.Now()
 	}
 
-	contribution, err := h.service.AddContribution(userID, uint(id), input)
+	contribution, err := h.service.WithContext(c.UserContext()).AddContribution(userID, uint(id), input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -160,7 +160,7 @@ func (h *SavingGoalHandler) UpdateGoal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	goal, err := h.service.UpdateGoal(userID, uint(id), input)
+	goal, err := h.service.WithContext(c.UserContext()).UpdateGoal(userID, uint(id), input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -190,7 +190,7 @@ func (h *SavingGoalHandler) DeleteGoal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid goal ID"})
 	}
 
-	if err := h.service.DeleteGoal(userID, uint(id)); err != nil {
+	if err := h.service.WithContext(c.UserContext()).DeleteGoal(userID, uint(id)); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -220,7 +220,7 @@ func (h *SavingGoalHandler) DeleteContribution(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid contribution ID"})
 	}
 
-	if err := h.service.DeleteContribution(userID, uint(contributionID)); err != nil {
+	if err := h.service.WithContext(c.UserContext()).DeleteContribution(userID, uint(contributionID)); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -249,7 +249,7 @@ func (h *SavingGoalHandler) FinishGoal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid goal ID"})
 	}
 
-	if err := h.service.FinishGoal(userID, uint(id)); err != nil {
+	if err := h.service.WithContext(c.UserContext()).FinishGoal(userID, uint(id)); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -1,6 +1,7 @@
 package handler_test
 
 import (
+	"context"
 	"bytes"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/handler"
@@ -22,6 +23,10 @@ type MockSavingGoalService struct {
 	mock.Mock
 }
 
+func (m *MockSavingGoalService) WithContext(ctx context.Context) service.SavingGoalService {
+	return m
+}
+
 func (m *MockSavingGoalService) CreateGoal(userID uint, input service.CreateGoalInput) (*entity.SavingGoal, error) {
 	args := m.Called(userID, input)
 	if args.Get(0) == nil {
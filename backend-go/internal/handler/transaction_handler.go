@@ -4,24 +4,13 @@ import (
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/service"
 	"cuan-backend/pkg/utils"
-	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
 
-	"fmt"
-	"image"
-	"image/jpeg"
-	_ "image/png"
-	"mime/multipart"
-	"os"
-	"path/filepath"
-	"strings"
 	"time"
-
-	"golang.org/x/image/draw"
 )
 
 type TransactionHandler interface {
@@ -39,11 +28,12 @@ type TransactionHandler interface {
 }
 
 type transactionHandler struct {
-	service service.TransactionService
+	service     service.TransactionService
+	attachments service.AttachmentService
 }
 
-func NewTransactionHandler(service service.TransactionService) TransactionHandler {
-	return &transactionHandler{service}
+func NewTransactionHandler(service service.TransactionService, attachments service.AttachmentService) TransactionHandler {
+	return &transactionHandler{service, attachments}
 }
 
 // CreateTransaction godoc
@@ -101,21 +91,22 @@ func (h *transactionHandler) CreateTransaction(c *fiber.Ctx) error {
 
 	fileHeader, err := c.FormFile("attachment")
 	if err == nil {
-		path, err := processAndSaveFile(fileHeader)
+		stored, err := h.attachments.SaveUpload(userID, fileHeader)
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process file: " + err.Error()})
 		}
-		input.Attachment = path
+		input.Attachment = stored.Key
 	}
 
-	transaction, err := h.service.CreateTransaction(userID, input)
+	transaction, err := h.service.WithContext(c.UserContext()).CreateTransaction(userID, input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	transaction.Attachment = h.attachments.SignedURL(userID, transaction.Attachment)
 
 	return c.Status(http.StatusCreated).JSON(transaction)
 }
@@ -181,6 +172,7 @@ func (h *transactionHandler) GetTransactions(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
+	h.attachments.SignTransactions(userID, transactions)
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -215,6 +207,7 @@ func (h *transactionHandler) GetTransaction(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Transaction not found"})
 	}
+	transaction.Attachment = h.attachments.SignedURL(userID, transaction.Attachment)
 
 	return c.JSON(transaction)
 }
@@ -280,21 +273,30 @@ func (h *transactionHandler) UpdateTransaction(c *fiber.Ctx) error {
 
 	fileHeader, err := c.FormFile("attachment")
 	if err == nil {
-		path, err := processAndSaveFile(fileHeader)
+		stored, err := h.attachments.SaveUpload(userID, fileHeader)
 		if err != nil {
 			reqID, _ := c.Locals("requestid").(string)
 			log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process file: " + err.Error()})
 		}
-		input.Attachment = path
+		input.Attachment = stored.Key
+	}
+
+	var previousAttachment string
+	if input.Attachment != "" {
+		if existing, err := h.service.GetTransaction(uint(id), userID); err == nil {
+			previousAttachment = existing.Attachment
+		}
 	}
 
-	transaction, err := h.service.UpdateTransaction(uint(id), userID, input)
+	transaction, err := h.service.WithContext(c.UserContext()).UpdateTransaction(uint(id), userID, input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	h.releaseAttachment(c, previousAttachment)
+	transaction.Attachment = h.attachments.SignedURL(userID, transaction.Attachment)
 
 	return c.JSON(transaction)
 }
@@ -317,12 +319,18 @@ func (h *transactionHandler) DeleteTransaction(c *fiber.Ctx) error {
 	}
 	id, _ := strconv.Atoi(c.Params("id"))
 
-	err = h.service.DeleteTransaction(uint(id), userID)
+	var attachment string
+	if existing, err := h.service.GetTransaction(uint(id), userID); err == nil {
+		attachment = existing.Attachment
+	}
+
+	err = h.service.WithContext(c.UserContext()).DeleteTransaction(uint(id), userID)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
+	h.releaseAttachment(c, attachment)
 
 	return c.JSON(fiber.Map{"message": "Transaction deleted"})
 }
@@ -352,7 +360,7 @@ func (h *transactionHandler) TransferTransaction(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	if err := h.service.TransferTransaction(userID, input); err != nil {
+	if err := h.service.WithContext(c.UserContext()).TransferTransaction(userID, input); err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -610,99 +618,14 @@ func (h *transactionHandler) WebhookReceiver(c *fiber.Ctx) error {
 	})
 }
 
-func processAndSaveFile(fileHeader *multipart.FileHeader) (string, error) {
-	contentType := fileHeader.Header.Get("Content-Type")
-
-	var subFolder string
-	isImage := false
-
-	if strings.HasPrefix(contentType, "image/") {
-		subFolder = "images"
-		isImage = true
-	} else if strings.HasPrefix(contentType, "audio/") {
-		subFolder = "audio"
-	} else {
-		subFolder = "files"
+// releaseAttachment drops a file that is no longer referenced. Failures are only
+// logged; the periodic GC sweep will retry.
+func (h *transactionHandler) releaseAttachment(c *fiber.Ctx, ref string) {
+	if ref == "" {
+		return
 	}
-
-	baseDir := "./uploads"
-	targetDir := filepath.Join(baseDir, subFolder)
-
-	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(targetDir, 0755); err != nil {
-			return "", fmt.Errorf("failed to create directory: %v", err)
-		}
-	}
-
-	ext := filepath.Ext(fileHeader.Filename)
-	if isImage {
-		ext = ".jpg"
-	}
-
-	filename := fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), strings.TrimSuffix(fileHeader.Filename, filepath.Ext(fileHeader.Filename)), ext)
-	outPath := filepath.Join(targetDir, filename)
-
-	if isImage {
-		file, err := fileHeader.Open()
-		if err != nil {
-			return "", err
-		}
-		defer file.Close()
-
-		img, _, err := image.Decode(file)
-		if err != nil {
-			return "", fmt.Errorf("failed to decode image: %v", err)
-		}
-
-		bounds := img.Bounds()
-		width := bounds.Dx()
-		height := bounds.Dy()
-		maxDim := 1600
-
-		var finalImg image.Image = img
-
-		if width > maxDim || height > maxDim {
-			var newW, newH int
-			if width > height {
-				newW = maxDim
-				newH = (height * maxDim) / width
-			} else {
-				newH = maxDim
-				newW = (width * maxDim) / height
-			}
-
-			dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
-			draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
-			finalImg = dst
-		}
-
-		outFile, err := os.Create(outPath)
-		if err != nil {
-			return "", err
-		}
-		defer outFile.Close()
-
-		if err := jpeg.Encode(outFile, finalImg, &jpeg.Options{Quality: 75}); err != nil {
-			return "", err
-		}
-
-	} else {
-		src, err := fileHeader.Open()
-		if err != nil {
-			return "", err
-		}
-		defer src.Close()
-
-		dst, err := os.Create(outPath)
-		if err != nil {
-			return "", err
-		}
-		defer dst.Close()
-
-		if _, err := io.Copy(dst, src); err != nil {
-			return "", err
-		}
+	if err := h.attachments.Release(ref); err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Warn().Str("request_id", reqID).Err(err).Str("attachment", ref).Msg("Failed to release attachment")
 	}
-
-	return fmt.Sprintf("/uploads/%s/%s", subFolder, filename), nil
 }
@@ -1,6 +1,7 @@
 package handler_test
 
 import (
+	"context"
 	"bytes"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/handler"
@@ -21,6 +22,10 @@ type MockTransactionService struct {
 	mock.Mock
 }
 
+func (m *MockTransactionService) WithContext(ctx context.Context) service.TransactionService {
+	return m
+}
+
 func (m *MockTransactionService) CreateTransaction(userID uint, input service.CreateTransactionInput) (*entity.Transaction, error) {
 	args := m.Called(userID, input)
 	if args.Get(0) == nil {
@@ -99,7 +104,7 @@ func (m *MockTransactionService) ExportReport(userID uint, startDate, endDate st
 
 func TestCreateTransaction(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Post("/api/transactions", mockAuthMiddleware(1), transactionHandler.CreateTransaction)
@@ -139,7 +144,7 @@ func TestCreateTransaction(t *testing.T) {
 
 func TestGetTransactions(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Get("/api/transactions", mockAuthMiddleware(1), transactionHandler.GetTransactions)
@@ -161,11 +166,12 @@ func TestGetTransactions(t *testing.T) {
 
 func TestDeleteTransaction(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Delete("/api/transactions/:id", mockAuthMiddleware(1), transactionHandler.DeleteTransaction)
 
+	mockService.On("GetTransaction", uint(1), uint(1)).Return(&entity.Transaction{ID: 1}, nil)
 	mockService.On("DeleteTransaction", uint(1), uint(1)).Return(nil)
 
 	req := httptest.NewRequest("DELETE", "/api/transactions/1", nil)
@@ -177,7 +183,7 @@ func TestDeleteTransaction(t *testing.T) {
 
 func TestTransferTransaction(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Post("/api/transactions/transfer", mockAuthMiddleware(1), transactionHandler.TransferTransaction)
@@ -204,7 +210,7 @@ func TestTransferTransaction(t *testing.T) {
 
 func TestGetCalendarData(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Get("/api/transactions/calendar", mockAuthMiddleware(1), transactionHandler.GetCalendarData)
@@ -224,7 +230,7 @@ func TestGetCalendarData(t *testing.T) {
 
 func TestGetReport(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Get("/transactions/report", mockAuthMiddleware(1), transactionHandler.GetReport)
@@ -244,7 +250,7 @@ func TestGetReport(t *testing.T) {
 
 func TestGetReport_WithFilters(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Get("/transactions/report", mockAuthMiddleware(1), transactionHandler.GetReport)
@@ -269,7 +275,7 @@ func TestGetReport_WithFilters(t *testing.T) {
 
 func TestUpdateTransaction(t *testing.T) {
 	mockService := new(MockTransactionService)
-	transactionHandler := handler.NewTransactionHandler(mockService)
+	transactionHandler := handler.NewTransactionHandler(mockService, newTestAttachmentService(t))
 
 	app := fiber.New()
 	app.Put("/api/transactions/:id", mockAuthMiddleware(1), transactionHandler.UpdateTransaction)
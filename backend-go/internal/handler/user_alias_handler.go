@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"cuan-backend/internal/service"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type UserAliasHandler struct {
+	aliasService service.UserAliasService
+}
+
+func NewUserAliasHandler(aliasService service.UserAliasService) *UserAliasHandler {
+	return &UserAliasHandler{aliasService}
+}
+
+// FindAll godoc
+// @Summary Get AI aliases
+// @Description Get the user's custom names for wallets and categories used by the AI chat
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Success 200 {object} []entity.UserAlias
+// @Security BearerAuth
+// @Router /api/ai/aliases [get]
+func (h *UserAliasHandler) FindAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	aliases, err := h.aliasService.List(userID)
+	if err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(aliases)
+}
+
+// Set godoc
+// @Summary Set an AI alias
+// @Description Create or replace a custom name (e.g. "jago") for a wallet or category name (e.g. "Jago Utama")
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Param alias body service.SetAliasRequest true "Alias"
+// @Success 200 {object} entity.UserAlias
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/ai/aliases [put]
+func (h *UserAliasHandler) Set(c *fiber.Ctx) error {
+	var req service.SetAliasRequest
+	if err := c.BodyParser(&req); err != nil {
+		reqID, _ := c.Locals("requestid").(string)
+		log.Warn().Str("request_id", reqID).Err(err).Msg("Invalid request body payload")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	userID := c.Locals("userID").(uint)
+
+	alias, err := h.aliasService.Set(userID, &req)
+	if errors.Is(err, service.ErrInvalidAlias) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(alias)
+}
+
+// Delete godoc
+// @Summary Delete an AI alias
+// @Description Delete a custom wallet or category name
+// @Tags ai
+// @Accept json
+// @Produce json
+// @Param id path int true "Alias ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/ai/aliases/{id} [delete]
+func (h *UserAliasHandler) Delete(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	userID := c.Locals("userID").(uint)
+
+	err = h.aliasService.Delete(uint(id), userID)
+	if errors.Is(err, service.ErrAliasNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Alias deleted successfully"})
+}
@@ -0,0 +1,104 @@
+package handler_test
+
+import (
+	"bytes"
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/handler"
+	"cuan-backend/internal/service"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserAliasService struct {
+	mock.Mock
+}
+
+func (m *MockUserAliasService) List(userID uint) ([]entity.UserAlias, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.UserAlias), args.Error(1)
+}
+
+func (m *MockUserAliasService) Set(userID uint, req *service.SetAliasRequest) (*entity.UserAlias, error) {
+	args := m.Called(userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.UserAlias), args.Error(1)
+}
+
+func (m *MockUserAliasService) Delete(id uint, userID uint) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func TestFindAllUserAlias_Handler(t *testing.T) {
+	mockService := new(MockUserAliasService)
+	h := handler.NewUserAliasHandler(mockService)
+
+	app := fiber.New()
+	app.Get("/api/ai/aliases", mockAuthMiddleware(1), h.FindAll)
+
+	mockService.On("List", uint(1)).Return([]entity.UserAlias{{ID: 1, UserID: 1, Alias: "jago", Target: "Jago Utama"}}, nil)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/ai/aliases", nil))
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var aliases []entity.UserAlias
+	_ = json.NewDecoder(resp.Body).Decode(&aliases)
+	assert.Equal(t, "Jago Utama", aliases[0].Target)
+	mockService.AssertExpectations(t)
+}
+
+func TestSetUserAlias_Handler(t *testing.T) {
+	mockService := new(MockUserAliasService)
+	h := handler.NewUserAliasHandler(mockService)
+
+	app := fiber.New()
+	app.Put("/api/ai/aliases", mockAuthMiddleware(1), h.Set)
+
+	mockService.On("Set", uint(1), mock.MatchedBy(func(req *service.SetAliasRequest) bool {
+		return req.Alias == "jago"
+	})).Return(&entity.UserAlias{ID: 1, UserID: 1, Alias: "jago", Target: "Jago Utama"}, nil)
+	mockService.On("Set", uint(1), mock.MatchedBy(func(req *service.SetAliasRequest) bool {
+		return req.Alias == "bca"
+	})).Return(nil, fmt.Errorf("%w: 'BCA' bukan nama dompet atau kategori kamu", service.ErrInvalidAlias))
+
+	body, _ := json.Marshal(service.SetAliasRequest{Alias: "jago", Target: "jago utama"})
+	req := httptest.NewRequest("PUT", "/api/ai/aliases", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, _ = json.Marshal(service.SetAliasRequest{Alias: "bca", Target: "BCA"})
+	req = httptest.NewRequest("PUT", "/api/ai/aliases", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteUserAlias_Handler_NotFound(t *testing.T) {
+	mockService := new(MockUserAliasService)
+	h := handler.NewUserAliasHandler(mockService)
+
+	app := fiber.New()
+	app.Delete("/api/ai/aliases/:id", mockAuthMiddleware(1), h.Delete)
+
+	mockService.On("Delete", uint(9), uint(1)).Return(service.ErrAliasNotFound)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/api/ai/aliases/9", nil))
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
@@ -54,7 +54,7 @@ func (h *walletHandler) CreateWallet(c *fiber.Ctx) error {
 
 	input.UserID = userID
 
-	wallet, err := h.walletService.CreateWallet(input)
+	wallet, err := h.walletService.WithContext(c.UserContext()).CreateWallet(input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -148,7 +148,7 @@ func (h *walletHandler) UpdateWallet(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	wallet, err := h.walletService.UpdateWallet(uint(id), userID, input)
+	wallet, err := h.walletService.WithContext(c.UserContext()).UpdateWallet(uint(id), userID, input)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -179,7 +179,7 @@ func (h *walletHandler) DeleteWallet(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	err = h.walletService.DeleteWallet(uint(id), userID)
+	err = h.walletService.WithContext(c.UserContext()).DeleteWallet(uint(id), userID)
 	if err != nil {
 		reqID, _ := c.Locals("requestid").(string)
 		log.Error().Str("request_id", reqID).Err(err).Msg("Internal server error")
@@ -1,6 +1,7 @@
 package handler_test
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -21,6 +22,10 @@ type MockWalletService struct {
 	mock.Mock
 }
 
+func (m *MockWalletService) WithContext(ctx context.Context) service.WalletService {
+	return m
+}
+
 func (m *MockWalletService) CreateWallet(input service.CreateWalletInput) (*entity.Wallet, error) {
 	args := m.Called(input)
 	if args.Get(0) == nil {
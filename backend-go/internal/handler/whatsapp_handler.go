@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"cuan-backend/internal/audit"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/service"
 	"encoding/hex"
@@ -60,8 +62,10 @@ func (h *whatsAppHandler) HandleWebhook(c *fiber.Ctx) error {
 	if event.Event != "message" {
 		return c.JSON(fiber.Map{"status": "ignored", "event": event.Event})
 	}
+	reqID, _ := c.Locals("requestid").(string)
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), audit.ActorWhatsApp), reqID)
 	go func() {
-		if err := h.waSvc.ProcessMessage(event); err != nil {
+		if err := h.waSvc.ProcessMessage(ctx, event); err != nil {
 			log.Error().Err(err).Msg("[WA] ProcessMessage gagal")
 		}
 	}()
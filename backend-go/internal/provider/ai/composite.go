@@ -0,0 +1,371 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoProviderAvailable is returned when every provider in the chain has an open circuit.
+var ErrNoProviderAvailable = errors.New("no AI provider available")
+
+// abortError ends the fallback chain early: the stream already reached the client, or the client
+// went away.
+type abortError struct {
+	err          error
+	backendFault bool
+}
+
+func (e *abortError) Error() string { return e.err.Error() }
+
+// Backend is one provider in a CompositeProvider chain. Timeout bounds each attempt; zero means
+// the provider's own HTTP client timeout applies.
+type Backend struct {
+	Name     string
+	Provider Provider
+	Timeout  time.Duration
+}
+
+// CompositeConfig tunes retries and the circuit breaker shared by every backend.
+type CompositeConfig struct {
+	// MaxRetries is the number of extra attempts on the same backend before falling back.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry; it doubles on every further retry up to
+	// MaxBackoff.
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	// FailureThreshold consecutive failures open a backend's circuit for OpenDuration. After
+	// that a single probe request is let through; it closes the circuit again on success.
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+func DefaultCompositeConfig() CompositeConfig {
+	return CompositeConfig{
+		MaxRetries:       1,
+		RetryBackoff:     500 * time.Millisecond,
+		MaxBackoff:       5 * time.Second,
+		FailureThreshold: 3,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// CompositeProvider tries its backends in order: each one gets a per-attempt timeout and a few
+// retries with exponential backoff, and a backend whose circuit is open is skipped until it
+// cools down. The first success wins.
+type CompositeProvider struct {
+	backends []*backendState
+	cfg      CompositeConfig
+	now      func() time.Time
+}
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half_open"
+)
+
+type backendState struct {
+	Backend
+
+	mu          sync.Mutex
+	state       circuitState
+	failures    int
+	probing     bool
+	openedAt    time.Time
+	lastFailure time.Time
+	lastSuccess time.Time
+}
+
+// ProviderHealth is the circuit breaker state of one backend.
+type ProviderHealth struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"` // closed, open or half_open
+	Available           bool       `json:"available"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	OpenUntil           *time.Time `json:"open_until,omitempty"`
+}
+
+// HealthReporter is implemented by providers that track the health of their backends.
+type HealthReporter interface {
+	Health() []ProviderHealth
+}
+
+func NewCompositeProvider(cfg CompositeConfig, backends ...Backend) *CompositeProvider {
+	states := make([]*backendState, len(backends))
+	for i, b := range backends {
+		states[i] = newBackendState(b)
+	}
+	return newCompositeProvider(cfg, states)
+}
+
+// newCompositeProvider builds a chain over existing backend states, so several chains (one per
+// task in a Router) share each backend's circuit breaker.
+func newCompositeProvider(cfg CompositeConfig, states []*backendState) *CompositeProvider {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 1
+	}
+	if cfg.MaxBackoff < cfg.RetryBackoff {
+		cfg.MaxBackoff = cfg.RetryBackoff
+	}
+	return &CompositeProvider{backends: states, cfg: cfg, now: time.Now}
+}
+
+func newBackendState(b Backend) *backendState {
+	return &backendState{Backend: b, state: circuitClosed}
+}
+
+func (p *CompositeProvider) GenerateCompletion(ctx context.Context, req AIRequest) (string, error) {
+	var content string
+	err := p.do(ctx, func(ctx context.Context, provider Provider) error {
+		var err error
+		content, err = provider.GenerateCompletion(ctx, req)
+		return err
+	})
+	return content, err
+}
+
+func (p *CompositeProvider) GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	var resp *AIResponse
+	err := p.do(ctx, func(ctx context.Context, provider Provider) error {
+		var err error
+		resp, err = provider.GenerateWithTools(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// StreamWithTools falls back like GenerateWithTools, but only until the first token has been
+// passed on: after that a retry would repeat text the client already shows.
+func (p *CompositeProvider) StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error) {
+	var resp *AIResponse
+	err := p.do(ctx, func(ctx context.Context, provider Provider) error {
+		streamed := false
+		var clientErr error
+		var err error
+		resp, err = provider.StreamWithTools(ctx, req, func(token string) error {
+			streamed = true
+			if err := onToken(token); err != nil {
+				clientErr = err
+				return err
+			}
+			return nil
+		})
+		switch {
+		case clientErr != nil:
+			return &abortError{err: clientErr}
+		case err != nil && streamed:
+			return &abortError{err: err, backendFault: true}
+		}
+		return err
+	})
+	return resp, err
+}
+
+func (p *CompositeProvider) do(ctx context.Context, call func(context.Context, Provider) error) error {
+	reqID, _ := ctx.Value("request_id").(string)
+	var errs []string
+	for _, b := range p.backends {
+		for attempt := 0; attempt <= p.cfg.MaxRetries; attempt++ {
+			if attempt > 0 {
+				if err := sleepContext(ctx, p.backoff(attempt)); err != nil {
+					return err
+				}
+			}
+			if !b.allow(p.now(), p.cfg.OpenDuration) {
+				log.Warn().Str("request_id", reqID).Str("provider", b.Name).Msg("[AI] Circuit open, skipping provider")
+				break
+			}
+
+			err := p.attempt(ctx, b, call)
+			if err == nil {
+				b.succeed(p.now())
+				if meter := usageMeterFrom(ctx); meter != nil {
+					meter.setProvider(b.Name)
+				}
+				return nil
+			}
+			if ctx.Err() != nil {
+				// The caller gave up (client disconnected); that says nothing about the backend.
+				b.release()
+				return ctx.Err()
+			}
+
+			var abort *abortError
+			if errors.As(err, &abort) {
+				if abort.backendFault {
+					b.fail(p.now(), p.cfg.FailureThreshold)
+				} else {
+					b.release()
+				}
+				return abort.err
+			}
+
+			retryable, countsAsFailure := classifyError(err)
+			if countsAsFailure {
+				b.fail(p.now(), p.cfg.FailureThreshold)
+			} else {
+				b.release()
+			}
+			log.Warn().Err(err).Str("request_id", reqID).Str("provider", b.Name).Int("attempt", attempt+1).Bool("retryable", retryable).Msg("[AI] Provider request failed")
+			errs = append(errs, fmt.Sprintf("%s: %v", b.Name, err))
+			if !retryable {
+				break
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return ErrNoProviderAvailable
+	}
+	return fmt.Errorf("all AI providers failed: %s", strings.Join(errs, "; "))
+}
+
+func (p *CompositeProvider) attempt(ctx context.Context, b *backendState, call func(context.Context, Provider) error) error {
+	if b.Timeout <= 0 {
+		return call(ctx, b.Provider)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, b.Timeout)
+	defer cancel()
+	return call(attemptCtx, b.Provider)
+}
+
+func (p *CompositeProvider) backoff(attempt int) time.Duration {
+	d := p.cfg.RetryBackoff
+	for i := 1; i < attempt && d < p.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.cfg.MaxBackoff)
+}
+
+// classifyError decides whether to retry the same backend and whether the error says the backend
+// is unhealthy. A request the API rejects as malformed (400, 413, 422) is not retried and does not
+// trip the breaker, but the next backend may still accept it.
+func classifyError(err error) (retryable bool, countsAsFailure bool) {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		// Network errors, timeouts and unreadable responses.
+		return true, true
+	}
+	switch code := statusErr.StatusCode; {
+	case code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500:
+		return true, true
+	case code == http.StatusBadRequest || code == http.StatusRequestEntityTooLarge || code == http.StatusUnprocessableEntity:
+		return false, false
+	default:
+		// 401/403/404: misconfigured backend, retrying will not help.
+		return false, true
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Health reports every backend in chain order.
+func (p *CompositeProvider) Health() []ProviderHealth {
+	now := p.now()
+	out := make([]ProviderHealth, len(p.backends))
+	for i, b := range p.backends {
+		out[i] = b.health(now, p.cfg.OpenDuration)
+	}
+	return out
+}
+
+// allow reports whether a request may be sent. An open circuit turns half-open after
+// openDuration and then lets exactly one probe through.
+func (b *backendState) allow(now time.Time, openDuration time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < openDuration {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *backendState) succeed(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != circuitClosed {
+		log.Info().Str("provider", b.Name).Msg("[AI] Circuit closed")
+	}
+	b.state = circuitClosed
+	b.failures = 0
+	b.probing = false
+	b.lastSuccess = now
+}
+
+func (b *backendState) fail(now time.Time, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	b.lastFailure = now
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= threshold) {
+		log.Warn().Str("provider", b.Name).Int("failures", b.failures).Msg("[AI] Circuit opened")
+		b.state = circuitOpen
+		b.openedAt = now
+	}
+}
+
+// release ends a half-open probe without a verdict, so the next request can probe again.
+func (b *backendState) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *backendState) health(now time.Time, openDuration time.Duration) ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := ProviderHealth{
+		Name:                b.Name,
+		State:               string(b.state),
+		Available:           b.state == circuitClosed || (b.state == circuitHalfOpen && !b.probing),
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == circuitOpen {
+		until := b.openedAt.Add(openDuration)
+		h.OpenUntil = &until
+		h.Available = !now.Before(until)
+	}
+	if !b.lastFailure.IsZero() {
+		t := b.lastFailure
+		h.LastFailureAt = &t
+	}
+	if !b.lastSuccess.IsZero() {
+		t := b.lastSuccess
+		h.LastSuccessAt = &t
+	}
+	return h
+}
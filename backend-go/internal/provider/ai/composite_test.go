@@ -0,0 +1,172 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// llmStub is an OpenAI-compatible chat endpoint that answers with status codes from script, one
+// per request; after the script runs out it keeps answering 200.
+func llmStub(t *testing.T, reply string, script ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(script) && script[n-1] != http.StatusOK {
+			w.WriteHeader(script[n-1])
+			fmt.Fprint(w, `{"error":"stub"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%q}}]}`, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// hangingServer never answers until the test ends.
+func hangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+	return srv
+}
+
+func testCompositeConfig() CompositeConfig {
+	return CompositeConfig{
+		MaxRetries:       1,
+		RetryBackoff:     time.Millisecond,
+		MaxBackoff:       4 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenDuration:     time.Minute,
+	}
+}
+
+func TestCompositeProvider_RetriesThenSucceeds(t *testing.T) {
+	local, localCalls := llmStub(t, "dari lokal", http.StatusServiceUnavailable)
+	external, externalCalls := llmStub(t, "dari eksternal")
+
+	p := NewCompositeProvider(testCompositeConfig(),
+		Backend{Name: "local", Provider: NewLocalProvider(local.URL)},
+		Backend{Name: "external", Provider: NewExternalProvider(external.URL, "key", "model")},
+	)
+
+	content, err := p.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, "dari lokal", content)
+	assert.Equal(t, int32(2), localCalls.Load())
+	assert.Equal(t, int32(0), externalCalls.Load())
+	assert.Equal(t, "closed", p.Health()[0].State)
+	assert.Zero(t, p.Health()[0].ConsecutiveFailures)
+}
+
+func TestCompositeProvider_FallsBackAndOpensCircuit(t *testing.T) {
+	local, localCalls := llmStub(t, "", 500, 500, 500, 500)
+	external, externalCalls := llmStub(t, "dari eksternal")
+
+	p := NewCompositeProvider(testCompositeConfig(),
+		Backend{Name: "local", Provider: NewLocalProvider(local.URL)},
+		Backend{Name: "external", Provider: NewExternalProvider(external.URL, "key", "model")},
+	)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	resp, err := p.GenerateWithTools(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, "dari eksternal", resp.Content)
+	assert.Equal(t, int32(2), localCalls.Load())
+
+	health := p.Health()
+	assert.Equal(t, "open", health[0].State)
+	assert.False(t, health[0].Available)
+	require.NotNil(t, health[0].OpenUntil)
+	assert.True(t, health[1].Available)
+
+	// While open, local is skipped entirely.
+	_, err = p.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), localCalls.Load())
+	assert.Equal(t, int32(2), externalCalls.Load())
+
+	// After the cooldown one probe is let through; its failure reopens the circuit.
+	now = now.Add(time.Minute)
+	_, err = p.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), localCalls.Load())
+	assert.Equal(t, "open", p.Health()[0].State)
+
+	// The next probe succeeds and closes it.
+	now = now.Add(time.Minute)
+	localCalls.Store(4)
+	content, err := p.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, "", content)
+	assert.Equal(t, "closed", p.Health()[0].State)
+	assert.Equal(t, int32(3), externalCalls.Load())
+}
+
+func TestCompositeProvider_DoesNotRetryBadRequest(t *testing.T) {
+	local, localCalls := llmStub(t, "", http.StatusBadRequest)
+	external, _ := llmStub(t, "dari eksternal")
+
+	p := NewCompositeProvider(testCompositeConfig(),
+		Backend{Name: "local", Provider: NewLocalProvider(local.URL)},
+		Backend{Name: "external", Provider: NewExternalProvider(external.URL, "key", "model")},
+	)
+
+	content, err := p.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, "dari eksternal", content)
+	assert.Equal(t, int32(1), localCalls.Load())
+	assert.Zero(t, p.Health()[0].ConsecutiveFailures)
+}
+
+func TestCompositeProvider_TimeoutAndAllFailed(t *testing.T) {
+	slow := hangingServer(t)
+	down, _ := llmStub(t, "", 502, 502)
+
+	p := NewCompositeProvider(testCompositeConfig(),
+		Backend{Name: "local", Provider: NewLocalProvider(slow.URL), Timeout: 20 * time.Millisecond},
+		Backend{Name: "external", Provider: NewExternalProvider(down.URL, "key", "model")},
+	)
+
+	start := time.Now()
+	_, err := p.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Contains(t, err.Error(), "local:")
+	assert.Contains(t, err.Error(), "external:")
+
+	// Both circuits are open now.
+	_, err = p.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	assert.True(t, errors.Is(err, ErrNoProviderAvailable))
+}
+
+func TestCompositeProvider_CallerCancelDoesNotTripBreaker(t *testing.T) {
+	slow := hangingServer(t)
+
+	p := NewCompositeProvider(testCompositeConfig(), Backend{Name: "local", Provider: NewLocalProvider(slow.URL)})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := p.GenerateCompletion(ctx, AIRequest{Prompt: "halo"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, p.Health()[0].ConsecutiveFailures)
+	assert.Equal(t, "closed", p.Health()[0].State)
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects meaning. Model identifies the
+// vector space: vectors from different models must not be compared.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+}
+
+// HTTPEmbedder calls an OpenAI-compatible /v1/embeddings endpoint, e.g. llama.cpp started with
+// --embedding or Ollama.
+type HTTPEmbedder struct {
+	url    string
+	apiKey string
+	model  string
+}
+
+func NewHTTPEmbedder(url, apiKey, model string) *HTTPEmbedder {
+	return &HTTPEmbedder{url: url, apiKey: apiKey, model: model}
+}
+
+func (e *HTTPEmbedder) Model() string {
+	if e.model == "" {
+		return "default"
+	}
+	return e.model
+}
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	payload := map[string]interface{}{"input": texts}
+	if e.model != "" {
+		payload["model"] = e.model
+	}
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("[Embedding] failed to marshal payload: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("[Embedding] failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	reqID, _ := ctx.Value("request_id").(string)
+	log.Debug().Str("request_id", reqID).Int("texts", len(texts)).Msg("[Embedding] Sending request")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("[Embedding] request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{Prefix: "[Embedding]", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("[Embedding] failed to decode response: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("[Embedding] response index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("[Embedding] no embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// HashEmbedder is a deterministic bag-of-words embedder: every lowercased word is hashed into one
+// of dim buckets. Texts sharing words are similar; it has no notion of synonyms. It needs no
+// model server, which makes it the embedder for tests.
+type HashEmbedder struct {
+	dim int
+}
+
+func NewHashEmbedder(dim int) *HashEmbedder {
+	return &HashEmbedder{dim: dim}
+}
+
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.dim)
+}
+
+func (e *HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, e.dim)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range words {
+			h := fnv.New32a()
+			h.Write([]byte(w))
+			v[h.Sum32()%uint32(e.dim)]++
+		}
+		var norm float64
+		for _, x := range v {
+			norm += float64(x * x)
+		}
+		if norm > 0 {
+			scale := float32(1 / math.Sqrt(norm))
+			for j := range v {
+				v[j] *= scale
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
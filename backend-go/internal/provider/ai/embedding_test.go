@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPEmbedder_Embed(t *testing.T) {
+	var got struct {
+		Input []string `json:"input"`
+		Model string   `json:"model"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer rahasia", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		// Urutan data tidak harus sama dengan urutan input.
+		fmt.Fprint(w, `{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	e := NewHTTPEmbedder(srv.URL, "rahasia", "nomic-embed-text")
+	vectors, err := e.Embed(context.Background(), []string{"kopi", "bensin"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+	assert.Equal(t, []string{"kopi", "bensin"}, got.Input)
+	assert.Equal(t, "nomic-embed-text", got.Model)
+	assert.Equal(t, "nomic-embed-text", e.Model())
+}
+
+func TestHTTPEmbedder_MissingEmbedding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1,0]}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := NewHTTPEmbedder(srv.URL, "", "").Embed(context.Background(), []string{"kopi", "bensin"})
+	assert.ErrorContains(t, err, "no embedding for input 1")
+}
+
+func TestHashEmbedder(t *testing.T) {
+	e := NewHashEmbedder(256)
+	vectors, err := e.Embed(context.Background(), []string{"Kopi susu", "kopi susu", "bensin motor", ""})
+	require.NoError(t, err)
+
+	assert.Equal(t, vectors[0], vectors[1])
+	var dot, norm float64
+	for i := range vectors[0] {
+		dot += float64(vectors[0][i] * vectors[2][i])
+		norm += float64(vectors[0][i] * vectors[0][i])
+	}
+	assert.InDelta(t, 1, math.Sqrt(norm), 1e-6)
+	assert.Zero(t, dot)
+	assert.Len(t, vectors[3], 256)
+	assert.Equal(t, "hash-256", e.Model())
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -23,14 +24,82 @@ func NewExternalProvider(url, apiKey, model string) *ExternalProvider {
 }
 
 func (p *ExternalProvider) GenerateCompletion(ctx context.Context, req AIRequest) (string, error) {
+	result, err := p.post(ctx, buildExternalPayload(req))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+// GenerateWithTools uses the API's native OpenAI-style "tools" field.
+func (p *ExternalProvider) GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	payload := buildExternalPayload(req)
+	payload.Messages = appendNativeToolTurns(payload.Messages, req.ToolTurns)
+	payload.Tools = wireTools(req.Tools)
+
+	result, err := p.post(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	message := result.Choices[0].Message
+	calls, err := fromWireToolCalls(message.ToolCalls)
+	if err != nil {
+		return nil, fmt.Errorf("[External AI] %w", err)
+	}
+	return &AIResponse{Content: strings.TrimSpace(message.Content), ToolCalls: calls}, nil
+}
+
+// StreamWithTools is GenerateWithTools with "stream": true. Content deltas reach onToken as the
+// model writes them; tool call fragments are merged and returned once the stream ends.
+func (p *ExternalProvider) StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error) {
+	payload := buildExternalPayload(req)
+	payload.Messages = appendNativeToolTurns(payload.Messages, req.ToolTurns)
+	payload.Tools = wireTools(req.Tools)
+	payload.Stream = true
+	payload.StreamOptions = &streamOptions{IncludeUsage: true}
+
+	body, err := p.send(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	result, err := readStream(body, "[External AI]", onToken)
+	if err != nil {
+		return nil, err
+	}
+	recordWireUsage(ctx, result.usage)
+	calls, err := fromWireToolCalls(result.toolCalls())
+	if err != nil {
+		return nil, fmt.Errorf("[External AI] %w", err)
+	}
+	return &AIResponse{Content: strings.TrimSpace(result.content.String()), ToolCalls: calls}, nil
+}
+
+func (p *ExternalProvider) post(ctx context.Context, payload completionPayload) (*completionResponse, error) {
+	body, err := p.send(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	result, err := decodeCompletion(body, "[External AI]")
+	if err != nil {
+		return nil, err
+	}
+	recordWireUsage(ctx, result.Usage)
+	return result, nil
+}
+
+// send posts the payload and returns the response body of a 200 answer; the caller closes it.
+func (p *ExternalProvider) send(ctx context.Context, payload completionPayload) (io.ReadCloser, error) {
 	reqID, _ := ctx.Value("request_id").(string)
 	log.Info().Str("request_id", reqID).Str("url", p.url).Str("model", p.model).Msg("[External AI] Sending request")
 
-	payload := buildExternalPayload(req)
 	payload.Model = p.model
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("[External AI] failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("[External AI] failed to marshal payload: %w", err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(
@@ -40,7 +109,7 @@ func (p *ExternalProvider) GenerateCompletion(ctx context.Context, req AIRequest
 		bytes.NewBuffer(jsonPayload),
 	)
 	if err != nil {
-		return "", fmt.Errorf("[External AI] failed to create request: %w", err)
+		return nil, fmt.Errorf("[External AI] failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
@@ -48,16 +117,15 @@ func (p *ExternalProvider) GenerateCompletion(ctx context.Context, req AIRequest
 	client := &http.Client{Timeout: 60 * time.Second}
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("[External AI] request failed: %w", err)
+		return nil, fmt.Errorf("[External AI] request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("[External AI] returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{Prefix: "[External AI]", StatusCode: resp.StatusCode, Body: string(body)}
 	}
-
-	return decodeCompletion(resp.Body, "[External AI]")
+	return resp.Body, nil
 }
 
 func buildExternalPayload(req AIRequest) completionPayload {
@@ -82,6 +150,10 @@ func buildExternalPayload(req AIRequest) completionPayload {
 			Content: "Baik, saya mengerti dan akan mengikuti instruksi tersebut.",
 		})
 	}
+	for _, turn := range req.History {
+		messages = append(messages, localChatMessage{Role: turn.Role, Content: turn.Content})
+	}
+
 	messages = append(messages, localChatMessage{Role: "user", Content: userContent})
 
 	return completionPayload{
@@ -91,4 +163,3 @@ func buildExternalPayload(req AIRequest) completionPayload {
 		Stream:      false,
 	}
 }
-
@@ -26,13 +26,91 @@ func (p *LocalProvider) GetURL() string {
 }
 
 func (p *LocalProvider) GenerateCompletion(ctx context.Context, req AIRequest) (string, error) {
+	result, err := p.post(ctx, buildChatPayload(req))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+// GenerateWithTools does not rely on the model's chat template supporting tools. The tools are
+// described in the system prompt and the reply is constrained by llama.cpp's JSON-schema grammar
+// to a {"tool_calls": [...], "reply": "..."} envelope.
+func (p *LocalProvider) GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	if len(req.Tools) == 0 {
+		content, err := p.GenerateCompletion(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return &AIResponse{Content: content}, nil
+	}
+
+	req.System += describeTools(req.Tools)
+	payload := buildChatPayload(req)
+	payload.Messages = appendEnvelopeToolTurns(payload.Messages, req.ToolTurns)
+	payload.ResponseFormat = toolEnvelopeFormat(req.Tools)
+
+	result, err := p.post(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	return parseToolEnvelope(result.Choices[0].Message.Content)
+}
+
+// StreamWithTools streams the same constrained envelope as GenerateWithTools; only the text of
+// its "reply" field is passed to onToken.
+func (p *LocalProvider) StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error) {
+	payload := buildChatPayload(req)
+	forward := onToken
+	if len(req.Tools) > 0 {
+		req.System += describeTools(req.Tools)
+		payload = buildChatPayload(req)
+		payload.Messages = appendEnvelopeToolTurns(payload.Messages, req.ToolTurns)
+		payload.ResponseFormat = toolEnvelopeFormat(req.Tools)
+		forward = newReplyExtractor(onToken).Write
+	}
+	payload.Stream = true
+	payload.StreamOptions = &streamOptions{IncludeUsage: true}
+
+	body, err := p.send(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	result, err := readStream(body, "[Local AI]", forward)
+	if err != nil {
+		return nil, err
+	}
+	recordWireUsage(ctx, result.usage)
+	if len(req.Tools) == 0 {
+		return &AIResponse{Content: strings.TrimSpace(result.content.String())}, nil
+	}
+	return parseToolEnvelope(result.content.String())
+}
+
+func (p *LocalProvider) post(ctx context.Context, payload completionPayload) (*completionResponse, error) {
+	body, err := p.send(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	result, err := decodeCompletion(body, "[Local AI]")
+	if err != nil {
+		return nil, err
+	}
+	recordWireUsage(ctx, result.Usage)
+	return result, nil
+}
+
+// send posts the payload and returns the response body of a 200 answer; the caller closes it.
+func (p *LocalProvider) send(ctx context.Context, payload completionPayload) (io.ReadCloser, error) {
 	reqID, _ := ctx.Value("request_id").(string)
 	log.Info().Str("request_id", reqID).Str("url", p.url).Msg("[Local AI] Sending request")
 
-	payload := buildChatPayload(req)
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("[Local AI] failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("[Local AI] failed to marshal payload: %w", err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(
@@ -42,34 +120,35 @@ func (p *LocalProvider) GenerateCompletion(ctx context.Context, req AIRequest) (
 		bytes.NewBuffer(jsonPayload),
 	)
 	if err != nil {
-		return "", fmt.Errorf("[Local AI] failed to create request: %w", err)
+		return nil, fmt.Errorf("[Local AI] failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{Timeout: 120 * time.Second}
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("[Local AI] request failed: %w", err)
+		return nil, fmt.Errorf("[Local AI] request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("[Local AI] returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{Prefix: "[Local AI]", StatusCode: resp.StatusCode, Body: string(body)}
 	}
-
-	return decodeCompletion(resp.Body, "[Local AI]")
+	return resp.Body, nil
 }
 
 type localChatMessage struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+	Role       string         `json:"role"`
+	Content    interface{}    `json:"content"`
+	ToolCalls  []wireToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
 }
 
 type localContentPart struct {
-	Type     string        `json:"type"`
-	Text     string        `json:"text,omitempty"`
-	ImageURL *localImgURL  `json:"image_url,omitempty"`
+	Type     string       `json:"type"`
+	Text     string       `json:"text,omitempty"`
+	ImageURL *localImgURL `json:"image_url,omitempty"`
 }
 
 type localImgURL struct {
@@ -77,24 +156,33 @@ type localImgURL struct {
 }
 
 type completionPayload struct {
-	Model       string             `json:"model,omitempty"`
-	Messages    []localChatMessage `json:"messages"`
-	MaxTokens   int                `json:"max_tokens,omitempty"`
-	Temperature float64            `json:"temperature,omitempty"`
-	Stream      bool               `json:"stream"`
+	Model          string             `json:"model,omitempty"`
+	Messages       []localChatMessage `json:"messages"`
+	MaxTokens      int                `json:"max_tokens,omitempty"`
+	Temperature    float64            `json:"temperature,omitempty"`
+	Stream         bool               `json:"stream"`
+	Tools          []wireTool         `json:"tools,omitempty"`
+	ResponseFormat *responseFormat    `json:"response_format,omitempty"`
+	StreamOptions  *streamOptions     `json:"stream_options,omitempty"`
+}
+
+// streamOptions asks for a final chunk with the token usage of a streamed completion.
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type completionResponse struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string         `json:"content"`
+			ToolCalls []wireToolCall `json:"tool_calls"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *wireUsage `json:"usage"`
 }
 
 func buildChatPayload(req AIRequest) completionPayload {
 	var userContent interface{}
-
 	if req.Base64Image != "" {
 		userContent = []localContentPart{
 			{Type: "text", Text: req.Prompt},
@@ -108,6 +196,9 @@ func buildChatPayload(req AIRequest) completionPayload {
 	if req.System != "" {
 		messages = append(messages, localChatMessage{Role: "system", Content: req.System})
 	}
+	for _, turn := range req.History {
+		messages = append(messages, localChatMessage{Role: turn.Role, Content: turn.Content})
+	}
 	messages = append(messages, localChatMessage{Role: "user", Content: userContent})
 
 	return completionPayload{
@@ -118,13 +209,13 @@ func buildChatPayload(req AIRequest) completionPayload {
 	}
 }
 
-func decodeCompletion(body io.Reader, logPrefix string) (string, error) {
+func decodeCompletion(body io.Reader, logPrefix string) (*completionResponse, error) {
 	var result completionResponse
 	if err := json.NewDecoder(body).Decode(&result); err != nil {
-		return "", fmt.Errorf("%s failed to decode response: %w", logPrefix, err)
+		return nil, fmt.Errorf("%s failed to decode response: %w", logPrefix, err)
 	}
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("%s returned no choices", logPrefix)
+		return nil, fmt.Errorf("%s returned no choices", logPrefix)
 	}
-	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+	return &result, nil
 }
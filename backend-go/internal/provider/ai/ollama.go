@@ -0,0 +1,213 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OllamaProvider talks to Ollama's native /api/chat endpoint, which takes images as a separate
+// base64 list and supports tool calling for models that declare it.
+type OllamaProvider struct {
+	url   string
+	model string
+}
+
+func NewOllamaProvider(url, model string) *OllamaProvider {
+	return &OllamaProvider{url: strings.TrimRight(url, "/"), model: model}
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaPayload struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Tools    []wireTool             `json:"tools,omitempty"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	Error           string        `json:"error"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+func (p *OllamaProvider) GenerateCompletion(ctx context.Context, req AIRequest) (string, error) {
+	req.Tools = nil
+	resp, err := p.GenerateWithTools(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func (p *OllamaProvider) GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	body, err := p.send(ctx, p.buildPayload(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var result ollamaResponse
+	if err := json.NewDecoder(body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("[Ollama] failed to decode response: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("[Ollama] %s", result.Error)
+	}
+	recordUsage(ctx, result.PromptEvalCount, result.EvalCount)
+	return ollamaToResponse(result.Message.Content, result.Message.ToolCalls)
+}
+
+// StreamWithTools reads Ollama's newline-delimited JSON stream. Tool calls arrive whole in one of
+// the chunks.
+func (p *OllamaProvider) StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error) {
+	body, err := p.send(ctx, p.buildPayload(req, true))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var content strings.Builder
+	var calls []ollamaToolCall
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("[Ollama] invalid stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("[Ollama] %s", chunk.Error)
+		}
+		calls = append(calls, chunk.Message.ToolCalls...)
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := onToken(chunk.Message.Content); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Done {
+			recordUsage(ctx, chunk.PromptEvalCount, chunk.EvalCount)
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("[Ollama] stream interrupted: %w", err)
+	}
+	return ollamaToResponse(content.String(), calls)
+}
+
+func (p *OllamaProvider) buildPayload(req AIRequest, stream bool) ollamaPayload {
+	var messages []ollamaMessage
+	if req.System != "" {
+		messages = append(messages, ollamaMessage{Role: "system", Content: req.System})
+	}
+	for _, turn := range req.History {
+		messages = append(messages, ollamaMessage{Role: turn.Role, Content: turn.Content})
+	}
+	user := ollamaMessage{Role: "user", Content: req.Prompt}
+	if req.Base64Image != "" {
+		user.Images = []string{req.Base64Image}
+	}
+	messages = append(messages, user)
+
+	for _, turn := range req.ToolTurns {
+		msg := ollamaMessage{Role: turn.Role, Content: turn.Content}
+		for _, c := range turn.ToolCalls {
+			var call ollamaToolCall
+			call.Function.Name = c.Name
+			call.Function.Arguments = c.Arguments
+			msg.ToolCalls = append(msg.ToolCalls, call)
+		}
+		messages = append(messages, msg)
+	}
+
+	payload := ollamaPayload{
+		Model:    p.model,
+		Messages: messages,
+		Stream:   stream,
+		Options:  map[string]interface{}{"temperature": 0.3, "num_predict": 1024},
+	}
+	if len(req.Tools) > 0 {
+		payload.Tools = wireTools(req.Tools)
+	}
+	return payload
+}
+
+func (p *OllamaProvider) send(ctx context.Context, payload ollamaPayload) (io.ReadCloser, error) {
+	reqID, _ := ctx.Value("request_id").(string)
+	log.Info().Str("request_id", reqID).Str("url", p.url).Str("model", p.model).Msg("[Ollama] Sending request")
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("[Ollama] failed to marshal payload: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.url+"/api/chat", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("[Ollama] failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 120 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("[Ollama] request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{Prefix: "[Ollama]", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp.Body, nil
+}
+
+// ollamaToResponse converts Ollama's tool calls (object arguments, no IDs) to ToolCalls.
+func ollamaToResponse(content string, calls []ollamaToolCall) (*AIResponse, error) {
+	resp := &AIResponse{Content: strings.TrimSpace(content)}
+	for i, c := range calls {
+		if c.Function.Name == "" {
+			return nil, fmt.Errorf("[Ollama] tool call %d has no function name", i)
+		}
+		args := bytes.TrimSpace(c.Function.Arguments)
+		var encoded string
+		if json.Unmarshal(args, &encoded) == nil {
+			// Some model templates emit the arguments as a JSON string.
+			args = []byte(encoded)
+		}
+		if len(args) == 0 || string(args) == "null" {
+			args = []byte("{}")
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: fmt.Sprintf("call_%d", i), Name: c.Function.Name, Arguments: json.RawMessage(args)})
+	}
+	return resp, nil
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ollamaStub answers /api/chat with body and records the last payload.
+func ollamaStub(t *testing.T, body string) (*httptest.Server, *ollamaPayload) {
+	t.Helper()
+	var got ollamaPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestOllamaProvider_GenerateWithTools(t *testing.T) {
+	srv, got := ollamaStub(t, `{"message":{"role":"assistant","content":"","tool_calls":[`+
+		`{"function":{"name":"create_transaction","arguments":{"amount":25000}}},`+
+		`{"function":{"name":"get_balance","arguments":"{\"wallet\":\"BCA\"}"}}]},"done":true}`)
+
+	p := NewOllamaProvider(srv.URL+"/", "qwen2.5vl:7b")
+	resp, err := p.GenerateWithTools(context.Background(), AIRequest{
+		Prompt:      "makan 25rb",
+		System:      "sistem",
+		Base64Image: "aW1n",
+		Tools:       []Tool{{Name: "create_transaction", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		ToolTurns: []Message{
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Name: "get_balance", Arguments: json.RawMessage(`{}`)}}},
+			{Role: "tool", ToolCallID: "call_0", Content: "saldo 10000"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resp.ToolCalls, 2)
+	assert.Equal(t, ToolCall{ID: "call_0", Name: "create_transaction", Arguments: json.RawMessage(`{"amount":25000}`)}, resp.ToolCalls[0])
+	assert.JSONEq(t, `{"wallet":"BCA"}`, string(resp.ToolCalls[1].Arguments))
+
+	assert.Equal(t, "qwen2.5vl:7b", got.Model)
+	assert.False(t, got.Stream)
+	require.Len(t, got.Messages, 4)
+	assert.Equal(t, "system", got.Messages[0].Role)
+	assert.Equal(t, []string{"aW1n"}, got.Messages[1].Images)
+	assert.Equal(t, "get_balance", got.Messages[2].ToolCalls[0].Function.Name)
+	assert.Equal(t, "tool", got.Messages[3].Role)
+	require.Len(t, got.Tools, 1)
+	assert.Equal(t, "create_transaction", got.Tools[0].Function.Name)
+}
+
+func TestOllamaProvider_StreamWithTools(t *testing.T) {
+	lines := []string{
+		`{"message":{"role":"assistant","content":"Sal"},"done":false}`,
+		`{"message":{"role":"assistant","content":"do aman 👍"},"done":false}`,
+		`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_balance","arguments":{}}}]},"done":false}`,
+		`{"message":{"role":"assistant","content":""},"done":true}`,
+	}
+	srv, got := ollamaStub(t, strings.Join(lines, "\n")+"\n")
+
+	var tokens []string
+	resp, err := NewOllamaProvider(srv.URL, "llama3.1").StreamWithTools(context.Background(), AIRequest{Prompt: "saldo?"}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, got.Stream)
+	assert.Equal(t, []string{"Sal", "do aman 👍"}, tokens)
+	assert.Equal(t, "Saldo aman 👍", resp.Content)
+	require.Len(t, resp.ToolCalls, 1)
+	assert.Equal(t, "get_balance", resp.ToolCalls[0].Name)
+	assert.JSONEq(t, `{}`, string(resp.ToolCalls[0].Arguments))
+}
+
+func TestOllamaProvider_StatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model not found"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := NewOllamaProvider(srv.URL, "missing").GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
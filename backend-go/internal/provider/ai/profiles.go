@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Task is the kind of request aiService sends, used to route it to a provider profile.
+type Task string
+
+const (
+	TaskChat            Task = "chat"
+	TaskReceipt         Task = "receipt" // also image chat: needs a vision model
+	TaskVoiceCorrection Task = "voice_correction"
+	TaskSummarize       Task = "summarize"
+	TaskInsight         Task = "insight"
+)
+
+var knownTasks = map[Task]bool{TaskChat: true, TaskReceipt: true, TaskVoiceCorrection: true, TaskSummarize: true, TaskInsight: true}
+
+// TaskRouter is implemented by providers that pick a different model per task.
+type TaskRouter interface {
+	ForTask(task Task) Provider
+	// HasRoute reports whether the task has its own route instead of falling back to chat.
+	HasRoute(task Task) bool
+}
+
+// ProfileConfig is one model endpoint in the profiles file.
+type ProfileConfig struct {
+	// Type is llamacpp (llama.cpp server with grammar-constrained tools), ollama (native
+	// /api/chat) or openai (any OpenAI-compatible chat completions URL).
+	Type    string        `yaml:"type"`
+	URL     string        `yaml:"url"`
+	APIKey  string        `yaml:"api_key"`
+	Model   string        `yaml:"model"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RoutingConfig is the profiles file: named profiles, and per task the chain of profiles to try
+// in order. Tasks without a route use the chat route.
+type RoutingConfig struct {
+	Profiles map[string]ProfileConfig `yaml:"profiles"`
+	Routes   map[Task][]string        `yaml:"routes"`
+}
+
+// Router sends each task to its own fallback chain. A profile used by several routes keeps one
+// circuit breaker, so an outage seen by chat also makes summarization skip it.
+type Router struct {
+	routes map[Task]*CompositeProvider
+	states []*backendState
+}
+
+// LoadRouter reads a YAML profiles file; ${VAR} references are expanded from the environment so
+// API keys stay out of the file.
+func LoadRouter(path string, resilience CompositeConfig) (*Router, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AI profiles: %w", err)
+	}
+	var cfg RoutingConfig
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid AI profiles %s: %w", path, err)
+	}
+	return NewRouter(cfg, resilience)
+}
+
+func NewRouter(cfg RoutingConfig, resilience CompositeConfig) (*Router, error) {
+	if len(cfg.Routes[TaskChat]) == 0 {
+		return nil, fmt.Errorf("AI profiles: route %q is required", TaskChat)
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := &Router{routes: map[Task]*CompositeProvider{}}
+	states := map[string]*backendState{}
+	for _, name := range names {
+		profile := cfg.Profiles[name]
+		provider, err := newProfileProvider(profile)
+		if err != nil {
+			return nil, fmt.Errorf("AI profile %q: %w", name, err)
+		}
+		state := newBackendState(Backend{Name: name, Provider: provider, Timeout: profile.Timeout})
+		states[name] = state
+		r.states = append(r.states, state)
+	}
+
+	for task, chain := range cfg.Routes {
+		if !knownTasks[task] {
+			return nil, fmt.Errorf("AI profiles: unknown task %q", task)
+		}
+		var route []*backendState
+		for _, name := range chain {
+			state, ok := states[name]
+			if !ok {
+				return nil, fmt.Errorf("AI profiles: route %q uses unknown profile %q", task, name)
+			}
+			route = append(route, state)
+		}
+		if len(route) > 0 {
+			r.routes[task] = newCompositeProvider(resilience, route)
+		}
+	}
+	return r, nil
+}
+
+func newProfileProvider(p ProfileConfig) (Provider, error) {
+	if p.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	switch p.Type {
+	case "llamacpp", "":
+		return NewLocalProvider(p.URL), nil
+	case "ollama":
+		if p.Model == "" {
+			return nil, fmt.Errorf("model is required for ollama")
+		}
+		return NewOllamaProvider(p.URL, p.Model), nil
+	case "openai":
+		return NewExternalProvider(p.URL, p.APIKey, p.Model), nil
+	default:
+		return nil, fmt.Errorf("unknown type %q (llamacpp, ollama or openai)", p.Type)
+	}
+}
+
+func (r *Router) ForTask(task Task) Provider {
+	if route, ok := r.routes[task]; ok {
+		return route
+	}
+	return r.routes[TaskChat]
+}
+
+func (r *Router) HasRoute(task Task) bool {
+	_, ok := r.routes[task]
+	return ok
+}
+
+// The Provider methods use the chat route, for callers that do not know about tasks.
+
+func (r *Router) GenerateCompletion(ctx context.Context, req AIRequest) (string, error) {
+	return r.routes[TaskChat].GenerateCompletion(ctx, req)
+}
+
+func (r *Router) GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	return r.routes[TaskChat].GenerateWithTools(ctx, req)
+}
+
+func (r *Router) StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error) {
+	return r.routes[TaskChat].StreamWithTools(ctx, req, onToken)
+}
+
+// Health reports every profile once, in name order.
+func (r *Router) Health() []ProviderHealth {
+	chat := r.routes[TaskChat]
+	out := make([]ProviderHealth, len(r.states))
+	for i, s := range r.states {
+		out[i] = s.health(chat.now(), chat.cfg.OpenDuration)
+	}
+	return out
+}
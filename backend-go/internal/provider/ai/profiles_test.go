@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProfiles(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadRouter_RoutesTasksToProfiles(t *testing.T) {
+	small, smallCalls := llmStub(t, "dari small")
+	big, bigCalls := llmStub(t, "dari big")
+	t.Setenv("TEST_BIG_KEY", "rahasia")
+
+	path := writeProfiles(t, fmt.Sprintf(`
+profiles:
+  small:
+    type: llamacpp
+    url: %s
+    timeout: 5s
+  big:
+    type: openai
+    url: %s
+    api_key: ${TEST_BIG_KEY}
+    model: big-model
+routes:
+  chat: [small]
+  summarize: [big, small]
+`, small.URL, big.URL))
+
+	r, err := LoadRouter(path, testCompositeConfig())
+	require.NoError(t, err)
+	assert.True(t, r.HasRoute(TaskSummarize))
+	assert.False(t, r.HasRoute(TaskVoiceCorrection))
+
+	content, err := r.ForTask(TaskSummarize).GenerateCompletion(context.Background(), AIRequest{Prompt: "ringkas"})
+	require.NoError(t, err)
+	assert.Equal(t, "dari big", content)
+
+	// Tasks without a route, and plain Provider calls, use the chat route.
+	content, err = r.ForTask(TaskReceipt).GenerateCompletion(context.Background(), AIRequest{Prompt: "struk"})
+	require.NoError(t, err)
+	assert.Equal(t, "dari small", content)
+	content, err = r.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, "dari small", content)
+
+	assert.Equal(t, int32(1), bigCalls.Load())
+	assert.Equal(t, int32(2), smallCalls.Load())
+
+	big1 := r.routes[TaskSummarize].backends[0]
+	assert.Equal(t, "rahasia", big1.Provider.(*ExternalProvider).apiKey)
+	assert.Equal(t, 5*time.Second, r.routes[TaskChat].backends[0].Timeout)
+}
+
+func TestRouter_SharesCircuitAcrossRoutes(t *testing.T) {
+	flaky, flakyCalls := llmStub(t, "", 500, 500, 500, 500)
+	backup, _ := llmStub(t, "dari backup")
+
+	r, err := NewRouter(RoutingConfig{
+		Profiles: map[string]ProfileConfig{
+			"flaky":  {Type: "llamacpp", URL: flaky.URL},
+			"backup": {Type: "openai", URL: backup.URL, Model: "m"},
+		},
+		Routes: map[Task][]string{
+			TaskChat:      {"flaky", "backup"},
+			TaskSummarize: {"flaky", "backup"},
+		},
+	}, testCompositeConfig())
+	require.NoError(t, err)
+
+	_, err = r.ForTask(TaskChat).GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), flakyCalls.Load())
+
+	// The chat route opened flaky's circuit, so summarization skips it.
+	content, err := r.ForTask(TaskSummarize).GenerateCompletion(context.Background(), AIRequest{Prompt: "ringkas"})
+	require.NoError(t, err)
+	assert.Equal(t, "dari backup", content)
+	assert.Equal(t, int32(2), flakyCalls.Load())
+
+	health := r.Health()
+	require.Len(t, health, 2)
+	assert.Equal(t, "backup", health[0].Name)
+	assert.Equal(t, "flaky", health[1].Name)
+	assert.Equal(t, "open", health[1].State)
+}
+
+func TestNewRouter_Validation(t *testing.T) {
+	valid := ProfileConfig{Type: "llamacpp", URL: "http://llm:8080"}
+	tests := []struct {
+		name string
+		cfg  RoutingConfig
+		want string
+	}{
+		{
+			name: "no chat route",
+			cfg:  RoutingConfig{Profiles: map[string]ProfileConfig{"a": valid}, Routes: map[Task][]string{TaskSummarize: {"a"}}},
+			want: `route "chat" is required`,
+		},
+		{
+			name: "unknown task",
+			cfg:  RoutingConfig{Profiles: map[string]ProfileConfig{"a": valid}, Routes: map[Task][]string{TaskChat: {"a"}, "translate": {"a"}}},
+			want: `unknown task "translate"`,
+		},
+		{
+			name: "unknown profile",
+			cfg:  RoutingConfig{Profiles: map[string]ProfileConfig{"a": valid}, Routes: map[Task][]string{TaskChat: {"b"}}},
+			want: `unknown profile "b"`,
+		},
+		{
+			name: "unknown type",
+			cfg:  RoutingConfig{Profiles: map[string]ProfileConfig{"a": {Type: "bard", URL: "http://x"}}, Routes: map[Task][]string{TaskChat: {"a"}}},
+			want: `unknown type "bard"`,
+		},
+		{
+			name: "ollama without model",
+			cfg:  RoutingConfig{Profiles: map[string]ProfileConfig{"a": {Type: "ollama", URL: "http://x"}}, Routes: map[Task][]string{TaskChat: {"a"}}},
+			want: "model is required",
+		},
+		{
+			name: "missing url",
+			cfg:  RoutingConfig{Profiles: map[string]ProfileConfig{"a": {Type: "openai"}}, Routes: map[Task][]string{TaskChat: {"a"}}},
+			want: "url is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRouter(tt.cfg, DefaultCompositeConfig())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestLoadRouter_InvalidFile(t *testing.T) {
+	_, err := LoadRouter(filepath.Join(t.TempDir(), "missing.yaml"), DefaultCompositeConfig())
+	assert.Error(t, err)
+
+	_, err = LoadRouter(writeProfiles(t, "profiles: [oops"), DefaultCompositeConfig())
+	assert.Error(t, err)
+
+	_, err = LoadRouter(writeProfiles(t, "routes:\n  chat: [a]\nprofiles:\n  a:\n    url: http://x\n    timeout: soon\n"), DefaultCompositeConfig())
+	assert.Error(t, err)
+}
@@ -1,13 +1,65 @@
 package ai
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Message is one earlier turn of the conversation, replayed between the system prompt and Prompt.
+// Assistant turns may carry ToolCalls; role "tool" turns answer the call named by ToolCallID.
+type Message struct {
+	Role       string
+	Content    string
+	ToolCalls  []ToolCall
+	ToolCallID string
+}
+
+// Tool is an OpenAI-style function the model may call. Parameters is a JSON Schema object.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
 
 type AIRequest struct {
 	Prompt      string
 	Base64Image string
 	System      string
+	History     []Message
+	// ToolTurns follow Prompt: the assistant's tool calls and their results from earlier rounds.
+	ToolTurns []Message
+	Tools     []Tool
+}
+
+// AIResponse is either a final answer (Content) or a set of tool calls to run before asking again.
+type AIResponse struct {
+	Content   string
+	ToolCalls []ToolCall
 }
 
 type Provider interface {
 	GenerateCompletion(ctx context.Context, req AIRequest) (string, error)
+	GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error)
+	// StreamWithTools is GenerateWithTools that passes reply text to onToken while the model is
+	// still generating. An error from onToken (the client went away) aborts the request.
+	StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error)
+}
+
+// StatusError is a non-200 answer from a provider's HTTP API. CompositeProvider uses the status
+// code to decide whether a request is worth retrying.
+type StatusError struct {
+	Prefix     string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.Prefix, e.StatusCode, e.Body)
 }
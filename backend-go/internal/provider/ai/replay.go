@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrReplayExhausted is returned when a ReplayProvider is asked for more answers than were recorded,
+// which usually means the code under test now makes an extra model call.
+var ErrReplayExhausted = errors.New("replay: no more recorded exchanges")
+
+// Exchange is one recorded provider answer: Completion for GenerateCompletion, Response for
+// GenerateWithTools and StreamWithTools.
+type Exchange struct {
+	Completion *string     `json:"completion,omitempty"`
+	Response   *AIResponse `json:"response,omitempty"`
+}
+
+// RecordingProvider forwards every call to Provider and keeps the answers so they can be replayed
+// later without a model server.
+type RecordingProvider struct {
+	Provider Provider
+
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+func NewRecordingProvider(p Provider) *RecordingProvider {
+	return &RecordingProvider{Provider: p}
+}
+
+// Exchanges returns the answers recorded so far, in call order.
+func (r *RecordingProvider) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Exchange(nil), r.exchanges...)
+}
+
+func (r *RecordingProvider) record(e Exchange) {
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, e)
+	r.mu.Unlock()
+}
+
+func (r *RecordingProvider) GenerateCompletion(ctx context.Context, req AIRequest) (string, error) {
+	content, err := r.Provider.GenerateCompletion(ctx, req)
+	if err == nil {
+		r.record(Exchange{Completion: &content})
+	}
+	return content, err
+}
+
+func (r *RecordingProvider) GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	resp, err := r.Provider.GenerateWithTools(ctx, req)
+	if err == nil {
+		r.record(Exchange{Response: resp})
+	}
+	return resp, err
+}
+
+func (r *RecordingProvider) StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error) {
+	resp, err := r.Provider.StreamWithTools(ctx, req, onToken)
+	if err == nil {
+		r.record(Exchange{Response: resp})
+	}
+	return resp, err
+}
+
+// ReplayProvider answers calls with recorded exchanges in order, ignoring the request. A call whose
+// kind does not match the next exchange fails instead of skipping ahead.
+type ReplayProvider struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	next      int
+}
+
+func NewReplayProvider(exchanges []Exchange) *ReplayProvider {
+	return &ReplayProvider{exchanges: exchanges}
+}
+
+// Remaining reports how many recorded exchanges were not used.
+func (r *ReplayProvider) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.exchanges) - r.next
+}
+
+func (r *ReplayProvider) pop() (Exchange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.exchanges) {
+		return Exchange{}, ErrReplayExhausted
+	}
+	e := r.exchanges[r.next]
+	r.next++
+	return e, nil
+}
+
+func (r *ReplayProvider) GenerateCompletion(ctx context.Context, req AIRequest) (string, error) {
+	e, err := r.pop()
+	if err != nil {
+		return "", err
+	}
+	if e.Completion == nil {
+		return "", fmt.Errorf("replay: exchange %d is a tool response, not a completion", r.next-1)
+	}
+	return *e.Completion, nil
+}
+
+func (r *ReplayProvider) GenerateWithTools(ctx context.Context, req AIRequest) (*AIResponse, error) {
+	e, err := r.pop()
+	if err != nil {
+		return nil, err
+	}
+	if e.Response == nil {
+		return nil, fmt.Errorf("replay: exchange %d is a completion, not a tool response", r.next-1)
+	}
+	resp := *e.Response
+	return &resp, nil
+}
+
+func (r *ReplayProvider) StreamWithTools(ctx context.Context, req AIRequest, onToken func(string) error) (*AIResponse, error) {
+	resp, err := r.GenerateWithTools(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Content != "" {
+		if err := onToken(resp.Content); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingProvider_ReplaysAfterRoundTrip(t *testing.T) {
+	srv, calls := llmStub(t, "halo juga")
+	rec := NewRecordingProvider(NewExternalProvider(srv.URL, "", "m"))
+
+	content, err := rec.GenerateCompletion(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, "halo juga", content)
+	resp, err := rec.GenerateWithTools(context.Background(), AIRequest{Prompt: "halo"})
+	require.NoError(t, err)
+	assert.Equal(t, "halo juga", resp.Content)
+
+	data, err := json.Marshal(rec.Exchanges())
+	require.NoError(t, err)
+	var exchanges []Exchange
+	require.NoError(t, json.Unmarshal(data, &exchanges))
+
+	replay := NewReplayProvider(exchanges)
+	content, err = replay.GenerateCompletion(context.Background(), AIRequest{Prompt: "apa saja"})
+	require.NoError(t, err)
+	assert.Equal(t, "halo juga", content)
+
+	var streamed string
+	resp, err = replay.StreamWithTools(context.Background(), AIRequest{}, func(s string) error {
+		streamed += s
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "halo juga", resp.Content)
+	assert.Equal(t, "halo juga", streamed)
+	assert.Equal(t, 0, replay.Remaining())
+	assert.Equal(t, int32(2), calls.Load())
+
+	_, err = replay.GenerateWithTools(context.Background(), AIRequest{})
+	assert.ErrorIs(t, err, ErrReplayExhausted)
+}
+
+func TestReplayProvider_RejectsMismatchedKind(t *testing.T) {
+	content := "teks"
+	replay := NewReplayProvider([]Exchange{
+		{Completion: &content},
+		{Response: &AIResponse{ToolCalls: []ToolCall{{ID: "c1", Name: "create_transaction", Arguments: json.RawMessage(`{"amount":1}`)}}}},
+	})
+
+	_, err := replay.GenerateWithTools(context.Background(), AIRequest{})
+	assert.ErrorContains(t, err, "is a completion")
+	_, err = replay.GenerateCompletion(context.Background(), AIRequest{})
+	assert.ErrorContains(t, err, "is a tool response")
+}
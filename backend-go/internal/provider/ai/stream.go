@@ -0,0 +1,217 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// streamChunk is one "data:" event of an OpenAI-compatible streaming completion.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *wireUsage `json:"usage"`
+}
+
+// streamResult collects a streamed completion: content as one string and tool call fragments
+// merged by index, the way the OpenAI API splits them across chunks.
+type streamResult struct {
+	content strings.Builder
+	calls   map[int]*wireToolCall
+	usage   *wireUsage // from the final chunk, when the server sends one
+}
+
+// readStream reads server-sent events until "[DONE]" or EOF, calling onContent with every content
+// delta as it arrives. An error from onContent stops reading and is returned as is.
+func readStream(body io.Reader, logPrefix string, onContent func(string) error) (*streamResult, error) {
+	result := &streamResult{calls: map[int]*wireToolCall{}}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return result, nil
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("%s invalid stream chunk: %w", logPrefix, err)
+		}
+		if chunk.Usage != nil {
+			result.usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			for _, d := range choice.Delta.ToolCalls {
+				call, ok := result.calls[d.Index]
+				if !ok {
+					call = &wireToolCall{Type: "function"}
+					result.calls[d.Index] = call
+				}
+				if d.ID != "" {
+					call.ID = d.ID
+				}
+				call.Function.Name += d.Function.Name
+				call.Function.Arguments += d.Function.Arguments
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			result.content.WriteString(choice.Delta.Content)
+			if err := onContent(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s stream interrupted: %w", logPrefix, err)
+	}
+	return result, nil
+}
+
+func (r *streamResult) toolCalls() []wireToolCall {
+	indexes := make([]int, 0, len(r.calls))
+	for i := range r.calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	out := make([]wireToolCall, 0, len(indexes))
+	for _, i := range indexes {
+		out = append(out, *r.calls[i])
+	}
+	return out
+}
+
+// replyExtractor follows a streamed tool envelope ({"tool_calls":[...],"reply":"..."}) and passes
+// the decoded text of the top-level "reply" string on as soon as it is generated.
+type replyExtractor struct {
+	onToken func(string) error
+
+	depth    int
+	inString bool
+	escape   []byte // pending escape sequence, starting with the backslash
+	key      strings.Builder
+	lastKey  string
+	isKey    bool
+	inReply  bool
+	pendingU rune // high surrogate waiting for its pair
+}
+
+func newReplyExtractor(onToken func(string) error) *replyExtractor {
+	return &replyExtractor{onToken: onToken}
+}
+
+func (e *replyExtractor) Write(fragment string) error {
+	var out strings.Builder
+	for i := 0; i < len(fragment); i++ {
+		c := fragment[i]
+		if !e.inString {
+			switch c {
+			case '{', '[':
+				e.depth++
+			case '}', ']':
+				e.depth--
+			case '"':
+				e.inString = true
+				// At the top level a string is a key unless it follows "key":.
+				e.isKey = e.depth == 1 && e.lastKey == ""
+				e.inReply = e.depth == 1 && e.lastKey == "reply"
+				e.key.Reset()
+			case ',':
+				if e.depth == 1 {
+					e.lastKey = ""
+				}
+			}
+			continue
+		}
+
+		if len(e.escape) > 0 {
+			e.escape = append(e.escape, c)
+			if r, done := e.decodeEscape(); done {
+				e.escape = e.escape[:0]
+				e.emit(&out, r)
+			}
+			continue
+		}
+		switch c {
+		case '\\':
+			e.escape = append(e.escape, c)
+		case '"':
+			e.inString = false
+			if e.isKey {
+				e.lastKey = e.key.String()
+			}
+			e.inReply = false
+		default:
+			if e.isKey {
+				e.key.WriteByte(c)
+			} else if e.inReply {
+				out.WriteByte(c)
+			}
+		}
+	}
+
+	if out.Len() == 0 {
+		return nil
+	}
+	return e.onToken(out.String())
+}
+
+// decodeEscape reports the rune of a complete escape sequence, or -1 for one that only completes
+// a surrogate half.
+func (e *replyExtractor) decodeEscape() (rune, bool) {
+	if len(e.escape) < 2 {
+		return 0, false
+	}
+	if e.escape[1] != 'u' {
+		var s string
+		if err := json.Unmarshal([]byte(`"`+string(e.escape)+`"`), &s); err != nil || s == "" {
+			return -1, true
+		}
+		return []rune(s)[0], true
+	}
+	if len(e.escape) < 6 {
+		return 0, false
+	}
+	var code rune
+	fmt.Sscanf(string(e.escape[2:6]), "%04x", &code)
+	if utf16.IsSurrogate(code) {
+		if e.pendingU == 0 {
+			e.pendingU = code
+			return -1, true
+		}
+		code = utf16.DecodeRune(e.pendingU, code)
+		e.pendingU = 0
+	}
+	return code, true
+}
+
+func (e *replyExtractor) emit(out *strings.Builder, r rune) {
+	if r < 0 {
+		return
+	}
+	if e.isKey {
+		e.key.WriteRune(r)
+	} else if e.inReply {
+		out.WriteRune(r)
+	}
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseStub answers every request with the given chunks as server-sent events, flushing each one.
+func sseStub(t *testing.T, chunks ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload completionPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.True(t, payload.Stream)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func contentChunk(s string) string {
+	data, _ := json.Marshal(map[string]interface{}{"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": s}}}})
+	return string(data)
+}
+
+func TestExternalProvider_StreamWithTools(t *testing.T) {
+	srv := sseStub(t,
+		contentChunk("Siap, "),
+		contentChunk("aku catat."),
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"create_transaction","arguments":"{\"amo"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"unt\":15000}"}}]}}]}`,
+	)
+
+	var tokens []string
+	resp, err := NewExternalProvider(srv.URL, "key", "model").StreamWithTools(context.Background(), AIRequest{Prompt: "kopi 15rb"}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Siap, ", "aku catat."}, tokens)
+	assert.Equal(t, "Siap, aku catat.", resp.Content)
+	require.Len(t, resp.ToolCalls, 1)
+	assert.Equal(t, "call_a", resp.ToolCalls[0].ID)
+	assert.JSONEq(t, `{"amount":15000}`, string(resp.ToolCalls[0].Arguments))
+}
+
+func TestLocalProvider_StreamWithTools_ForwardsOnlyReply(t *testing.T) {
+	// llama.cpp never splits a UTF-8 character, but escapes may span chunks.
+	envelope := []rune(`{"tool_calls":[],"reply":"Halo \"bos\", saldo aman 😀\ud83d\ude4f\nok"}`)
+	var chunks []string
+	for i := 0; i < len(envelope); i += 3 {
+		chunks = append(chunks, contentChunk(string(envelope[i:min(i+3, len(envelope))])))
+	}
+	srv := sseStub(t, chunks...)
+
+	var streamed strings.Builder
+	resp, err := NewLocalProvider(srv.URL).StreamWithTools(context.Background(), AIRequest{
+		Prompt: "halo",
+		Tools:  []Tool{{Name: "create_transaction"}},
+	}, func(token string) error {
+		streamed.WriteString(token)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Halo \"bos\", saldo aman 😀🙏\nok", streamed.String())
+	assert.Equal(t, streamed.String(), resp.Content)
+	assert.Empty(t, resp.ToolCalls)
+}
+
+func TestReplyExtractor_IgnoresToolCallStrings(t *testing.T) {
+	var out strings.Builder
+	e := newReplyExtractor(func(s string) error { out.WriteString(s); return nil })
+	require.NoError(t, e.Write(`{"tool_calls":[{"name":"reply","arguments":{"reply":"bukan ini"}}],`))
+	require.NoError(t, e.Write(`"reply":"ini ya"}`))
+	assert.Equal(t, "ini ya", out.String())
+}
+
+func TestCompositeProvider_StreamDoesNotFallBackAfterFirstToken(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\ndata: {bukan json\n\n", contentChunk("Sebagian "))
+	}))
+	t.Cleanup(broken.Close)
+	fallback, fallbackCalls := llmStub(t, "dari eksternal")
+
+	p := NewCompositeProvider(testCompositeConfig(),
+		Backend{Name: "local", Provider: NewLocalProvider(broken.URL)},
+		Backend{Name: "external", Provider: NewExternalProvider(fallback.URL, "key", "model")},
+	)
+
+	var tokens []string
+	_, err := p.StreamWithTools(context.Background(), AIRequest{Prompt: "halo"}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{"Sebagian "}, tokens)
+	assert.Zero(t, fallbackCalls.Load())
+	assert.Equal(t, 1, p.Health()[0].ConsecutiveFailures)
+
+	// The client going away is not the backend's fault.
+	gone := errors.New("client gone")
+	ok := sseStub(t, contentChunk("a"), contentChunk("b"))
+	p = NewCompositeProvider(testCompositeConfig(), Backend{Name: "local", Provider: NewLocalProvider(ok.URL)})
+	_, err = p.StreamWithTools(context.Background(), AIRequest{Prompt: "halo"}, func(string) error { return gone })
+	assert.ErrorIs(t, err, gone)
+	assert.Zero(t, p.Health()[0].ConsecutiveFailures)
+}
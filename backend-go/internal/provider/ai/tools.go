@@ -0,0 +1,193 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type wireTool struct {
+	Type     string       `json:"type"`
+	Function wireFunction `json:"function"`
+}
+
+type wireFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type wireToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type responseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *jsonSchemaFormat `json:"json_schema,omitempty"`
+}
+
+type jsonSchemaFormat struct {
+	Name   string          `json:"name"`
+	Strict bool            `json:"strict"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// envelopeCall is how a tool call is written inside the constrained JSON envelope.
+type envelopeCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type toolEnvelope struct {
+	ToolCalls []envelopeCall `json:"tool_calls"`
+	Reply     string         `json:"reply"`
+}
+
+func wireTools(tools []Tool) []wireTool {
+	out := make([]wireTool, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, wireTool{
+			Type:     "function",
+			Function: wireFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	return out
+}
+
+func toWireToolCalls(calls []ToolCall) []wireToolCall {
+	out := make([]wireToolCall, 0, len(calls))
+	for _, c := range calls {
+		var w wireToolCall
+		w.ID = c.ID
+		w.Type = "function"
+		w.Function.Name = c.Name
+		w.Function.Arguments = string(c.Arguments)
+		out = append(out, w)
+	}
+	return out
+}
+
+func fromWireToolCalls(calls []wireToolCall) ([]ToolCall, error) {
+	out := make([]ToolCall, 0, len(calls))
+	for i, c := range calls {
+		if c.Function.Name == "" {
+			return nil, fmt.Errorf("tool call %d has no function name", i)
+		}
+		args := strings.TrimSpace(c.Function.Arguments)
+		if args == "" {
+			args = "{}"
+		}
+		id := c.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		out = append(out, ToolCall{ID: id, Name: c.Function.Name, Arguments: json.RawMessage(args)})
+	}
+	return out, nil
+}
+
+// appendNativeToolTurns replays earlier rounds using the OpenAI tool_calls / role "tool" format.
+func appendNativeToolTurns(messages []localChatMessage, turns []Message) []localChatMessage {
+	for _, turn := range turns {
+		msg := localChatMessage{Role: turn.Role, Content: turn.Content, ToolCallID: turn.ToolCallID}
+		if len(turn.ToolCalls) > 0 {
+			msg.ToolCalls = toWireToolCalls(turn.ToolCalls)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// appendEnvelopeToolTurns replays earlier rounds for models without tool-aware chat templates:
+// the assistant's calls as its JSON envelope, and the results as a single user turn.
+func appendEnvelopeToolTurns(messages []localChatMessage, turns []Message) []localChatMessage {
+	names := map[string]string{}
+	var results []string
+
+	flush := func() {
+		if len(results) > 0 {
+			messages = append(messages, localChatMessage{Role: "user", Content: strings.Join(results, "\n")})
+			results = nil
+		}
+	}
+
+	for _, turn := range turns {
+		if turn.Role == "tool" {
+			results = append(results, fmt.Sprintf("[HASIL TOOL %s]: %s", names[turn.ToolCallID], turn.Content))
+			continue
+		}
+		flush()
+
+		envelope := toolEnvelope{ToolCalls: []envelopeCall{}, Reply: turn.Content}
+		for _, c := range turn.ToolCalls {
+			names[c.ID] = c.Name
+			envelope.ToolCalls = append(envelope.ToolCalls, envelopeCall{Name: c.Name, Arguments: c.Arguments})
+		}
+		data, _ := json.Marshal(envelope)
+		messages = append(messages, localChatMessage{Role: turn.Role, Content: string(data)})
+	}
+	flush()
+	return messages
+}
+
+func describeTools(tools []Tool) string {
+	var b strings.Builder
+	b.WriteString("\n\nTOOLS YANG TERSEDIA:\n")
+	for _, t := range tools {
+		params := string(t.Parameters)
+		if params == "" {
+			params = "{}"
+		}
+		fmt.Fprintf(&b, "- %s: %s\n  parameter (JSON Schema): %s\n", t.Name, t.Description, params)
+	}
+	b.WriteString("\nFORMAT OUTPUT: selalu JSON {\"tool_calls\": [{\"name\": \"...\", \"arguments\": {...}}], \"reply\": \"...\"}.\n")
+	b.WriteString("Jika perlu memanggil tool, isi tool_calls dan biarkan reply kosong. Setelah menerima [HASIL TOOL], kosongkan tool_calls dan tulis jawaban akhir di reply.")
+	return b.String()
+}
+
+// toolEnvelopeFormat builds the JSON schema llama.cpp turns into a grammar. tool_calls is listed
+// before reply so the model commits to its calls first.
+func toolEnvelopeFormat(tools []Tool) *responseFormat {
+	variants := make([]string, 0, len(tools))
+	for _, t := range tools {
+		params := string(t.Parameters)
+		if params == "" {
+			params = `{"type":"object"}`
+		}
+		name, _ := json.Marshal(t.Name)
+		variants = append(variants, fmt.Sprintf(
+			`{"type":"object","properties":{"name":{"const":%s},"arguments":%s},"required":["name","arguments"],"additionalProperties":false}`,
+			name, params))
+	}
+
+	schema := fmt.Sprintf(
+		`{"type":"object","properties":{"tool_calls":{"type":"array","items":{"anyOf":[%s]}},"reply":{"type":"string"}},"required":["tool_calls","reply"],"additionalProperties":false}`,
+		strings.Join(variants, ","))
+
+	return &responseFormat{
+		Type:       "json_schema",
+		JSONSchema: &jsonSchemaFormat{Name: "tool_envelope", Strict: true, Schema: json.RawMessage(schema)},
+	}
+}
+
+func parseToolEnvelope(content string) (*AIResponse, error) {
+	var envelope toolEnvelope
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &envelope); err != nil {
+		return nil, fmt.Errorf("[Local AI] invalid tool envelope: %w", err)
+	}
+
+	resp := &AIResponse{Content: strings.TrimSpace(envelope.Reply)}
+	for i, c := range envelope.ToolCalls {
+		args := c.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: fmt.Sprintf("call_%d", i), Name: c.Name, Arguments: args})
+	}
+	return resp, nil
+}
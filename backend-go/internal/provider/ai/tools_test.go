@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolEnvelopeFormat_IsValidSchemaPerTool(t *testing.T) {
+	format := toolEnvelopeFormat([]Tool{
+		{Name: "create_transaction", Parameters: json.RawMessage(`{"type":"object","properties":{"amount":{"type":"number"}}}`)},
+		{Name: "query_report"},
+	})
+
+	require.Equal(t, "json_schema", format.Type)
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(format.JSONSchema.Schema, &schema))
+	items := schema["properties"].(map[string]interface{})["tool_calls"].(map[string]interface{})["items"].(map[string]interface{})
+	assert.Len(t, items["anyOf"], 2)
+}
+
+func TestParseToolEnvelope(t *testing.T) {
+	resp, err := parseToolEnvelope(`{"tool_calls":[{"name":"create_transaction","arguments":{"amount":15000}}],"reply":""}`)
+	require.NoError(t, err)
+	require.Len(t, resp.ToolCalls, 1)
+	assert.Equal(t, "create_transaction", resp.ToolCalls[0].Name)
+	assert.JSONEq(t, `{"amount":15000}`, string(resp.ToolCalls[0].Arguments))
+
+	resp, err = parseToolEnvelope(`{"tool_calls":[],"reply":" Dicatat! "}`)
+	require.NoError(t, err)
+	assert.Empty(t, resp.ToolCalls)
+	assert.Equal(t, "Dicatat!", resp.Content)
+
+	_, err = parseToolEnvelope("bukan json")
+	assert.Error(t, err)
+}
+
+func TestAppendEnvelopeToolTurns_ReplaysResultsAsUserTurn(t *testing.T) {
+	messages := appendEnvelopeToolTurns(nil, []Message{
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Name: "pay_debt", Arguments: json.RawMessage(`{"amount":1}`)}}},
+		{Role: "tool", ToolCallID: "call_0", Content: `{"ok":false,"error":"isi debt_id atau debt_name"}`},
+	})
+
+	require.Len(t, messages, 2)
+	assert.Equal(t, "assistant", messages[0].Role)
+	assert.Contains(t, messages[0].Content, `"name":"pay_debt"`)
+	assert.Equal(t, "user", messages[1].Role)
+	assert.Contains(t, messages[1].Content, "[HASIL TOOL pay_debt]")
+}
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Synthesizer turns text into speech audio.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (*Speech, error)
+}
+
+// Speech is synthesized audio. ContentType tells callers whether it still needs converting,
+// e.g. Piper answers with WAV while WhatsApp voice notes must be OGG/Opus.
+type Speech struct {
+	Audio       []byte
+	ContentType string
+}
+
+// IsOggOpus reports whether the audio is already an Ogg container.
+func (s *Speech) IsOggOpus() bool {
+	return strings.HasPrefix(s.ContentType, "audio/ogg") || bytes.HasPrefix(s.Audio, []byte("OggS"))
+}
+
+// PiperClient calls a Piper HTTP server (python -m piper.http_server), which takes a JSON body
+// with the text and optional voice and returns WAV.
+type PiperClient struct {
+	url    string
+	voice  string
+	client *http.Client
+}
+
+func NewPiperClient(url, voice string) *PiperClient {
+	return &PiperClient{url: strings.TrimRight(url, "/"), voice: voice, client: &http.Client{}}
+}
+
+func (c *PiperClient) Synthesize(ctx context.Context, text string) (*Speech, error) {
+	payload := map[string]interface{}{"text": text}
+	if c.voice != "" {
+		payload["voice"] = c.voice
+	}
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("[Piper] failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url+"/", bytes.NewReader(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("[Piper] failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[Piper] request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("[Piper] failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("[Piper] returned status %d: %s", resp.StatusCode, string(body))
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("[Piper] returned empty audio")
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "audio/wav"
+	}
+	return &Speech{Audio: body, ContentType: contentType}, nil
+}
+
+// StubSynthesizer returns a fixed Ogg payload without a TTS server and records the texts it was
+// asked to speak. It is meant for tests and local development.
+type StubSynthesizer struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func NewStubSynthesizer() *StubSynthesizer {
+	return &StubSynthesizer{}
+}
+
+func (s *StubSynthesizer) Synthesize(ctx context.Context, text string) (*Speech, error) {
+	s.mu.Lock()
+	s.texts = append(s.texts, text)
+	s.mu.Unlock()
+	return &Speech{Audio: append([]byte("OggS"), text...), ContentType: "audio/ogg; codecs=opus"}, nil
+}
+
+// Texts returns every text synthesized so far.
+func (s *StubSynthesizer) Texts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.texts...)
+}
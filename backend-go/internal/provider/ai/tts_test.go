@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPiperClient_Synthesize(t *testing.T) {
+	var payload map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Write([]byte("RIFF....WAVE"))
+	}))
+	defer srv.Close()
+
+	speech, err := NewPiperClient(srv.URL+"/", "id_ID-news_tts-medium").Synthesize(context.Background(), "Sudah dicatat.")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Sudah dicatat.", payload["text"])
+	assert.Equal(t, "id_ID-news_tts-medium", payload["voice"])
+	assert.Equal(t, "audio/wav", speech.ContentType)
+	assert.False(t, speech.IsOggOpus())
+}
+
+func TestPiperClient_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "voice not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := NewPiperClient(srv.URL, "").Synthesize(context.Background(), "halo")
+	assert.ErrorContains(t, err, "status 404")
+}
+
+func TestStubSynthesizer(t *testing.T) {
+	stub := NewStubSynthesizer()
+	speech, err := stub.Synthesize(context.Background(), "halo")
+	require.NoError(t, err)
+
+	assert.True(t, speech.IsOggOpus())
+	assert.Equal(t, []string{"halo"}, stub.Texts())
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"sync"
+)
+
+// Usage is the token count a provider reported for the calls made under one UsageMeter.
+// Provider is the backend that answered last; it stays empty when the provider is not a
+// CompositeProvider.
+type Usage struct {
+	Provider         string
+	PromptTokens     int
+	CompletionTokens int
+	Calls            int
+}
+
+// UsageMeter adds up the usage of every provider call made with a context from WithUsageMeter,
+// e.g. all rounds of one tool loop. Providers that do not report token counts leave it at zero.
+type UsageMeter struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+type usageMeterKey struct{}
+
+func WithUsageMeter(ctx context.Context, meter *UsageMeter) context.Context {
+	return context.WithValue(ctx, usageMeterKey{}, meter)
+}
+
+func usageMeterFrom(ctx context.Context) *UsageMeter {
+	meter, _ := ctx.Value(usageMeterKey{}).(*UsageMeter)
+	return meter
+}
+
+// Usage returns the totals recorded so far.
+func (m *UsageMeter) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+func (m *UsageMeter) add(prompt, completion int) {
+	m.mu.Lock()
+	m.usage.PromptTokens += prompt
+	m.usage.CompletionTokens += completion
+	m.usage.Calls++
+	m.mu.Unlock()
+}
+
+func (m *UsageMeter) setProvider(name string) {
+	m.mu.Lock()
+	m.usage.Provider = name
+	m.mu.Unlock()
+}
+
+// wireUsage is the "usage" object of an OpenAI-compatible completion or final stream chunk.
+type wireUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// recordUsage adds one call to the meter in ctx, if any.
+func recordUsage(ctx context.Context, prompt, completion int) {
+	if meter := usageMeterFrom(ctx); meter != nil {
+		meter.add(prompt, completion)
+	}
+}
+
+func recordWireUsage(ctx context.Context, usage *wireUsage) {
+	if usage == nil {
+		recordUsage(ctx, 0, 0)
+		return
+	}
+	recordUsage(ctx, usage.PromptTokens, usage.CompletionTokens)
+}
@@ -0,0 +1,32 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageMeter_StreamAndComposite(t *testing.T) {
+	srv := sseStub(t,
+		contentChunk("Siap."),
+		`{"choices":[],"usage":{"prompt_tokens":120,"completion_tokens":8}}`,
+	)
+	composite := NewCompositeProvider(DefaultCompositeConfig(), Backend{Name: "openrouter", Provider: NewExternalProvider(srv.URL, "key", "model")})
+
+	meter := &UsageMeter{}
+	ctx := WithUsageMeter(context.Background(), meter)
+	for i := 0; i < 2; i++ {
+		_, err := composite.StreamWithTools(ctx, AIRequest{Prompt: "halo"}, func(string) error { return nil })
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, Usage{Provider: "openrouter", PromptTokens: 240, CompletionTokens: 16, Calls: 2}, meter.Usage())
+}
+
+func TestUsageMeter_NoMeterInContext(t *testing.T) {
+	srv := sseStub(t, contentChunk("Siap."))
+	_, err := NewExternalProvider(srv.URL, "key", "model").StreamWithTools(context.Background(), AIRequest{Prompt: "halo"}, func(string) error { return nil })
+	require.NoError(t, err)
+}
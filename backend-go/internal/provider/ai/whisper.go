@@ -0,0 +1,193 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Transcriber turns an audio file into text with per-segment and per-word confidence.
+type Transcriber interface {
+	Transcribe(ctx context.Context, path string, opts TranscribeOptions) (*Transcript, error)
+}
+
+type TranscribeOptions struct {
+	// Language is an ISO-639-1 code. Empty lets the model detect it.
+	Language string
+	// VAD asks the server to skip non-speech parts before decoding.
+	VAD bool
+}
+
+type Transcript struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language"` // ISO-639-1 code
+	Duration float64             `json:"duration"`
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+type TranscriptSegment struct {
+	Start        float64          `json:"start"`
+	End          float64          `json:"end"`
+	Text         string           `json:"text"`
+	Confidence   float64          `json:"confidence"` // exp(avg_logprob), 0-1
+	NoSpeechProb float64          `json:"no_speech_prob"`
+	Words        []TranscriptWord `json:"words,omitempty"`
+}
+
+type TranscriptWord struct {
+	Word        string  `json:"word"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Probability float64 `json:"probability"`
+}
+
+// WhisperClient calls an OpenAI-compatible /v1/audio/transcriptions endpoint such as
+// faster-whisper-server, asking for verbose_json with word timestamps.
+type WhisperClient struct {
+	url    string
+	model  string
+	client *http.Client
+}
+
+func NewWhisperClient(url, model string) *WhisperClient {
+	if model == "" {
+		model = "small"
+	}
+	return &WhisperClient{url: strings.TrimRight(url, "/"), model: model, client: &http.Client{}}
+}
+
+// whisperLanguageCodes maps the language names returned by OpenAI-style servers, and the codes
+// Whisper uses differently from ISO-639-1, back to ISO-639-1.
+var whisperLanguageCodes = map[string]string{
+	"indonesian": "id",
+	"english":    "en",
+	"javanese":   "jv",
+	"jw":         "jv",
+	"sundanese":  "su",
+	"malay":      "ms",
+}
+
+// NormalizeLanguage returns the ISO-639-1 code for a language reported by Whisper.
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if code, ok := whisperLanguageCodes[lang]; ok {
+		return code
+	}
+	return lang
+}
+
+func (c *WhisperClient) Transcribe(ctx context.Context, path string, opts TranscribeOptions) (*Transcript, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("[Whisper] failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	pr, pw := io.Pipe()
+	m := multipart.NewWriter(pw)
+	go func() {
+		fw, err := m.CreateFormFile("file", filepath.Base(path))
+		if err == nil {
+			_, err = io.Copy(fw, file)
+		}
+		if err == nil {
+			fields := [][2]string{
+				{"model", c.model},
+				{"response_format", "verbose_json"},
+				{"timestamp_granularities[]", "segment"},
+				{"timestamp_granularities[]", "word"},
+			}
+			if opts.Language != "" {
+				lang := opts.Language
+				if lang == "jv" {
+					lang = "jw" // Whisper's code for Javanese
+				}
+				fields = append(fields, [2]string{"language", lang})
+			}
+			if opts.VAD {
+				fields = append(fields, [2]string{"vad_filter", "true"})
+			}
+			for _, f := range fields {
+				if err = m.WriteField(f[0], f[1]); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			err = m.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url+"/v1/audio/transcriptions", pr)
+	if err != nil {
+		return nil, fmt.Errorf("[Whisper] failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", m.FormDataContentType())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[Whisper] request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("[Whisper] returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Text     string  `json:"text"`
+		Language string  `json:"language"`
+		Duration float64 `json:"duration"`
+		Segments []struct {
+			Start        float64          `json:"start"`
+			End          float64          `json:"end"`
+			Text         string           `json:"text"`
+			AvgLogprob   float64          `json:"avg_logprob"`
+			NoSpeechProb float64          `json:"no_speech_prob"`
+			Words        []TranscriptWord `json:"words"`
+		} `json:"segments"`
+		Words []TranscriptWord `json:"words"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("[Whisper] failed to decode response: %w", err)
+	}
+
+	t := &Transcript{
+		Text:     strings.TrimSpace(result.Text),
+		Language: NormalizeLanguage(result.Language),
+		Duration: result.Duration,
+	}
+	for _, s := range result.Segments {
+		t.Segments = append(t.Segments, TranscriptSegment{
+			Start:        s.Start,
+			End:          s.End,
+			Text:         strings.TrimSpace(s.Text),
+			Confidence:   math.Min(1, math.Exp(s.AvgLogprob)),
+			NoSpeechProb: s.NoSpeechProb,
+			Words:        s.Words,
+		})
+	}
+	// OpenAI returns words at the top level; spread them over the segments by time.
+	if len(result.Words) > 0 {
+		for i := range t.Segments {
+			if len(t.Segments[i].Words) > 0 {
+				continue
+			}
+			for _, w := range result.Words {
+				if w.Start >= t.Segments[i].Start && w.Start < t.Segments[i].End {
+					t.Segments[i].Words = append(t.Segments[i].Words, w)
+				}
+			}
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhisperClient_Transcribe(t *testing.T) {
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/transcriptions", r.URL.Path)
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		form = r.MultipartForm.Value
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		file.Close()
+		fmt.Fprint(w, `{"text":" beli kopi gopek","language":"indonesian","duration":2.5,
+			"segments":[{"start":0,"end":2.5,"text":" beli kopi gopek","avg_logprob":-0.4,"no_speech_prob":0.01}],
+			"words":[{"word":" beli","start":0,"end":0.4,"probability":0.98},{"word":" gopek","start":1.6,"end":2.4,"probability":0.31}]}`)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "voice.ogg")
+	require.NoError(t, os.WriteFile(path, []byte("OggS"), 0o600))
+
+	tr, err := NewWhisperClient(srv.URL+"/", "").Transcribe(context.Background(), path, TranscribeOptions{Language: "jv", VAD: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"small"}, form["model"])
+	assert.Equal(t, []string{"verbose_json"}, form["response_format"])
+	assert.Equal(t, []string{"segment", "word"}, form["timestamp_granularities[]"])
+	assert.Equal(t, []string{"jw"}, form["language"])
+	assert.Equal(t, []string{"true"}, form["vad_filter"])
+
+	assert.Equal(t, "beli kopi gopek", tr.Text)
+	assert.Equal(t, "id", tr.Language)
+	require.Len(t, tr.Segments, 1)
+	assert.InDelta(t, math.Exp(-0.4), tr.Segments[0].Confidence, 1e-9)
+	require.Len(t, tr.Segments[0].Words, 2)
+	assert.Equal(t, 0.31, tr.Segments[0].Words[1].Probability)
+}
+
+func TestWhisperClient_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "voice.ogg")
+	require.NoError(t, os.WriteFile(path, []byte("OggS"), 0o600))
+
+	_, err := NewWhisperClient(srv.URL, "").Transcribe(context.Background(), path, TranscribeOptions{})
+	assert.ErrorContains(t, err, "status 503")
+}
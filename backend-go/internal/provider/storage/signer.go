@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("tanda tangan tidak valid")
+	ErrURLExpired       = errors.New("url kedaluwarsa")
+)
+
+// URLSigner menandatangani URL lampiran dengan HMAC agar hanya pemilik (uid)
+// yang bisa mengaksesnya dan hanya dalam jangka waktu tertentu.
+type URLSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewURLSigner(secret string, ttl time.Duration) *URLSigner {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &URLSigner{secret: []byte(secret), ttl: ttl, now: time.Now}
+}
+
+func (s *URLSigner) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign mengembalikan query string "uid=..&exp=..&sig=.." untuk key tersebut.
+func (s *URLSigner) Sign(key string, userID uint) string {
+	exp := s.now().Add(s.ttl).Unix()
+	query := url.Values{}
+	query.Set("uid", strconv.FormatUint(uint64(userID), 10))
+	query.Set("exp", strconv.FormatInt(exp, 10))
+	query.Set("sig", s.mac(key, userID, exp))
+	return query.Encode()
+}
+
+// Verify memeriksa tanda tangan dan masa berlaku, lalu mengembalikan userID pemilik.
+func (s *URLSigner) Verify(key, uid, exp, sig string) (uint, error) {
+	userID, err := strconv.ParseUint(uid, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSignature
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSignature
+	}
+
+	expected := s.mac(key, uint(userID), expUnix)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return 0, ErrInvalidSignature
+	}
+	if s.now().Unix() > expUnix {
+		return 0, ErrURLExpired
+	}
+	return uint(userID), nil
+}
+
+func (s *URLSigner) mac(key string, userID uint, exp int64) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(key + "|" + strconv.FormatUint(uint64(userID), 10) + "|" + strconv.FormatInt(exp, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
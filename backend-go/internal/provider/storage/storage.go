@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("objek tidak ditemukan")
+
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// BlobStore adalah abstraksi penyimpanan lampiran (struk, voice note, gambar chat).
+// Key selalu berupa path relatif dengan pemisah "/", misalnya "images/12/1700000000_struk.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// Presigner diimplementasikan oleh store yang bisa memberikan URL unduhan langsung
+// berbatas waktu (misalnya S3/MinIO), sehingga file tidak perlu diproksikan lewat API.
+type Presigner interface {
+	PresignGet(key string, expiry time.Duration) (string, error)
+}
+
+// CleanKey menormalkan key dan menolak path traversal.
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(filepath.ToSlash(key), "/")
+	if key == "" {
+		return "", errors.New("key kosong")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", errors.New("key tidak valid")
+		}
+	}
+	return key, nil
+}
+
+func contentTypeByKey(key string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,154 @@
+package whatsapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Client adalah abstraksi wa-gateway yang dipakai service untuk mengirim
+// dan mengambil pesan WhatsApp.
+type Client interface {
+	SendMessage(phone, text string) error
+	SendFile(phone, caption, filename string, data []byte) error
+	// SendAudio mengirim audio OGG/Opus sebagai voice note.
+	SendAudio(phone, filename string, data []byte) error
+	DownloadMedia(mediaPath string) ([]byte, error)
+}
+
+type GatewayClient struct {
+	url      string
+	username string
+	password string
+}
+
+func NewGatewayClient(url, username, password string) *GatewayClient {
+	return &GatewayClient{url: url, username: username, password: password}
+}
+
+func (g *GatewayClient) SendMessage(phone, text string) error {
+	payload := map[string]interface{}{
+		"phone":   phone,
+		"message": text,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("gagal marshal payload: %w", err)
+	}
+
+	log.Debug().Str("phone", phone).Msg("[WA] SendMessage")
+	return g.post("/send/message", "application/json", bytes.NewReader(jsonData), 15*time.Second)
+}
+
+func (g *GatewayClient) SendFile(phone, caption, filename string, data []byte) error {
+	var body bytes.Buffer
+	m := multipart.NewWriter(&body)
+
+	if err := m.WriteField("phone", phone); err != nil {
+		return fmt.Errorf("gagal menulis field phone: %w", err)
+	}
+	if caption != "" {
+		if err := m.WriteField("caption", caption); err != nil {
+			return fmt.Errorf("gagal menulis field caption: %w", err)
+		}
+	}
+	fw, err := m.CreateFormFile("file", filename)
+	if err != nil {
+		return fmt.Errorf("gagal membuat form file: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("gagal menulis file: %w", err)
+	}
+	if err := m.Close(); err != nil {
+		return fmt.Errorf("gagal menutup multipart: %w", err)
+	}
+
+	log.Debug().Str("phone", phone).Str("filename", filename).Msg("[WA] SendFile")
+	return g.post("/send/file", m.FormDataContentType(), &body, 60*time.Second)
+}
+
+func (g *GatewayClient) SendAudio(phone, filename string, data []byte) error {
+	var body bytes.Buffer
+	m := multipart.NewWriter(&body)
+
+	if err := m.WriteField("phone", phone); err != nil {
+		return fmt.Errorf("gagal menulis field phone: %w", err)
+	}
+	// Tanpa ptt, wa-gateway mengirim audio sebagai lampiran file biasa, bukan voice note.
+	if err := m.WriteField("ptt", "true"); err != nil {
+		return fmt.Errorf("gagal menulis field ptt: %w", err)
+	}
+	fw, err := m.CreateFormFile("audio", filename)
+	if err != nil {
+		return fmt.Errorf("gagal membuat form audio: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("gagal menulis audio: %w", err)
+	}
+	if err := m.Close(); err != nil {
+		return fmt.Errorf("gagal menutup multipart: %w", err)
+	}
+
+	log.Debug().Str("phone", phone).Str("filename", filename).Msg("[WA] SendAudio")
+	return g.post("/send/audio", m.FormDataContentType(), &body, 60*time.Second)
+}
+
+func (g *GatewayClient) DownloadMedia(mediaPath string) ([]byte, error) {
+	url := g.url + "/" + strings.TrimPrefix(mediaPath, "/")
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat request: %w", err)
+	}
+	g.setAuth(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengunduh media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wa-gateway mengembalikan status %d untuk %s", resp.StatusCode, mediaPath)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (g *GatewayClient) post(path, contentType string, body io.Reader, timeout time.Duration) error {
+	req, err := http.NewRequest(http.MethodPost, g.url+path, body)
+	if err != nil {
+		return fmt.Errorf("gagal membuat request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	g.setAuth(req)
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gagal mengirim ke wa-gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("wa-gateway error %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+func (g *GatewayClient) setAuth(req *http.Request) {
+	if g.username != "" {
+		req.SetBasicAuth(g.username, g.password)
+	}
+}
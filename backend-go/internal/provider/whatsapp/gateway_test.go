@@ -0,0 +1,50 @@
+package whatsapp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayClient_SendAudioPostsVoiceNote(t *testing.T) {
+	var path, user, phone, ptt, filename string
+	var audio []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, _, _ = r.BasicAuth()
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		phone, ptt = r.FormValue("phone"), r.FormValue("ptt")
+		file, header, err := r.FormFile("audio")
+		require.NoError(t, err)
+		defer file.Close()
+		filename = header.Filename
+		audio, _ = io.ReadAll(file)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewGatewayClient(server.URL, "bot", "rahasia")
+	require.NoError(t, client.SendAudio("6281234567890", "balasan.ogg", []byte("OggS")))
+
+	assert.Equal(t, "/send/audio", path)
+	assert.Equal(t, "bot", user)
+	assert.Equal(t, "6281234567890", phone)
+	assert.Equal(t, "true", ptt)
+	assert.Equal(t, "balasan.ogg", filename)
+	assert.Equal(t, []byte("OggS"), audio)
+}
+
+func TestGatewayClient_SendAudioReturnsGatewayError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "device offline", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewGatewayClient(server.URL, "", "").SendAudio("62812", "balasan.ogg", []byte("OggS"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "device offline")
+}
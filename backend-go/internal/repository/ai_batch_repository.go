@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type AIBatchRepository interface {
+	Create(batch *entity.AIBatch) error
+	FindLastActive(userID uint) (*entity.AIBatch, error)
+	MarkUndone(id uint, at time.Time) error
+	WithTx(tx *gorm.DB) AIBatchRepository
+}
+
+type aiBatchRepository struct {
+	db *gorm.DB
+}
+
+func NewAIBatchRepository(db *gorm.DB) AIBatchRepository {
+	return &aiBatchRepository{db}
+}
+
+func (r *aiBatchRepository) WithTx(tx *gorm.DB) AIBatchRepository {
+	return &aiBatchRepository{db: tx}
+}
+
+func (r *aiBatchRepository) Create(batch *entity.AIBatch) error {
+	if err := r.db.Create(batch).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", batch.UserID).Msg("Database operation failed")
+		return err
+	}
+	return nil
+}
+
+// FindLastActive returns the newest batch of the user that has not been undone yet.
+func (r *aiBatchRepository) FindLastActive(userID uint) (*entity.AIBatch, error) {
+	var batch entity.AIBatch
+	err := r.db.Where("user_id = ? AND undone_at IS NULL", userID).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("id desc").
+		First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// MarkUndone only succeeds once per batch, so two concurrent undo requests cannot both revert it.
+func (r *aiBatchRepository) MarkUndone(id uint, at time.Time) error {
+	result := r.db.Model(&entity.AIBatch{}).
+		Where("id = ? AND undone_at IS NULL", id).
+		Update("undone_at", at)
+	if result.Error != nil {
+		log.Error().Err(result.Error).Uint("batch_id", id).Msg("Database operation failed")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type AIDraftRepository interface {
+	Create(draft *entity.AIDraft) error
+	FindByID(id uint, userID uint) (*entity.AIDraft, error)
+	FindPending(userID uint, now time.Time) ([]entity.AIDraft, error)
+	Resolve(id uint, userID uint, status string, items entity.JSONText, at time.Time) error
+}
+
+type aiDraftRepository struct {
+	db *gorm.DB
+}
+
+func NewAIDraftRepository(db *gorm.DB) AIDraftRepository {
+	return &aiDraftRepository{db}
+}
+
+func (r *aiDraftRepository) Create(draft *entity.AIDraft) error {
+	if err := r.db.Create(draft).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", draft.UserID).Msg("Database operation failed")
+		return err
+	}
+	return nil
+}
+
+func (r *aiDraftRepository) FindByID(id uint, userID uint) (*entity.AIDraft, error) {
+	var draft entity.AIDraft
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&draft).Error; err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// FindPending returns the user's unexpired pending drafts, newest first.
+func (r *aiDraftRepository) FindPending(userID uint, now time.Time) ([]entity.AIDraft, error) {
+	var drafts []entity.AIDraft
+	err := r.db.Where("user_id = ? AND status = ? AND expires_at > ?", userID, entity.AIDraftPending, now).
+		Order("id desc").
+		Find(&drafts).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+	}
+	return drafts, err
+}
+
+// Resolve only moves a draft out of pending once, so a web confirm and a WhatsApp "ya"
+// racing each other cannot both commit it. Empty items keeps the proposed ones.
+func (r *aiDraftRepository) Resolve(id uint, userID uint, status string, items entity.JSONText, at time.Time) error {
+	updates := map[string]interface{}{"status": status, "resolved_at": at}
+	if items != "" {
+		updates["items"] = items
+	}
+	result := r.db.Model(&entity.AIDraft{}).
+		Where("id = ? AND user_id = ? AND status = ? AND expires_at > ?", id, userID, entity.AIDraftPending, at).
+		Updates(updates)
+	if result.Error != nil {
+		log.Error().Err(result.Error).Uint("draft_id", id).Msg("Database operation failed")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
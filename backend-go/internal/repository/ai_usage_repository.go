@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type AIUsageRepository interface {
+	Create(usage *entity.AIUsage) error
+	// DailySummary aggregates usage per user and day for fromDay..toDay (YYYY-MM-DD, inclusive).
+	// userID 0 means every user.
+	DailySummary(fromDay, toDay string, userID uint) ([]entity.AIUsageDaily, error)
+}
+
+type aiUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewAIUsageRepository(db *gorm.DB) AIUsageRepository {
+	return &aiUsageRepository{db}
+}
+
+func (r *aiUsageRepository) Create(usage *entity.AIUsage) error {
+	if err := r.db.Create(usage).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", usage.UserID).Msg("Database operation failed")
+		return err
+	}
+	return nil
+}
+
+func (r *aiUsageRepository) DailySummary(fromDay, toDay string, userID uint) ([]entity.AIUsageDaily, error) {
+	results := make([]entity.AIUsageDaily, 0)
+
+	query := r.db.Table("ai_usages as u").
+		Select("u.day, u.user_id, COALESCE(MAX(usr.name), '') as user_name, COUNT(*) as requests, "+
+			"SUM(u.prompt_tokens) as prompt_tokens, SUM(u.completion_tokens) as completion_tokens, "+
+			"COALESCE(AVG(CASE WHEN u.status = 'ok' THEN u.latency_ms END), 0) as avg_latency_ms, "+
+			"SUM(CASE WHEN u.has_image THEN 1 ELSE 0 END) as image_requests, "+
+			"SUM(CASE WHEN u.has_voice THEN 1 ELSE 0 END) as voice_requests, "+
+			"SUM(CASE WHEN u.status = 'error' THEN 1 ELSE 0 END) as errors, "+
+			"SUM(CASE WHEN u.status = 'busy' THEN 1 ELSE 0 END) as busy, "+
+			"SUM(CASE WHEN u.status = 'rate_limited' THEN 1 ELSE 0 END) as rate_limited").
+		Joins("LEFT JOIN users usr ON usr.id = u.user_id").
+		Where("u.day BETWEEN ? AND ?", fromDay, toDay)
+
+	if userID != 0 {
+		query = query.Where("u.user_id = ?", userID)
+	}
+
+	err := query.Group("u.day, u.user_id").Order("u.day desc, requests desc").Scan(&results).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, err
+	}
+	return results, nil
+}
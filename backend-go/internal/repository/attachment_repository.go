@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// AttachmentRepository answers "who still points at this blob?" across every
+// table that stores an attachment reference, including receipt images held by
+// pending AI drafts that are not saved as transactions yet.
+type AttachmentRepository interface {
+	IsReferenced(refs []string) (bool, error)
+	IsOwnedBy(userID uint, refs []string) (bool, error)
+	ReferencedKeys() ([]string, error)
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) IsReferenced(refs []string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&entity.Transaction{}).Where("attachment IN ?", refs).Count(&count).Error; err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	err := r.db.Model(&entity.ChatMessage{}).
+		Where("image_url IN ? OR audio_url IN ?", refs, refs).
+		Count(&count).Error
+	if err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	drafted, err := r.draftAttachments()
+	if err != nil {
+		return false, err
+	}
+	for _, key := range drafted {
+		for _, ref := range refs {
+			if key == ref {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *attachmentRepository) IsOwnedBy(userID uint, refs []string) (bool, error) {
+	var count int64
+	err := r.db.Model(&entity.Transaction{}).
+		Where("user_id = ? AND attachment IN ?", userID, refs).
+		Count(&count).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	err = r.db.Model(&entity.ChatMessage{}).
+		Where("user_id = ? AND (image_url IN ? OR audio_url IN ?)", userID, refs, refs).
+		Count(&count).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *attachmentRepository) ReferencedKeys() ([]string, error) {
+	var refs []string
+	if err := r.db.Model(&entity.Transaction{}).Where("attachment <> ''").Pluck("attachment", &refs).Error; err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+		return nil, err
+	}
+
+	var images, audios []string
+	if err := r.db.Model(&entity.ChatMessage{}).Where("image_url <> ''").Pluck("image_url", &images).Error; err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+		return nil, err
+	}
+	if err := r.db.Model(&entity.ChatMessage{}).Where("audio_url <> ''").Pluck("audio_url", &audios).Error; err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+		return nil, err
+	}
+
+	drafted, err := r.draftAttachments()
+	if err != nil {
+		return nil, err
+	}
+
+	refs = append(refs, images...)
+	refs = append(refs, audios...)
+	return append(refs, drafted...), nil
+}
+
+// draftAttachments returns the attachment keys of pending, unexpired AI drafts. The keys live
+// inside the JSON items, so they are extracted here instead of in SQL to stay portable.
+func (r *attachmentRepository) draftAttachments() ([]string, error) {
+	var drafts []entity.AIDraft
+	err := r.db.Select("id", "items").
+		Where("status = ? AND expires_at > ?", entity.AIDraftPending, time.Now()).
+		Find(&drafts).Error
+	if err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+		return nil, err
+	}
+
+	var keys []string
+	for _, draft := range drafts {
+		var items []entity.TransactionItemAI
+		if err := json.Unmarshal([]byte(draft.Items), &items); err != nil {
+			log.Warn().Err(err).Uint("draft_id", draft.ID).Msg("Invalid AI draft items")
+			continue
+		}
+		for _, item := range items {
+			if item.Attachment != "" {
+				keys = append(keys, item.Attachment)
+			}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// AuditRepository is read-only: events are written by the audit GORM plugin
+// inside the mutating transaction and are never updated or deleted.
+type AuditRepository interface {
+	FindAll(userID uint, params entity.AuditFilterParams) ([]entity.AuditEvent, int64, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) FindAll(userID uint, params entity.AuditFilterParams) ([]entity.AuditEvent, int64, error) {
+	var events []entity.AuditEvent
+	var total int64
+
+	query := r.db.Model(&entity.AuditEvent{}).Where("user_id = ?", userID)
+
+	if params.EntityType != "" {
+		query = query.Where("entity_type = ?", params.EntityType)
+	}
+	if params.EntityID != 0 {
+		query = query.Where("entity_id = ?", params.EntityID)
+	}
+	if params.Actor != "" {
+		query = query.Where("actor = ?", params.Actor)
+	}
+	if params.Action != "" {
+		query = query.Where("action = ?", params.Action)
+	}
+	if params.RequestID != "" {
+		query = query.Where("request_id = ?", params.RequestID)
+	}
+	if params.StartDate != "" {
+		query = query.Where("created_at >= ?", params.StartDate)
+	}
+	if params.EndDate != "" {
+		query = query.Where("created_at < ?", params.EndDate)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, 0, err
+	}
+
+	offset := (params.Page - 1) * params.Limit
+	if params.Limit > 0 {
+		query = query.Offset(offset).Limit(params.Limit)
+	}
+
+	if err := query.Order("id desc").Find(&events).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
@@ -2,6 +2,9 @@ package repository
 
 import (
 	"cuan-backend/internal/entity"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
@@ -10,7 +13,14 @@ import (
 type ChatRepository interface {
 	Save(msg *entity.ChatMessage) error
 	FindByUserID(userID uint, limit int) ([]entity.ChatMessage, error)
+	FindRecentByUserID(userID uint, limit int) ([]entity.ChatMessage, error)
+	FindPage(userID uint, before uint, limit int, search string) ([]entity.ChatMessage, error)
 	DeleteByUserID(userID uint) error
+	DeleteMessage(id uint, userID uint) (*entity.ChatMessage, error)
+	DeleteOlderThan(userID uint, cutoff time.Time) ([]entity.ChatMessage, error)
+	FindSummary(userID uint) (*entity.ChatSummary, error)
+	SaveSummary(summary *entity.ChatSummary) error
+	DeleteSummary(userID uint) error
 }
 
 type chatRepository struct {
@@ -42,8 +52,121 @@ func (r *chatRepository) FindByUserID(userID uint, limit int) ([]entity.ChatMess
 	return messages, err
 }
 
+// FindRecentByUserID returns the newest limit messages, oldest first.
+func (r *chatRepository) FindRecentByUserID(userID uint, limit int) ([]entity.ChatMessage, error) {
+	var messages []entity.ChatMessage
+	err := r.db.
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// likeEscaper escapes LIKE wildcards so a search for "50%" matches the literal text.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// FindPage returns up to limit messages older than the before cursor (any message when before is
+// 0), newest first. A non-empty search matches the content with Postgres full-text search; other
+// databases (sqlite in tests) fall back to a case-insensitive substring match.
+func (r *chatRepository) FindPage(userID uint, before uint, limit int, search string) ([]entity.ChatMessage, error) {
+	var messages []entity.ChatMessage
+	query := r.db.Where("user_id = ?", userID)
+	if before > 0 {
+		query = query.Where("id < ?", before)
+	}
+	if search != "" {
+		if r.db.Dialector.Name() == "postgres" {
+			query = query.Where("to_tsvector('simple', content) @@ plainto_tsquery('simple', ?)", search)
+		} else {
+			query = query.Where(`LOWER(content) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(search))+"%")
+		}
+	}
+	if err := query.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, err
+	}
+	return messages, nil
+}
+
+// DeleteByUserID removes the messages together with their rolling summary.
 func (r *chatRepository) DeleteByUserID(userID uint) error {
-	if err := r.db.Where("user_id = ?", userID).Delete(&entity.ChatMessage{}).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.ChatMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&entity.ChatSummary{}).Error
+	})
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return err
+	}
+	return nil
+}
+
+// DeleteMessage removes one of the user's messages and returns it, so its attachments can be
+// released. It returns gorm.ErrRecordNotFound when the user has no such message.
+func (r *chatRepository) DeleteMessage(id uint, userID uint) (*entity.ChatMessage, error) {
+	var msg entity.ChatMessage
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&msg).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&msg).Error
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Error().Err(err).Uint("message_id", id).Msg("Database operation failed")
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// DeleteOlderThan removes the user's messages created before cutoff and returns them.
+func (r *chatRepository) DeleteOlderThan(userID uint, cutoff time.Time) ([]entity.ChatMessage, error) {
+	var messages []entity.ChatMessage
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "audio_url", "image_url").Where("user_id = ? AND created_at < ?", userID, cutoff).Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		return tx.Where("user_id = ? AND created_at < ?", userID, cutoff).Delete(&entity.ChatMessage{}).Error
+	})
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *chatRepository) FindSummary(userID uint) (*entity.ChatSummary, error) {
+	var summary entity.ChatSummary
+	if err := r.db.Where("user_id = ?", userID).First(&summary).Error; err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (r *chatRepository) SaveSummary(summary *entity.ChatSummary) error {
+	if err := r.db.Save(summary).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", summary.UserID).Msg("Database operation failed")
+		return err
+	}
+	return nil
+}
+
+func (r *chatRepository) DeleteSummary(userID uint) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&entity.ChatSummary{}).Error; err != nil {
 		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
 		return err
 	}
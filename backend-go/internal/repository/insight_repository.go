@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InsightRepository interface {
+	CreateBatch(insights []entity.Insight) (int64, error)
+	FindByUserID(userID uint, unreadOnly bool, limit int) ([]entity.Insight, error)
+	CountUnread(userID uint) (int64, error)
+	HasPeriod(userID uint, periodStart string) (bool, error)
+	MarkRead(id uint, userID uint, at time.Time) error
+	MarkAllRead(userID uint, at time.Time) error
+}
+
+type insightRepository struct {
+	db *gorm.DB
+}
+
+func NewInsightRepository(db *gorm.DB) InsightRepository {
+	return &insightRepository{db}
+}
+
+// CreateBatch inserts insights, skipping any fact already stored for the same cycle
+// (idx_insight_fact), and returns how many rows were actually inserted.
+func (r *insightRepository) CreateBatch(insights []entity.Insight) (int64, error) {
+	if len(insights) == 0 {
+		return 0, nil
+	}
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "period_start"}, {Name: "kind"}, {Name: "subject"}},
+		DoNothing: true,
+	}).Create(&insights)
+	if result.Error != nil {
+		log.Error().Err(result.Error).Uint("user_id", insights[0].UserID).Msg("Database operation failed")
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// FindByUserID returns the newest insights first; limit <= 0 returns all of them.
+func (r *insightRepository) FindByUserID(userID uint, unreadOnly bool, limit int) ([]entity.Insight, error) {
+	var insights []entity.Insight
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Order("id desc").Find(&insights).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, err
+	}
+	return insights, nil
+}
+
+func (r *insightRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&entity.Insight{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+	}
+	return count, err
+}
+
+// HasPeriod reports whether insights were already generated for the cycle starting at periodStart.
+func (r *insightRepository) HasPeriod(userID uint, periodStart string) (bool, error) {
+	var count int64
+	err := r.db.Model(&entity.Insight{}).Where("user_id = ? AND period_start = ?", userID, periodStart).Count(&count).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+	}
+	return count > 0, err
+}
+
+func (r *insightRepository) MarkRead(id uint, userID uint, at time.Time) error {
+	result := r.db.Model(&entity.Insight{}).Where("id = ? AND user_id = ?", id, userID).Update("read_at", gorm.Expr("COALESCE(read_at, ?)", at))
+	if result.Error != nil {
+		log.Error().Err(result.Error).Uint("insight_id", id).Msg("Database operation failed")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *insightRepository) MarkAllRead(userID uint, at time.Time) error {
+	err := r.db.Model(&entity.Insight{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", at).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+	}
+	return err
+}
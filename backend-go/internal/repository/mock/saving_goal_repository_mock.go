@@ -2,14 +2,24 @@ package mock
 
 import (
 	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
 
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 type SavingGoalRepositoryMock struct {
 	mock.Mock
 }
 
+func (m *SavingGoalRepositoryMock) WithTx(tx *gorm.DB) repository.SavingGoalRepository {
+	args := m.Called(tx)
+	if args.Get(0) == nil {
+		return m
+	}
+	return args.Get(0).(repository.SavingGoalRepository)
+}
+
 func (m *SavingGoalRepositoryMock) Create(goal *entity.SavingGoal) error {
 	args := m.Called(goal)
 	return args.Error(0)
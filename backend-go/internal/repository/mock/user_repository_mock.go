@@ -43,3 +43,11 @@ func (m *UserRepositoryMock) FindByPhone(phone string) (*entity.User, error) {
 	}
 	return args.Get(0).(*entity.User), args.Error(1)
 }
+
+func (m *UserRepositoryMock) FindAll() ([]entity.User, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.User), args.Error(1)
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type ReportScheduleRepository interface {
+	FindByUserID(userID uint) (*entity.ReportSchedule, error)
+	FindActive() ([]entity.ReportSchedule, error)
+	Save(schedule *entity.ReportSchedule) error
+}
+
+type reportScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewReportScheduleRepository(db *gorm.DB) ReportScheduleRepository {
+	return &reportScheduleRepository{db}
+}
+
+func (r *reportScheduleRepository) FindByUserID(userID uint) (*entity.ReportSchedule, error) {
+	var schedule entity.ReportSchedule
+	err := r.db.Where("user_id = ?", userID).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *reportScheduleRepository) FindActive() ([]entity.ReportSchedule, error) {
+	var schedules []entity.ReportSchedule
+	err := r.db.Preload("User").
+		Where("(weekly = ? OR on_payday = ?) AND (via_whatsapp = ? OR via_email = ?)", true, true, true, true).
+		Find(&schedules).Error
+	if err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+	}
+	return schedules, err
+}
+
+func (r *reportScheduleRepository) Save(schedule *entity.ReportSchedule) error {
+	if err := r.db.Omit("User").Save(schedule).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", schedule.UserID).Msg("Database operation failed")
+		return err
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 )
 
 type SavingGoalRepository interface {
+	WithTx(tx *gorm.DB) SavingGoalRepository
 	Create(goal *entity.SavingGoal) error
 	FindAll(userID uint) ([]entity.SavingGoal, error)
 	FindByID(id uint, userID uint) (*entity.SavingGoal, error)
@@ -29,6 +30,10 @@ func NewSavingGoalRepository(db *gorm.DB) SavingGoalRepository {
 	return &savingGoalRepository{db: db}
 }
 
+func (r *savingGoalRepository) WithTx(tx *gorm.DB) SavingGoalRepository {
+	return &savingGoalRepository{db: tx}
+}
+
 func (r *savingGoalRepository) Create(goal *entity.SavingGoal) error {
 	if err := r.db.Create(goal).Error; err != nil {
 		log.Error().Err(err).Uint("user_id", goal.UserID).Msg("Database operation failed")
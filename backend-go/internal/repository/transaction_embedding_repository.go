@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TransactionEmbeddingRepository interface {
+	FindPending(userID uint, model string, limit int) ([]entity.Transaction, error)
+	Upsert(embeddings []entity.TransactionEmbedding) error
+	FindByUserSince(userID uint, model string, since time.Time) ([]entity.TransactionEmbedding, error)
+}
+
+type transactionEmbeddingRepository struct {
+	db *gorm.DB
+}
+
+func NewTransactionEmbeddingRepository(db *gorm.DB) TransactionEmbeddingRepository {
+	return &transactionEmbeddingRepository{db}
+}
+
+// FindPending returns transactions with no embedding for model yet, or edited since they were
+// embedded, oldest first. userID 0 means every user.
+func (r *transactionEmbeddingRepository) FindPending(userID uint, model string, limit int) ([]entity.Transaction, error) {
+	var txns []entity.Transaction
+	query := r.db.Model(&entity.Transaction{}).
+		Joins("LEFT JOIN transaction_embeddings e ON e.transaction_id = transactions.id").
+		Where("e.transaction_id IS NULL OR e.model <> ? OR e.source_updated_at < transactions.updated_at", model).
+		Preload("Category").
+		Order("transactions.id asc").
+		Limit(limit)
+	if userID != 0 {
+		query = query.Where("transactions.user_id = ?", userID)
+	}
+	if err := query.Find(&txns).Error; err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, err
+	}
+	return txns, nil
+}
+
+func (r *transactionEmbeddingRepository) Upsert(embeddings []entity.TransactionEmbedding) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "transaction_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "model", "vector", "source_updated_at", "updated_at"}),
+	}).Create(&embeddings).Error
+	if err != nil {
+		log.Error().Err(err).Int("count", len(embeddings)).Msg("Database operation failed")
+	}
+	return err
+}
+
+// FindByUserSince returns the user's embeddings for model whose transaction date is on or after
+// since, with the transaction, wallet and category loaded.
+func (r *transactionEmbeddingRepository) FindByUserSince(userID uint, model string, since time.Time) ([]entity.TransactionEmbedding, error) {
+	var embeddings []entity.TransactionEmbedding
+	err := r.db.Joins("JOIN transactions ON transactions.id = transaction_embeddings.transaction_id").
+		Where("transaction_embeddings.user_id = ? AND transaction_embeddings.model = ? AND transactions.date >= ?", userID, model, since).
+		Preload("Transaction.Wallet").
+		Preload("Transaction.Category").
+		Find(&embeddings).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+		return nil, err
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"cuan-backend/internal/entity"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserAliasRepository interface {
+	FindByUserID(userID uint) ([]entity.UserAlias, error)
+	// Upsert menyimpan alias baru atau mengganti target alias yang sudah ada.
+	Upsert(alias *entity.UserAlias) error
+	Delete(id uint, userID uint) error
+}
+
+type userAliasRepository struct {
+	db *gorm.DB
+}
+
+func NewUserAliasRepository(db *gorm.DB) UserAliasRepository {
+	return &userAliasRepository{db}
+}
+
+func (r *userAliasRepository) FindByUserID(userID uint) ([]entity.UserAlias, error) {
+	var aliases []entity.UserAlias
+	err := r.db.Where("user_id = ?", userID).Order("alias ASC").Find(&aliases).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Database operation failed")
+	}
+	return aliases, err
+}
+
+func (r *userAliasRepository) Upsert(alias *entity.UserAlias) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "alias"}},
+		DoUpdates: clause.AssignmentColumns([]string{"target", "updated_at"}),
+	}).Create(alias).Error
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", alias.UserID).Msg("Database operation failed")
+		return err
+	}
+	return nil
+}
+
+func (r *userAliasRepository) Delete(id uint, userID uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&entity.UserAlias{})
+	if result.Error != nil {
+		log.Error().Err(result.Error).Uint("alias_id", id).Uint("user_id", userID).Msg("Database operation failed")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ type UserRepository interface {
 	FindByEmail(email string) (*entity.User, error)
 	FindByID(id uint) (*entity.User, error)
 	FindByPhone(phone string) (*entity.User, error)
+	FindAll() ([]entity.User, error)
 	Update(user *entity.User) error
 }
 
@@ -67,3 +68,12 @@ func (r *userRepository) FindByPhone(phone string) (*entity.User, error) {
 	}
 	return &user, nil
 }
+
+func (r *userRepository) FindAll() ([]entity.User, error) {
+	var users []entity.User
+	if err := r.db.Order("id asc").Find(&users).Error; err != nil {
+		log.Error().Err(err).Msg("Database operation failed")
+		return nil, err
+	}
+	return users, nil
+}
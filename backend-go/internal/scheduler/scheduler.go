@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Every menjalankan fn secara periodik di goroutine terpisah sampai ctx dibatalkan.
+// Panic di dalam fn di-recover supaya satu job yang gagal tidak mematikan server.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(now time.Time)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Info().Str("job", name).Dur("interval", interval).Msg("Scheduler job started")
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info().Str("job", name).Msg("Scheduler job stopped")
+				return
+			case now := <-ticker.C:
+				runSafely(name, fn, now)
+			}
+		}
+	}()
+}
+
+func runSafely(name string, fn func(now time.Time), now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("job", name).Interface("panic", r).Msg("Scheduler job panicked")
+		}
+	}()
+
+	start := time.Now()
+	fn(now)
+	log.Debug().Str("job", name).Dur("duration", time.Since(start)).Msg("Scheduler job finished")
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"cuan-backend/internal/audit"
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNothingToUndo = errors.New("tidak ada aksi AI yang bisa dibatalkan")
+
+// ErrNotUndoable means the newest AI batch contains an action (debt, transfer, saving, wishlist)
+// that undo cannot revert. Older batches are left alone so "batal" never reverts the wrong action.
+var ErrNotUndoable = errors.New("aksi AI terakhir tidak bisa dibatalkan otomatis")
+
+type AIBatchService interface {
+	// Snapshot captures a transaction (and its transfer pair) before the AI updates or deletes it.
+	Snapshot(userID uint, transactionID uint) (entity.JSONText, error)
+	Record(userID uint, items []entity.AIBatchItem) (*entity.AIBatch, error)
+	// UndoLast reverts the newest batch of the user, including wallet balances, in one DB transaction.
+	UndoLast(userID uint) (*entity.AIBatch, error)
+	// WithContext menyimpan ctx; actor dan request_id-nya ikut disimpan pada batch dan audit transaksinya.
+	WithContext(ctx context.Context) AIBatchService
+}
+
+type aiBatchService struct {
+	repo repository.AIBatchRepository
+	db   *gorm.DB
+	ctx  context.Context
+}
+
+func NewAIBatchService(repo repository.AIBatchRepository, db *gorm.DB) AIBatchService {
+	return &aiBatchService{
+		repo: repo,
+		db:   db,
+		ctx:  context.Background(),
+	}
+}
+
+// transactionState is the subset of a transaction needed to put it back exactly as it was.
+type transactionState struct {
+	ID                   uint      `json:"id"`
+	RelatedTransactionID *uint     `json:"related_transaction_id"`
+	WalletID             uint      `json:"wallet_id"`
+	CategoryID           uint      `json:"category_id"`
+	Amount               float64   `json:"amount"`
+	Type                 string    `json:"type"`
+	Description          string    `json:"description"`
+	Attachment           string    `json:"attachment"`
+	Date                 time.Time `json:"date"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+type transactionSnapshot struct {
+	Transaction transactionState  `json:"transaction"`
+	Related     *transactionState `json:"related,omitempty"`
+}
+
+func newTransactionState(t *entity.Transaction) transactionState {
+	return transactionState{
+		ID:                   t.ID,
+		RelatedTransactionID: t.RelatedTransactionID,
+		WalletID:             t.WalletID,
+		CategoryID:           t.CategoryID,
+		Amount:               t.Amount,
+		Type:                 t.Type,
+		Description:          t.Description,
+		Attachment:           t.Attachment,
+		Date:                 t.Date,
+		CreatedAt:            t.CreatedAt,
+	}
+}
+
+func (s *aiBatchService) WithContext(ctx context.Context) AIBatchService {
+	db := s.db.WithContext(ctx)
+	return &aiBatchService{
+		repo: s.repo.WithTx(db),
+		db:   db,
+		ctx:  ctx,
+	}
+}
+
+func (s *aiBatchService) Snapshot(userID uint, transactionID uint) (entity.JSONText, error) {
+	var t entity.Transaction
+	if err := s.db.Where("id = ? AND user_id = ?", transactionID, userID).First(&t).Error; err != nil {
+		return "", err
+	}
+
+	snapshot := transactionSnapshot{Transaction: newTransactionState(&t)}
+	if t.RelatedTransactionID != nil {
+		var related entity.Transaction
+		if err := s.db.Where("id = ? AND user_id = ?", *t.RelatedTransactionID, userID).First(&related).Error; err == nil {
+			state := newTransactionState(&related)
+			snapshot.Related = &state
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+	return entity.JSONText(data), nil
+}
+
+func (s *aiBatchService) Record(userID uint, items []entity.AIBatchItem) (*entity.AIBatch, error) {
+	batch := &entity.AIBatch{
+		UserID:    userID,
+		Actor:     audit.ActorFrom(s.ctx),
+		RequestID: audit.RequestIDFrom(s.ctx),
+		Items:     items,
+	}
+	if err := s.repo.Create(batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (s *aiBatchService) UndoLast(userID uint) (*entity.AIBatch, error) {
+	var undone *entity.AIBatch
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+
+		batch, err := repo.FindLastActive(userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return err
+		}
+		for _, item := range batch.Items {
+			if !item.Undoable() {
+				return fmt.Errorf("%w: %s", ErrNotUndoable, item.Description)
+			}
+		}
+
+		// Tandai dulu agar undo paralel untuk batch yang sama gagal sebelum menyentuh saldo.
+		if err := repo.MarkUndone(batch.ID, time.Now()); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNothingToUndo
+			}
+			return err
+		}
+
+		for i := len(batch.Items) - 1; i >= 0; i-- {
+			item := batch.Items[i]
+			if err := revertBatchItem(tx, userID, item); err != nil {
+				return fmt.Errorf("gagal membatalkan '%s': %w", item.Description, err)
+			}
+		}
+
+		undone = batch
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Uint("user_id", userID).Uint("batch_id", undone.ID).Int("items", len(undone.Items)).Msg("AI batch undone")
+	return undone, nil
+}
+
+func revertBatchItem(tx *gorm.DB, userID uint, item entity.AIBatchItem) error {
+	if item.Action == "create" {
+		return removeTransaction(tx, userID, item.TransactionID)
+	}
+
+	var snapshot transactionSnapshot
+	if err := json.Unmarshal([]byte(item.Before), &snapshot); err != nil {
+		return fmt.Errorf("snapshot tidak valid: %w", err)
+	}
+	if err := restoreTransaction(tx, userID, snapshot.Transaction); err != nil {
+		return err
+	}
+	if snapshot.Related != nil {
+		return restoreTransaction(tx, userID, *snapshot.Related)
+	}
+	return nil
+}
+
+// removeTransaction undoes a create. A transaction the user already deleted by hand is skipped,
+// since that delete has reverted its balance already.
+func removeTransaction(tx *gorm.DB, userID uint, id uint) error {
+	var t entity.Transaction
+	err := tx.Where("id = ? AND user_id = ?", id, userID).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := adjustWalletBalance(tx, userID, t.WalletID, -balanceEffect(t.Type, t.Amount)); err != nil {
+		return err
+	}
+	return tx.Where("id = ? AND user_id = ?", id, userID).Delete(&entity.Transaction{}).Error
+}
+
+// restoreTransaction puts a transaction back to state, re-inserting it under its old ID if it was deleted.
+func restoreTransaction(tx *gorm.DB, userID uint, state transactionState) error {
+	var current entity.Transaction
+	err := tx.Where("id = ? AND user_id = ?", state.ID, userID).First(&current).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if exists {
+		if err := adjustWalletBalance(tx, userID, current.WalletID, -balanceEffect(current.Type, current.Amount)); err != nil {
+			return err
+		}
+	} else {
+		current = entity.Transaction{ID: state.ID, UserID: userID, CreatedAt: state.CreatedAt}
+	}
+
+	current.RelatedTransactionID = state.RelatedTransactionID
+	current.WalletID = state.WalletID
+	current.CategoryID = state.CategoryID
+	current.Amount = state.Amount
+	current.Type = state.Type
+	current.Description = state.Description
+	current.Attachment = state.Attachment
+	current.Date = state.Date
+
+	if exists {
+		err = tx.Omit(clause.Associations).Save(&current).Error
+	} else {
+		err = tx.Omit(clause.Associations).Create(&current).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	return adjustWalletBalance(tx, userID, state.WalletID, balanceEffect(state.Type, state.Amount))
+}
+
+// balanceEffect is how much a transaction of the given type moved its wallet balance.
+func balanceEffect(txType string, amount float64) float64 {
+	switch txType {
+	case "income", "transfer_in":
+		return amount
+	case "expense", "transfer_out":
+		return -amount
+	}
+	return 0
+}
+
+func adjustWalletBalance(tx *gorm.DB, userID uint, walletID uint, delta float64) error {
+	if delta == 0 {
+		return nil
+	}
+	result := tx.Model(&entity.Wallet{}).
+		Where("id = ? AND user_id = ?", walletID, userID).
+		Update("balance", gorm.Expr("balance + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("wallet not found")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"cuan-backend/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupAIBatchTest(t *testing.T) (*gorm.DB, *service.ChatbotService) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}, &entity.Wallet{}, &entity.Category{}, &entity.Transaction{}, &entity.AIBatch{}, &entity.AIBatchItem{}))
+
+	require.NoError(t, db.Create(&entity.User{ID: 1, Email: "batch@test.com"}).Error)
+	require.NoError(t, db.Create(&entity.Wallet{ID: 1, UserID: 1, Name: "Tunai", Balance: 80000}).Error)
+	require.NoError(t, db.Create(&entity.Category{ID: 1, UserID: 1, Name: "Makan", Type: "expense"}).Error)
+	require.NoError(t, db.Create(&entity.Transaction{ID: 10, UserID: 1, WalletID: 1, CategoryID: 1, Amount: 20000, Type: "expense", Description: "Bakso", Date: time.Now()}).Error)
+
+	walletRepo := repository.NewWalletRepository(db)
+	txRepo := repository.NewTransactionRepository(db)
+	txSvc := service.NewTransactionService(txRepo, walletRepo, db)
+	batchSvc := service.NewAIBatchService(repository.NewAIBatchRepository(db), db)
+
+	chatbot := service.NewChatbotService(
+		walletRepo, repository.NewCategoryRepository(db), txSvc,
+		txRepo, nil, nil, nil, nil, nil,
+		batchSvc, nil, nil, nil, nil,
+	)
+	return db, chatbot
+}
+
+func walletBalance(t *testing.T, db *gorm.DB) float64 {
+	var w entity.Wallet
+	require.NoError(t, db.First(&w, 1).Error)
+	return w.Balance
+}
+
+func TestUndoLastBatch_RevertsCreateUpdateDelete(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+
+	require.NoError(t, db.Create(&entity.Transaction{ID: 11, UserID: 1, WalletID: 1, CategoryID: 1, Amount: 5000, Type: "expense", Description: "Parkir", Date: time.Now()}).Error)
+	require.NoError(t, db.Model(&entity.Wallet{}).Where("id = ?", 1).Update("balance", 75000).Error)
+
+	saved, err := chatbot.SaveTransactions(1, []entity.TransactionItemAI{
+		{Action: "create", Description: "Kopi", Amount: 15000, Type: "expense", CategoryName: "Makan", WalletName: "Tunai"},
+		{Action: "update", ID: 10, Description: "Bakso jumbo", Amount: 30000, Type: "expense", CategoryName: "Makan", WalletName: "Tunai"},
+		{Action: "delete", ID: 11},
+	})
+	require.NoError(t, err)
+	require.Len(t, saved, 3)
+	assert.Equal(t, float64(75000-15000-10000+5000), walletBalance(t, db))
+
+	batch, err := chatbot.UndoLastBatch(1)
+	require.NoError(t, err)
+	assert.Len(t, batch.Items, 3)
+
+	assert.Equal(t, float64(75000), walletBalance(t, db))
+
+	var count int64
+	db.Model(&entity.Transaction{}).Where("id = ?", saved[0].ID).Count(&count)
+	assert.Zero(t, count, "created transaction should be removed")
+
+	var updated entity.Transaction
+	require.NoError(t, db.First(&updated, 10).Error)
+	assert.Equal(t, "Bakso", updated.Description)
+	assert.Equal(t, float64(20000), updated.Amount)
+
+	var restored entity.Transaction
+	require.NoError(t, db.First(&restored, 11).Error)
+	assert.Equal(t, "Parkir", restored.Description)
+
+	_, err = chatbot.UndoLastBatch(1)
+	assert.ErrorIs(t, err, service.ErrNothingToUndo)
+}
+
+func TestUndoLastBatch_IsAtomic(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+
+	saved, err := chatbot.SaveTransactions(1, []entity.TransactionItemAI{
+		{Action: "create", Description: "Kopi", Amount: 15000, Type: "expense", CategoryName: "Makan", WalletName: "Tunai"},
+		{Action: "update", ID: 10, Description: "Bakso jumbo", Amount: 30000, Type: "expense", CategoryName: "Makan", WalletName: "Tunai"},
+	})
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+
+	// Wallet hilang: revert item kedua gagal, jadi item pertama juga tidak boleh ter-revert.
+	require.NoError(t, db.Model(&entity.Transaction{}).Where("id = ?", 10).Update("wallet_id", 99).Error)
+
+	_, err = chatbot.UndoLastBatch(1)
+	assert.Error(t, err)
+
+	var count int64
+	db.Model(&entity.Transaction{}).Where("id = ?", saved[0].ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	var batch entity.AIBatch
+	require.NoError(t, db.First(&batch).Error)
+	assert.Nil(t, batch.UndoneAt)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DraftTTL adalah lama draft AI menunggu konfirmasi sebelum dianggap kedaluwarsa.
+const DraftTTL = 24 * time.Hour
+
+// DefaultAIAutoCommitLimit dipakai jika user belum mengatur batas auto-simpan.
+const DefaultAIAutoCommitLimit = 1000000
+
+var (
+	ErrDraftNotFound    = errors.New("draft tidak ditemukan, sudah diproses, atau kedaluwarsa")
+	ErrInvalidDraftItem = errors.New("item draft tidak valid")
+)
+
+type AIDraftService interface {
+	Create(userID uint, reason string, items []entity.TransactionItemAI) (*entity.AIDraft, error)
+	GetPending(userID uint) ([]entity.AIDraft, error)
+	// Resolve menandai draft pending sebagai confirmed/rejected dan mengembalikan item finalnya.
+	// edits (jika tidak kosong) menggantikan item usulan AI; lampiran dan aksi tool tetap diambil
+	// dari item draft dengan indeks yang sama, bukan dari edits.
+	Resolve(userID uint, id uint, status string, edits []entity.TransactionItemAI) ([]entity.TransactionItemAI, error)
+}
+
+type aiDraftService struct {
+	repo repository.AIDraftRepository
+}
+
+func NewAIDraftService(repo repository.AIDraftRepository) AIDraftService {
+	return &aiDraftService{repo: repo}
+}
+
+func (s *aiDraftService) Create(userID uint, reason string, items []entity.TransactionItemAI) (*entity.AIDraft, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	draft := &entity.AIDraft{
+		UserID:    userID,
+		Status:    entity.AIDraftPending,
+		Reason:    reason,
+		Items:     entity.JSONText(data),
+		ExpiresAt: time.Now().Add(DraftTTL),
+	}
+	if err := s.repo.Create(draft); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+func (s *aiDraftService) GetPending(userID uint) ([]entity.AIDraft, error) {
+	return s.repo.FindPending(userID, time.Now())
+}
+
+func (s *aiDraftService) Resolve(userID uint, id uint, status string, edits []entity.TransactionItemAI) ([]entity.TransactionItemAI, error) {
+	draft, err := s.repo.FindByID(id, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDraftNotFound
+		}
+		return nil, err
+	}
+
+	var items []entity.TransactionItemAI
+	if err := json.Unmarshal([]byte(draft.Items), &items); err != nil {
+		return nil, err
+	}
+
+	var edited entity.JSONText
+	if len(edits) > 0 {
+		merged := make([]entity.TransactionItemAI, len(edits))
+		for i, item := range edits {
+			var original entity.TransactionItemAI
+			if i < len(items) {
+				original = items[i]
+			}
+			if err := validateDraftItem(item, original); err != nil {
+				return nil, err
+			}
+			// Lampiran sudah lolos cek kepemilikan saat draft dibuat; jangan percaya key dari client.
+			item.Attachment = original.Attachment
+			if original.Action == entity.DraftToolAction {
+				item = original
+			}
+			merged[i] = item
+		}
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+		items, edited = merged, entity.JSONText(data)
+	}
+
+	if err := s.repo.Resolve(id, userID, status, edited, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDraftNotFound
+		}
+		return nil, err
+	}
+	return items, nil
+}
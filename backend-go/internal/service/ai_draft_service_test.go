@@ -0,0 +1,204 @@
+package service_test
+
+import (
+	"context"
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/provider/storage"
+	"cuan-backend/internal/repository"
+	"cuan-backend/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupAIDraftTest(t *testing.T, limit float64) (*gorm.DB, *service.ChatbotService) {
+	t.Helper()
+	db, _ := setupAIBatchTest(t)
+	require.NoError(t, db.AutoMigrate(&entity.AIDraft{}))
+	require.NoError(t, db.Model(&entity.User{}).Where("id = ?", 1).Update("ai_auto_commit_limit", limit).Error)
+
+	walletRepo := repository.NewWalletRepository(db)
+	txRepo := repository.NewTransactionRepository(db)
+	chatbot := service.NewChatbotService(
+		walletRepo, repository.NewCategoryRepository(db), service.NewTransactionService(txRepo, walletRepo, db),
+		txRepo, nil, nil, nil, nil, repository.NewUserRepository(db),
+		service.NewAIBatchService(repository.NewAIBatchRepository(db), db), nil, nil, nil,
+		service.NewAIDraftService(repository.NewAIDraftRepository(db)),
+	)
+	return db, chatbot
+}
+
+func TestAIToolSession_HoldsTransactionsAboveLimitAsDraft(t *testing.T) {
+	db, chatbot := setupAIDraftTest(t, 50000)
+	session := chatbot.NewToolSession(1, "", false)
+
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":15000,"description":"Kopi"}`)), `"ok":true`)
+	result := session.Execute(toolCall("create_transaction", `{"type":"expense","amount":75000,"description":"Sepatu"}`))
+	assert.Contains(t, result, "menunggu_konfirmasi")
+
+	saved := session.Finish()
+	require.Len(t, saved, 1)
+	assert.Equal(t, "Kopi", saved[0].Description)
+
+	draft := session.Draft()
+	require.NotNil(t, draft)
+	assert.Equal(t, entity.AIDraftPending, draft.Status)
+	assert.Contains(t, session.Summary(), "📝 Sepatu — Rp75.000 (expense)")
+	assert.Equal(t, 65000.0, walletBalance(t, db))
+
+	// Konfirmasi dengan nominal yang diedit user; konfirmasi kedua ditolak.
+	confirmed, _, err := chatbot.ConfirmDraft(1, draft.ID, []entity.TransactionItemAI{
+		{Action: "create", Type: "expense", Amount: 60000, Description: "Sepatu"},
+	})
+	require.NoError(t, err)
+	require.Len(t, confirmed, 1)
+	assert.Equal(t, 5000.0, walletBalance(t, db))
+
+	_, _, err = chatbot.ConfirmDraft(1, draft.ID, nil)
+	assert.ErrorIs(t, err, service.ErrDraftNotFound)
+
+	pending, err := chatbot.PendingDrafts(1)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestAIToolSession_ReceiptDraftCanBeRejected(t *testing.T) {
+	db, chatbot := setupAIDraftTest(t, 1000000)
+	session := chatbot.NewToolSession(1, "", true)
+
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":5000,"description":"Roti"}`)), "menunggu_konfirmasi")
+	assert.Empty(t, session.Finish())
+	draft := session.Draft()
+	require.NotNil(t, draft)
+	assert.Equal(t, "transaksi dari gambar/struk", draft.Reason)
+
+	_, _, err := chatbot.ConfirmDraft(1, draft.ID, []entity.TransactionItemAI{{Action: "create", Type: "expense", Amount: 0, Description: "Roti"}})
+	assert.ErrorIs(t, err, service.ErrInvalidDraftItem)
+
+	require.NoError(t, chatbot.RejectDraft(1, draft.ID))
+	assert.ErrorIs(t, chatbot.RejectDraft(1, draft.ID), service.ErrDraftNotFound)
+	assert.Equal(t, 80000.0, walletBalance(t, db))
+}
+
+func TestConfirmDraft_KeepsAttachmentFromStoredDraft(t *testing.T) {
+	db, chatbot := setupAIDraftTest(t, 1000000)
+	session := chatbot.NewToolSession(1, "", true).AttachImage("receipts/1/struk.jpg")
+
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":5000,"description":"Roti"}`)), "menunggu_konfirmasi")
+	session.Finish()
+	draft := session.Draft()
+	require.NotNil(t, draft)
+
+	// Key lampiran dari client tidak melewati cek kepemilikan /api/files, jadi ditolak.
+	_, _, err := chatbot.ConfirmDraft(1, draft.ID, []entity.TransactionItemAI{
+		{Action: "create", Type: "expense", Amount: 6000, Description: "Roti", Attachment: "receipts/2/milik-orang.jpg"},
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidDraftItem)
+
+	saved, _, err := chatbot.ConfirmDraft(1, draft.ID, []entity.TransactionItemAI{
+		{Action: "create", Type: "expense", Amount: 6000, Description: "Roti"},
+	})
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+
+	var tx entity.Transaction
+	require.NoError(t, db.First(&tx, saved[0].ID).Error)
+	assert.Equal(t, "receipts/1/struk.jpg", tx.Attachment)
+	assert.Equal(t, 6000.0, tx.Amount)
+}
+
+func TestConfirmDraft_AfterDeletingReceiptMessageKeepsImage(t *testing.T) {
+	db, chatbot := setupAIDraftTest(t, 1000000)
+	require.NoError(t, db.AutoMigrate(&entity.ChatMessage{}, &entity.ChatSummary{}))
+	store := storage.NewLocalStore(t.TempDir())
+	attachments := service.NewAttachmentService(store, nil, repository.NewAttachmentRepository(db))
+	chatHist := service.NewChatHistoryService(repository.NewChatRepository(db), nil, attachments, repository.NewUserRepository(db))
+
+	const key = "images/1/struk.jpg"
+	require.NoError(t, store.Put(context.Background(), key, strings.NewReader("jpeg"), 4, "image/jpeg"))
+	msg := entity.ChatMessage{UserID: 1, Role: "user", Content: "struk", ImageURL: key}
+	require.NoError(t, db.Create(&msg).Error)
+
+	session := chatbot.NewToolSession(1, "", true).AttachImage(key)
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":5000,"description":"Roti"}`)), "menunggu_konfirmasi")
+	session.Finish()
+	require.NotNil(t, session.Draft())
+
+	// Draft pending masih memakai gambar struknya, jadi blob tidak ikut terhapus bersama pesan.
+	require.NoError(t, chatHist.DeleteMessage(1, msg.ID))
+	reader, _, err := attachments.Open(key)
+	require.NoError(t, err)
+	reader.Close()
+
+	saved, _, err := chatbot.ConfirmDraft(1, session.Draft().ID, nil)
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	var tx entity.Transaction
+	require.NoError(t, db.First(&tx, saved[0].ID).Error)
+	assert.Equal(t, key, tx.Attachment)
+	reader, _, err = attachments.Open(tx.Attachment)
+	require.NoError(t, err)
+	reader.Close()
+}
+
+func TestAIToolSession_HoldsTransferAboveLimitAndReplaysOnConfirm(t *testing.T) {
+	db, chatbot := setupAIDraftTest(t, 50000)
+	require.NoError(t, db.Create(&entity.Wallet{ID: 2, UserID: 1, Name: "GoPay", Balance: 0}).Error)
+	session := chatbot.NewToolSession(1, "", false)
+
+	result := session.Execute(toolCall("transfer_between_wallets", `{"from_wallet":"tunai","to_wallet":"gopay","amount":60000}`))
+	assert.Contains(t, result, "menunggu_konfirmasi")
+	assert.Empty(t, session.Finish())
+	draft := session.Draft()
+	require.NotNil(t, draft)
+	assert.Contains(t, session.Summary(), "🔁 Transfer Rp60.000 dari Tunai ke GoPay")
+	assert.Equal(t, 80000.0, walletBalance(t, db))
+
+	_, _, err := chatbot.ConfirmDraft(1, draft.ID, []entity.TransactionItemAI{{Action: entity.DraftToolAction, Tool: "transfer_between_wallets", Amount: 1}})
+	assert.ErrorIs(t, err, service.ErrInvalidDraftItem)
+
+	saved, summary, err := chatbot.ConfirmDraft(1, draft.ID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, saved)
+	assert.Contains(t, summary, "🔁 Transfer Rp60.000 dari Tunai ke GoPay")
+	assert.Equal(t, 20000.0, walletBalance(t, db))
+}
+
+func TestUndoLastBatch_RefusesNonTransactionAction(t *testing.T) {
+	db, chatbot := setupAIDraftTest(t, 1000000)
+	require.NoError(t, db.Create(&entity.Wallet{ID: 2, UserID: 1, Name: "GoPay", Balance: 0}).Error)
+
+	first := chatbot.NewToolSession(1, "", false)
+	assert.Contains(t, first.Execute(toolCall("create_transaction", `{"type":"expense","amount":15000,"description":"Kopi"}`)), `"ok":true`)
+	first.Finish()
+
+	second := chatbot.NewToolSession(1, "", false)
+	assert.Contains(t, second.Execute(toolCall("transfer_between_wallets", `{"from_wallet":"tunai","to_wallet":"gopay","amount":10000}`)), `"ok":true`)
+	second.Finish()
+	assert.Equal(t, 55000.0, walletBalance(t, db))
+
+	// "batal" tidak boleh diam-diam membatalkan transaksi Kopi dari batch sebelumnya.
+	_, err := chatbot.UndoLastBatch(1)
+	assert.ErrorIs(t, err, service.ErrNotUndoable)
+	assert.Equal(t, 55000.0, walletBalance(t, db))
+
+	var kopi int64
+	require.NoError(t, db.Model(&entity.Transaction{}).Where("description = ?", "Kopi").Count(&kopi).Error)
+	assert.Equal(t, int64(1), kopi)
+}
+
+func TestAIToolSession_UnclearVoiceHoldsTransactions(t *testing.T) {
+	db, chatbot := setupAIDraftTest(t, 1000000)
+	transcript := &entity.VoiceTranscript{Text: "beli kopi lima belas ribu pakai gopek", LowConfidence: []string{"gopek"}}
+	session := chatbot.NewToolSession(1, transcript.Text, false).RequireConfirmation(service.VoiceConfirmationReason(transcript))
+
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":15000,"description":"Kopi"}`)), "menunggu_konfirmasi")
+	assert.Empty(t, session.Finish())
+	draft := session.Draft()
+	require.NotNil(t, draft)
+	assert.Equal(t, `suara kurang jelas: "gopek"`, draft.Reason)
+	assert.Equal(t, 80000.0, walletBalance(t, db))
+}
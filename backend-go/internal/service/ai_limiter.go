@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrAIBusy dikembalikan jika slot LLM tidak didapat sebelum batas tunggu.
+var ErrAIBusy = errors.New("AI Server sedang sibuk, mohon coba beberapa saat lagi")
+
+// ErrAIRateLimited adalah target errors.Is untuk RateLimitError.
+var ErrAIRateLimited = errors.New("batas pemakaian AI tercapai")
+
+// RateLimitError berarti token bucket user habis; RetryAfter adalah waktu sampai satu token kembali.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("Pesanmu ke AI terlalu banyak dalam waktu singkat. Coba lagi dalam %d detik ya 🙏", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrAIRateLimited
+}
+
+type AILimiterConfig struct {
+	// Concurrency adalah jumlah request LLM yang boleh berjalan bersamaan.
+	Concurrency int
+	// Burst adalah isi penuh token bucket per user; satu token kembali setiap Refill.
+	Burst  int
+	Refill time.Duration
+}
+
+func DefaultAILimiterConfig() AILimiterConfig {
+	return AILimiterConfig{
+		Concurrency: 2,
+		Burst:       5,
+		Refill:      12 * time.Second, // 5 pesan per menit setelah burst habis
+	}
+}
+
+// AILimiter membatasi pemakaian LLM dengan dua cara: token bucket per user untuk pesan
+// interaktif (Allow), dan antrean adil untuk slot LLM (Acquire). Slot yang kosong diberikan
+// bergiliran ke user yang sedang menunggu, sehingga satu user dengan banyak pesan tidak bisa
+// menahan user lain di belakang antreannya.
+type AILimiter struct {
+	cfg AILimiterConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[uint]*tokenBucket
+	free    int
+	waiting map[uint][]chan struct{}
+	turns   []uint // user yang punya antrean, urut giliran berikutnya
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewAILimiter(cfg AILimiterConfig) *AILimiter {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	return &AILimiter{
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: map[uint]*tokenBucket{},
+		free:    cfg.Concurrency,
+		waiting: map[uint][]chan struct{}{},
+	}
+}
+
+// Allow mengambil satu token dari bucket user. Burst atau Refill nol mematikan batas ini.
+func (l *AILimiter) Allow(userID uint) error {
+	return l.take(userID, true)
+}
+
+// Check sama seperti Allow tetapi tidak mengambil token. Dipakai untuk menolak pesan sebelum
+// transkripsi dan penyimpanan history; token baru diambil saat pesan benar-benar ke LLM.
+func (l *AILimiter) Check(userID uint) error {
+	return l.take(userID, false)
+}
+
+func (l *AILimiter) take(userID uint, consume bool) error {
+	if l.cfg.Burst <= 0 || l.cfg.Refill <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.cfg.Burst), updated: now}
+		l.buckets[userID] = bucket
+	}
+	refilled := float64(now.Sub(bucket.updated)) / float64(l.cfg.Refill)
+	bucket.tokens = math.Min(float64(l.cfg.Burst), bucket.tokens+refilled)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return &RateLimitError{RetryAfter: time.Duration((1 - bucket.tokens) * float64(l.cfg.Refill))}
+	}
+	if consume {
+		bucket.tokens--
+	}
+	return nil
+}
+
+// Acquire menunggu slot LLM paling lama wait. release wajib dipanggil sekali setelah request
+// selesai.
+func (l *AILimiter) Acquire(ctx context.Context, userID uint, wait time.Duration) (release func(), err error) {
+	l.mu.Lock()
+	if l.free > 0 && len(l.turns) == 0 {
+		l.free--
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	ready := make(chan struct{})
+	if len(l.waiting[userID]) == 0 {
+		l.turns = append(l.turns, userID)
+	}
+	l.waiting[userID] = append(l.waiting[userID], ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return l.release, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+		err = ErrAIBusy
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.dequeue(userID, ready) {
+		// Slot sudah diberikan bersamaan dengan batal/timeout; teruskan ke antrean berikutnya.
+		l.handOff()
+	}
+	return nil, err
+}
+
+func (l *AILimiter) release() {
+	l.mu.Lock()
+	l.handOff()
+	l.mu.Unlock()
+}
+
+// handOff memberikan slot yang dilepas ke waiter terdepan dari user giliran berikutnya; user itu
+// pindah ke belakang jika masih punya antrean. Dipanggil dengan mu terkunci.
+func (l *AILimiter) handOff() {
+	if len(l.turns) == 0 {
+		l.free++
+		return
+	}
+	userID := l.turns[0]
+	l.turns = l.turns[1:]
+	queue := l.waiting[userID]
+	if len(queue) > 1 {
+		l.waiting[userID] = queue[1:]
+		l.turns = append(l.turns, userID)
+	} else {
+		delete(l.waiting, userID)
+	}
+	close(queue[0])
+}
+
+// dequeue menghapus waiter yang menyerah; false jika waiter itu sudah mendapat slot.
+// Dipanggil dengan mu terkunci.
+func (l *AILimiter) dequeue(userID uint, ready chan struct{}) bool {
+	queue := l.waiting[userID]
+	for i, w := range queue {
+		if w != ready {
+			continue
+		}
+		queue = append(queue[:i:i], queue[i+1:]...)
+		if len(queue) > 0 {
+			l.waiting[userID] = queue
+			return true
+		}
+		delete(l.waiting, userID)
+		for j, u := range l.turns {
+			if u == userID {
+				l.turns = append(l.turns[:j:j], l.turns[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAILimiter_TokenBucketPerUser(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	l := NewAILimiter(AILimiterConfig{Concurrency: 1, Burst: 2, Refill: 10 * time.Second})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Allow(1))
+	require.NoError(t, l.Allow(1))
+	err := l.Allow(1)
+	var limited *RateLimitError
+	require.True(t, errors.As(err, &limited))
+	assert.True(t, errors.Is(err, ErrAIRateLimited))
+	assert.Equal(t, 10*time.Second, limited.RetryAfter)
+
+	// User lain punya bucket sendiri.
+	require.NoError(t, l.Allow(2))
+
+	now = now.Add(4 * time.Second)
+	err = l.Allow(1)
+	require.True(t, errors.As(err, &limited))
+	assert.Equal(t, 6*time.Second, limited.RetryAfter)
+
+	now = now.Add(6 * time.Second)
+	assert.NoError(t, l.Allow(1))
+}
+
+func TestAILimiter_CheckDoesNotConsume(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	l := NewAILimiter(AILimiterConfig{Concurrency: 1, Burst: 1, Refill: 10 * time.Second})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Check(1))
+	require.NoError(t, l.Check(1))
+	require.NoError(t, l.Allow(1))
+	assert.ErrorIs(t, l.Check(1), ErrAIRateLimited)
+}
+
+// queued menghitung waiter yang sedang antre.
+func queued(l *AILimiter) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, q := range l.waiting {
+		n += len(q)
+	}
+	return n
+}
+
+func TestAILimiter_FairQueueAcrossUsers(t *testing.T) {
+	l := NewAILimiter(AILimiterConfig{Concurrency: 1})
+	hold, err := l.Acquire(context.Background(), 9, time.Second)
+	require.NoError(t, err)
+
+	type grant struct {
+		userID  uint
+		release func()
+	}
+	grants := make(chan grant)
+	enqueue := func(userID uint) {
+		n := queued(l)
+		go func() {
+			release, err := l.Acquire(context.Background(), userID, 5*time.Second)
+			if err == nil {
+				grants <- grant{userID, release}
+			}
+		}()
+		require.Eventually(t, func() bool { return queued(l) == n+1 }, time.Second, time.Millisecond)
+	}
+
+	// User 1 mengirim tiga pesan sebelum user 2 sempat mengirim satu.
+	enqueue(1)
+	enqueue(1)
+	enqueue(1)
+	enqueue(2)
+
+	hold()
+	var order []uint
+	for i := 0; i < 4; i++ {
+		g := <-grants
+		order = append(order, g.userID)
+		g.release()
+	}
+	assert.Equal(t, []uint{1, 2, 1, 1}, order)
+	assert.Equal(t, 1, l.free)
+}
+
+func TestAILimiter_AcquireTimesOutAndCancels(t *testing.T) {
+	l := NewAILimiter(AILimiterConfig{Concurrency: 1})
+	hold, err := l.Acquire(context.Background(), 1, time.Second)
+	require.NoError(t, err)
+
+	_, err = l.Acquire(context.Background(), 2, 10*time.Millisecond)
+	assert.ErrorIs(t, err, ErrAIBusy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = l.Acquire(ctx, 3, time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.Equal(t, 0, queued(l))
+	hold()
+	assert.Equal(t, 1, l.free)
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// queryMaxRangeDays membatasi rentang tanggal satu query analitik.
+	queryMaxRangeDays = 366
+	// queryMaxRows adalah jumlah transaksi maksimal yang diagregasi; jika lebih, hasil ditandai
+	// truncated agar model tidak menyajikannya sebagai angka pasti.
+	queryMaxRows = 5000
+	// queryMaxGroups adalah jumlah grup maksimal yang dikirim balik ke model.
+	queryMaxGroups = 31
+)
+
+var (
+	queryGroupBys = []string{"none", "category", "wallet", "day", "month"}
+	queryMetrics  = []string{"sum", "count", "avg"}
+)
+
+// transactionQuery adalah DSL query analitik yang diisi model. Semua field divalidasi lalu
+// diterjemahkan ke entity.TransactionFilterParams; model tidak pernah menulis SQL.
+type transactionQuery struct {
+	StartDate        string   `json:"start_date"`
+	EndDate          string   `json:"end_date"`
+	Type             string   `json:"type"`
+	Wallets          []string `json:"wallets"`
+	Categories       []string `json:"categories"`
+	Search           string   `json:"search"`
+	GroupBy          string   `json:"group_by"`
+	Metrics          []string `json:"metrics"`
+	IncludeTransfers bool     `json:"include_transfers"`
+}
+
+type queryGroup struct {
+	key   string
+	count int
+	sum   float64
+}
+
+// queryTransactions menjalankan transactionQuery lewat TransactionRepository dan mengagregasi
+// hasilnya per grup.
+func (t *AIToolSession) queryTransactions(raw json.RawMessage) (interface{}, error) {
+	var q transactionQuery
+	if err := decodeToolArgs(raw, &q); err != nil {
+		return nil, err
+	}
+	params, err := t.transactionQueryParams(&q)
+	if err != nil {
+		return nil, err
+	}
+
+	txns, total, err := t.chatbot.transactionRepo.FindAll(t.userID, params)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil transaksi: %w", err)
+	}
+
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	overall := &queryGroup{}
+	groups := map[string]*queryGroup{}
+	for _, tx := range txns {
+		// Transfer antar dompet dicatat sebagai pasangan expense/income, bukan belanja atau pemasukan.
+		if tx.RelatedTransactionID != nil && !q.IncludeTransfers {
+			continue
+		}
+		overall.count++
+		overall.sum += tx.Amount
+		if q.GroupBy == "none" {
+			continue
+		}
+		key := queryGroupKey(tx, q.GroupBy, wib)
+		g, ok := groups[key]
+		if !ok {
+			g = &queryGroup{key: key}
+			groups[key] = g
+		}
+		g.count++
+		g.sum += tx.Amount
+	}
+
+	sorted := make([]*queryGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		// Grup waktu diurutkan kronologis, grup lain dari nominal terbesar.
+		if q.GroupBy == "day" || q.GroupBy == "month" {
+			return sorted[i].key < sorted[j].key
+		}
+		if sorted[i].sum != sorted[j].sum {
+			return sorted[i].sum > sorted[j].sum
+		}
+		return sorted[i].key < sorted[j].key
+	})
+
+	result := map[string]interface{}{
+		"query":     q,
+		"truncated": total > int64(len(txns)),
+	}
+	for k, v := range queryMetricValues(overall, q.Metrics) {
+		result[k] = v
+	}
+	if q.GroupBy != "none" {
+		out := make([]map[string]interface{}, 0, min(len(sorted), queryMaxGroups))
+		for _, g := range sorted[:min(len(sorted), queryMaxGroups)] {
+			row := queryMetricValues(g, q.Metrics)
+			row["group"] = g.key
+			out = append(out, row)
+		}
+		result["groups"] = out
+		result["total_groups"] = len(sorted)
+	}
+	return result, nil
+}
+
+// transactionQueryParams memvalidasi query, mengisi nilai default, dan mencocokkan nama dompet
+// dan kategori dengan milik user.
+func (t *AIToolSession) transactionQueryParams(q *transactionQuery) (entity.TransactionFilterParams, error) {
+	params := entity.TransactionFilterParams{Page: 1, Limit: queryMaxRows}
+
+	start, err := time.Parse("2006-01-02", q.StartDate)
+	if err != nil {
+		return params, errors.New("start_date harus berformat YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", q.EndDate)
+	if err != nil {
+		return params, errors.New("end_date harus berformat YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return params, errors.New("end_date tidak boleh sebelum start_date")
+	}
+	if end.Sub(start) > queryMaxRangeDays*24*time.Hour {
+		return params, fmt.Errorf("rentang tanggal maksimal %d hari", queryMaxRangeDays)
+	}
+	params.StartDate = q.StartDate
+	params.EndDate = q.EndDate + " 23:59:59"
+
+	switch q.Type {
+	case "":
+		q.Type = "expense"
+	case "expense", "income":
+	default:
+		return params, fmt.Errorf("type harus 'expense' atau 'income', bukan '%s'", q.Type)
+	}
+	params.Type = q.Type
+
+	if q.GroupBy == "" {
+		q.GroupBy = "none"
+	}
+	if !containsString(queryGroupBys, q.GroupBy) {
+		return params, fmt.Errorf("group_by harus salah satu dari %s, bukan '%s'", strings.Join(queryGroupBys, ", "), q.GroupBy)
+	}
+	if len(q.Metrics) == 0 {
+		q.Metrics = queryMetrics
+	}
+	for _, m := range q.Metrics {
+		if !containsString(queryMetrics, m) {
+			return params, fmt.Errorf("metrics hanya boleh berisi %s, bukan '%s'", strings.Join(queryMetrics, ", "), m)
+		}
+	}
+	q.Search = strings.TrimSpace(q.Search)
+	params.Search = q.Search
+
+	if len(q.Wallets) > 0 {
+		wallets, err := t.chatbot.walletRepo.FindByUserID(t.userID)
+		if err != nil {
+			return params, err
+		}
+		names := make([]string, len(wallets))
+		for i, w := range wallets {
+			names[i] = w.Name
+		}
+		for i, name := range q.Wallets {
+			idx := matchByName(names, name)
+			if idx < 0 {
+				return params, fmt.Errorf("dompet '%s' tidak ditemukan; dompet user: %s", name, listOrDash(names))
+			}
+			q.Wallets[i] = wallets[idx].Name
+			params.WalletIDs = append(params.WalletIDs, wallets[idx].ID)
+		}
+	}
+
+	if len(q.Categories) > 0 {
+		categories, err := t.chatbot.categoryRepo.FindAll(t.userID)
+		if err != nil {
+			return params, err
+		}
+		names := make([]string, len(categories))
+		for i, c := range categories {
+			names[i] = c.Name
+		}
+		for i, name := range q.Categories {
+			idx := matchByName(names, name)
+			if idx < 0 {
+				return params, fmt.Errorf("kategori '%s' tidak ditemukan; kategori user: %s", name, listOrDash(names))
+			}
+			q.Categories[i] = categories[idx].Name
+			params.CategoryIDs = append(params.CategoryIDs, categories[idx].ID)
+		}
+	}
+	return params, nil
+}
+
+func queryGroupKey(tx entity.Transaction, groupBy string, loc *time.Location) string {
+	switch groupBy {
+	case "category":
+		if tx.Category.Name == "" {
+			return "Tanpa kategori"
+		}
+		return tx.Category.Name
+	case "wallet":
+		return tx.Wallet.Name
+	case "day":
+		return tx.Date.In(loc).Format("2006-01-02")
+	default:
+		return tx.Date.In(loc).Format("2006-01")
+	}
+}
+
+func queryMetricValues(g *queryGroup, metrics []string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, m := range metrics {
+		switch m {
+		case "sum":
+			values["sum"] = g.sum
+		case "count":
+			values["count"] = g.count
+		case "avg":
+			avg := 0.0
+			if g.count > 0 {
+				avg = g.sum / float64(g.count)
+			}
+			values["avg"] = avg
+		}
+	}
+	return values
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	pkgutils "cuan-backend/pkg/utils"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// QuickCapturer adalah ToolExecutor yang bisa mencatat pesan sederhana ("kopi 18rb gopay")
+// secara deterministik, tanpa LLM. Jika ok false, pesan diteruskan ke LLM seperti biasa.
+type QuickCapturer interface {
+	QuickCapture(message string) (reply string, ok bool)
+}
+
+// quickCaptureMaxLen membatasi fast path ke pesan pendek satu baris; pesan yang lebih panjang
+// (termasuk transkripsi suara yang diberi awalan) hampir selalu butuh pemahaman LLM.
+const quickCaptureMaxLen = 60
+
+// Kata yang menandakan niat selain mencatat satu pengeluaran baru.
+var quickCaptureBlockWords = map[string]bool{
+	"utang": true, "hutang": true, "piutang": true, "pinjam": true, "pinjem": true, "minjem": true, "cicil": true, "cicilan": true,
+	"transfer": true, "tf": true, "tarik": true, "topup": true, "top": true,
+	"nabung": true, "tabung": true, "tabungan": true, "wishlist": true, "pengen": true, "ingin": true, "mau": true,
+	"ubah": true, "ganti": true, "edit": true, "koreksi": true, "hapus": true, "salah": true, "batal": true, "undo": true,
+	"berapa": true, "laporan": true, "budget": true, "anggaran": true, "sisa": true, "saldo": true,
+	"dan": true, "sama": true, "plus": true, "terus": true, "trus": true, "lalu": true,
+}
+
+// Kata pengisi yang dibuang dari deskripsi.
+var quickCaptureFillerWords = map[string]bool{
+	"beli": true, "bayar": true, "pakai": true, "pake": true, "pk": true, "via": true, "dari": true, "lewat": true,
+	"dengan": true, "dgn": true, "buat": true, "untuk": true, "utk": true, "harga": true, "seharga": true,
+	"tadi": true, "td": true, "abis": true, "habis": true, "aja": true, "ya": true, "nih": true, "dong": true, "rupiah": true,
+}
+
+// Kata yang menunjuk dompet pada kata berikutnya ("pakai bca").
+var quickCaptureWalletHints = map[string]bool{
+	"pakai": true, "pake": true, "pk": true, "via": true, "dari": true, "lewat": true, "dengan": true, "dgn": true,
+}
+
+// Kata kunci deskripsi → nama kategori default. Kategori milik user dengan nama yang sama
+// (atau mirip) yang akhirnya dipakai.
+var quickCaptureCategoryKeywords = map[string]string{
+	"makan": "Makan", "minum": "Makan", "kopi": "Makan", "nasi": "Makan", "bakso": "Makan", "mie": "Makan", "mi": "Makan",
+	"ayam": "Makan", "sate": "Makan", "soto": "Makan", "teh": "Makan", "jus": "Makan", "roti": "Makan", "snack": "Makan",
+	"jajan": "Makan", "gorengan": "Makan", "martabak": "Makan", "boba": "Makan", "sarapan": "Makan", "warteg": "Makan",
+	"bensin": "Transport", "pertalite": "Transport", "pertamax": "Transport", "parkir": "Transport", "tol": "Transport",
+	"ojek": "Transport", "ojol": "Transport", "gojek": "Transport", "grab": "Transport", "taksi": "Transport",
+	"krl": "Transport", "kereta": "Transport", "busway": "Transport", "angkot": "Transport", "bus": "Transport",
+	"listrik": "Tagihan", "pln": "Tagihan", "pdam": "Tagihan", "internet": "Tagihan", "wifi": "Tagihan", "pulsa": "Tagihan",
+	"kuota": "Tagihan", "bpjs": "Tagihan", "kos": "Tagihan", "kost": "Tagihan", "sewa": "Tagihan",
+	"belanja": "Belanja", "indomaret": "Belanja", "alfamart": "Belanja", "sabun": "Belanja", "baju": "Belanja",
+	"sepatu": "Belanja", "sampo": "Belanja", "shampo": "Belanja", "deterjen": "Belanja",
+	"nonton": "Hiburan", "bioskop": "Hiburan", "netflix": "Hiburan", "spotify": "Hiburan", "game": "Hiburan", "karaoke": "Hiburan",
+	"obat": "Kesehatan", "dokter": "Kesehatan", "apotek": "Kesehatan", "vitamin": "Kesehatan", "klinik": "Kesehatan",
+	"buku": "Pendidikan", "kursus": "Pendidikan", "spp": "Pendidikan", "les": "Pendidikan",
+	"gaji": "Gaji", "gajian": "Gaji", "thr": "Gaji", "bonus": "Gaji",
+}
+
+var quickCaptureWordRe = regexp.MustCompile(`[a-z0-9']+`)
+
+// parseQuickTransaction mengubah pesan pendek berisi tepat satu nominal menjadi satu transaksi
+// baru. Jika pesan tidak cukup jelas, item nil dan alasan fallback ke LLM dikembalikan.
+func parseQuickTransaction(message string, wallets []entity.Wallet, categories []entity.Category) (*entity.TransactionItemAI, string) {
+	text := strings.ToLower(strings.TrimSpace(message))
+	switch {
+	case text == "":
+		return nil, "pesan kosong"
+	case len(text) > quickCaptureMaxLen || strings.ContainsAny(text, "\n?[+,;&"):
+		return nil, "pesan terlalu panjang/kompleks"
+	}
+
+	amounts := pkgutils.FindAmounts(text)
+	if len(amounts) != 1 {
+		return nil, "nominal tidak tepat satu"
+	}
+	if len(pkgutils.FindDates(text, time.Now())) > 0 {
+		return nil, "pesan menyebut tanggal"
+	}
+	rest := text[:amounts[0].Start] + " " + text[amounts[0].End:]
+	words := quickCaptureWordRe.FindAllString(rest, -1)
+
+	walletNames := make([]string, len(wallets))
+	for i, w := range wallets {
+		walletNames[i] = w.Name
+	}
+
+	var descWords []string
+	var wallet *entity.Wallet
+	txType, categoryName := "expense", ""
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if quickCaptureBlockWords[w] {
+			return nil, "kata kunci '" + w + "' butuh LLM"
+		}
+		hinted := i > 0 && quickCaptureWalletHints[words[i-1]]
+		idx := -1
+		// Nama dompet yang ditulis terpisah ("go pay" untuk GoPay) harus sama persis.
+		if i+1 < len(words) {
+			if j, score := fuzzyMatchName(walletNames, w+words[i+1]); j >= 0 && score == 1 {
+				idx = j
+				i++
+			}
+		}
+		if idx < 0 {
+			idx = quickWalletMatch(walletNames, w, hinted)
+		}
+		if idx >= 0 {
+			if wallet != nil && wallet.ID != wallets[idx].ID {
+				return nil, "lebih dari satu dompet"
+			}
+			wallet = &wallets[idx]
+			continue
+		} else if hinted && !quickCaptureFillerWords[w] {
+			return nil, "dompet '" + w + "' tidak dikenal"
+		}
+		if quickCaptureFillerWords[w] {
+			continue
+		}
+		if name, ok := quickCaptureCategoryKeywords[w]; ok && categoryName == "" {
+			categoryName = name
+			if name == "Gaji" {
+				txType = "income"
+			}
+		}
+		descWords = append(descWords, w)
+	}
+
+	if len(descWords) == 0 || len(descWords) > 4 {
+		return nil, "deskripsi tidak jelas"
+	}
+	category := quickCategoryMatch(categories, descWords, categoryName, txType)
+	if category == nil {
+		return nil, "kategori tidak yakin"
+	}
+
+	amount := amounts[0].Value
+	if wallet == nil {
+		// Tanpa dompet disebut, LLM memilih dompet yang saldonya cukup; fast path hanya
+		// memakai dompet default jika saldonya memang cukup.
+		id, _, err := resolveWalletFromList(wallets, "")
+		if err != nil {
+			return nil, "user belum punya dompet"
+		}
+		for i := range wallets {
+			if wallets[i].ID == id {
+				wallet = &wallets[i]
+			}
+		}
+		if txType == "expense" && wallet.Balance < amount {
+			return nil, "saldo dompet default tidak cukup"
+		}
+	}
+
+	for i, w := range descWords {
+		descWords[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return &entity.TransactionItemAI{
+		Action:       "create",
+		Type:         txType,
+		Amount:       amount,
+		Description:  strings.Join(descWords, " "),
+		CategoryName: category.Name,
+		WalletName:   wallet.Name,
+	}, ""
+}
+
+// quickWalletMatch mencocokkan satu kata dengan nama dompet. Tanpa kata penunjuk ("pakai",
+// "via"), kata harus cukup panjang agar "es" atau "mi" tidak dianggap dompet.
+func quickWalletMatch(names []string, word string, hinted bool) int {
+	if !hinted && len(word) < 3 {
+		return -1
+	}
+	idx, score := fuzzyMatchName(names, word)
+	if idx < 0 || (!hinted && score < 0.9) {
+		return -1
+	}
+	return idx
+}
+
+// quickCategoryMatch memilih kategori user: nama kategori yang disebut langsung di deskripsi,
+// lalu kategori dari kata kunci. Nil jika keduanya tidak ditemukan.
+func quickCategoryMatch(categories []entity.Category, descWords []string, keywordCategory string, txType string) *entity.Category {
+	var filtered []entity.Category
+	var names []string
+	for _, c := range categories {
+		if strings.ToLower(c.Type) == txType {
+			filtered = append(filtered, c)
+			names = append(names, c.Name)
+		}
+	}
+	for _, w := range descWords {
+		if len(w) < 3 {
+			continue
+		}
+		if idx, score := fuzzyMatchName(names, w); idx >= 0 && score == 1 {
+			return &filtered[idx]
+		}
+	}
+	if keywordCategory == "" {
+		return nil
+	}
+	if idx, _ := fuzzyMatchName(names, keywordCategory); idx >= 0 {
+		return &filtered[idx]
+	}
+	return nil
+}
+
+// QuickCapture mencatat pesan sederhana tanpa LLM lewat jalur yang sama dengan tool
+// create_transaction, sehingga batas auto-simpan, draft dan undo tetap berlaku.
+func (t *AIToolSession) QuickCapture(message string) (string, bool) {
+	if t.fromImage {
+		return "", false
+	}
+	wallets, err := t.chatbot.walletRepo.FindByUserID(t.userID)
+	if err != nil {
+		return "", false
+	}
+	categories, err := t.chatbot.categoryRepo.FindAll(t.userID)
+	if err != nil {
+		return "", false
+	}
+
+	item, reason := parseQuickTransaction(message, wallets, categories)
+	if item == nil {
+		log.Debug().Uint("user_id", t.userID).Str("reason", reason).Msg("Quick capture fallback ke LLM")
+		return "", false
+	}
+	if _, err := t.apply(*item); err != nil {
+		log.Warn().Err(err).Uint("user_id", t.userID).Msg("Quick capture gagal, fallback ke LLM")
+		return "", false
+	}
+	log.Info().Uint("user_id", t.userID).Str("description", item.Description).Float64("amount", item.Amount).Msg("Quick capture tanpa LLM")
+
+	if len(t.pending) > 0 {
+		return "Transaksi ini perlu konfirmasi dulu sebelum disimpan 🙏", true
+	}
+	return "Siap, langsung dicatat! 👍", true
+}
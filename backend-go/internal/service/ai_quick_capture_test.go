@@ -0,0 +1,70 @@
+package service_test
+
+import (
+	"cuan-backend/internal/entity"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIToolSession_QuickCapture(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+	require.NoError(t, db.Create(&entity.Wallet{ID: 2, UserID: 1, Name: "GoPay", Balance: 100000}).Error)
+	require.NoError(t, db.Create(&entity.Category{ID: 2, UserID: 1, Name: "Transport", Type: "expense"}).Error)
+
+	cases := []struct {
+		message  string
+		desc     string
+		amount   float64
+		wallet   string
+		category string
+	}{
+		{"kopi 18rb gopay", "Kopi", 18000, "GoPay", "Makan"},
+		{"Bensin 25k pake go pay", "Bensin", 25000, "GoPay", "Transport"},
+		{"makan siang lima belas ribu", "Makan Siang", 15000, "Tunai", "Makan"},
+		{"parkir goceng", "Parkir", 5000, "Tunai", "Transport"},
+	}
+	for _, tc := range cases {
+		session := chatbot.NewToolSession(1, tc.message, false)
+		reply, ok := session.QuickCapture(tc.message)
+		require.True(t, ok, tc.message)
+		assert.NotEmpty(t, reply)
+
+		saved := session.Finish()
+		require.Len(t, saved, 1, tc.message)
+		assert.Equal(t, tc.desc, saved[0].Description, tc.message)
+		assert.Equal(t, tc.amount, saved[0].Amount, tc.message)
+		assert.Equal(t, tc.wallet, saved[0].WalletName, tc.message)
+		assert.Equal(t, tc.category, saved[0].CategoryName, tc.message)
+	}
+
+	var batches int64
+	require.NoError(t, db.Model(&entity.AIBatch{}).Count(&batches).Error)
+	assert.Equal(t, int64(len(cases)), batches)
+}
+
+func TestAIToolSession_QuickCaptureFallsBackToLLM(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+
+	for _, message := range []string{
+		"kopi 18rb roti 12rb",           // dua nominal
+		"bayar utang budi 50rb",         // bukan transaksi baru
+		"kemarin kopi 18rb",             // tanggal lewat parser tanggal di LLM path
+		"beli sesuatu 20rb",             // kategori tidak yakin
+		"kopi 18rb pakai ovo",           // dompet tidak dikenal
+		"kopi 500rb",                    // saldo Tunai tidak cukup, biar LLM memilih dompet
+		"berapa pengeluaran kopi 18rb?", // pertanyaan
+		"kopi",                          // tanpa nominal
+	} {
+		session := chatbot.NewToolSession(1, message, false)
+		_, ok := session.QuickCapture(message)
+		assert.False(t, ok, message)
+		assert.Empty(t, session.Finish(), message)
+	}
+
+	session := chatbot.NewToolSession(1, "kopi 18rb", true)
+	_, ok := session.QuickCapture("kopi 18rb")
+	assert.False(t, ok, "gambar selalu lewat LLM")
+	assert.Equal(t, 80000.0, walletBalance(t, db))
+}
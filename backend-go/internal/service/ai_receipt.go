@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ReceiptCapturer adalah ToolExecutor yang bisa langsung mencatat hasil pipeline struk. Jika ok
+// false (misal tidak ada item yang bisa dicatat), gambar diproses lewat chat biasa.
+type ReceiptCapturer interface {
+	CaptureReceipt(receipt *entity.ReceiptExtraction, message string) (reply string, ok bool)
+}
+
+// receiptMinConfidence: di bawah ini struk tidak dipecah per item, tapi dicatat satu transaksi
+// sebesar total agar saldo tetap benar.
+const receiptMinConfidence = 0.6
+
+var receiptTool = aiprovider.Tool{
+	Name:        "submit_receipt",
+	Description: "Mengirim isi struk yang terbaca dari gambar.",
+	Parameters: json.RawMessage(`{"type":"object","properties":{` +
+		`"is_receipt":{"type":"boolean"},` +
+		`"merchant":{"type":"string"},` +
+		`"date":{"type":"string","description":"YYYY-MM-DD, kosong jika tidak terbaca"},` +
+		`"items":{"type":"array","items":{"type":"object","properties":{` +
+		`"name":{"type":"string"},` +
+		`"quantity":{"type":"number"},` +
+		`"unit_price":{"type":"number"},` +
+		`"total":{"type":"number"},` +
+		`"category":{"type":"string"}},` +
+		`"required":["name","total"],"additionalProperties":false}},` +
+		`"subtotal":{"type":"number"},` +
+		`"tax":{"type":"number"},` +
+		`"service_charge":{"type":"number"},` +
+		`"discount":{"type":"number"},` +
+		`"total":{"type":"number"},` +
+		`"payment_method":{"type":"string"}},` +
+		`"required":["is_receipt","items","total"],"additionalProperties":false}`),
+}
+
+// extractReceipt menjalankan pipeline struk: praproses gambar, ekstraksi terstruktur oleh model,
+// lalu rekonsiliasi dan skor keyakinan. Pemanggil sudah memegang slot AILimiter.
+func (s *aiService) extractReceipt(ctx context.Context, imageBase64 string, message string) (*entity.ReceiptExtraction, error) {
+	raw, err := base64.StdEncoding.DecodeString(imageBase64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	processed, err := preprocessReceiptImage(raw)
+	if err != nil {
+		// Model masih bisa membaca gambar aslinya.
+		log.Warn().Err(err).Msg("Praproses struk gagal, memakai gambar asli")
+		processed = raw
+	}
+
+	prompt := "Baca struk pada gambar ini."
+	if message != "" {
+		prompt += "\nPesan user: " + message
+	}
+	resp, err := s.providerFor(aiprovider.TaskReceipt).GenerateWithTools(ctx, aiprovider.AIRequest{
+		Prompt:      prompt,
+		Base64Image: base64.StdEncoding.EncodeToString(processed),
+		System:      SystemPromptReceipt,
+		Tools:       []aiprovider.Tool{receiptTool},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("provider error: %w", err)
+	}
+
+	for _, call := range resp.ToolCalls {
+		if call.Name != receiptTool.Name {
+			continue
+		}
+		var receipt entity.ReceiptExtraction
+		if err := json.Unmarshal(call.Arguments, &receipt); err != nil {
+			return nil, fmt.Errorf("invalid receipt arguments: %w", err)
+		}
+		reconcileReceipt(&receipt)
+		return &receipt, nil
+	}
+	return nil, errors.New("model tidak mengembalikan hasil struk")
+}
+
+// reconcileReceipt mencocokkan item dengan total struk dan menghitung skor keyakinan.
+// Item tanpa total diisi dari quantity × unit_price; struk tanpa total memakai hasil hitung ulang.
+func reconcileReceipt(r *entity.ReceiptExtraction) {
+	r.Warnings = nil
+	r.ItemsTotal = 0
+	confidence := 1.0
+
+	var items []entity.ReceiptItem
+	inconsistent := 0
+	for _, item := range r.Items {
+		item.Name = strings.TrimSpace(item.Name)
+		if item.Quantity <= 0 {
+			item.Quantity = 1
+		}
+		if item.Total <= 0 {
+			item.Total = item.Quantity * item.UnitPrice
+		} else if item.UnitPrice > 0 && math.Abs(item.Quantity*item.UnitPrice-item.Total) > math.Max(1, item.Total*0.01) {
+			inconsistent++
+		}
+		if item.Name == "" || item.Total <= 0 {
+			continue
+		}
+		items = append(items, item)
+		r.ItemsTotal += item.Total
+	}
+	r.Items = items
+	if inconsistent > 0 {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%d item jumlah × harga tidak cocok dengan totalnya", inconsistent))
+		confidence -= math.Min(0.2, 0.05*float64(inconsistent))
+	}
+
+	expected := r.ItemsTotal - r.Discount + r.Tax + r.ServiceCharge
+	if r.Total <= 0 {
+		r.Total = expected
+		r.Warnings = append(r.Warnings, "total struk tidak terbaca, dihitung dari item")
+		confidence -= 0.3
+	}
+	if len(r.Items) == 0 {
+		r.Warnings = append(r.Warnings, "tidak ada item yang terbaca")
+		confidence -= 0.3
+	}
+
+	tolerance := math.Max(100, r.Total*0.01)
+	r.Difference = r.Total - expected
+	switch {
+	case math.Abs(r.Difference) <= tolerance:
+		r.Reconciled = true
+	case r.Tax+r.ServiceCharge > 0 && math.Abs(r.Total-(r.ItemsTotal-r.Discount)) <= tolerance:
+		// Harga item sudah termasuk pajak; pajak hanya dicetak sebagai informasi.
+		r.Reconciled, r.TaxIncluded = true, true
+		r.Difference = r.Total - (r.ItemsTotal - r.Discount)
+	default:
+		r.Reconciled = false
+		r.Warnings = append(r.Warnings, fmt.Sprintf("jumlah item %s tidak cocok dengan total %s (selisih %s)",
+			formatRupiah(expected), formatRupiah(r.Total), formatRupiah(r.Difference)))
+		if r.Total > 0 {
+			confidence -= math.Min(0.4, 0.1+2*math.Abs(r.Difference)/r.Total)
+		}
+	}
+
+	if r.Date == "" {
+		confidence -= 0.1
+	}
+	if strings.TrimSpace(r.Merchant) == "" {
+		confidence -= 0.05
+	}
+	r.Confidence = math.Round(math.Max(0, math.Min(1, confidence))*100) / 100
+}
+
+// receiptTransactions membagi total struk ke item secara proporsional sehingga pajak, service
+// dan diskon ikut terhitung dan jumlahnya sama persis dengan yang dibayar. Struk yang tidak
+// cocok atau keyakinannya rendah dicatat sebagai satu transaksi sebesar total.
+func receiptTransactions(r *entity.ReceiptExtraction) []entity.TransactionItemAI {
+	merchant := strings.TrimSpace(r.Merchant)
+	if merchant == "" {
+		merchant = "struk"
+	}
+	if r.Total <= 0 {
+		return nil
+	}
+	if receiptAsSingleTransaction(r) {
+		return []entity.TransactionItemAI{{
+			Action:       "create",
+			Type:         "expense",
+			Amount:       math.Round(r.Total),
+			Description:  "Belanja " + merchant,
+			CategoryName: "Belanja",
+		}}
+	}
+
+	ratio := r.Total / r.ItemsTotal
+	items := make([]entity.TransactionItemAI, len(r.Items))
+	var allocated float64
+	largest := 0
+	for i, item := range r.Items {
+		amount := math.Round(item.Total * ratio)
+		allocated += amount
+		if item.Total > r.Items[largest].Total {
+			largest = i
+		}
+		description := item.Name
+		if item.Quantity > 1 {
+			description = fmt.Sprintf("%s x%g", item.Name, item.Quantity)
+		}
+		category := item.Category
+		if category == "" {
+			category = "Belanja"
+		}
+		items[i] = entity.TransactionItemAI{
+			Action:       "create",
+			Type:         "expense",
+			Amount:       amount,
+			Description:  description,
+			CategoryName: category,
+		}
+	}
+	// Sisa pembulatan masuk ke item termahal.
+	items[largest].Amount += math.Round(r.Total) - allocated
+
+	var out []entity.TransactionItemAI
+	for _, item := range items {
+		if item.Amount > 0 {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func receiptAsSingleTransaction(r *entity.ReceiptExtraction) bool {
+	return !r.Reconciled || r.Confidence < receiptMinConfidence || len(r.Items) == 0
+}
+
+// CaptureReceipt mencatat hasil pipeline struk lewat jalur create_transaction: tanggal divalidasi
+// seperti usulan model, gambar dilampirkan, dan kebijakan draft struk tetap berlaku.
+func (t *AIToolSession) CaptureReceipt(receipt *entity.ReceiptExtraction, message string) (string, bool) {
+	items := receiptTransactions(receipt)
+	if len(items) == 0 {
+		return "", false
+	}
+
+	wallets, err := t.chatbot.walletRepo.FindByUserID(t.userID)
+	if err != nil {
+		return "", false
+	}
+	walletName := receiptWallet(wallets, message, receipt.PaymentMethod)
+
+	date, err := t.resolveDate(receipt.Date, false)
+	if err != nil {
+		receipt.Warnings = append(receipt.Warnings, "tanggal struk diabaikan: "+err.Error())
+		date = ""
+	}
+
+	recorded := 0
+	for _, item := range items {
+		item.WalletName = walletName
+		item.Date = date
+		if _, err := t.apply(item); err != nil {
+			log.Warn().Err(err).Uint("user_id", t.userID).Str("item", item.Description).Msg("Gagal mencatat item struk")
+			receipt.Warnings = append(receipt.Warnings, fmt.Sprintf("%s gagal dicatat: %v", item.Description, err))
+			continue
+		}
+		recorded++
+	}
+	if recorded == 0 {
+		return "", false
+	}
+	return formatReceiptReply(receipt), true
+}
+
+// receiptWallet memilih dompet dari pesan user ("pakai BCA"), lalu dari metode bayar di struk.
+// Kosong berarti dompet default.
+func receiptWallet(wallets []entity.Wallet, message string, paymentMethod string) string {
+	names := make([]string, len(wallets))
+	for i, w := range wallets {
+		names[i] = w.Name
+	}
+	words := quickCaptureWordRe.FindAllString(strings.ToLower(message), -1)
+	for i, w := range words {
+		hinted := i > 0 && quickCaptureWalletHints[words[i-1]]
+		if idx := quickWalletMatch(names, w, hinted); idx >= 0 {
+			return wallets[idx].Name
+		}
+	}
+	if idx, _ := fuzzyMatchName(names, paymentMethod); idx >= 0 {
+		return wallets[idx].Name
+	}
+	return ""
+}
+
+func formatReceiptReply(r *entity.ReceiptExtraction) string {
+	merchant := strings.TrimSpace(r.Merchant)
+	if merchant == "" {
+		merchant = "tanpa nama toko"
+	}
+	reply := fmt.Sprintf("🧾 Struk %s", merchant)
+	if r.Date != "" {
+		reply += " (" + r.Date + ")"
+	}
+	reply += fmt.Sprintf(" — %d item, total %s", len(r.Items), formatRupiah(r.Total))
+	if r.Discount > 0 {
+		reply += ", diskon " + formatRupiah(r.Discount)
+	}
+	if r.Tax > 0 {
+		reply += ", pajak " + formatRupiah(r.Tax)
+		if r.TaxIncluded {
+			reply += " (sudah termasuk)"
+		}
+	}
+	reply += fmt.Sprintf("\nKeyakinan: %.0f%%", r.Confidence*100)
+	if receiptAsSingleTransaction(r) {
+		reply += " — rincian item kurang meyakinkan, jadi dicatat sebagai satu transaksi sebesar total."
+	}
+	for _, w := range r.Warnings {
+		reply += "\n⚠️ " + w
+	}
+	return reply
+}
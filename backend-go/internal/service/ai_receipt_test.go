@@ -0,0 +1,117 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileReceipt(t *testing.T) {
+	cases := []struct {
+		name        string
+		receipt     entity.ReceiptExtraction
+		reconciled  bool
+		taxIncluded bool
+		minConf     float64
+		maxConf     float64
+	}{
+		{
+			name: "pajak dan diskon cocok",
+			receipt: entity.ReceiptExtraction{Merchant: "Kopi Kenangan", Date: "2025-03-05", Total: 53900, Tax: 4900, Discount: 5000,
+				Items: []entity.ReceiptItem{{Name: "Kopi Susu", Quantity: 2, UnitPrice: 18000}, {Name: "Roti", Total: 18000}}},
+			reconciled: true, minConf: 1, maxConf: 1,
+		},
+		{
+			name: "harga sudah termasuk pajak",
+			receipt: entity.ReceiptExtraction{Merchant: "Indomaret", Date: "2025-03-05", Total: 55500, Tax: 5500,
+				Items: []entity.ReceiptItem{{Name: "Sabun", Total: 25500}, {Name: "Sampo", Total: 30000}}},
+			reconciled: true, taxIncluded: true, minConf: 1, maxConf: 1,
+		},
+		{
+			name: "item tidak cocok dengan total",
+			receipt: entity.ReceiptExtraction{Merchant: "Warung", Total: 100000,
+				Items: []entity.ReceiptItem{{Name: "Nasi", Total: 30000}, {Name: "Ayam", Total: 40000}}},
+			reconciled: false, minConf: 0.4, maxConf: 0.55,
+		},
+		{
+			name: "total tidak terbaca",
+			receipt: entity.ReceiptExtraction{Merchant: "Warung", Date: "2025-03-05",
+				Items: []entity.ReceiptItem{{Name: "Nasi", Total: 30000}}},
+			reconciled: true, minConf: 0.7, maxConf: 0.7,
+		},
+	}
+	for _, tc := range cases {
+		r := tc.receipt
+		reconcileReceipt(&r)
+		assert.Equal(t, tc.reconciled, r.Reconciled, tc.name)
+		assert.Equal(t, tc.taxIncluded, r.TaxIncluded, tc.name)
+		assert.GreaterOrEqual(t, r.Confidence, tc.minConf, tc.name)
+		assert.LessOrEqual(t, r.Confidence, tc.maxConf, tc.name)
+	}
+}
+
+func TestReceiptTransactions_DistributesTotal(t *testing.T) {
+	r := entity.ReceiptExtraction{Merchant: "Kopi Kenangan", Date: "2025-03-05", Total: 53901, Tax: 4901, Discount: 5000,
+		Items: []entity.ReceiptItem{{Name: "Kopi Susu", Quantity: 2, UnitPrice: 18000, Category: "Makan"}, {Name: "Roti", Total: 18000}}}
+	reconcileReceipt(&r)
+
+	items := receiptTransactions(&r)
+	require.Len(t, items, 2)
+	assert.Equal(t, "Kopi Susu x2", items[0].Description)
+	assert.Equal(t, "Makan", items[0].CategoryName)
+	assert.Equal(t, "Belanja", items[1].CategoryName)
+	assert.Equal(t, 53901.0, items[0].Amount+items[1].Amount)
+
+	// Struk yang tidak cocok dicatat satu transaksi sebesar total.
+	r = entity.ReceiptExtraction{Merchant: "Warung", Total: 100000, Items: []entity.ReceiptItem{{Name: "Nasi", Total: 30000}}}
+	reconcileReceipt(&r)
+	items = receiptTransactions(&r)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Belanja Warung", items[0].Description)
+	assert.Equal(t, 100000.0, items[0].Amount)
+}
+
+type stubReceiptCapturer struct {
+	stubToolExecutor
+	receipts []*entity.ReceiptExtraction
+}
+
+func (c *stubReceiptCapturer) CaptureReceipt(receipt *entity.ReceiptExtraction, _ string) (string, bool) {
+	c.receipts = append(c.receipts, receipt)
+	return formatReceiptReply(receipt), true
+}
+
+func TestAIService_Chat_ReceiptPipeline(t *testing.T) {
+	args, _ := json.Marshal(entity.ReceiptExtraction{IsReceipt: true, Merchant: "Indomaret", Date: "2025-03-05", Total: 20000,
+		Items: []entity.ReceiptItem{{Name: "Sabun", Total: 20000}}})
+	provider := &stubProvider{responses: []aiprovider.AIResponse{
+		{ToolCalls: []aiprovider.ToolCall{{ID: "1", Name: "submit_receipt", Arguments: args}}},
+	}}
+	svc := NewAIService(provider, "")
+
+	tools := &stubReceiptCapturer{}
+	resp, err := svc.Chat("", "aW1hZ2U=", ChatPrompt{}, Conversation{}, tools)
+	require.NoError(t, err)
+	require.NotNil(t, resp.Receipt)
+	assert.Equal(t, 1.0, resp.Receipt.Confidence)
+	assert.Contains(t, resp.Reply, "Struk Indomaret")
+	require.Len(t, provider.requests, 1)
+	assert.Equal(t, SystemPromptReceipt, provider.requests[0].System)
+	assert.Empty(t, tools.calls)
+
+	// Bukan struk: gambar diproses lewat chat biasa.
+	args, _ = json.Marshal(entity.ReceiptExtraction{IsReceipt: false})
+	provider.responses = []aiprovider.AIResponse{
+		{ToolCalls: []aiprovider.ToolCall{{ID: "1", Name: "submit_receipt", Arguments: args}}},
+	}
+	resp, err = svc.Chat("ini foto apa?", "aW1hZ2U=", ChatPrompt{}, Conversation{}, tools)
+	require.NoError(t, err)
+	assert.Nil(t, resp.Receipt)
+	assert.Len(t, tools.receipts, 1)
+	assert.Len(t, provider.requests, 3)
+}
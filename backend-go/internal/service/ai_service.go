@@ -1,417 +1,436 @@
 package service
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"cuan-backend/internal/entity"
 	aiprovider "cuan-backend/internal/provider/ai"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// maxToolRounds membatasi berapa kali model boleh memanggil tool sebelum harus menjawab.
+const maxToolRounds = 4
+
 type AIService interface {
-	Chat(message string, imageBase64 string, userContext string) (*entity.ChatAIResponse, error)
-	ChatStream(message string, imageBase64 string, userContext string, onToken func(string) error) (*entity.ChatAIResponse, error)
-	ProcessVoice(path string) (string, error)
+	Chat(message string, imageBase64 string, prompt ChatPrompt, conv Conversation, tools ToolExecutor) (*entity.ChatAIResponse, error)
+	ChatStream(ctx context.Context, message string, imageBase64 string, prompt ChatPrompt, conv Conversation, tools ToolExecutor, onToken func(string) error) (*entity.ChatAIResponse, error)
+	ProcessVoice(path string) (*entity.VoiceTranscript, error)
+	// Speak membacakan balasan sebagai audio OGG/Opus; ErrSpeechDisabled jika TTS tidak dikonfigurasi.
+	Speak(text string) ([]byte, error)
+	Summarize(userID uint, previous string, turns []aiprovider.Message) (string, error)
+	PhraseInsights(userID uint, facts []entity.InsightFact) ([]string, error)
+	ProviderHealth() []aiprovider.ProviderHealth
+	// CheckRateLimit menolak lebih awal user scope yang token bucket-nya habis tanpa mengambil
+	// token, agar pesan yang pasti ditolak tidak ditranskripsi maupun disimpan ke history.
+	CheckRateLimit() error
+	// WithScope mengembalikan salinan service yang request-nya dihitung sebagai milik scope:
+	// kena rate limit per user, antre bergiliran dengan user lain dan dicatat pemakaiannya.
+	WithScope(scope AIScope) AIService
+}
+
+// AIScope menandai pemilik dan kanal request AI. Scope kosong (eval, tes) tidak kena rate limit.
+type AIScope struct {
+	UserID  uint
+	Channel string // entity.AIChannelWeb, entity.AIChannelWhatsApp
+	Voice   bool   // pesan berasal dari pesan suara
 }
 
 type aiService struct {
-	provider   aiprovider.Provider
-	whisperURL string
-	llmSem     chan struct{}
+	provider aiprovider.Provider
+	voice    *VoicePipeline
+	speech   *SpeechPipeline
+	limiter  *AILimiter
+	usage    AIUsageRecorder
+	scope    AIScope
 }
 
+// NewAIService memakai pipeline suara bawaan untuk whisperURL; kosong berarti tanpa transkripsi.
 func NewAIService(provider aiprovider.Provider, whisperURL string) AIService {
-	return &aiService{
-		provider:   provider,
-		whisperURL: whisperURL,
-		llmSem:     make(chan struct{}, 2), // max 2 concurrent LLM inference
+	var voice *VoicePipeline
+	if whisperURL != "" {
+		voice = NewVoicePipeline(aiprovider.NewWhisperClient(whisperURL, ""), DefaultVoiceConfig())
 	}
+	return NewAIServiceWithVoice(provider, voice, nil)
 }
 
-type chatMessage struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+// NewAIServiceWithVoice memakai pipeline transkripsi dan balasan suara yang sudah dikonfigurasi;
+// keduanya boleh nil.
+func NewAIServiceWithVoice(provider aiprovider.Provider, voice *VoicePipeline, speech *SpeechPipeline) AIService {
+	return NewAIServiceWithLimits(provider, voice, speech, NewAILimiter(DefaultAILimiterConfig()), nil)
+}
+
+// NewAIServiceWithLimits memakai limiter bersama dan menyimpan pemakaian setiap request ke usage
+// (boleh nil).
+func NewAIServiceWithLimits(provider aiprovider.Provider, voice *VoicePipeline, speech *SpeechPipeline, limiter *AILimiter, usage AIUsageRecorder) AIService {
+	return &aiService{
+		provider: provider,
+		voice:    voice,
+		speech:   speech,
+		limiter:  limiter,
+		usage:    usage,
+	}
 }
 
-type contentPart struct {
-	Type     string    `json:"type"`
-	Text     string    `json:"text,omitempty"`
-	ImageURL *imageURL `json:"image_url,omitempty"`
+func (s *aiService) WithScope(scope AIScope) AIService {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
 }
 
-type imageURL struct {
-	URL string `json:"url"`
+func (s *aiService) CheckRateLimit() error {
+	if s.scope.UserID == 0 {
+		return nil
+	}
+	if err := s.limiter.Check(s.scope.UserID); err != nil {
+		s.record(entity.AIUsage{Task: string(aiprovider.TaskChat), Status: entity.AIUsageRateLimited})
+		return err
+	}
+	return nil
 }
 
-type chatCompletionRequest struct {
-	Model       string        `json:"model,omitempty"`
-	Messages    []chatMessage `json:"messages"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	Stream      bool          `json:"stream"`
+func (s *aiService) Chat(message string, imageBase64 string, prompt ChatPrompt, conv Conversation, tools ToolExecutor) (*entity.ChatAIResponse, error) {
+	return s.chat(context.Background(), message, imageBase64, prompt, conv, tools, nil)
 }
 
-type chatCompletionResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+// ChatStream menjalankan loop tool yang sama dengan Chat, tetapi jawaban model dikirim ke onToken
+// selama masih dibuat. Membatalkan ctx (client terputus) menghentikan request ke provider.
+func (s *aiService) ChatStream(ctx context.Context, message string, imageBase64 string, prompt ChatPrompt, conv Conversation, tools ToolExecutor, onToken func(string) error) (*entity.ChatAIResponse, error) {
+	return s.chat(ctx, message, imageBase64, prompt, conv, tools, onToken)
 }
 
-func (s *aiService) Chat(message string, imageBase64 string, userContext string) (*entity.ChatAIResponse, error) {
-	select {
-	case s.llmSem <- struct{}{}:
-		defer func() { <-s.llmSem }()
-	case <-time.After(10 * time.Second):
-		return nil, fmt.Errorf("AI Server sedang sibuk, mohon coba beberapa saat lagi")
+func (s *aiService) chat(parent context.Context, message string, imageBase64 string, prompt ChatPrompt, conv Conversation, tools ToolExecutor, onToken func(string) error) (*entity.ChatAIResponse, error) {
+	// Pesan sederhana dicatat tanpa LLM, jadi tidak ikut rate limit maupun antrean.
+	if quick, ok := tools.(QuickCapturer); ok && imageBase64 == "" {
+		if reply, ok := quick.QuickCapture(message); ok {
+			return emitReply(&entity.ChatAIResponse{Reply: reply, FastPath: true}, onToken)
+		}
 	}
 
-	systemPrompt := fmt.Sprintf(SystemPromptChat, userContext)
-	log.Debug().Str("context", userContext).Msg("User Context sent to LLM")
+	// Chat bergambar butuh model vision, sama seperti pipeline struk.
+	task := aiprovider.TaskChat
+	if imageBase64 != "" {
+		task = aiprovider.TaskReceipt
+	}
+	if s.scope.UserID != 0 {
+		if err := s.limiter.Allow(s.scope.UserID); err != nil {
+			s.record(entity.AIUsage{Task: string(task), Status: entity.AIUsageRateLimited, HasImage: imageBase64 != ""})
+			return nil, err
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
+	var resp *entity.ChatAIResponse
+	err := s.call(parent, task, imageBase64 != "", 10*time.Second, func(ctx context.Context) error {
+		var err error
+		resp, err = s.chatLLM(ctx, message, imageBase64, prompt, conv, tools, onToken, task)
+		return err
+	})
+	return resp, err
+}
 
-	req := aiprovider.AIRequest{
-		Prompt:      message,
-		Base64Image: imageBase64,
-		System:      systemPrompt,
-	}
+func (s *aiService) chatLLM(parent context.Context, message string, imageBase64 string, prompt ChatPrompt, conv Conversation, tools ToolExecutor, onToken func(string) error, task aiprovider.Task) (*entity.ChatAIResponse, error) {
+	ctx, cancel := context.WithTimeout(parent, 120*time.Second)
+	defer cancel()
 
-	content, err := s.provider.GenerateCompletion(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("provider error: %w", err)
+	// Gambar struk lewat pipeline khusus; gambar lain (atau struk yang gagal dibaca) tetap
+	// diproses chat biasa.
+	if capturer, ok := tools.(ReceiptCapturer); ok && imageBase64 != "" {
+		receipt, err := s.extractReceipt(ctx, imageBase64, message)
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err != nil:
+			log.Warn().Err(err).Msg("Pipeline struk gagal, fallback ke chat")
+		case !receipt.IsReceipt:
+			log.Debug().Msg("Gambar bukan struk, fallback ke chat")
+		default:
+			if reply, ok := capturer.CaptureReceipt(receipt, message); ok {
+				return emitReply(&entity.ChatAIResponse{Reply: reply, Receipt: receipt}, onToken)
+			}
+		}
 	}
 
-	log.Debug().Str("response", content).Msg("AI Raw Response")
+	log.Debug().Str("prompt_version", prompt.Version).Str("prompt", prompt.Text).Int("history_turns", len(conv.Turns)).Msg("System prompt sent to LLM")
 
-	return parseAIResponse(content), nil
+	return s.runTools(ctx, s.providerFor(task), aiprovider.AIRequest{
+		Prompt:      message,
+		Base64Image: imageBase64,
+		System:      composeSystemPrompt(prompt, conv),
+		History:     conv.Turns,
+	}, tools, onToken)
 }
 
-func (s *aiService) ProcessVoice(path string) (string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return "", fmt.Errorf("failed to open audio file: %w", err)
+// emitReply mengirim balasan yang tidak berasal dari model (fast path, struk) sekaligus ke onToken.
+func emitReply(resp *entity.ChatAIResponse, onToken func(string) error) (*entity.ChatAIResponse, error) {
+	if onToken != nil && resp.Reply != "" {
+		if err := onToken(resp.Reply); err != nil {
+			return nil, err
+		}
 	}
-	defer file.Close()
+	return resp, nil
+}
 
-	pr, pw := io.Pipe()
-	m := multipart.NewWriter(pw)
+// runTools memanggil provider berulang kali: setiap tool call dieksekusi dan hasilnya (termasuk
+// error validasi) dikirim balik ke model sampai model menjawab tanpa tool call. Dengan onToken,
+// setiap ronde di-stream; teks sebelum tool call ikut terkirim, dan jawaban akhir di event done
+// yang menjadi acuan.
+func (s *aiService) runTools(ctx context.Context, provider aiprovider.Provider, req aiprovider.AIRequest, tools ToolExecutor, onToken func(string) error) (*entity.ChatAIResponse, error) {
+	if tools != nil {
+		req.Tools = tools.Tools()
+	}
 
-	go func() {
-		defer pw.Close()
-		defer m.Close()
-		fw, err := m.CreateFormFile("file", filepath.Base(path))
-		if err == nil {
-			io.Copy(fw, file)
+	for round := 0; round < maxToolRounds; round++ {
+		var resp *aiprovider.AIResponse
+		var err error
+		if onToken != nil {
+			resp, err = provider.StreamWithTools(ctx, req, onToken)
+		} else {
+			resp, err = provider.GenerateWithTools(ctx, req)
 		}
-		m.WriteField("model", "small")
-	}()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
-	defer cancel()
+		if err != nil {
+			return nil, fmt.Errorf("provider error: %w", err)
+		}
+		log.Debug().Str("response", resp.Content).Int("tool_calls", len(resp.ToolCalls)).Int("round", round).Msg("AI Raw Response")
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.whisperURL+"/v1/audio/transcriptions", pr)
-	if err != nil {
-		return "", fmt.Errorf("failed to create whisper request: %w", err)
-	}
-	req.Header.Set("Content-Type", m.FormDataContentType())
+		if len(resp.ToolCalls) == 0 || tools == nil {
+			return &entity.ChatAIResponse{Reply: resp.Content, ToolRounds: round}, nil
+		}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("whisper request failed: %w", err)
+		req.ToolTurns = append(req.ToolTurns, aiprovider.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			req.ToolTurns = append(req.ToolTurns, aiprovider.Message{Role: "tool", Content: tools.Execute(call), ToolCallID: call.ID})
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("whisper returned status %d: %s", resp.StatusCode, string(body))
-	}
+	log.Warn().Int("rounds", maxToolRounds).Msg("AI tool loop reached max rounds")
+	return emitReply(&entity.ChatAIResponse{
+		Reply:      "Maaf, permintaan ini butuh terlalu banyak langkah. Coba pecah jadi beberapa pesan ya 🙏",
+		ToolRounds: maxToolRounds,
+	}, onToken)
+}
 
-	var result struct {
-		Text string `json:"text"`
+// ProcessVoice mentranskripsi pesan suara lewat VoicePipeline, lalu merapikan teksnya dengan
+// model koreksi jika dikonfigurasi.
+func (s *aiService) ProcessVoice(path string) (*entity.VoiceTranscript, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode whisper response: %w", err)
+	if s.voice == nil {
+		return nil, errors.New("transkripsi suara belum dikonfigurasi")
 	}
 
-	return strings.TrimSpace(result.Text), nil
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
 
-func (s *aiService) ChatStream(message string, imageBase64 string, userContext string, onToken func(string) error) (*entity.ChatAIResponse, error) {
-	select {
-	case s.llmSem <- struct{}{}:
-		defer func() { <-s.llmSem }()
-	case <-time.After(10 * time.Second):
-		return nil, fmt.Errorf("AI Server sedang sibuk, mohon coba beberapa saat lagi")
+	transcript, err := s.voice.Transcribe(ctx, path)
+	if err != nil {
+		return nil, err
 	}
-
-	systemPrompt := fmt.Sprintf(SystemPromptChat, userContext)
-	log.Debug().Str("context", userContext).Msg("User Context sent to LLM (Stream)")
-
-	payload := chatCompletionRequest{
-		Messages:    buildMessages(systemPrompt, message, imageBase64),
-		MaxTokens:   1024,
-		Temperature: 0.3,
-		Stream:      true,
+	if corrected := s.correctTranscription(transcript.Text); corrected != transcript.Text {
+		transcript.RawText = transcript.Text
+		transcript.Text = corrected
 	}
-
-	return s.doChatStream(payload, onToken)
+	log.Debug().Str("language", transcript.Language).Float64("confidence", transcript.Confidence).Strs("low_confidence", transcript.LowConfidence).Msg("Pesan suara ditranskripsi")
+	return transcript, nil
 }
 
-func buildMessages(system, prompt, imageBase64 string) []chatMessage {
-	var userContent interface{}
-	if imageBase64 != "" {
-		userContent = []contentPart{
-			{Type: "text", Text: prompt},
-			{Type: "image_url", ImageURL: &imageURL{URL: "data:image/jpeg;base64," + imageBase64}},
-		}
-	} else {
-		userContent = prompt
+// providerFor memilih profil provider untuk task jika provider mendukung routing per task.
+func (s *aiService) providerFor(task aiprovider.Task) aiprovider.Provider {
+	if router, ok := s.provider.(aiprovider.TaskRouter); ok {
+		return router.ForTask(task)
 	}
+	return s.provider
+}
 
-	return []chatMessage{
-		{Role: "system", Content: system},
-		{Role: "user", Content: userContent},
+func (s *aiService) Speak(text string) ([]byte, error) {
+	if s.speech == nil {
+		return nil, ErrSpeechDisabled
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return s.speech.Speak(ctx, text)
 }
 
-func parseAIResponse(content string) *entity.ChatAIResponse {
-	startIdx := strings.Index(content, "{")
-	endIdx := strings.LastIndex(content, "}")
-	if startIdx == -1 || endIdx == -1 {
-		return &entity.ChatAIResponse{Reply: content, IsTransaction: false}
+// correctTranscription merapikan hasil Whisper (angka, nama dompet, istilah keuangan) dengan
+// model kecil. Hanya berjalan jika profil voice_correction dikonfigurasi; jika gagal, transkripsi
+// asli yang dipakai.
+func (s *aiService) correctTranscription(text string) string {
+	router, ok := s.provider.(aiprovider.TaskRouter)
+	if !ok || !router.HasRoute(aiprovider.TaskVoiceCorrection) || text == "" {
+		return text
 	}
 
-	jsonPart := content[startIdx : endIdx+1]
-	var response entity.ChatAIResponse
-	if err := json.Unmarshal([]byte(jsonPart), &response); err != nil {
-		log.Warn().Err(err).Str("raw", jsonPart).Msg("Failed to parse AI JSON")
-		return &entity.ChatAIResponse{Reply: content, IsTransaction: false}
+	var corrected string
+	err := s.call(context.Background(), aiprovider.TaskVoiceCorrection, false, 10*time.Second, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		var err error
+		corrected, err = router.ForTask(aiprovider.TaskVoiceCorrection).GenerateCompletion(ctx, aiprovider.AIRequest{
+			Prompt: text,
+			System: SystemPromptVoiceCorrection,
+		})
+		return err
+	})
+	if errors.Is(err, ErrAIBusy) {
+		log.Warn().Msg("AI Server sibuk, koreksi transkripsi dilewati")
+		return text
+	}
+	corrected = strings.TrimSpace(corrected)
+	if err != nil || corrected == "" {
+		log.Warn().Err(err).Msg("Koreksi transkripsi gagal, memakai hasil Whisper")
+		return text
 	}
-	return &response
+	log.Debug().Str("original", text).Str("corrected", corrected).Msg("Transkripsi dikoreksi")
+	return corrected
 }
 
-func (s *aiService) doChatStream(payload chatCompletionRequest, onToken func(string) error) (*entity.ChatAIResponse, error) {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
+// ProviderHealth mengembalikan status circuit breaker tiap provider LLM, atau nil jika provider
+// tidak melacaknya.
+func (s *aiService) ProviderHealth() []aiprovider.ProviderHealth {
+	if reporter, ok := s.provider.(aiprovider.HealthReporter); ok {
+		return reporter.Health()
 	}
+	return nil
+}
 
-	type urlGetter interface {
-		GetURL() string
-	}
+func (s *aiService) Summarize(userID uint, previous string, turns []aiprovider.Message) (string, error) {
+	background := s.WithScope(AIScope{UserID: userID, Channel: entity.AIChannelBackground}).(*aiService)
 
-	var streamURL string
-	if ug, ok := s.provider.(urlGetter); ok {
-		streamURL = ug.GetURL() + "/v1/chat/completions"
-	} else {
-		return s.chatStreamViaProvider(payload, onToken)
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("RINGKASAN SEBELUMNYA:\n" + previous + "\n\nGILIRAN BARU:\n")
 	}
-
-	req, err := http.NewRequest("POST", streamURL, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return nil, err
+	for _, turn := range turns {
+		role := "User"
+		if turn.Role == "assistant" {
+			role = "Asisten"
+		}
+		transcript.WriteString(role + ": " + turn.Content + "\n")
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "text/event-stream")
 
-	client := &http.Client{Timeout: 180 * time.Second}
-	resp, err := client.Do(req)
+	var content string
+	err := background.call(context.Background(), aiprovider.TaskSummarize, false, 30*time.Second, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+
+		var err error
+		content, err = s.providerFor(aiprovider.TaskSummarize).GenerateCompletion(ctx, aiprovider.AIRequest{
+			Prompt: transcript.String(),
+			System: SystemPromptSummarize,
+		})
+		return err
+	})
+	if errors.Is(err, ErrAIBusy) {
+		return "", fmt.Errorf("AI Server sedang sibuk, ringkasan ditunda")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("llm stream request failed: %w", err)
+		return "", fmt.Errorf("provider error: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("llm returned status %d: %s", resp.StatusCode, string(body))
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", fmt.Errorf("ringkasan kosong")
 	}
-
-	return parseSSEStream(resp.Body, onToken)
+	return content, nil
 }
 
-func (s *aiService) chatStreamViaProvider(payload chatCompletionRequest, onToken func(string) error) (*entity.ChatAIResponse, error) {
-	var prompt, system, imageBase64 string
-	for _, msg := range payload.Messages {
-		switch msg.Role {
-		case "system":
-			if s, ok := msg.Content.(string); ok {
-				system = s
-			}
-		case "user":
-			if s, ok := msg.Content.(string); ok {
-				prompt = s
-			} else if parts, ok := msg.Content.([]contentPart); ok {
-				for _, p := range parts {
-					if p.Type == "text" {
-						prompt = p.Text
-					} else if p.Type == "image_url" && p.ImageURL != nil {
-						imageBase64 = strings.TrimPrefix(p.ImageURL.URL, "data:image/jpeg;base64,")
-					}
-				}
-			}
-		}
+// PhraseInsights meminta LLM merangkai tiap fakta menjadi satu kalimat. Jawaban yang jumlah
+// barisnya tidak sama dengan jumlah fakta ditolak, sehingga pemanggil memakai Summary fakta.
+func (s *aiService) PhraseInsights(userID uint, facts []entity.InsightFact) ([]string, error) {
+	if len(facts) == 0 {
+		return nil, nil
 	}
+	background := s.WithScope(AIScope{UserID: userID, Channel: entity.AIChannelBackground}).(*aiService)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
-	req := aiprovider.AIRequest{
-		Prompt:      prompt,
-		Base64Image: imageBase64,
-		System:      system,
+	var prompt strings.Builder
+	prompt.WriteString("FAKTA:\n")
+	for i, f := range facts {
+		prompt.WriteString(fmt.Sprintf("%d. %s\n", i+1, f.Summary))
 	}
 
-	content, err := s.provider.GenerateCompletion(ctx, req)
+	var content string
+	err := background.call(context.Background(), aiprovider.TaskInsight, false, 30*time.Second, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+
+		var err error
+		content, err = s.providerFor(aiprovider.TaskInsight).GenerateCompletion(ctx, aiprovider.AIRequest{
+			Prompt: prompt.String(),
+			System: SystemPromptInsight,
+		})
+		return err
+	})
+	if errors.Is(err, ErrAIBusy) {
+		return nil, fmt.Errorf("AI Server sedang sibuk, insight ditunda")
+	}
 	if err != nil {
 		return nil, fmt.Errorf("provider error: %w", err)
 	}
 
-	log.Debug().Str("content", content).Msg("AI Stream (via provider) Final Content")
-
-	response := parseAIResponse(content)
-	for _, char := range response.Reply {
-		if err := onToken(string(char)); err != nil {
-			return nil, err
-		}
-	}
-
-	return response, nil
-}
-
-func parseSSEStream(body io.Reader, onToken func(string) error) (*entity.ChatAIResponse, error) {
-	reader := bufio.NewReader(body)
-	var fullContent strings.Builder
-
-	var (
-		buffer       strings.Builder
-		inString     bool
-		isReplyField bool
-		escape       bool
-		jsonDepth    int
-	)
-
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, fmt.Errorf("error reading stream: %w", err)
-		}
-
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
 		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "data: ") {
+		if line == "" {
 			continue
 		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
-		}
-
-		var chunk struct {
-			Choices []struct {
-				Delta struct {
-					Content string `json:"content"`
-				} `json:"delta"`
-			} `json:"choices"`
+		prefix := fmt.Sprintf("%d.", len(lines)+1)
+		if !strings.HasPrefix(line, prefix) {
+			return nil, fmt.Errorf("insight baris %d tidak sesuai format", len(lines)+1)
 		}
+		lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, prefix)))
+	}
+	if len(lines) != len(facts) {
+		return nil, fmt.Errorf("insight berisi %d baris untuk %d fakta", len(lines), len(facts))
+	}
+	return lines, nil
+}
 
-		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			continue
-		}
+// call menjalankan fn setelah mendapat slot LLM dari antrean adil (paling lama wait), lalu
+// mencatat token, latensi dan provider yang menjawab. ctx yang diterima fn membawa UsageMeter.
+func (s *aiService) call(parent context.Context, task aiprovider.Task, hasImage bool, wait time.Duration, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	usage := entity.AIUsage{Task: string(task), HasImage: hasImage}
 
-		if len(chunk.Choices) > 0 {
-			content := chunk.Choices[0].Delta.Content
-			if content != "" {
-				fullContent.WriteString(content)
-
-				for _, r := range content {
-					if !inString {
-						if r == '{' {
-							jsonDepth++
-						} else if r == '}' {
-							jsonDepth--
-						}
-					}
-
-					buffer.WriteRune(r)
-					bufStr := buffer.String()
-
-					if !inString && !isReplyField {
-						if strings.HasSuffix(bufStr, `"reply": "`) || strings.HasSuffix(bufStr, `"reply":"`) {
-							isReplyField = true
-							inString = true
-							buffer.Reset()
-						}
-					} else if isReplyField && inString {
-						if escape {
-							escape = false
-							if err := onToken(string(r)); err != nil {
-								return nil, err
-							}
-						} else {
-							if r == '\\' {
-								escape = true
-								if err := onToken(string(r)); err != nil {
-									return nil, err
-								}
-							} else if r == '"' {
-								inString = false
-								isReplyField = false
-							} else {
-								if err := onToken(string(r)); err != nil {
-									return nil, err
-								}
-							}
-						}
-					}
-				}
-				if buffer.Len() > 20 && !isReplyField {
-					temp := buffer.String()
-					buffer.Reset()
-					buffer.WriteString(temp[len(temp)-20:])
-				}
-			}
+	release, err := s.limiter.Acquire(parent, s.scope.UserID, wait)
+	if err != nil {
+		if errors.Is(err, ErrAIBusy) {
+			usage.Status = entity.AIUsageBusy
+			usage.QueueMs = time.Since(start).Milliseconds()
+			usage.LatencyMs = usage.QueueMs
+			s.record(usage)
 		}
+		return err
 	}
-
-	finalContent := fullContent.String()
-	log.Debug().Str("content", finalContent).Msg("AI Stream Final Content")
-
-	startIdx := strings.Index(finalContent, "{")
-	endIdx := strings.LastIndex(finalContent, "}")
-
-	response := &entity.ChatAIResponse{
-		Reply:         finalContent,
-		IsTransaction: false,
-		Transactions:  nil,
+	defer release()
+	usage.QueueMs = time.Since(start).Milliseconds()
+
+	meter := &aiprovider.UsageMeter{}
+	err = fn(aiprovider.WithUsageMeter(parent, meter))
+
+	counted := meter.Usage()
+	usage.Provider = counted.Provider
+	usage.PromptTokens = counted.PromptTokens
+	usage.CompletionTokens = counted.CompletionTokens
+	usage.LatencyMs = time.Since(start).Milliseconds()
+	usage.Status = entity.AIUsageOK
+	if err != nil {
+		usage.Status = entity.AIUsageError
 	}
+	s.record(usage)
+	return err
+}
 
-	if startIdx != -1 && endIdx != -1 {
-		jsonPart := finalContent[startIdx : endIdx+1]
-		var parsed entity.ChatAIResponse
-		if err := json.Unmarshal([]byte(jsonPart), &parsed); err == nil {
-			response.Reply = parsed.Reply
-			response.IsTransaction = parsed.IsTransaction
-			response.Transactions = parsed.Transactions
-		}
+// record melengkapi catatan pemakaian dengan scope service lalu menyimpannya.
+func (s *aiService) record(usage entity.AIUsage) {
+	if s.usage == nil {
+		return
 	}
-
-	return response, nil
-}
\ No newline at end of file
+	usage.UserID = s.scope.UserID
+	usage.Channel = s.scope.Channel
+	usage.HasVoice = s.scope.Voice
+	s.usage.Record(usage)
+}
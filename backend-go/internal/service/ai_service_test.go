@@ -2,17 +2,71 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"cuan-backend/internal/entity"
 	aiprovider "cuan-backend/internal/provider/ai"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-type stubProvider struct{}
+// stubProvider mengembalikan responses secara berurutan dan menyimpan setiap request.
+type stubProvider struct {
+	responses  []aiprovider.AIResponse
+	requests   []aiprovider.AIRequest
+	streamed   int
+	completion string
+}
+
+func (s *stubProvider) GenerateCompletion(_ context.Context, req aiprovider.AIRequest) (string, error) {
+	s.requests = append(s.requests, req)
+	return s.completion, nil
+}
+
+func (s *stubProvider) GenerateWithTools(_ context.Context, req aiprovider.AIRequest) (*aiprovider.AIResponse, error) {
+	s.requests = append(s.requests, req)
+	if len(s.responses) == 0 {
+		return &aiprovider.AIResponse{Content: "selesai"}, nil
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return &resp, nil
+}
+
+// StreamWithTools mengirim jawaban stub per kata.
+func (s *stubProvider) StreamWithTools(ctx context.Context, req aiprovider.AIRequest, onToken func(string) error) (*aiprovider.AIResponse, error) {
+	resp, _ := s.GenerateWithTools(ctx, req)
+	for i, word := range strings.SplitAfter(resp.Content, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onToken(word); err != nil {
+			return nil, err
+		}
+		s.streamed = i + 1
+	}
+	return resp, nil
+}
 
-func (s *stubProvider) GenerateCompletion(_ context.Context, _ aiprovider.AIRequest) (string, error) {
-	return "", nil
+type stubToolExecutor struct {
+	calls []aiprovider.ToolCall
+}
+
+func (e *stubToolExecutor) Tools() []aiprovider.Tool {
+	return []aiprovider.Tool{{Name: "create_transaction", Parameters: json.RawMessage(`{"type":"object"}`)}}
+}
+
+func (e *stubToolExecutor) Execute(call aiprovider.ToolCall) string {
+	e.calls = append(e.calls, call)
+	if strings.Contains(string(call.Arguments), `"amount":0`) {
+		return `{"ok":false,"error":"amount harus lebih dari 0"}`
+	}
+	return `{"ok":true}`
 }
 
 func TestAIService_ProcessVoice_FileNotFound(t *testing.T) {
@@ -29,3 +83,212 @@ func TestAIService_ProcessVoice_NoWhisperURL(t *testing.T) {
 	_, err := svc.ProcessVoice("./non_existent_file.ogg")
 	assert.Error(t, err)
 }
+
+func TestAIService_Chat_FeedsToolErrorsBackToModel(t *testing.T) {
+	provider := &stubProvider{responses: []aiprovider.AIResponse{
+		{ToolCalls: []aiprovider.ToolCall{{ID: "call_0", Name: "create_transaction", Arguments: json.RawMessage(`{"amount":0}`)}}},
+		{ToolCalls: []aiprovider.ToolCall{{ID: "call_1", Name: "create_transaction", Arguments: json.RawMessage(`{"amount":15000}`)}}},
+		{Content: "Dicatat! ✅"},
+	}}
+	tools := &stubToolExecutor{}
+	svc := NewAIService(provider, "")
+
+	resp, err := svc.Chat("beli kopi 15rb", "", ChatPrompt{}, Conversation{}, tools)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Dicatat! ✅", resp.Reply)
+	assert.Equal(t, 2, resp.ToolRounds)
+	assert.Len(t, tools.calls, 2)
+	require.Len(t, provider.requests, 3)
+	assert.NotEmpty(t, provider.requests[0].Tools)
+
+	// Ronde kedua membawa error validasi dari ronde pertama.
+	turns := provider.requests[1].ToolTurns
+	require.Len(t, turns, 2)
+	assert.Equal(t, "assistant", turns[0].Role)
+	assert.Equal(t, "tool", turns[1].Role)
+	assert.Equal(t, "call_0", turns[1].ToolCallID)
+	assert.Contains(t, turns[1].Content, "amount harus lebih dari 0")
+	assert.Len(t, provider.requests[2].ToolTurns, 4)
+}
+
+func TestAIService_Chat_StopsAfterMaxToolRounds(t *testing.T) {
+	call := aiprovider.AIResponse{ToolCalls: []aiprovider.ToolCall{{ID: "call_0", Name: "create_transaction", Arguments: json.RawMessage(`{}`)}}}
+	provider := &stubProvider{}
+	for i := 0; i < maxToolRounds+1; i++ {
+		provider.responses = append(provider.responses, call)
+	}
+	svc := NewAIService(provider, "")
+
+	resp, err := svc.Chat("halo", "", ChatPrompt{}, Conversation{}, &stubToolExecutor{})
+
+	require.NoError(t, err)
+	assert.Equal(t, maxToolRounds, resp.ToolRounds)
+	assert.Len(t, provider.requests, maxToolRounds)
+}
+
+type stubQuickCapturer struct {
+	stubToolExecutor
+	ok       bool
+	messages []string
+}
+
+func (q *stubQuickCapturer) QuickCapture(message string) (string, bool) {
+	q.messages = append(q.messages, message)
+	if !q.ok {
+		return "", false
+	}
+	return "Siap, langsung dicatat! 👍", true
+}
+
+func TestAIService_Chat_QuickCaptureSkipsProvider(t *testing.T) {
+	provider := &stubProvider{}
+	svc := NewAIService(provider, "")
+
+	tools := &stubQuickCapturer{ok: true}
+	resp, err := svc.Chat("kopi 18rb gopay", "", ChatPrompt{}, Conversation{}, tools)
+	require.NoError(t, err)
+	assert.True(t, resp.FastPath)
+	assert.Empty(t, provider.requests)
+
+	// Fallback ke LLM jika parser tidak yakin, dan gambar tidak pernah lewat fast path.
+	tools = &stubQuickCapturer{ok: false}
+	resp, err = svc.Chat("kopi 18rb sama roti", "", ChatPrompt{}, Conversation{}, tools)
+	require.NoError(t, err)
+	assert.False(t, resp.FastPath)
+	assert.Len(t, provider.requests, 1)
+
+	tools = &stubQuickCapturer{ok: true}
+	_, err = svc.Chat("struk ini", "aW1hZ2U=", ChatPrompt{}, Conversation{}, tools)
+	require.NoError(t, err)
+	assert.Empty(t, tools.messages)
+	assert.Len(t, provider.requests, 2)
+}
+
+func TestAIService_ChatStream_StreamsTokensUntilClientGone(t *testing.T) {
+	provider := &stubProvider{responses: []aiprovider.AIResponse{
+		{ToolCalls: []aiprovider.ToolCall{{ID: "call_0", Name: "create_transaction", Arguments: json.RawMessage(`{"amount":15000}`)}}},
+		{Content: "Sudah dicatat ya, kopi 15 ribu."},
+	}}
+	svc := NewAIService(provider, "")
+
+	var tokens []string
+	resp, err := svc.ChatStream(context.Background(), "kopi 15rb", "", ChatPrompt{}, Conversation{}, &stubToolExecutor{}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Greater(t, len(tokens), 1)
+	assert.Equal(t, resp.Reply, strings.Join(tokens, ""))
+	assert.Equal(t, 1, resp.ToolRounds)
+
+	// Tulisan ke client gagal: stream berhenti dan error diteruskan.
+	provider.responses = []aiprovider.AIResponse{{Content: "Jawaban yang panjang sekali"}}
+	provider.streamed = 0
+	gone := errors.New("client terputus")
+	_, err = svc.ChatStream(context.Background(), "halo", "", ChatPrompt{}, Conversation{}, &stubToolExecutor{}, func(token string) error {
+		if provider.streamed > 0 {
+			return gone
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, gone)
+	assert.Equal(t, 1, provider.streamed)
+}
+
+// stubRouter memetakan task ke stubProvider; task tanpa rute memakai rute chat.
+type stubRouter struct {
+	stubProvider
+	routes map[aiprovider.Task]*stubProvider
+}
+
+func (r *stubRouter) ForTask(task aiprovider.Task) aiprovider.Provider {
+	if p, ok := r.routes[task]; ok {
+		return p
+	}
+	return &r.stubProvider
+}
+
+func (r *stubRouter) HasRoute(task aiprovider.Task) bool {
+	_, ok := r.routes[task]
+	return ok
+}
+
+func TestAIService_RoutesTasksToProfiles(t *testing.T) {
+	vision := &stubProvider{}
+	summarizer := &stubProvider{completion: "Ringkasan baru"}
+	router := &stubRouter{routes: map[aiprovider.Task]*stubProvider{
+		aiprovider.TaskReceipt:   vision,
+		aiprovider.TaskSummarize: summarizer,
+	}}
+	svc := NewAIService(router, "")
+
+	_, err := svc.Chat("halo", "", ChatPrompt{}, Conversation{}, &stubToolExecutor{})
+	require.NoError(t, err)
+	_, err = svc.Chat("ini apa?", "aW1hZ2U=", ChatPrompt{}, Conversation{}, &stubToolExecutor{})
+	require.NoError(t, err)
+	summary, err := svc.Summarize(1, "", []aiprovider.Message{{Role: "user", Content: "halo"}})
+	require.NoError(t, err)
+
+	assert.Len(t, router.requests, 1)
+	require.Len(t, vision.requests, 1)
+	assert.Equal(t, "aW1hZ2U=", vision.requests[0].Base64Image)
+	assert.Equal(t, "Ringkasan baru", summary)
+	require.Len(t, summarizer.requests, 1)
+	assert.Equal(t, SystemPromptSummarize, summarizer.requests[0].System)
+}
+
+func TestAIService_CorrectTranscription(t *testing.T) {
+	// Tanpa rute voice_correction, transkripsi tidak diubah dan model tidak dipanggil.
+	router := &stubRouter{}
+	svc := NewAIService(router, "").(*aiService)
+	assert.Equal(t, "beli kopi lima belas ribu", svc.correctTranscription("beli kopi lima belas ribu"))
+	assert.Empty(t, router.requests)
+
+	corrector := &stubProvider{completion: " beli kopi 15rb \n"}
+	router.routes = map[aiprovider.Task]*stubProvider{aiprovider.TaskVoiceCorrection: corrector}
+	assert.Equal(t, "beli kopi 15rb", svc.correctTranscription("beli kopi lima belas ribu"))
+	require.Len(t, corrector.requests, 1)
+	assert.Equal(t, SystemPromptVoiceCorrection, corrector.requests[0].System)
+
+	// Jawaban kosong dari model: pakai hasil Whisper.
+	corrector.completion = ""
+	assert.Equal(t, "transfer ke bca", svc.correctTranscription("transfer ke bca"))
+}
+
+type stubUsageRecorder struct {
+	records []entity.AIUsage
+}
+
+func (r *stubUsageRecorder) Record(usage entity.AIUsage) {
+	r.records = append(r.records, usage)
+}
+
+func TestAIService_ScopedChatIsRateLimitedAndRecorded(t *testing.T) {
+	provider := &stubProvider{completion: "Ringkasan"}
+	usage := &stubUsageRecorder{}
+	limiter := NewAILimiter(AILimiterConfig{Concurrency: 1, Burst: 1, Refill: time.Minute})
+	svc := NewAIServiceWithLimits(provider, nil, nil, limiter, usage)
+	scoped := svc.WithScope(AIScope{UserID: 7, Channel: entity.AIChannelWhatsApp, Voice: true})
+
+	_, err := scoped.Chat("halo", "", ChatPrompt{}, Conversation{}, &stubToolExecutor{})
+	require.NoError(t, err)
+	_, err = scoped.Chat("halo lagi", "", ChatPrompt{}, Conversation{}, &stubToolExecutor{})
+	assert.ErrorIs(t, err, ErrAIRateLimited)
+	assert.Len(t, provider.requests, 1, "request yang kena rate limit tidak sampai ke provider")
+
+	// Tugas background tidak memakai bucket chat, dan service tanpa scope tidak dibatasi.
+	_, err = svc.Summarize(7, "", []aiprovider.Message{{Role: "user", Content: "halo"}})
+	require.NoError(t, err)
+	_, err = svc.Chat("halo", "", ChatPrompt{}, Conversation{}, &stubToolExecutor{})
+	require.NoError(t, err)
+
+	require.Len(t, usage.records, 4)
+	assert.Equal(t, entity.AIUsage{UserID: 7, Task: "chat", Channel: entity.AIChannelWhatsApp, Status: entity.AIUsageOK, HasVoice: true,
+		LatencyMs: usage.records[0].LatencyMs, QueueMs: usage.records[0].QueueMs}, usage.records[0])
+	assert.Equal(t, entity.AIUsageRateLimited, usage.records[1].Status)
+	assert.Equal(t, uint(7), usage.records[1].UserID)
+	assert.Equal(t, "summarize", usage.records[2].Task)
+	assert.Equal(t, entity.AIChannelBackground, usage.records[2].Channel)
+	assert.Equal(t, uint(0), usage.records[3].UserID)
+}
@@ -0,0 +1,1087 @@
+package service
+
+import (
+	"bytes"
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	pkgutils "cuan-backend/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ToolExecutor menjalankan tool yang dipanggil AI. Hasilnya (JSON) dikirim balik ke model
+// pada ronde berikutnya, termasuk pesan error validasi agar model bisa memperbaiki argumennya.
+type ToolExecutor interface {
+	Tools() []aiprovider.Tool
+	Execute(call aiprovider.ToolCall) string
+}
+
+type aiTool struct {
+	spec aiprovider.Tool
+	run  func(t *AIToolSession, args json.RawMessage) (interface{}, error)
+}
+
+var aiTools = []aiTool{
+	{
+		spec: aiprovider.Tool{
+			Name:        "create_transaction",
+			Description: "Mencatat SATU transaksi baru (pengeluaran/pemasukan). Panggil sekali per item; untuk struk, satu panggilan per produk.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"type":{"type":"string","enum":["expense","income"]},` +
+				`"amount":{"type":"number","description":"Nominal rupiah, misal 15rb = 15000"},` +
+				`"description":{"type":"string"},` +
+				`"category_name":{"type":"string","description":"Nama kategori persis dari daftar Kategori user"},` +
+				`"wallet_name":{"type":"string","description":"Nama dompet; kosongkan untuk Tunai"},` +
+				`"date":{"type":"string","description":"Tanggal transaksi YYYY-MM-DD jika user menyebutnya (kemarin, tgl 3, senin lalu); kosongkan untuk hari ini"}},` +
+				`"required":["type","amount","description"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).createTransaction,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "update_transaction",
+			Description: "Mengubah transaksi yang sudah ada (cek ID di DATA KEUANGAN atau riwayat), misal koreksi harga atau nama.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"id":{"type":"integer"},` +
+				`"type":{"type":"string","enum":["expense","income"]},` +
+				`"amount":{"type":"number"},` +
+				`"description":{"type":"string"},` +
+				`"category_name":{"type":"string"},` +
+				`"wallet_name":{"type":"string"},` +
+				`"date":{"type":"string","description":"Isi YYYY-MM-DD hanya jika user ingin memindah tanggalnya"}},` +
+				`"required":["id","type","amount","description"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).updateTransaction,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "delete_transaction",
+			Description: "Menghapus transaksi yang sudah ada berdasarkan ID.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"id":{"type":"integer"}},"required":["id"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).deleteTransaction,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "create_debt",
+			Description: "Mencatat utang baru (type debt: user meminjam uang) atau piutang baru (type receivable: user meminjamkan uang).",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"type":{"type":"string","enum":["debt","receivable"]},` +
+				`"name":{"type":"string","description":"Nama orang/pihak"},` +
+				`"amount":{"type":"number"},` +
+				`"wallet_name":{"type":"string","description":"Dompet tempat uang masuk/keluar; kosongkan untuk Tunai"},` +
+				`"description":{"type":"string"},` +
+				`"due_date":{"type":"string","description":"Jatuh tempo YYYY-MM-DD, opsional"}},` +
+				`"required":["type","name","amount"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).createDebt,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "pay_debt",
+			Description: "Mencatat pembayaran utang atau penerimaan piutang. Isi debt_id atau debt_name.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"debt_id":{"type":"integer"},` +
+				`"debt_name":{"type":"string"},` +
+				`"amount":{"type":"number"},` +
+				`"wallet_name":{"type":"string","description":"Kosongkan untuk memakai dompet utang tersebut"},` +
+				`"note":{"type":"string"}},` +
+				`"required":["amount"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).payDebt,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "add_contribution",
+			Description: "Menabung ke target tabungan (saving goal). Isi goal_id atau goal_name.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"goal_id":{"type":"integer"},` +
+				`"goal_name":{"type":"string"},` +
+				`"amount":{"type":"number"},` +
+				`"wallet_name":{"type":"string"},` +
+				`"description":{"type":"string"},` +
+				`"date":{"type":"string","description":"Tanggal YYYY-MM-DD jika user menyebutnya; kosongkan untuk hari ini"}},` +
+				`"required":["amount"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).addContribution,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "transfer_between_wallets",
+			Description: "Memindahkan saldo antar dompet milik user, misal tarik tunai atau top up e-wallet. Bukan pengeluaran.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"from_wallet":{"type":"string"},` +
+				`"to_wallet":{"type":"string"},` +
+				`"amount":{"type":"number"},` +
+				`"fee":{"type":"number","description":"Biaya admin, opsional"},` +
+				`"description":{"type":"string"},` +
+				`"date":{"type":"string","description":"Tanggal YYYY-MM-DD jika user menyebutnya; kosongkan untuk hari ini"}},` +
+				`"required":["from_wallet","to_wallet","amount"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).transferBetweenWallets,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "add_wishlist_item",
+			Description: "Menambahkan barang yang ingin dibeli ke wishlist. Tidak mengubah saldo.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"name":{"type":"string"},` +
+				`"estimated_price":{"type":"number"},` +
+				`"category_name":{"type":"string"},` +
+				`"priority":{"type":"string","enum":["low","medium","high"]}},` +
+				`"required":["name","estimated_price"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).addWishlistItem,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "query_report",
+			Description: "Mengambil total pengeluaran/pemasukan per kategori untuk rentang tanggal (YYYY-MM-DD). Pakai jika DATA KEUANGAN tidak cukup.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"start_date":{"type":"string"},` +
+				`"end_date":{"type":"string"},` +
+				`"type":{"type":"string","enum":["all","expense","income"]}},` +
+				`"required":["start_date","end_date"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).queryReport,
+	},
+	{
+		spec: aiprovider.Tool{
+			Name:        "query_transactions",
+			Description: "Menghitung total (sum), jumlah transaksi (count) dan rata-rata (avg) dengan filter dompet/kategori/kata kunci, bisa dikelompokkan per kategori, dompet, hari atau bulan. Transfer antar dompet tidak dihitung kecuali include_transfers.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"start_date":{"type":"string","description":"YYYY-MM-DD"},` +
+				`"end_date":{"type":"string","description":"YYYY-MM-DD, maksimal 366 hari setelah start_date"},` +
+				`"type":{"type":"string","enum":["expense","income"],"description":"Default expense"},` +
+				`"wallets":{"type":"array","items":{"type":"string"},"description":"Nama dompet; kosongkan untuk semua"},` +
+				`"categories":{"type":"array","items":{"type":"string"},"description":"Nama kategori; kosongkan untuk semua"},` +
+				`"search":{"type":"string","description":"Kata kunci di deskripsi transaksi, misal kopi"},` +
+				`"group_by":{"type":"string","enum":["none","category","wallet","day","month"]},` +
+				`"metrics":{"type":"array","items":{"type":"string","enum":["sum","count","avg"]},"description":"Default semua"},` +
+				`"include_transfers":{"type":"boolean"}},` +
+				`"required":["start_date","end_date"],"additionalProperties":false}`),
+		},
+		run: (*AIToolSession).queryTransactions,
+	},
+}
+
+// AIToolSession mengeksekusi tool untuk satu pesan user. Semua perubahan transaksi dalam satu
+// sesi dicatat sebagai satu batch AI sehingga bisa di-undo sekaligus; aksi lain (utang, tabungan,
+// transfer, wishlist) menyimpan baris konfirmasi untuk balasan dan ikut dicatat di batch sebagai
+// aksi yang tidak bisa di-undo otomatis.
+//
+// Transaksi, utang, transfer dan tabungan yang melewati batas auto-simpan user (atau berasal dari
+// gambar, jika user memintanya) tidak langsung disimpan, melainkan dikumpulkan menjadi satu draft
+// yang menunggu konfirmasi.
+//
+// Tanggal transaksi tidak dipercayakan sepenuhnya ke LLM: tanggal yang disebut di pesan user
+// diparse secara deterministik (WIB) dan dipakai untuk memvalidasi atau menimpa usulan model.
+type AIToolSession struct {
+	chatbot       *ChatbotService
+	userID        uint
+	saved         []entity.SavedTransaction
+	batchItems    []entity.AIBatchItem
+	confirmations []string
+
+	fromImage       bool
+	imageKey        string // lampiran untuk transaksi baru dari gambar
+	autoCommitLimit float64
+	confirmReceipts bool
+	holdReason      string // jika diisi, semua transaksi sesi ini menunggu konfirmasi
+	pending         []entity.TransactionItemAI
+	pendingReasons  []string
+	draft           *entity.AIDraft
+	replaying       bool // menjalankan item draft yang sudah dikonfirmasi user
+
+	today        time.Time   // tengah malam WIB saat sesi dibuat
+	messageDates []time.Time // tanggal yang disebut user di pesan ini
+}
+
+// NewToolSession membuat sesi tool untuk satu pesan user. fromImage menandai pesan berisi gambar/struk.
+func (s *ChatbotService) NewToolSession(userID uint, message string, fromImage bool) *AIToolSession {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now := time.Now().In(wib)
+	t := &AIToolSession{
+		chatbot:         s,
+		userID:          userID,
+		fromImage:       fromImage,
+		autoCommitLimit: DefaultAIAutoCommitLimit,
+		confirmReceipts: true,
+		today:           time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, wib),
+		messageDates:    pkgutils.FindDates(message, now),
+	}
+	if s.userRepo != nil {
+		if user, err := s.userRepo.FindByID(userID); err == nil {
+			if user.AIAutoCommitLimit != nil {
+				t.autoCommitLimit = *user.AIAutoCommitLimit
+			}
+			if user.AIConfirmReceipts != nil {
+				t.confirmReceipts = *user.AIConfirmReceipts
+			}
+		}
+	}
+	return t
+}
+
+// AttachImage melampirkan gambar yang sudah disimpan (key blob) ke setiap transaksi baru
+// yang dicatat dari pesan ini.
+func (t *AIToolSession) AttachImage(key string) *AIToolSession {
+	t.imageKey = key
+	return t
+}
+
+// RequireConfirmation menahan semua transaksi baru/ubahan di sesi ini sebagai draft, misalnya
+// karena ada kata di pesan suara yang kurang jelas.
+func (t *AIToolSession) RequireConfirmation(reason string) *AIToolSession {
+	t.holdReason = reason
+	return t
+}
+
+func (t *AIToolSession) Tools() []aiprovider.Tool {
+	return ChatTools()
+}
+
+// ChatTools mengembalikan spesifikasi semua tool chat, misalnya untuk evaluasi model tanpa database.
+func ChatTools() []aiprovider.Tool {
+	tools := make([]aiprovider.Tool, 0, len(aiTools))
+	for _, tool := range aiTools {
+		tools = append(tools, tool.spec)
+	}
+	return tools
+}
+
+func (t *AIToolSession) Execute(call aiprovider.ToolCall) string {
+	for _, tool := range aiTools {
+		if tool.spec.Name != call.Name {
+			continue
+		}
+		result, err := tool.run(t, call.Arguments)
+		if err != nil {
+			log.Warn().Err(err).Uint("user_id", t.userID).Str("tool", call.Name).Str("arguments", string(call.Arguments)).Msg("AI tool call rejected")
+			return toolResult(nil, err)
+		}
+		log.Info().Uint("user_id", t.userID).Str("tool", call.Name).Msg("AI tool call executed")
+		return toolResult(result, nil)
+	}
+	return toolResult(nil, fmt.Errorf("tool '%s' tidak dikenal", call.Name))
+}
+
+// Finish mencatat batch undo untuk sesi ini, menyimpan draft untuk transaksi yang perlu
+// konfirmasi, dan mengembalikan transaksi yang sudah tersimpan.
+func (t *AIToolSession) Finish() []entity.SavedTransaction {
+	t.chatbot.recordBatch(t.userID, t.batchItems)
+	t.batchItems = nil
+
+	if len(t.pending) > 0 {
+		draft, err := t.chatbot.draftSvc.Create(t.userID, strings.Join(t.pendingReasons, "; "), t.pending)
+		if err != nil {
+			log.Error().Err(err).Uint("user_id", t.userID).Msg("Gagal menyimpan draft AI")
+			t.confirm("⚠️ %d transaksi butuh konfirmasi tapi draft gagal disimpan. Coba kirim ulang.", len(t.pending))
+		} else {
+			t.draft = draft
+		}
+		t.pending = nil
+	}
+	return t.saved
+}
+
+// Committed melaporkan apakah sesi sudah menyimpan sesuatu (transaksi, aksi lain atau draft).
+// Panggil setelah Finish.
+func (t *AIToolSession) Committed() bool {
+	return len(t.saved) > 0 || len(t.confirmations) > 0 || t.draft != nil
+}
+
+// InterruptedReply adalah balasan ketika AI gagal setelah tool sudah menyimpan data. Ringkasan
+// sesi tetap ditambahkan oleh pemanggil agar user tahu apa yang tercatat dan tidak mengirim ulang
+// pesan yang akan membuat transaksi ganda.
+func InterruptedReply(err error) string {
+	return fmt.Sprintf("⚠️ AI terputus sebelum selesai menjawab (%s), tapi aksi di bawah ini sudah tersimpan. Jangan kirim ulang pesan yang sama.", err.Error())
+}
+
+// replay menjalankan satu item draft yang sudah dikonfirmasi user tanpa menahannya lagi. Aksi
+// non-transaksi dijalankan ulang lewat tool aslinya dengan argumen yang disimpan di draft.
+func (t *AIToolSession) replay(item entity.TransactionItemAI) error {
+	t.replaying = true
+	if item.Action != entity.DraftToolAction {
+		_, err := t.apply(item)
+		return err
+	}
+	for _, tool := range aiTools {
+		if tool.spec.Name == item.Tool {
+			_, err := tool.run(t, item.Arguments)
+			return err
+		}
+	}
+	return fmt.Errorf("tool '%s' tidak dikenal", item.Tool)
+}
+
+// Draft mengembalikan draft yang dibuat oleh Finish, atau nil.
+func (t *AIToolSession) Draft() *entity.AIDraft {
+	return t.draft
+}
+
+// Summary menyusun konfirmasi aksi non-transaksi dan daftar item draft untuk ditambahkan ke
+// balasan AI. Panggil setelah Finish.
+func (t *AIToolSession) Summary() string {
+	lines := t.confirmations
+	if t.draft != nil {
+		lines = append(lines, FormatDraftSummary(t.draft))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n\n" + strings.Join(lines, "\n")
+}
+
+// FormatDraftSummary menampilkan isi draft yang menunggu konfirmasi.
+func FormatDraftSummary(draft *entity.AIDraft) string {
+	summary := fmt.Sprintf("⏳ Menunggu konfirmasi (draft #%d, %s):", draft.ID, draft.Reason)
+	var items []entity.TransactionItemAI
+	_ = json.Unmarshal([]byte(draft.Items), &items)
+	for _, item := range items {
+		switch strings.ToLower(item.Action) {
+		case entity.DraftToolAction:
+			summary += "\n" + item.Description
+		case "update":
+			summary += fmt.Sprintf("\n✏️ #%d → %s — %s", item.ID, item.Description, formatRupiah(item.Amount))
+		default:
+			summary += fmt.Sprintf("\n📝 %s — %s (%s)%s", item.Description, formatRupiah(item.Amount), item.Type, FormatDateLabel(item.Date))
+		}
+	}
+	return summary
+}
+
+// confirmationReason mengembalikan alasan item harus menunggu konfirmasi, atau "" jika boleh langsung disimpan.
+// Delete tidak pernah ditahan karena bisa di-undo dan tidak menambah data baru.
+func (t *AIToolSession) confirmationReason(item entity.TransactionItemAI) string {
+	if t.chatbot.draftSvc == nil || t.replaying || item.Action == "delete" {
+		return ""
+	}
+	if t.holdReason != "" {
+		return t.holdReason
+	}
+	if t.fromImage && t.confirmReceipts {
+		return "transaksi dari gambar/struk"
+	}
+	if item.Amount > t.autoCommitLimit {
+		return "nominal di atas batas auto-simpan " + formatRupiah(t.autoCommitLimit)
+	}
+	return ""
+}
+
+func (t *AIToolSession) confirm(format string, args ...interface{}) {
+	t.confirmations = append(t.confirmations, fmt.Sprintf(format, args...))
+}
+
+// hold memasukkan item ke draft sesi ini dan mengembalikan hasil tool untuk model.
+func (t *AIToolSession) hold(item entity.TransactionItemAI, reason string) interface{} {
+	t.pending = append(t.pending, item)
+	if !containsString(t.pendingReasons, reason) {
+		t.pendingReasons = append(t.pendingReasons, reason)
+	}
+	return map[string]interface{}{
+		"status": "menunggu_konfirmasi",
+		"reason": reason,
+		"note":   "Belum disimpan. Minta user mengonfirmasi draft ini.",
+	}
+}
+
+// holdAction menahan aksi non-transaksi (utang, transfer, tabungan) dengan aturan konfirmasi yang
+// sama seperti transaksi. args disimpan di draft dan tool dijalankan ulang dengan args itu saat
+// draft dikonfirmasi. held bernilai false jika aksi boleh langsung dijalankan.
+func (t *AIToolSession) holdAction(tool string, args interface{}, amount float64, description string) (result interface{}, held bool, err error) {
+	reason := t.confirmationReason(entity.TransactionItemAI{Action: entity.DraftToolAction, Amount: amount})
+	if reason == "" {
+		return nil, false, nil
+	}
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return nil, false, err
+	}
+	return t.hold(entity.TransactionItemAI{
+		Action:      entity.DraftToolAction,
+		Tool:        tool,
+		Arguments:   raw,
+		Amount:      amount,
+		Description: description,
+	}, reason), true, nil
+}
+
+// recordAction mencatat aksi non-transaksi ke batch sesi ini. Undo menolak batch berisi aksi
+// seperti ini, sehingga "batal" tidak diam-diam membatalkan batch transaksi sebelumnya.
+func (t *AIToolSession) recordAction(kind, description string, amount float64) {
+	t.batchItems = append(t.batchItems, entity.AIBatchItem{Action: kind, Description: description, Amount: amount})
+}
+
+func toolResult(result interface{}, err error) string {
+	payload := map[string]interface{}{"ok": err == nil}
+	if err != nil {
+		payload["error"] = err.Error()
+	} else {
+		payload["result"] = result
+	}
+	data, _ := json.Marshal(payload)
+	return string(data)
+}
+
+func decodeToolArgs(raw json.RawMessage, dst interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("argumen tidak valid: %v", err)
+	}
+	return nil
+}
+
+type transactionToolArgs struct {
+	ID           uint    `json:"id"`
+	Type         string  `json:"type"`
+	Amount       float64 `json:"amount"`
+	Description  string  `json:"description"`
+	CategoryName string  `json:"category_name"`
+	WalletName   string  `json:"wallet_name"`
+	Date         string  `json:"date"`
+}
+
+func (a transactionToolArgs) validate() error {
+	if a.Type != "expense" && a.Type != "income" {
+		return fmt.Errorf("type harus 'expense' atau 'income', bukan '%s'", a.Type)
+	}
+	if a.Amount <= 0 {
+		return errors.New("amount harus lebih dari 0")
+	}
+	if strings.TrimSpace(a.Description) == "" {
+		return errors.New("description wajib diisi")
+	}
+	return nil
+}
+
+func (a transactionToolArgs) item(action string) entity.TransactionItemAI {
+	return entity.TransactionItemAI{
+		Action:       action,
+		ID:           a.ID,
+		Type:         a.Type,
+		Amount:       a.Amount,
+		Description:  strings.TrimSpace(a.Description),
+		CategoryName: a.CategoryName,
+		WalletName:   a.WalletName,
+		Date:         a.Date,
+	}
+}
+
+// resolveDate menentukan tanggal final untuk usulan model. Satu tanggal di pesan user selalu
+// menang; jika ada beberapa, usulan harus salah satunya. Tanpa tanggal di pesan, usulan hanya
+// dipercaya untuk gambar/struk (tanggal tercetak) selama tidak di masa depan dan tidak lebih
+// dari setahun lalu; selain itu diabaikan karena kemungkinan besar halusinasi.
+// Untuk update tanpa usulan tanggal (keepExisting), tanggal lama transaksi dipertahankan.
+func (t *AIToolSession) resolveDate(proposed string, keepExisting bool) (string, error) {
+	var proposedDay time.Time
+	if proposed != "" {
+		d, err := time.ParseInLocation("2006-01-02", proposed, t.today.Location())
+		if err != nil {
+			return "", errors.New("date harus berformat YYYY-MM-DD")
+		}
+		proposedDay = d
+	}
+	// Tanggal item draft sudah diresolusi saat pesan aslinya diproses.
+	if t.replaying {
+		return proposed, nil
+	}
+	if keepExisting && proposed == "" {
+		return "", nil
+	}
+
+	switch len(t.messageDates) {
+	case 0:
+		if proposed == "" || !t.fromImage {
+			return "", nil
+		}
+		if proposedDay.After(t.today) || proposedDay.Before(t.today.AddDate(-1, 0, 0)) {
+			return "", fmt.Errorf("date %s di luar rentang wajar untuk struk; kosongkan jika tanggal tidak terbaca", proposed)
+		}
+		return proposed, nil
+	case 1:
+		return t.messageDates[0].Format("2006-01-02"), nil
+	}
+
+	options := make([]string, len(t.messageDates))
+	for i, d := range t.messageDates {
+		options[i] = d.Format("2006-01-02")
+		if d.Equal(proposedDay) {
+			return proposed, nil
+		}
+	}
+	return "", fmt.Errorf("pesan menyebut beberapa tanggal (%s); isi date dengan salah satunya untuk item ini", strings.Join(options, ", "))
+}
+
+// actionDate meresolusi tanggal aksi non-transaksi (transfer, tabungan) seperti transaksi baru dan
+// membekukannya ke *date, agar draft yang baru dikonfirmasi besok tetap memakai hari pesan dikirim.
+func (t *AIToolSession) actionDate(date *string) (time.Time, error) {
+	resolved, err := t.resolveDate(*date, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if resolved == "" {
+		resolved = t.today.Format("2006-01-02")
+	}
+	*date = resolved
+	return transactionDate(resolved, time.Now())
+}
+
+func (t *AIToolSession) apply(item entity.TransactionItemAI) (interface{}, error) {
+	if item.Action == "create" && t.fromImage && item.Attachment == "" {
+		item.Attachment = t.imageKey
+	}
+	if reason := t.confirmationReason(item); reason != "" {
+		// Draft bisa baru dikonfirmasi besok; bekukan tanggalnya ke hari pesan dikirim.
+		if item.Action == "create" && item.Date == "" {
+			item.Date = t.today.Format("2006-01-02")
+		}
+		return t.hold(item, reason), nil
+	}
+
+	saved, batchItem, err := t.chatbot.applyItem(t.userID, item)
+	if err != nil {
+		return nil, err
+	}
+	t.saved = append(t.saved, *saved)
+	t.batchItems = append(t.batchItems, *batchItem)
+	return saved, nil
+}
+
+func (t *AIToolSession) createTransaction(raw json.RawMessage) (interface{}, error) {
+	var args transactionToolArgs
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.ID != 0 {
+		return nil, errors.New("create_transaction tidak menerima id; pakai update_transaction untuk mengubah")
+	}
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	date, err := t.resolveDate(args.Date, false)
+	if err != nil {
+		return nil, err
+	}
+	args.Date = date
+	return t.apply(args.item("create"))
+}
+
+func (t *AIToolSession) updateTransaction(raw json.RawMessage) (interface{}, error) {
+	var args transactionToolArgs
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.ID == 0 {
+		return nil, errors.New("id transaksi wajib diisi")
+	}
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	date, err := t.resolveDate(args.Date, true)
+	if err != nil {
+		return nil, err
+	}
+	args.Date = date
+	return t.apply(args.item("update"))
+}
+
+func (t *AIToolSession) deleteTransaction(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		ID uint `json:"id"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.ID == 0 {
+		return nil, errors.New("id transaksi wajib diisi")
+	}
+	return t.apply(entity.TransactionItemAI{Action: "delete", ID: args.ID})
+}
+
+func (t *AIToolSession) payDebt(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		DebtID     uint    `json:"debt_id"`
+		DebtName   string  `json:"debt_name"`
+		Amount     float64 `json:"amount"`
+		WalletName string  `json:"wallet_name"`
+		Note       string  `json:"note"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Amount <= 0 {
+		return nil, errors.New("amount harus lebih dari 0")
+	}
+	if t.chatbot.debtSvc == nil {
+		return nil, errors.New("fitur utang belum tersedia")
+	}
+
+	debt, err := t.findDebt(args.DebtID, args.DebtName)
+	if err != nil {
+		return nil, err
+	}
+	if args.Amount > debt.Remaining {
+		return nil, fmt.Errorf("amount %s melebihi sisa %s", formatRupiah(args.Amount), formatRupiah(debt.Remaining))
+	}
+
+	walletID, walletName := debt.WalletID, debt.Wallet.Name
+	if strings.TrimSpace(args.WalletName) != "" {
+		if walletID, walletName, err = t.chatbot.resolveWallet(t.userID, args.WalletName); err != nil {
+			return nil, fmt.Errorf("wallet '%s' tidak ditemukan: %w", args.WalletName, err)
+		}
+	}
+
+	verb := "💸 Bayar utang"
+	if debt.Type == entity.DebtTypeReceivable {
+		verb = "💰 Terima piutang"
+	}
+	line := fmt.Sprintf("%s %s — %s (%s)", verb, debt.Name, formatRupiah(args.Amount), walletName)
+	args.DebtID, args.WalletName = debt.ID, walletName
+	if result, held, err := t.holdAction("pay_debt", args, args.Amount, line); held || err != nil {
+		return result, err
+	}
+
+	updated, err := t.chatbot.debtSvc.PayDebt(debt.ID, t.userID, PayDebtInput{WalletID: walletID, Amount: args.Amount, Note: args.Note})
+	if err != nil {
+		return nil, fmt.Errorf("gagal membayar: %w", err)
+	}
+	t.recordAction("debt_pay", line, args.Amount)
+
+	if updated.IsPaid {
+		t.confirm("%s ✅ Lunas!", line)
+	} else {
+		t.confirm("%s, sisa %s", line, formatRupiah(updated.Remaining))
+	}
+	return map[string]interface{}{
+		"debt_id":   updated.ID,
+		"name":      updated.Name,
+		"type":      updated.Type,
+		"paid":      args.Amount,
+		"remaining": updated.Remaining,
+		"is_paid":   updated.IsPaid,
+		"wallet":    walletName,
+	}, nil
+}
+
+func (t *AIToolSession) findDebt(id uint, name string) (*entity.Debt, error) {
+	if id == 0 && strings.TrimSpace(name) == "" {
+		return nil, errors.New("isi debt_id atau debt_name")
+	}
+	debts, err := t.chatbot.debtRepo.FindByUserID(t.userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var active []entity.Debt
+	for _, d := range debts {
+		if !d.IsPaid {
+			active = append(active, d)
+		}
+	}
+	names := make([]string, len(active))
+	for i, d := range active {
+		names[i] = d.Name
+	}
+	idx := matchByName(names, name)
+	for i, d := range active {
+		if (id != 0 && d.ID == id) || (id == 0 && i == idx) {
+			return &active[i], nil
+		}
+	}
+	return nil, fmt.Errorf("utang/piutang aktif '%s' tidak ditemukan; yang aktif: %s", name, listOrDash(names))
+}
+
+func (t *AIToolSession) addContribution(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		GoalID      uint    `json:"goal_id"`
+		GoalName    string  `json:"goal_name"`
+		Amount      float64 `json:"amount"`
+		WalletName  string  `json:"wallet_name"`
+		Description string  `json:"description"`
+		Date        string  `json:"date"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Amount <= 0 {
+		return nil, errors.New("amount harus lebih dari 0")
+	}
+	if t.chatbot.savingGoalSvc == nil {
+		return nil, errors.New("fitur target tabungan belum tersedia")
+	}
+	if args.GoalID == 0 && strings.TrimSpace(args.GoalName) == "" {
+		return nil, errors.New("isi goal_id atau goal_name")
+	}
+
+	goals, err := t.chatbot.savingGoalRepo.FindAll(t.userID)
+	if err != nil {
+		return nil, err
+	}
+	var active []entity.SavingGoal
+	for _, g := range goals {
+		if !g.IsFinished {
+			active = append(active, g)
+		}
+	}
+	names := make([]string, len(active))
+	for i, g := range active {
+		names[i] = g.Name
+	}
+	var goal *entity.SavingGoal
+	idx := matchByName(names, args.GoalName)
+	for i, g := range active {
+		if (args.GoalID != 0 && g.ID == args.GoalID) || (args.GoalID == 0 && i == idx) {
+			goal = &active[i]
+			break
+		}
+	}
+	if goal == nil {
+		return nil, fmt.Errorf("target tabungan '%s' tidak ditemukan; yang aktif: %s", args.GoalName, listOrDash(names))
+	}
+
+	walletID, walletName, err := t.chatbot.resolveWallet(t.userID, args.WalletName)
+	if err != nil {
+		return nil, fmt.Errorf("wallet '%s' tidak ditemukan: %w", args.WalletName, err)
+	}
+
+	day, err := t.actionDate(&args.Date)
+	if err != nil {
+		return nil, err
+	}
+	args.GoalID, args.WalletName = goal.ID, walletName
+	line := fmt.Sprintf("🎯 Nabung %s ke %s (%s)%s", formatRupiah(args.Amount), goal.Name, walletName, FormatDateLabel(args.Date))
+	if result, held, err := t.holdAction("add_contribution", args, args.Amount, line); held || err != nil {
+		return result, err
+	}
+
+	description := args.Description
+	if description == "" {
+		description = "Tabungan " + goal.Name
+	}
+	if _, err := t.chatbot.savingGoalSvc.AddContribution(t.userID, goal.ID, ContributionInput{
+		WalletID:    walletID,
+		Amount:      args.Amount,
+		Date:        day,
+		Description: description,
+	}); err != nil {
+		return nil, fmt.Errorf("gagal menabung: %w", err)
+	}
+	t.recordAction("saving", line, args.Amount)
+
+	current := goal.CurrentAmount + args.Amount
+	progress := 0.0
+	if goal.TargetAmount > 0 {
+		progress = current / goal.TargetAmount * 100
+	}
+	t.confirm("%s — %s/%s (%.0f%%)", line, formatRupiah(current), formatRupiah(goal.TargetAmount), progress)
+	return map[string]interface{}{
+		"goal_id":        goal.ID,
+		"name":           goal.Name,
+		"contributed":    args.Amount,
+		"current_amount": current,
+		"target_amount":  goal.TargetAmount,
+		"wallet":         walletName,
+	}, nil
+}
+
+func (t *AIToolSession) createDebt(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Type        string  `json:"type"`
+		Name        string  `json:"name"`
+		Amount      float64 `json:"amount"`
+		WalletName  string  `json:"wallet_name"`
+		Description string  `json:"description"`
+		DueDate     string  `json:"due_date"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Type != string(entity.DebtTypePayable) && args.Type != string(entity.DebtTypeReceivable) {
+		return nil, fmt.Errorf("type harus 'debt' atau 'receivable', bukan '%s'", args.Type)
+	}
+	if strings.TrimSpace(args.Name) == "" {
+		return nil, errors.New("name wajib diisi")
+	}
+	if args.Amount <= 0 {
+		return nil, errors.New("amount harus lebih dari 0")
+	}
+	var dueDate *time.Time
+	if args.DueDate != "" {
+		d, err := time.ParseInLocation("2006-01-02", args.DueDate, t.today.Location())
+		if err != nil {
+			return nil, errors.New("due_date harus berformat YYYY-MM-DD")
+		}
+		dueDate = &d
+	}
+	if t.chatbot.debtSvc == nil {
+		return nil, errors.New("fitur utang belum tersedia")
+	}
+
+	walletID, walletName, err := t.chatbot.resolveWallet(t.userID, args.WalletName)
+	if err != nil {
+		return nil, fmt.Errorf("wallet '%s' tidak ditemukan: %w", args.WalletName, err)
+	}
+
+	label := "Utang ke"
+	if args.Type == string(entity.DebtTypeReceivable) {
+		label = "Piutang dari"
+	}
+	line := fmt.Sprintf("🧾 %s %s dicatat — %s (%s)", label, strings.TrimSpace(args.Name), formatRupiah(args.Amount), walletName)
+	if dueDate != nil {
+		line += ", jatuh tempo " + dueDate.Format("02/01/2006")
+	}
+	args.WalletName = walletName
+	if result, held, err := t.holdAction("create_debt", args, args.Amount, line); held || err != nil {
+		return result, err
+	}
+
+	debt, err := t.chatbot.debtSvc.CreateDebt(t.userID, CreateDebtInput{
+		WalletID:    walletID,
+		Name:        strings.TrimSpace(args.Name),
+		Amount:      args.Amount,
+		Type:        args.Type,
+		Description: args.Description,
+		DueDate:     dueDate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal mencatat utang: %w", err)
+	}
+	t.recordAction("debt", line, debt.Amount)
+	t.confirm("%s", line)
+
+	return map[string]interface{}{
+		"debt_id": debt.ID,
+		"name":    debt.Name,
+		"type":    debt.Type,
+		"amount":  debt.Amount,
+		"wallet":  walletName,
+	}, nil
+}
+
+func (t *AIToolSession) transferBetweenWallets(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		FromWallet  string  `json:"from_wallet"`
+		ToWallet    string  `json:"to_wallet"`
+		Amount      float64 `json:"amount"`
+		Fee         float64 `json:"fee"`
+		Description string  `json:"description"`
+		Date        string  `json:"date"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Amount <= 0 {
+		return nil, errors.New("amount harus lebih dari 0")
+	}
+	if args.Fee < 0 {
+		return nil, errors.New("fee tidak boleh negatif")
+	}
+
+	wallets, err := t.chatbot.walletRepo.FindByUserID(t.userID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(wallets))
+	for i, w := range wallets {
+		names[i] = w.Name
+	}
+	// Transfer tidak boleh jatuh ke dompet default: kedua nama harus cocok.
+	from, to := matchByName(names, args.FromWallet), matchByName(names, args.ToWallet)
+	if from < 0 {
+		return nil, fmt.Errorf("dompet asal '%s' tidak ditemukan; dompet user: %s", args.FromWallet, listOrDash(names))
+	}
+	if to < 0 {
+		return nil, fmt.Errorf("dompet tujuan '%s' tidak ditemukan; dompet user: %s", args.ToWallet, listOrDash(names))
+	}
+	if from == to {
+		return nil, errors.New("dompet asal dan tujuan tidak boleh sama")
+	}
+
+	day, err := t.actionDate(&args.Date)
+	if err != nil {
+		return nil, err
+	}
+	line := fmt.Sprintf("🔁 Transfer %s dari %s ke %s", formatRupiah(args.Amount), wallets[from].Name, wallets[to].Name)
+	if args.Fee > 0 {
+		line += " (biaya " + formatRupiah(args.Fee) + ")"
+	}
+	line += FormatDateLabel(args.Date)
+	args.FromWallet, args.ToWallet = wallets[from].Name, wallets[to].Name
+	if result, held, err := t.holdAction("transfer_between_wallets", args, args.Amount+args.Fee, line); held || err != nil {
+		return result, err
+	}
+
+	description := args.Description
+	if description == "" {
+		description = fmt.Sprintf("Transfer %s ke %s", wallets[from].Name, wallets[to].Name)
+	}
+	if err := t.chatbot.transactionSvc.TransferTransaction(t.userID, TransferTransactionInput{
+		FromWalletID: wallets[from].ID,
+		ToWalletID:   wallets[to].ID,
+		Amount:       args.Amount,
+		TransferFee:  args.Fee,
+		Description:  description,
+		Date:         day,
+	}); err != nil {
+		return nil, fmt.Errorf("gagal transfer: %w", err)
+	}
+	t.recordAction("transfer", line, args.Amount)
+	t.confirm("%s", line)
+
+	return map[string]interface{}{
+		"from":   wallets[from].Name,
+		"to":     wallets[to].Name,
+		"amount": args.Amount,
+		"fee":    args.Fee,
+	}, nil
+}
+
+func (t *AIToolSession) addWishlistItem(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Name           string  `json:"name"`
+		EstimatedPrice float64 `json:"estimated_price"`
+		CategoryName   string  `json:"category_name"`
+		Priority       string  `json:"priority"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(args.Name) == "" {
+		return nil, errors.New("name wajib diisi")
+	}
+	if args.EstimatedPrice <= 0 {
+		return nil, errors.New("estimated_price harus lebih dari 0")
+	}
+	switch entity.WishlistPriority(args.Priority) {
+	case "":
+		args.Priority = string(entity.WishlistPriorityMedium)
+	case entity.WishlistPriorityLow, entity.WishlistPriorityMedium, entity.WishlistPriorityHigh:
+	default:
+		return nil, fmt.Errorf("priority harus 'low', 'medium' atau 'high', bukan '%s'", args.Priority)
+	}
+	if t.chatbot.wishlistSvc == nil {
+		return nil, errors.New("fitur wishlist belum tersedia")
+	}
+
+	categoryID, categoryName, err := t.chatbot.resolveCategory(t.userID, args.CategoryName, "expense")
+	if err != nil {
+		return nil, fmt.Errorf("kategori '%s' tidak ditemukan: %w", args.CategoryName, err)
+	}
+
+	if err := t.chatbot.wishlistSvc.Create(t.userID, &StoreWishlistRequest{
+		CategoryID:     categoryID,
+		Name:           strings.TrimSpace(args.Name),
+		EstimatedPrice: args.EstimatedPrice,
+		Priority:       args.Priority,
+	}); err != nil {
+		return nil, fmt.Errorf("gagal menambah wishlist: %w", err)
+	}
+
+	line := fmt.Sprintf("⭐ Wishlist: %s — %s (%s, prioritas %s)", strings.TrimSpace(args.Name), formatRupiah(args.EstimatedPrice), categoryName, args.Priority)
+	t.recordAction("wishlist", line, args.EstimatedPrice)
+	t.confirm("%s", line)
+	return map[string]interface{}{
+		"name":            strings.TrimSpace(args.Name),
+		"estimated_price": args.EstimatedPrice,
+		"category":        categoryName,
+		"priority":        args.Priority,
+	}, nil
+}
+
+func (t *AIToolSession) queryReport(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		Type      string `json:"type"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	start, err := time.ParseInLocation("2006-01-02", args.StartDate, t.today.Location())
+	if err != nil {
+		return nil, errors.New("start_date harus berformat YYYY-MM-DD")
+	}
+	end, err := time.ParseInLocation("2006-01-02", args.EndDate, t.today.Location())
+	if err != nil {
+		return nil, errors.New("end_date harus berformat YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return nil, errors.New("end_date tidak boleh sebelum start_date")
+	}
+	var filterType *string
+	switch args.Type {
+	case "", "all":
+	case "expense", "income":
+		filterType = &args.Type
+	default:
+		return nil, fmt.Errorf("type harus 'all', 'expense' atau 'income', bukan '%s'", args.Type)
+	}
+
+	breakdown, err := t.chatbot.transactionSvc.GetReport(t.userID, args.StartDate, args.EndDate+" 23:59:59", nil, filterType)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil laporan: %w", err)
+	}
+
+	totals := map[string]float64{"expense": 0, "income": 0}
+	categories := make([]map[string]interface{}, 0, len(breakdown))
+	for _, b := range breakdown {
+		totals[b.Type] += b.TotalAmount
+		categories = append(categories, map[string]interface{}{
+			"category":       b.CategoryName,
+			"type":           b.Type,
+			"total":          b.TotalAmount,
+			"is_over_budget": b.IsOverBudget,
+		})
+	}
+	return map[string]interface{}{
+		"start_date":    args.StartDate,
+		"end_date":      args.EndDate,
+		"total_expense": totals["expense"],
+		"total_income":  totals["income"],
+		"categories":    categories,
+	}, nil
+}
+
+// matchByName mengembalikan indeks nama yang sama persis (case-insensitive), atau yang saling
+// mengandung jika tidak ada yang persis; -1 jika tidak ada.
+func matchByName(names []string, name string) int {
+	nameLower := strings.ToLower(strings.TrimSpace(name))
+	if nameLower == "" {
+		return -1
+	}
+	for i, n := range names {
+		if strings.ToLower(n) == nameLower {
+			return i
+		}
+	}
+	for i, n := range names {
+		nLower := strings.ToLower(n)
+		if strings.Contains(nLower, nameLower) || strings.Contains(nameLower, nLower) {
+			return i
+		}
+	}
+	return -1
+}
+
+func listOrDash(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ", ")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
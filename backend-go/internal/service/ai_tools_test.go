@@ -0,0 +1,257 @@
+package service_test
+
+import (
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/repository"
+	"cuan-backend/internal/service"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func toolCall(name, args string) aiprovider.ToolCall {
+	return aiprovider.ToolCall{ID: "call_0", Name: name, Arguments: json.RawMessage(args)}
+}
+
+func TestAIToolSession_CreateTransactionAndFinishRecordsBatch(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+	session := chatbot.NewToolSession(1, "", false)
+
+	result := session.Execute(toolCall("create_transaction", `{"type":"expense","amount":15000,"description":"Kopi","category_name":"Makan"}`))
+	assert.Contains(t, result, `"ok":true`)
+	assert.Equal(t, 65000.0, walletBalance(t, db))
+
+	saved := session.Finish()
+	require.Len(t, saved, 1)
+	assert.True(t, session.Committed())
+	assert.Equal(t, "create", saved[0].Action)
+	assert.Equal(t, "Tunai", saved[0].WalletName)
+
+	var batches []entity.AIBatch
+	require.NoError(t, db.Preload("Items").Find(&batches).Error)
+	require.Len(t, batches, 1)
+	assert.Len(t, batches[0].Items, 1)
+}
+
+func TestAIToolSession_RejectsInvalidArguments(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+	session := chatbot.NewToolSession(1, "", false)
+
+	cases := map[string]aiprovider.ToolCall{
+		"amount harus lebih dari 0": toolCall("create_transaction", `{"type":"expense","amount":0,"description":"Kopi"}`),
+		"type harus":                toolCall("create_transaction", `{"type":"transfer","amount":1000,"description":"Kopi"}`),
+		"unknown field":             toolCall("create_transaction", `{"type":"expense","amount":1000,"description":"Kopi","qty":2}`),
+		"id transaksi wajib diisi":  toolCall("delete_transaction", `{}`),
+		"tidak dikenal":             toolCall("transfer_money", `{}`),
+		"YYYY-MM-DD":                toolCall("query_report", `{"start_date":"kemarin","end_date":"2026-01-31"}`),
+		"fitur utang belum":         toolCall("pay_debt", `{"debt_name":"Budi","amount":1000}`),
+	}
+	for want, call := range cases {
+		result := session.Execute(call)
+		assert.Contains(t, result, `"ok":false`, call.Name)
+		assert.Contains(t, result, want, call.Name)
+	}
+
+	assert.Empty(t, session.Finish())
+	assert.False(t, session.Committed())
+	assert.Equal(t, 80000.0, walletBalance(t, db))
+}
+
+func TestAIToolSession_ResolvesDateFromMessage(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	today := time.Now().In(wib)
+	yesterday := today.AddDate(0, 0, -1).Format("2006-01-02")
+
+	// Satu tanggal di pesan menimpa usulan model.
+	session := chatbot.NewToolSession(1, "kemarin makan bakso 20rb", false)
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":20000,"description":"Bakso","date":"2020-01-01"}`)), `"ok":true`)
+	saved := session.Finish()
+	require.Len(t, saved, 1)
+	assert.Equal(t, yesterday, saved[0].Date)
+
+	var tx entity.Transaction
+	require.NoError(t, db.First(&tx, saved[0].ID).Error)
+	assert.Equal(t, yesterday, tx.Date.In(wib).Format("2006-01-02"))
+
+	// Beberapa tanggal: usulan model harus salah satunya.
+	session = chatbot.NewToolSession(1, "kemarin kopi 10rb, hari ini roti 5rb", false)
+	result := session.Execute(toolCall("create_transaction", `{"type":"expense","amount":10000,"description":"Kopi","date":"2020-01-01"}`))
+	assert.Contains(t, result, "beberapa tanggal")
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":10000,"description":"Kopi","date":"`+yesterday+`"}`)), `"ok":true`)
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":5000,"description":"Roti"}`)), "beberapa tanggal")
+
+	// Tanpa tanggal di pesan teks, usulan model diabaikan.
+	session = chatbot.NewToolSession(1, "beli pulsa 50rb", false)
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":50000,"description":"Pulsa","date":"`+yesterday+`"}`)), `"ok":true`)
+	assert.Contains(t, session.Execute(toolCall("create_transaction", `{"type":"expense","amount":1000,"description":"Parkir","date":"kemarin"}`)), "YYYY-MM-DD")
+	saved = session.Finish()
+	require.Len(t, saved, 1)
+	assert.Equal(t, today.Format("2006-01-02"), saved[0].Date)
+}
+
+func TestAIToolSession_CaptureReceipt(t *testing.T) {
+	db, chatbot := setupAIBatchTest(t)
+	require.NoError(t, db.Create(&entity.Category{ID: 2, UserID: 1, Name: "Belanja", Type: "expense"}).Error)
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	yesterday := time.Now().In(wib).AddDate(0, 0, -1).Format("2006-01-02")
+
+	// Hasil pipeline yang sudah direkonsiliasi.
+	receipt := &entity.ReceiptExtraction{IsReceipt: true, Merchant: "Kopi Kenangan", Date: yesterday, Total: 53900, Tax: 4900, Discount: 5000,
+		Items:      []entity.ReceiptItem{{Name: "Kopi Susu", Quantity: 2, UnitPrice: 18000, Total: 36000, Category: "Makan"}, {Name: "Roti", Quantity: 1, Total: 18000}},
+		ItemsTotal: 54000, Reconciled: true, Confidence: 1}
+
+	session := chatbot.NewToolSession(1, "", true).AttachImage("uploads/chat/1/struk.jpg")
+	reply, ok := session.CaptureReceipt(receipt, "")
+	require.True(t, ok)
+	assert.Contains(t, reply, "Struk Kopi Kenangan")
+	assert.Contains(t, reply, "Keyakinan: 100%")
+
+	saved := session.Finish()
+	require.Len(t, saved, 2)
+	assert.Equal(t, 53900.0, saved[0].Amount+saved[1].Amount)
+	assert.Equal(t, yesterday, saved[0].Date)
+	assert.Equal(t, float64(80000-53900), walletBalance(t, db))
+
+	var txs []entity.Transaction
+	require.NoError(t, db.Where("id IN ?", []uint{saved[0].ID, saved[1].ID}).Find(&txs).Error)
+	for _, tx := range txs {
+		assert.Equal(t, "uploads/chat/1/struk.jpg", tx.Attachment)
+	}
+}
+
+func setupAIToolsTest(t *testing.T) (*gorm.DB, *service.AIToolSession) {
+	t.Helper()
+	db, _ := setupAIBatchTest(t)
+	require.NoError(t, db.AutoMigrate(&entity.Debt{}, &entity.DebtPayment{}, &entity.WishlistItem{}))
+	require.NoError(t, db.Create(&entity.Wallet{ID: 2, UserID: 1, Name: "GoPay", Balance: 0}).Error)
+	// Dibuat di luar transaksi transfer; sqlite in-memory mengunci tabel selama transaksi berjalan.
+	require.NoError(t, db.Create(&entity.Category{ID: 2, UserID: 1, Name: "Biaya Admin", Type: "expense"}).Error)
+
+	walletRepo := repository.NewWalletRepository(db)
+	txRepo := repository.NewTransactionRepository(db)
+	debtRepo := repository.NewDebtRepository(db)
+	chatbot := service.NewChatbotService(
+		walletRepo, repository.NewCategoryRepository(db), service.NewTransactionService(txRepo, walletRepo, db),
+		txRepo, debtRepo, nil, nil, nil, nil,
+		nil, service.NewDebtService(debtRepo, txRepo, walletRepo, db), nil, service.NewWishlistService(repository.NewWishlistRepository(db)), nil,
+	)
+	return db, chatbot.NewToolSession(1, "", false)
+}
+
+func TestAIToolSession_DebtTransferWishlistConfirmations(t *testing.T) {
+	db, session := setupAIToolsTest(t)
+
+	assert.Contains(t, session.Execute(toolCall("create_debt", `{"type":"receivable","name":"Andi","amount":30000}`)), `"ok":true`)
+	assert.Contains(t, session.Execute(toolCall("pay_debt", `{"debt_name":"andi","amount":10000}`)), `"ok":true`)
+	assert.Contains(t, session.Execute(toolCall("transfer_between_wallets", `{"from_wallet":"tunai","to_wallet":"gopay","amount":20000,"fee":1000}`)), `"ok":true`)
+	assert.Contains(t, session.Execute(toolCall("add_wishlist_item", `{"name":"Sepatu","estimated_price":800000,"category_name":"Makan"}`)), `"ok":true`)
+
+	summary := session.Summary()
+	assert.Contains(t, summary, "🧾 Piutang dari Andi dicatat — Rp30.000 (Tunai)")
+	assert.Contains(t, summary, "💰 Terima piutang Andi — Rp10.000 (Tunai), sisa Rp20.000")
+	assert.Contains(t, summary, "🔁 Transfer Rp20.000 dari Tunai ke GoPay (biaya Rp1.000)")
+	assert.Contains(t, summary, "⭐ Wishlist: Sepatu — Rp800.000 (Makan, prioritas medium)")
+
+	var gopay entity.Wallet
+	require.NoError(t, db.First(&gopay, 2).Error)
+	assert.Equal(t, 20000.0, gopay.Balance)
+}
+
+func TestAIToolSession_CreateDebtDueDateIsWIBMidnight(t *testing.T) {
+	db, session := setupAIToolsTest(t)
+
+	assert.Contains(t, session.Execute(toolCall("create_debt", `{"type":"debt","name":"Budi","amount":50000,"due_date":"2026-10-20"}`)), `"ok":true`)
+
+	var debt entity.Debt
+	require.NoError(t, db.Where("name = ?", "Budi").First(&debt).Error)
+	require.NotNil(t, debt.DueDate)
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	assert.True(t, time.Date(2026, 10, 20, 0, 0, 0, 0, wib).Equal(*debt.DueDate), "due date %s", debt.DueDate)
+}
+
+func TestAIToolSession_TransferRequiresKnownWallets(t *testing.T) {
+	_, session := setupAIToolsTest(t)
+
+	result := session.Execute(toolCall("transfer_between_wallets", `{"from_wallet":"Tunai","to_wallet":"Jenius","amount":1000}`))
+	assert.Contains(t, result, "dompet tujuan 'Jenius' tidak ditemukan")
+	assert.Contains(t, result, "Tunai, GoPay")
+	assert.Empty(t, session.Summary())
+}
+
+func TestAIToolSession_QueryTransactions(t *testing.T) {
+	db, session := setupAIToolsTest(t)
+	day := func(d string) time.Time {
+		parsed, _ := time.Parse("2006-01-02 15:04", d+" 05:00")
+		return parsed
+	}
+	related := uint(99)
+	require.NoError(t, db.Create([]entity.Transaction{
+		{UserID: 1, WalletID: 1, CategoryID: 1, Amount: 20000, Type: "expense", Description: "Nasi", Date: day("2025-01-05")},
+		{UserID: 1, WalletID: 2, CategoryID: 1, Amount: 40000, Type: "expense", Description: "Sate", Date: day("2025-01-20")},
+		{UserID: 1, WalletID: 2, CategoryID: 2, Amount: 1000, Type: "expense", Description: "Admin", Date: day("2025-02-03")},
+		{UserID: 1, WalletID: 1, CategoryID: 1, Amount: 30000, Type: "expense", Description: "Bakmi", Date: day("2025-02-10")},
+		{UserID: 1, WalletID: 1, CategoryID: 1, Amount: 500000, Type: "expense", Description: "Transfer ke GoPay", Date: day("2025-02-11"), RelatedTransactionID: &related},
+		{UserID: 1, WalletID: 1, CategoryID: 1, Amount: 100000, Type: "income", Description: "Bonus", Date: day("2025-02-12")},
+	}).Error)
+
+	var out struct {
+		OK     bool   `json:"ok"`
+		Error  string `json:"error"`
+		Result struct {
+			Sum       float64 `json:"sum"`
+			Count     int     `json:"count"`
+			Avg       float64 `json:"avg"`
+			Truncated bool    `json:"truncated"`
+			Groups    []struct {
+				Group string  `json:"group"`
+				Sum   float64 `json:"sum"`
+				Count int     `json:"count"`
+			} `json:"groups"`
+		} `json:"result"`
+	}
+
+	// Transfer dan pemasukan tidak ikut; grup bulan urut kronologis.
+	require.NoError(t, json.Unmarshal([]byte(session.Execute(toolCall("query_transactions",
+		`{"start_date":"2025-01-01","end_date":"2025-02-28","categories":["makan"],"group_by":"month"}`))), &out))
+	require.True(t, out.OK, out.Error)
+	assert.Equal(t, 90000.0, out.Result.Sum)
+	assert.Equal(t, 3, out.Result.Count)
+	assert.Equal(t, 30000.0, out.Result.Avg)
+	assert.False(t, out.Result.Truncated)
+	require.Len(t, out.Result.Groups, 2)
+	assert.Equal(t, "2025-01", out.Result.Groups[0].Group)
+	assert.Equal(t, 60000.0, out.Result.Groups[0].Sum)
+	assert.Equal(t, "2025-02", out.Result.Groups[1].Group)
+	assert.Equal(t, 30000.0, out.Result.Groups[1].Sum)
+
+	// Filter dompet, grup kategori dari nominal terbesar, hanya metrik yang diminta.
+	out.Result.Groups = nil
+	require.NoError(t, json.Unmarshal([]byte(session.Execute(toolCall("query_transactions",
+		`{"start_date":"2025-01-01","end_date":"2025-02-28","wallets":["gopay"],"group_by":"category","metrics":["sum","count"]}`))), &out))
+	require.True(t, out.OK, out.Error)
+	require.Len(t, out.Result.Groups, 2)
+	assert.Equal(t, "Makan", out.Result.Groups[0].Group)
+	assert.Equal(t, 1, out.Result.Groups[0].Count)
+	assert.Equal(t, "Biaya Admin", out.Result.Groups[1].Group)
+	assert.Equal(t, 41000.0, out.Result.Sum)
+
+	tests := []struct {
+		args string
+		want string
+	}{
+		{`{"start_date":"2025-01-01","end_date":"2025-01-31","wallets":["Jenius"]}`, "dompet 'Jenius' tidak ditemukan; dompet user: Tunai, GoPay"},
+		{`{"start_date":"2025-01-01","end_date":"2025-01-31","group_by":"week"}`, "group_by harus salah satu dari"},
+		{`{"start_date":"2025-01-01","end_date":"2025-01-31","metrics":["median"]}`, "metrics hanya boleh berisi sum, count, avg"},
+		{`{"start_date":"2024-01-01","end_date":"2025-06-30"}`, "rentang tanggal maksimal 366 hari"},
+		{`{"start_date":"2025-01-01","end_date":"2025-01-31","sql":"DROP TABLE transactions"}`, "argumen tidak valid"},
+	}
+	for _, tt := range tests {
+		assert.Contains(t, session.Execute(toolCall("query_transactions", tt.args)), tt.want)
+	}
+}
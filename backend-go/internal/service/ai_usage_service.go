@@ -0,0 +1,68 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AIUsageMaxDays membatasi rentang ringkasan pemakaian AI sekali request.
+const AIUsageMaxDays = 92
+
+var ErrInvalidUsageRange = errors.New("rentang tanggal tidak valid (format YYYY-MM-DD, maksimal 92 hari)")
+
+// AIUsageRecorder menyimpan catatan pemakaian setiap request AI.
+type AIUsageRecorder interface {
+	Record(usage entity.AIUsage)
+}
+
+type AIUsageService interface {
+	AIUsageRecorder
+	// DailySummary meringkas pemakaian per user per hari (WIB). from/to kosong berarti 7 hari
+	// terakhir; userID 0 berarti semua user.
+	DailySummary(from, to string, userID uint) ([]entity.AIUsageDaily, error)
+}
+
+type aiUsageService struct {
+	repo repository.AIUsageRepository
+	now  func() time.Time
+}
+
+func NewAIUsageService(repo repository.AIUsageRepository) AIUsageService {
+	return &aiUsageService{repo: repo, now: time.Now}
+}
+
+// Record tidak mengembalikan error: gagal mencatat pemakaian tidak boleh menggagalkan chat.
+func (s *aiUsageService) Record(usage entity.AIUsage) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = s.now()
+	}
+	usage.Day = usage.CreatedAt.In(wib).Format("2006-01-02")
+	if err := s.repo.Create(&usage); err != nil {
+		log.Warn().Err(err).Uint("user_id", usage.UserID).Str("task", usage.Task).Msg("Gagal mencatat pemakaian AI")
+	}
+}
+
+func (s *aiUsageService) DailySummary(from, to string, userID uint) ([]entity.AIUsageDaily, error) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	today := s.now().In(wib)
+	if to == "" {
+		to = today.Format("2006-01-02")
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, wib)
+	if err != nil {
+		return nil, ErrInvalidUsageRange
+	}
+	if from == "" {
+		from = end.AddDate(0, 0, -6).Format("2006-01-02")
+	}
+	start, err := time.ParseInLocation("2006-01-02", from, wib)
+	if err != nil || start.After(end) || end.Sub(start) >= AIUsageMaxDays*24*time.Hour {
+		return nil, ErrInvalidUsageRange
+	}
+	return s.repo.DailySummary(from, to, userID)
+}
@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"cuan-backend/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAIUsageService_DailySummary(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}, &entity.AIUsage{}))
+	require.NoError(t, db.Create(&entity.User{ID: 1, Name: "Budi", Email: "budi@test.com"}).Error)
+
+	svc := service.NewAIUsageService(repository.NewAIUsageRepository(db))
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	// 17:30 UTC tanggal 17 sudah tanggal 18 di WIB.
+	late := time.Date(2026, 10, 17, 17, 30, 0, 0, time.UTC)
+	svc.Record(entity.AIUsage{UserID: 1, Task: "chat", Channel: entity.AIChannelWeb, Status: entity.AIUsageOK, PromptTokens: 100, CompletionTokens: 20, LatencyMs: 1000, HasImage: true, CreatedAt: late})
+	svc.Record(entity.AIUsage{UserID: 1, Task: "chat", Channel: entity.AIChannelWhatsApp, Status: entity.AIUsageOK, PromptTokens: 50, CompletionTokens: 10, LatencyMs: 3000, HasVoice: true, CreatedAt: late})
+	svc.Record(entity.AIUsage{UserID: 1, Task: "chat", Channel: entity.AIChannelWhatsApp, Status: entity.AIUsageRateLimited, CreatedAt: late})
+	svc.Record(entity.AIUsage{UserID: 2, Task: "summarize", Channel: entity.AIChannelBackground, Status: entity.AIUsageError, LatencyMs: 500, CreatedAt: time.Date(2026, 10, 16, 9, 0, 0, 0, wib)})
+
+	usage, err := svc.DailySummary("2026-10-12", "2026-10-18", 0)
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	assert.Equal(t, entity.AIUsageDaily{
+		Day: "2026-10-18", UserID: 1, UserName: "Budi", Requests: 3, PromptTokens: 150, CompletionTokens: 30,
+		AvgLatencyMs: 2000, ImageRequests: 1, VoiceRequests: 1, RateLimited: 1,
+	}, usage[0])
+	assert.Equal(t, "2026-10-16", usage[1].Day)
+	assert.Equal(t, int64(1), usage[1].Errors)
+	assert.Equal(t, 0.0, usage[1].AvgLatencyMs)
+
+	usage, err = svc.DailySummary("2026-10-12", "2026-10-18", 2)
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+
+	_, err = svc.DailySummary("2026-10-18", "2026-10-12", 0)
+	assert.ErrorIs(t, err, service.ErrInvalidUsageRange)
+	_, err = svc.DailySummary("2026-01-01", "2026-10-18", 0)
+	assert.ErrorIs(t, err, service.ErrInvalidUsageRange)
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/provider/storage"
+	"cuan-backend/internal/repository"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/image/draw"
+)
+
+const (
+	AttachmentURLPrefix = "/api/files/"
+	LegacyUploadPrefix  = "/uploads/"
+
+	attachmentMaxImageDim = 1600
+	attachmentJPEGQuality = 75
+
+	// Grace period so files uploaded moments ago (not yet linked to a row) survive GC. Kept well
+	// beyond DraftTTL so a receipt image stays available for as long as its draft can be confirmed.
+	attachmentGCGrace = 2 * DraftTTL
+)
+
+type StoredFile struct {
+	Key         string
+	ContentType string
+	Data        []byte
+}
+
+type AttachmentService interface {
+	SaveUpload(userID uint, fileHeader *multipart.FileHeader) (*StoredFile, error)
+	SaveBytes(userID uint, filename, contentType string, data []byte) (string, error)
+	Open(key string) (io.ReadCloser, *storage.ObjectInfo, error)
+	PresignedURL(key string) (string, bool)
+	SignedURL(userID uint, ref string) string
+	Verify(key, uid, exp, sig string) (uint, error)
+	IsOwnedBy(userID uint, key string) (bool, error)
+	SignTransactions(userID uint, transactions []entity.Transaction)
+	SignChatMessages(userID uint, messages []entity.ChatMessage)
+	Release(ref string) error
+	CollectGarbage() (int, error)
+}
+
+type attachmentService struct {
+	store  storage.BlobStore
+	signer *storage.URLSigner
+	repo   repository.AttachmentRepository
+	now    func() time.Time
+}
+
+func NewAttachmentService(store storage.BlobStore, signer *storage.URLSigner, repo repository.AttachmentRepository) AttachmentService {
+	return &attachmentService{store: store, signer: signer, repo: repo, now: time.Now}
+}
+
+// AttachmentKey converts any stored reference (blob key, legacy "/uploads/..." path
+// or an already signed "/api/files/..." URL) back to the blob key.
+func AttachmentKey(ref string) string {
+	if i := strings.Index(ref, "?"); i >= 0 {
+		ref = ref[:i]
+	}
+	ref = strings.TrimPrefix(ref, AttachmentURLPrefix)
+	ref = strings.TrimPrefix(ref, LegacyUploadPrefix)
+	return strings.TrimPrefix(ref, "/")
+}
+
+func attachmentFolder(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "images"
+	case strings.HasPrefix(contentType, "audio/"):
+		return "audio"
+	default:
+		return "files"
+	}
+}
+
+func (s *attachmentService) newKey(userID uint, contentType, filename string) string {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filepath.Base(filename), ext)
+	if strings.HasPrefix(contentType, "image/") {
+		ext = ".jpg"
+	}
+	return fmt.Sprintf("%s/%d/%d_%s%s", attachmentFolder(contentType), userID, s.now().UnixNano(), base, ext)
+}
+
+func (s *attachmentService) SaveUpload(userID uint, fileHeader *multipart.FileHeader) (*StoredFile, error) {
+	contentType := fileHeader.Header.Get("Content-Type")
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var data []byte
+	if strings.HasPrefix(contentType, "image/") {
+		data, err = compressImage(src)
+		contentType = "image/jpeg"
+	} else {
+		data, err = io.ReadAll(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.newKey(userID, contentType, fileHeader.Filename)
+	if err := s.store.Put(context.Background(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, fmt.Errorf("gagal menyimpan file: %w", err)
+	}
+
+	return &StoredFile{Key: key, ContentType: contentType, Data: data}, nil
+}
+
+func (s *attachmentService) SaveBytes(userID uint, filename, contentType string, data []byte) (string, error) {
+	key := s.newKey(userID, contentType, filename)
+	if err := s.store.Put(context.Background(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return "", fmt.Errorf("gagal menyimpan file: %w", err)
+	}
+	return key, nil
+}
+
+func (s *attachmentService) Open(key string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	return s.store.Get(context.Background(), key)
+}
+
+// PresignedURL returns a direct download URL when the backing store supports it.
+func (s *attachmentService) PresignedURL(key string) (string, bool) {
+	presigner, ok := s.store.(storage.Presigner)
+	if !ok {
+		return "", false
+	}
+	url, err := presigner.PresignGet(key, s.signer.TTL())
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Gagal membuat presigned URL lampiran")
+		return "", false
+	}
+	return url, true
+}
+
+func (s *attachmentService) SignedURL(userID uint, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	key := AttachmentKey(ref)
+	return AttachmentURLPrefix + key + "?" + s.signer.Sign(key, userID)
+}
+
+func (s *attachmentService) Verify(key, uid, exp, sig string) (uint, error) {
+	return s.signer.Verify(key, uid, exp, sig)
+}
+
+// IsOwnedBy checks the key against the user's transactions and chat messages,
+// accepting both the blob key and the legacy "/uploads/..." form.
+func (s *attachmentService) IsOwnedBy(userID uint, key string) (bool, error) {
+	return s.repo.IsOwnedBy(userID, []string{key, LegacyUploadPrefix + key})
+}
+
+func (s *attachmentService) SignTransactions(userID uint, transactions []entity.Transaction) {
+	for i := range transactions {
+		transactions[i].Attachment = s.SignedURL(userID, transactions[i].Attachment)
+	}
+}
+
+func (s *attachmentService) SignChatMessages(userID uint, messages []entity.ChatMessage) {
+	for i := range messages {
+		messages[i].AudioURL = s.SignedURL(userID, messages[i].AudioURL)
+		messages[i].ImageURL = s.SignedURL(userID, messages[i].ImageURL)
+	}
+}
+
+// Release deletes the blob if no transaction or chat message references it anymore.
+func (s *attachmentService) Release(ref string) error {
+	if ref == "" {
+		return nil
+	}
+	key := AttachmentKey(ref)
+
+	referenced, err := s.repo.IsReferenced([]string{key, LegacyUploadPrefix + key})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return nil
+	}
+	return s.store.Delete(context.Background(), key)
+}
+
+// CollectGarbage removes blobs that are no longer referenced by any row.
+func (s *attachmentService) CollectGarbage() (int, error) {
+	refs, err := s.repo.ReferencedKeys()
+	if err != nil {
+		return 0, err
+	}
+	referenced := make(map[string]struct{}, len(refs))
+	for _, ref := range refs {
+		referenced[AttachmentKey(ref)] = struct{}{}
+	}
+
+	objects, err := s.store.List(context.Background(), "")
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := s.now().Add(-attachmentGCGrace)
+	removed := 0
+	for _, obj := range objects {
+		if _, ok := referenced[obj.Key]; ok || obj.ModTime.After(cutoff) {
+			continue
+		}
+		if err := s.store.Delete(context.Background(), obj.Key); err != nil {
+			log.Warn().Err(err).Str("key", obj.Key).Msg("Gagal menghapus lampiran yatim")
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func compressImage(r io.Reader) ([]byte, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca gambar: %v", err)
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	var finalImg image.Image = img
+
+	if width > attachmentMaxImageDim || height > attachmentMaxImageDim {
+		var newW, newH int
+		if width > height {
+			newW = attachmentMaxImageDim
+			newH = (height * attachmentMaxImageDim) / width
+		} else {
+			newH = attachmentMaxImageDim
+			newW = (width * attachmentMaxImageDim) / height
+		}
+
+		dst := image.NewRGBA(image.Rect(0, 0, newW, newH))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+		finalImg = dst
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, finalImg, &jpeg.Options{Quality: attachmentJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"cuan-backend/internal/provider/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubAttachmentRepository struct {
+	refs []string
+}
+
+func (r *stubAttachmentRepository) IsReferenced(refs []string) (bool, error) {
+	for _, ref := range refs {
+		for _, existing := range r.refs {
+			if ref == existing {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *stubAttachmentRepository) IsOwnedBy(_ uint, refs []string) (bool, error) {
+	return r.IsReferenced(refs)
+}
+
+func (r *stubAttachmentRepository) ReferencedKeys() ([]string, error) {
+	return r.refs, nil
+}
+
+func TestAttachmentKey(t *testing.T) {
+	assert.Equal(t, "images/1/a.jpg", AttachmentKey("images/1/a.jpg"))
+	assert.Equal(t, "images/a.jpg", AttachmentKey("/uploads/images/a.jpg"))
+	assert.Equal(t, "images/1/a.jpg", AttachmentKey("/api/files/images/1/a.jpg?uid=1&exp=1&sig=x"))
+}
+
+func TestAttachmentService_ReleaseAndCollectGarbage(t *testing.T) {
+	store := storage.NewLocalStore(t.TempDir())
+	repo := &stubAttachmentRepository{}
+	svc := NewAttachmentService(store, storage.NewURLSigner("secret", time.Hour), repo).(*attachmentService)
+
+	kept, err := svc.SaveBytes(1, "kept.txt", "text/plain", []byte("a"))
+	require.NoError(t, err)
+	released, err := svc.SaveBytes(1, "released.txt", "text/plain", []byte("b"))
+	require.NoError(t, err)
+	orphan, err := svc.SaveBytes(1, "orphan.txt", "text/plain", []byte("c"))
+	require.NoError(t, err)
+
+	// Legacy "/uploads/..." references still protect their blob
+	repo.refs = []string{LegacyUploadPrefix + kept}
+
+	require.NoError(t, svc.Release(kept))
+	require.NoError(t, svc.Release(released))
+
+	_, _, err = store.Get(context.Background(), kept)
+	assert.NoError(t, err)
+	_, _, err = store.Get(context.Background(), released)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Fresh uploads are inside the grace period and must survive
+	removed, err := svc.CollectGarbage()
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	svc.now = func() time.Time { return time.Now().Add(attachmentGCGrace + time.Hour) }
+	removed, err = svc.CollectGarbage()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, _, err = store.Get(context.Background(), orphan)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, _, err = store.Get(context.Background(), kept)
+	assert.NoError(t, err)
+}
+
+func TestAttachmentService_SignedURL(t *testing.T) {
+	svc := NewAttachmentService(storage.NewLocalStore(t.TempDir()), storage.NewURLSigner("secret", time.Hour), &stubAttachmentRepository{})
+
+	assert.Equal(t, "", svc.SignedURL(1, ""))
+
+	url := svc.SignedURL(7, "/uploads/audio/a.ogg")
+	require.True(t, strings.HasPrefix(url, "/api/files/audio/a.ogg?"))
+	// Signing an already signed URL is idempotent on the key
+	assert.True(t, strings.HasPrefix(svc.SignedURL(7, url), "/api/files/audio/a.ogg?"))
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+)
+
+const (
+	DefaultAuditPageLimit = 50
+	MaxAuditPageLimit     = 200
+)
+
+type AuditService interface {
+	GetEvents(userID uint, params entity.AuditFilterParams) ([]entity.AuditEvent, int64, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) GetEvents(userID uint, params entity.AuditFilterParams) ([]entity.AuditEvent, int64, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Limit <= 0 {
+		params.Limit = DefaultAuditPageLimit
+	}
+	if params.Limit > MaxAuditPageLimit {
+		params.Limit = MaxAuditPageLimit
+	}
+	return s.repo.FindAll(userID, params)
+}
@@ -1,27 +1,78 @@
 package service
 
 import (
+	"bytes"
 	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
 	"cuan-backend/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
-const DefaultChatHistoryLimit = 100
+const (
+	DefaultChatHistoryLimit = 100
+	MaxChatHistoryPageLimit = 200
+
+	ChatExportJSON     = "json"
+	ChatExportMarkdown = "md"
+)
+
+var (
+	ErrChatMessageNotFound = errors.New("pesan tidak ditemukan")
+	ErrInvalidExportFormat = errors.New("format export harus json atau md")
+)
 
 type ChatHistoryService interface {
-	SaveMessage(userID uint, role, content, audioURL, imageURL string) error
+	// SaveMessage menyimpan pesan; transcript diisi untuk pesan suara dan boleh nil.
+	SaveMessage(userID uint, role, content, audioURL, imageURL string, transcript *entity.VoiceTranscript) error
+	// SaveReply menyimpan balasan assistant beserta transaksi yang dihasilkannya. promptVersion
+	// kosong untuk balasan yang tidak dibuat LLM; audioURL adalah balasan suara (TTS), jika ada.
+	SaveReply(userID uint, reply string, saved []entity.SavedTransaction, promptVersion, audioURL string) error
 	GetHistory(userID uint, limit int) ([]entity.ChatMessage, error)
+	// GetPage mengembalikan satu halaman riwayat mulai dari cursor query.Before, opsional hanya
+	// pesan yang cocok dengan query.Search.
+	GetPage(userID uint, query entity.ChatHistoryQuery) (*entity.ChatHistoryPage, error)
+	// DeleteMessage menghapus satu pesan beserta audio/gambarnya.
+	DeleteMessage(userID uint, id uint) error
+	// ClearHistory menghapus semua pesan, ringkasan percakapan dan audio/gambarnya.
 	ClearHistory(userID uint) error
+	// Export mengembalikan seluruh riwayat chat sebagai JSON atau Markdown.
+	Export(userID uint, format string) ([]byte, error)
+	// PruneExpired menghapus pesan yang melewati ChatRetentionDays tiap user. Dijalankan scheduler.
+	PruneExpired(now time.Time)
+	// BuildConversation menyusun jendela percakapan untuk pesan saat ini. Giliran lama yang
+	// keluar dari jendela diringkas di background.
+	BuildConversation(userID uint, current string) Conversation
+}
+
+// ConversationSummarizer meringkas giliran chat lama menjadi ringkasan bergulir.
+type ConversationSummarizer interface {
+	Summarize(userID uint, previous string, turns []aiprovider.Message) (string, error)
 }
 
 type chatHistoryService struct {
-	repo repository.ChatRepository
+	repo        repository.ChatRepository
+	summarizer  ConversationSummarizer
+	attachments AttachmentService
+	userRepo    repository.UserRepository
+	// summarizing mencegah dua ringkasan berjalan bersamaan untuk user yang sama.
+	summarizing sync.Map
 }
 
-func NewChatHistoryService(repo repository.ChatRepository) ChatHistoryService {
-	return &chatHistoryService{repo: repo}
+// NewChatHistoryService: attachments dan userRepo boleh nil; tanpa attachments file media pesan
+// yang dihapus dibersihkan oleh GC attachment, tanpa userRepo PruneExpired tidak melakukan apa-apa.
+func NewChatHistoryService(repo repository.ChatRepository, summarizer ConversationSummarizer, attachments AttachmentService, userRepo repository.UserRepository) ChatHistoryService {
+	return &chatHistoryService{repo: repo, summarizer: summarizer, attachments: attachments, userRepo: userRepo}
 }
 
-func (s *chatHistoryService) SaveMessage(userID uint, role, content, audioURL, imageURL string) error {
+func (s *chatHistoryService) SaveMessage(userID uint, role, content, audioURL, imageURL string, transcript *entity.VoiceTranscript) error {
 	msg := &entity.ChatMessage{
 		UserID:   userID,
 		Role:     role,
@@ -29,6 +80,27 @@ func (s *chatHistoryService) SaveMessage(userID uint, role, content, audioURL, i
 		AudioURL: audioURL,
 		ImageURL: imageURL,
 	}
+	if transcript != nil {
+		if data, err := json.Marshal(transcript); err == nil {
+			msg.Transcript = entity.JSONText(data)
+		}
+	}
+	return s.repo.Save(msg)
+}
+
+func (s *chatHistoryService) SaveReply(userID uint, reply string, saved []entity.SavedTransaction, promptVersion, audioURL string) error {
+	msg := &entity.ChatMessage{
+		UserID:        userID,
+		Role:          "assistant",
+		Content:       reply,
+		AudioURL:      audioURL,
+		PromptVersion: promptVersion,
+	}
+	if len(saved) > 0 {
+		if data, err := json.Marshal(saved); err == nil {
+			msg.Transactions = entity.JSONText(data)
+		}
+	}
 	return s.repo.Save(msg)
 }
 
@@ -39,6 +111,214 @@ func (s *chatHistoryService) GetHistory(userID uint, limit int) ([]entity.ChatMe
 	return s.repo.FindByUserID(userID, limit)
 }
 
+func (s *chatHistoryService) GetPage(userID uint, query entity.ChatHistoryQuery) (*entity.ChatHistoryPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultChatHistoryLimit
+	}
+	limit = min(limit, MaxChatHistoryPageLimit)
+
+	// Satu pesan ekstra menandai masih ada halaman berikutnya.
+	messages, err := s.repo.FindPage(userID, query.Before, limit+1, strings.TrimSpace(query.Search))
+	if err != nil {
+		return nil, err
+	}
+	page := &entity.ChatHistoryPage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.HasMore = true
+		page.NextBefore = page.Messages[limit-1].ID
+	}
+	for i, j := 0, len(page.Messages)-1; i < j; i, j = i+1, j-1 {
+		page.Messages[i], page.Messages[j] = page.Messages[j], page.Messages[i]
+	}
+	return page, nil
+}
+
+func (s *chatHistoryService) DeleteMessage(userID uint, id uint) error {
+	msg, err := s.repo.DeleteMessage(id, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrChatMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.forgetSummary(userID, msg.ID)
+	s.releaseMedia([]entity.ChatMessage{*msg})
+	return nil
+}
+
 func (s *chatHistoryService) ClearHistory(userID uint) error {
-	return s.repo.DeleteByUserID(userID)
+	messages, err := s.repo.FindByUserID(userID, -1)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+	s.releaseMedia(messages)
+	return nil
+}
+
+func (s *chatHistoryService) Export(userID uint, format string) ([]byte, error) {
+	if format != ChatExportJSON && format != ChatExportMarkdown {
+		return nil, ErrInvalidExportFormat
+	}
+	messages, err := s.repo.FindByUserID(userID, -1)
+	if err != nil {
+		return nil, err
+	}
+	if s.attachments != nil {
+		s.attachments.SignChatMessages(userID, messages)
+	}
+	if format == ChatExportJSON {
+		return json.MarshalIndent(messages, "", "  ")
+	}
+	return FormatChatMarkdown(messages), nil
+}
+
+func (s *chatHistoryService) PruneExpired(now time.Time) {
+	if s.userRepo == nil {
+		return
+	}
+	users, err := s.userRepo.FindAll()
+	if err != nil {
+		log.Error().Err(err).Msg("Gagal memuat user untuk retensi chat")
+		return
+	}
+	for _, user := range users {
+		if user.ChatRetentionDays == nil || *user.ChatRetentionDays <= 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -*user.ChatRetentionDays)
+		pruned, err := s.repo.DeleteOlderThan(user.ID, cutoff)
+		if err != nil {
+			log.Error().Err(err).Uint("user_id", user.ID).Msg("Gagal menghapus pesan chat lama sesuai retensi")
+			continue
+		}
+		if len(pruned) == 0 {
+			continue
+		}
+		ids := make([]uint, len(pruned))
+		for i, m := range pruned {
+			ids[i] = m.ID
+		}
+		s.forgetSummary(user.ID, ids...)
+		s.releaseMedia(pruned)
+		log.Info().Uint("user_id", user.ID).Int("messages", len(pruned)).Msg("Pesan chat lama dihapus sesuai retensi")
+	}
+}
+
+// forgetSummary menghapus ringkasan bergulir jika salah satu pesan yang dihapus sudah masuk
+// ringkasan, agar isinya tidak tertinggal di sana. Ringkasan dibuat ulang dari pesan yang tersisa.
+func (s *chatHistoryService) forgetSummary(userID uint, deletedIDs ...uint) {
+	summary, err := s.repo.FindSummary(userID)
+	if err != nil {
+		return
+	}
+	for _, id := range deletedIDs {
+		if id <= summary.LastMessageID {
+			if err := s.repo.DeleteSummary(userID); err != nil {
+				log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal menghapus ringkasan percakapan")
+			}
+			return
+		}
+	}
+}
+
+// releaseMedia menghapus audio/gambar pesan yang sudah dihapus jika tidak dipakai baris lain
+// (misalnya struk yang juga menjadi lampiran transaksi).
+func (s *chatHistoryService) releaseMedia(messages []entity.ChatMessage) {
+	if s.attachments == nil {
+		return
+	}
+	for _, m := range messages {
+		for _, ref := range []string{m.AudioURL, m.ImageURL} {
+			if err := s.attachments.Release(ref); err != nil {
+				log.Warn().Err(err).Str("ref", ref).Msg("Gagal menghapus media chat")
+			}
+		}
+	}
+}
+
+// FormatChatMarkdown menulis riwayat chat (urut lama → baru) sebagai dokumen Markdown.
+func FormatChatMarkdown(messages []entity.ChatMessage) []byte {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	var b bytes.Buffer
+	b.WriteString("# Riwayat Chat\n\n")
+	fmt.Fprintf(&b, "_%d pesan_\n", len(messages))
+
+	for _, m := range messages {
+		who := "🧑 Kamu"
+		if m.Role == "assistant" {
+			who = "🤖 Asisten"
+		}
+		fmt.Fprintf(&b, "\n---\n\n**%s** · %s\n\n", who, m.CreatedAt.In(wib).Format("2006-01-02 15:04"))
+		b.WriteString(strings.TrimSpace(m.Content) + "\n")
+
+		if m.AudioURL != "" {
+			fmt.Fprintf(&b, "\n🎤 [Audio](%s)\n", m.AudioURL)
+		}
+		if m.ImageURL != "" {
+			fmt.Fprintf(&b, "\n![Gambar](%s)\n", m.ImageURL)
+		}
+		var saved []entity.SavedTransaction
+		if m.Transactions != "" && json.Unmarshal([]byte(m.Transactions), &saved) == nil && len(saved) > 0 {
+			b.WriteString("\nTransaksi:\n")
+			for _, t := range saved {
+				fmt.Fprintf(&b, "- %s — %s (%s, %s)\n", t.Description, formatRupiah(t.Amount), t.Type, t.WalletName)
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+func (s *chatHistoryService) BuildConversation(userID uint, current string) Conversation {
+	messages, err := s.repo.FindRecentByUserID(userID, conversationFetchLimit)
+	if err != nil {
+		return Conversation{}
+	}
+
+	summary, err := s.repo.FindSummary(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal memuat ringkasan percakapan")
+	}
+
+	conv, overflow := buildConversation(messages, current, summary, ConversationTokenBudget)
+	if len(overflow) >= summarizeThreshold && s.summarizer != nil {
+		go s.summarize(userID, summary, overflow)
+	}
+	return conv
+}
+
+// summarize menggabungkan giliran overflow ke ringkasan yang sudah ada.
+func (s *chatHistoryService) summarize(userID uint, previous *entity.ChatSummary, overflow []entity.ChatMessage) {
+	if _, busy := s.summarizing.LoadOrStore(userID, struct{}{}); busy {
+		return
+	}
+	defer s.summarizing.Delete(userID)
+
+	turns := make([]aiprovider.Message, 0, len(overflow))
+	for _, m := range overflow {
+		turns = append(turns, aiprovider.Message{Role: m.Role, Content: turnContent(m)})
+	}
+
+	summary := &entity.ChatSummary{UserID: userID}
+	if previous != nil {
+		summary = previous
+	}
+
+	content, err := s.summarizer.Summarize(userID, summary.Content, turns)
+	if err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal meringkas percakapan")
+		return
+	}
+
+	summary.Content = content
+	summary.LastMessageID = overflow[len(overflow)-1].ID
+	if err := s.repo.SaveSummary(summary); err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal menyimpan ringkasan percakapan")
+		return
+	}
+	log.Info().Uint("user_id", userID).Uint("last_message_id", summary.LastMessageID).Int("turns", len(turns)).Msg("Ringkasan percakapan diperbarui")
 }
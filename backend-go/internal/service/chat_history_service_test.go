@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"cuan-backend/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// releaseRecorder mencatat media yang dilepas tanpa menyentuh blob store.
+type releaseRecorder struct {
+	service.AttachmentService
+	released []string
+}
+
+func (r *releaseRecorder) Release(ref string) error {
+	if ref != "" {
+		r.released = append(r.released, ref)
+	}
+	return nil
+}
+
+func (r *releaseRecorder) SignChatMessages(_ uint, _ []entity.ChatMessage) {}
+
+func setupChatHistory(t *testing.T) (*gorm.DB, service.ChatHistoryService, *releaseRecorder) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}, &entity.ChatMessage{}, &entity.ChatSummary{}))
+
+	attachments := &releaseRecorder{}
+	svc := service.NewChatHistoryService(repository.NewChatRepository(db), nil, attachments, repository.NewUserRepository(db))
+	return db, svc, attachments
+}
+
+func TestChatHistoryService_GetPage(t *testing.T) {
+	db, svc, _ := setupChatHistory(t)
+	for _, content := range []string{"beli kopi 15rb", "Dicatat!", "gaji masuk 5jt", "Dicatat!", "kopi lagi 20rb"} {
+		require.NoError(t, db.Create(&entity.ChatMessage{UserID: 1, Role: "user", Content: content}).Error)
+	}
+	require.NoError(t, db.Create(&entity.ChatMessage{UserID: 2, Role: "user", Content: "kopi user lain"}).Error)
+
+	page, err := svc.GetPage(1, entity.ChatHistoryQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Messages, 2)
+	assert.Equal(t, "Dicatat!", page.Messages[0].Content)
+	assert.Equal(t, "kopi lagi 20rb", page.Messages[1].Content)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, page.Messages[0].ID, page.NextBefore)
+
+	page, err = svc.GetPage(1, entity.ChatHistoryQuery{Before: page.NextBefore, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page.Messages, 3)
+	assert.False(t, page.HasMore)
+	assert.Zero(t, page.NextBefore)
+
+	page, err = svc.GetPage(1, entity.ChatHistoryQuery{Search: " KOPI "})
+	require.NoError(t, err)
+	require.Len(t, page.Messages, 2)
+	assert.Equal(t, "beli kopi 15rb", page.Messages[0].Content)
+}
+
+func TestChatHistoryService_SearchTreatsWildcardsLiterally(t *testing.T) {
+	db, svc, _ := setupChatHistory(t)
+	for _, content := range []string{"diskon 50% sepatu", "diskon 500rb", "kode_promo hemat", "kode promo"} {
+		require.NoError(t, db.Create(&entity.ChatMessage{UserID: 1, Role: "user", Content: content}).Error)
+	}
+
+	page, err := svc.GetPage(1, entity.ChatHistoryQuery{Search: "50%"})
+	require.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, "diskon 50% sepatu", page.Messages[0].Content)
+
+	page, err = svc.GetPage(1, entity.ChatHistoryQuery{Search: "kode_promo"})
+	require.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, "kode_promo hemat", page.Messages[0].Content)
+}
+
+func TestChatHistoryService_DeleteMessage(t *testing.T) {
+	db, svc, attachments := setupChatHistory(t)
+	msg := entity.ChatMessage{UserID: 1, Role: "user", Content: "struk", ImageURL: "images/struk.jpg"}
+	require.NoError(t, db.Create(&msg).Error)
+	require.NoError(t, db.Create(&entity.ChatSummary{UserID: 1, Content: "- user kirim struk", LastMessageID: msg.ID}).Error)
+
+	assert.ErrorIs(t, svc.DeleteMessage(2, msg.ID), service.ErrChatMessageNotFound)
+	require.NoError(t, svc.DeleteMessage(1, msg.ID))
+
+	var count int64
+	db.Model(&entity.ChatMessage{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&entity.ChatSummary{}).Count(&count)
+	assert.Zero(t, count, "ringkasan yang memuat pesan terhapus ikut dihapus")
+	assert.Equal(t, []string{"images/struk.jpg"}, attachments.released)
+}
+
+func TestChatHistoryService_PruneExpired(t *testing.T) {
+	db, svc, attachments := setupChatHistory(t)
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	retention := 30
+	require.NoError(t, db.Create(&entity.User{ID: 1, Name: "Budi", Email: "budi@test.com", ChatRetentionDays: &retention}).Error)
+	require.NoError(t, db.Create(&entity.User{ID: 2, Name: "Sari", Email: "sari@test.com"}).Error)
+
+	old := now.AddDate(0, 0, -31)
+	require.NoError(t, db.Create(&entity.ChatMessage{UserID: 1, Role: "user", Content: "lama", AudioURL: "audio/lama.ogg", CreatedAt: old}).Error)
+	require.NoError(t, db.Create(&entity.ChatMessage{UserID: 1, Role: "user", Content: "baru", CreatedAt: now.AddDate(0, 0, -1)}).Error)
+	require.NoError(t, db.Create(&entity.ChatMessage{UserID: 2, Role: "user", Content: "lama user tanpa retensi", CreatedAt: old}).Error)
+
+	svc.PruneExpired(now)
+
+	var contents []string
+	db.Model(&entity.ChatMessage{}).Order("id").Pluck("content", &contents)
+	assert.Equal(t, []string{"baru", "lama user tanpa retensi"}, contents)
+	assert.Equal(t, []string{"audio/lama.ogg"}, attachments.released)
+}
+
+func TestChatHistoryService_Export(t *testing.T) {
+	db, svc, _ := setupChatHistory(t)
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	at := time.Date(2026, 10, 18, 9, 30, 0, 0, wib)
+	require.NoError(t, db.Create(&entity.ChatMessage{UserID: 1, Role: "user", Content: "beli nasi goreng 15rb", CreatedAt: at}).Error)
+	require.NoError(t, db.Create(&entity.ChatMessage{
+		UserID: 1, Role: "assistant", Content: "Dicatat! ✅", CreatedAt: at,
+		Transactions: `[{"id":45,"action":"create","description":"Nasi Goreng","amount":15000,"type":"expense","wallet_name":"BCA"}]`,
+	}).Error)
+
+	md, err := svc.Export(1, service.ChatExportMarkdown)
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "**🧑 Kamu** · 2026-10-18 09:30")
+	assert.Contains(t, string(md), "**🤖 Asisten**")
+	assert.Contains(t, string(md), "- Nasi Goreng — Rp15.000 (expense, BCA)")
+
+	data, err := svc.Export(1, service.ChatExportJSON)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"content": "beli nasi goreng 15rb"`)
+
+	_, err = svc.Export(1, "pdf")
+	assert.ErrorIs(t, err, service.ErrInvalidExportFormat)
+}
@@ -1,14 +1,17 @@
 package service
 
 import (
+	"context"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/repository"
 	pkgutils "cuan-backend/pkg/utils"
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/rs/zerolog/log"
 
@@ -41,6 +44,15 @@ type ChatbotService struct {
 	dashboardSvc    DashboardService
 	financialHealth FinancialHealthService
 	userRepo        repository.UserRepository
+	batchSvc        AIBatchService
+	debtSvc         DebtService
+	savingGoalSvc   SavingGoalService
+	wishlistSvc     WishlistService
+	draftSvc        AIDraftService
+	classifier      IntentClassifier
+	searchSvc       TransactionSearchService
+	prompts         *PromptTemplates
+	aliasRepo       repository.UserAliasRepository
 }
 
 func NewChatbotService(
@@ -53,6 +65,11 @@ func NewChatbotService(
 	dashboardSvc DashboardService,
 	financialHealth FinancialHealthService,
 	userRepo repository.UserRepository,
+	batchSvc AIBatchService,
+	debtSvc DebtService,
+	savingGoalSvc SavingGoalService,
+	wishlistSvc WishlistService,
+	draftSvc AIDraftService,
 ) *ChatbotService {
 	return &ChatbotService{
 		walletRepo:      walletRepo,
@@ -64,13 +81,103 @@ func NewChatbotService(
 		dashboardSvc:    dashboardSvc,
 		financialHealth: financialHealth,
 		userRepo:        userRepo,
+		batchSvc:        batchSvc,
+		debtSvc:         debtSvc,
+		savingGoalSvc:   savingGoalSvc,
+		wishlistSvc:     wishlistSvc,
+		draftSvc:        draftSvc,
+		classifier:      DefaultIntentClassifier(),
+		prompts:         DefaultPromptTemplates(),
 	}
 }
 
+// WithPromptTemplates returns a copy that renders the chat prompt from a different template set,
+// e.g. one pinned to an older version.
+func (s *ChatbotService) WithPromptTemplates(prompts *PromptTemplates) *ChatbotService {
+	clone := *s
+	clone.prompts = prompts
+	return &clone
+}
+
+// WithUserAliases returns a copy that lists the user's wallet and category aliases in the chat prompt.
+func (s *ChatbotService) WithUserAliases(aliasRepo repository.UserAliasRepository) *ChatbotService {
+	clone := *s
+	clone.aliasRepo = aliasRepo
+	return &clone
+}
+
+// WithTransactionSearch returns a copy that adds transactions similar to the question to the
+// LLM context.
+func (s *ChatbotService) WithTransactionSearch(searchSvc TransactionSearchService) *ChatbotService {
+	clone := *s
+	clone.searchSvc = searchSvc
+	return &clone
+}
+
+// WithIntentClassifier returns a copy that picks context with a different intent classifier.
+func (s *ChatbotService) WithIntentClassifier(classifier IntentClassifier) *ChatbotService {
+	clone := *s
+	clone.classifier = classifier
+	return &clone
+}
+
+// WithContext meneruskan ctx ke service transaksi, batch, utang, dan tabungan yang dipanggil tool AI.
+func (s *ChatbotService) WithContext(ctx context.Context) *ChatbotService {
+	clone := *s
+	if s.transactionSvc != nil {
+		clone.transactionSvc = s.transactionSvc.WithContext(ctx)
+	}
+	if s.batchSvc != nil {
+		clone.batchSvc = s.batchSvc.WithContext(ctx)
+	}
+	if s.debtSvc != nil {
+		clone.debtSvc = s.debtSvc.WithContext(ctx)
+	}
+	if s.savingGoalSvc != nil {
+		clone.savingGoalSvc = s.savingGoalSvc.WithContext(ctx)
+	}
+	return &clone
+}
+
+// ChatPrompt renders the active chat prompt template with the user's wallets (and the wallet used
+// when none is named), categories, aliases and the financial context for message. A failed lookup
+// leaves its list empty.
+func (s *ChatbotService) ChatPrompt(userID uint, message string) ChatPrompt {
+	data := ChatPromptData{Context: s.GetUserContext(userID, message)}
+
+	if wallets, err := s.walletRepo.FindByUserID(userID); err == nil {
+		for _, w := range wallets {
+			data.Wallets = append(data.Wallets, w.Name)
+		}
+		if _, name, err := resolveWalletFromList(wallets, ""); err == nil {
+			data.DefaultWallet = name
+		}
+	}
+	if categories, err := s.categoryRepo.FindAll(userID); err == nil {
+		for _, c := range categories {
+			data.Categories = append(data.Categories, c.Name)
+		}
+	}
+	if s.aliasRepo != nil {
+		if aliases, err := s.aliasRepo.FindByUserID(userID); err == nil {
+			data.Aliases = aliases
+		}
+	}
+
+	prompt, err := s.prompts.RenderChat(data)
+	if err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Gagal merender prompt chat")
+		prompt.Text = data.Context
+	}
+	return prompt
+}
+
 func (s *ChatbotService) GetUserContext(userID uint, message string) string {
-	intent := DetectIntent(message)
+	// Satu pesan bisa punya beberapa intent; context yang diambil adalah gabungannya.
+	intents := DetectIntents(s.classifier, message)
+	log.Debug().Str("intents", intents.String()).Msg("Intent pesan terdeteksi")
 
-	if intent == IntentSmallTalk {
+	if isSmallTalk(intents) {
 		return ""
 	}
 
@@ -91,6 +198,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	var debts []entity.Debt
 	var goals []entity.SavingGoal
 	var health entity.FinancialHealthResponse
+	var related *entity.TransactionSearchResult
 
 	wib, _ := time.LoadLocation("Asia/Jakarta")
 	now := time.Now().In(wib)
@@ -107,7 +215,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	endOfMonth := today + " 23:59:59"
 
 	// Dashboard — selalu dibutuhkan (saldo, income, expense bulan ini).
-	if needsDashboardContext(intent) {
+	if needsDashboardContext(intents) {
 		eg.Go(func() error {
 			if d, err := s.dashboardSvc.GetDashboardData(userID); err == nil {
 				dashboard = d
@@ -117,7 +225,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	}
 
 	// Wallet — dibutuhkan untuk semua intent transaksi & umum.
-	if needsWalletContext(intent) {
+	if needsWalletContext(intents) {
 		eg.Go(func() error {
 			if w, err := s.walletRepo.FindByUserID(userID); err == nil {
 				wallets = w
@@ -127,7 +235,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	}
 
 	// Transaksi terakhir — selalu berguna untuk transaksi & laporan.
-	if intent != IntentDebt && intent != IntentGoal && intent != IntentHealth {
+	if needsRecentTransactions(intents) {
 		eg.Go(func() error {
 			if t, err := s.transactionRepo.GetRecentTransactions(userID, MaxRecentTxns); err == nil {
 				txns = t
@@ -137,7 +245,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	}
 
 	// Ringkasan harian & mingguan — hanya untuk report / general / transaksi.
-	if needsReportContext(intent) {
+	if needsReportContext(intents) {
 		eg.Go(func() error {
 			if st, err := s.transactionRepo.FindSummaryByDateRange(userID, today, todayEnd, nil, nil, ""); err == nil {
 				summaryToday = st
@@ -154,7 +262,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 
 	// Breakdown per-hari bulan ini — HANYA untuk IntentReport agar AI bisa menjawab
 	// pertanyaan detail seperti "hari mana pengeluaran terbanyak?".
-	if intent == IntentReport {
+	if needsMonthlyBreakdown(intents) {
 		eg.Go(func() error {
 			if sm, err := s.transactionRepo.FindSummaryByDateRange(userID, startOfMonth, endOfMonth, nil, nil, ""); err == nil {
 				summaryMonth = sm
@@ -164,7 +272,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	}
 
 	// Utang / piutang — hanya untuk intent utang & general.
-	if needsDebtContext(intent) {
+	if needsDebtContext(intents) {
 		eg.Go(func() error {
 			if d, err := s.debtRepo.FindByUserID(userID, ""); err == nil {
 				debts = d
@@ -174,7 +282,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	}
 
 	// Target tabungan — hanya untuk intent goal & general.
-	if needsGoalContext(intent) {
+	if needsGoalContext(intents) {
 		eg.Go(func() error {
 			if g, err := s.savingGoalRepo.FindAll(userID); err == nil {
 				goals = g
@@ -184,7 +292,7 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	}
 
 	// Skor keuangan — hanya untuk intent health & general.
-	if needsHealthContext(intent) {
+	if needsHealthContext(intents) {
 		eg.Go(func() error {
 			if h, err := s.financialHealth.GetFinancialHealth(userID); err == nil {
 				health = h
@@ -193,6 +301,22 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 		})
 	}
 
+	// Transaksi lama yang mirip pertanyaan ("berapa kali beli kopi bulan lalu?") — di luar
+	// MaxRecentTxns, dicari lewat embedding.
+	if s.searchSvc != nil && needsHistorySearch(intents) {
+		eg.Go(func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			r, err := s.searchSvc.Search(ctx, userID, message, now)
+			if err != nil {
+				log.Warn().Err(err).Uint("user_id", userID).Msg("Pencarian transaksi mirip gagal")
+				return nil
+			}
+			related = r
+			return nil
+		})
+	}
+
 	eg.Wait()
 
 	var sb strings.Builder
@@ -363,7 +487,14 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 		sb.WriteString(fmt.Sprintf("\nSkor Keuangan: %.0f/100 (%s)\n", health.OverallScore, health.OverallStatus))
 	}
 
-	sb.WriteString("--- AKHIR DATA ---")
+	// Transaksi mirip ditulis terakhir dan hanya selama masih muat di MaxContextChars, supaya
+	// data di atas tidak ikut terpotong.
+	const footer = "--- AKHIR DATA ---"
+	if related != nil && related.Total > 0 {
+		writeRelatedTransactions(&sb, related, MaxContextChars-sb.Len()-len(footer))
+	}
+
+	sb.WriteString(footer)
 
 	// Terapkan token budget: potong jika melebihi MaxContextChars.
 	result := sb.String()
@@ -373,49 +504,240 @@ func (s *ChatbotService) GetUserContext(userID uint, message string) string {
 	return result
 }
 
+// writeRelatedTransactions menulis agregat bulanan lalu transaksi yang paling mirip, baris demi
+// baris selama masih muat dalam budget karakter.
+func writeRelatedTransactions(sb *strings.Builder, r *entity.TransactionSearchResult, budget int) {
+	lines := []string{fmt.Sprintf("\nTransaksi Mirip dengan Pertanyaan (%d cocok, %d bulan terakhir):\n", r.Total, searchLookbackMonths)}
+	for _, m := range r.Monthly {
+		line := fmt.Sprintf("  %s: %dx, pengeluaran %s", m.Month, m.Count, formatRupiah(m.Expense))
+		if m.Income > 0 {
+			line += ", pemasukan " + formatRupiah(m.Income)
+		}
+		lines = append(lines, line+"\n")
+	}
+	for _, m := range r.Matches {
+		t := m.Transaction
+		lines = append(lines, fmt.Sprintf("- [ID: %d] %s %s: %s (%s, %s, %s)\n",
+			t.ID, t.Date.Format("2006-01-02"), t.Description, formatRupiah(t.Amount), t.Type, t.Wallet.Name, t.Category.Name))
+	}
+
+	// Judul tanpa isi tidak berguna: minimal judul dan satu baris harus muat.
+	if len(lines) < 2 || len(lines[0])+len(lines[1]) > budget {
+		return
+	}
+	for _, line := range lines {
+		if len(line) > budget {
+			return
+		}
+		sb.WriteString(line)
+		budget -= len(line)
+	}
+}
+
 func (s *ChatbotService) SaveTransactions(userID uint, items []entity.TransactionItemAI) ([]entity.SavedTransaction, error) {
 	var results []entity.SavedTransaction
+	var batchItems []entity.AIBatchItem
 	var errs []string
 
 	for _, item := range items {
-		action := strings.ToLower(item.Action)
-		
-		if item.Amount <= 0 && action != "delete" {
+		if item.Amount <= 0 && strings.ToLower(item.Action) != "delete" {
 			continue
 		}
-		
-		var saved *entity.SavedTransaction
-		var err error
-		
-		switch action {
-		case "update":
-			saved, err = s.updateOne(userID, &item)
-		case "delete":
-			saved, err = s.deleteOne(userID, &item)
-		default:
-			saved, err = s.saveOne(userID, &item)
-			action = "create"
-		}
 
+		saved, batchItem, err := s.applyItem(userID, item)
 		if err != nil {
-			log.Error().Err(err).Str("action", action).Str("description", item.Description).Msg("Transaction item failed")
 			errs = append(errs, fmt.Sprintf("- '%s': %v", item.Description, err))
 			continue
 		}
-		
-		if saved != nil {
-			saved.Action = action
-			results = append(results, *saved)
-			log.Info().Uint("user_id", userID).Str("action", action).Uint("transaction_id", saved.ID).Msg("AI transaction processed successfully")
-		}
+		results = append(results, *saved)
+		batchItems = append(batchItems, *batchItem)
 	}
 
+	s.recordBatch(userID, batchItems)
+
 	if len(errs) > 0 {
 		return results, fmt.Errorf("Beberapa transaksi gagal diproses:\n%s", strings.Join(errs, "\n"))
 	}
 	return results, nil
 }
 
+// applyItem menjalankan satu aksi create/update/delete dari AI dan menyiapkan item batch untuk undo.
+func (s *ChatbotService) applyItem(userID uint, item entity.TransactionItemAI) (*entity.SavedTransaction, *entity.AIBatchItem, error) {
+	action := strings.ToLower(item.Action)
+
+	// Simpan kondisi sebelum update/delete agar batch bisa di-undo.
+	var before entity.JSONText
+	if s.batchSvc != nil && (action == "update" || action == "delete") && item.ID != 0 {
+		before, _ = s.batchSvc.Snapshot(userID, item.ID)
+	}
+
+	var saved *entity.SavedTransaction
+	var err error
+	switch action {
+	case "update":
+		saved, err = s.updateOne(userID, &item)
+	case "delete":
+		saved, err = s.deleteOne(userID, &item)
+	default:
+		saved, err = s.saveOne(userID, &item)
+		action = "create"
+	}
+	if err != nil {
+		log.Error().Err(err).Str("action", action).Str("description", item.Description).Msg("Transaction item failed")
+		return nil, nil, err
+	}
+
+	saved.Action = action
+	log.Info().Uint("user_id", userID).Str("action", action).Uint("transaction_id", saved.ID).Msg("AI transaction processed successfully")
+	return saved, &entity.AIBatchItem{
+		TransactionID: saved.ID,
+		Action:        action,
+		Description:   saved.Description,
+		Amount:        saved.Amount,
+		Type:          saved.Type,
+		Before:        before,
+	}, nil
+}
+
+func (s *ChatbotService) recordBatch(userID uint, items []entity.AIBatchItem) {
+	if s.batchSvc == nil || len(items) == 0 {
+		return
+	}
+	if _, err := s.batchSvc.Record(userID, items); err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal mencatat batch AI untuk undo")
+	}
+}
+
+// UndoLastBatch membatalkan seluruh perubahan dari respons AI terakhir milik user.
+func (s *ChatbotService) UndoLastBatch(userID uint) (*entity.AIBatch, error) {
+	if s.batchSvc == nil {
+		return nil, ErrNothingToUndo
+	}
+	return s.batchSvc.UndoLast(userID)
+}
+
+// PendingDrafts mengembalikan draft AI milik user yang masih menunggu konfirmasi.
+func (s *ChatbotService) PendingDrafts(userID uint) ([]entity.AIDraft, error) {
+	if s.draftSvc == nil {
+		return []entity.AIDraft{}, nil
+	}
+	return s.draftSvc.GetPending(userID)
+}
+
+// ConfirmDraft menyimpan transaksi dari draft, atau versi editan user jika edits diisi,
+// sebagai satu batch AI. Aksi non-transaksi yang ditahan (utang, transfer, tabungan) dijalankan
+// ulang lewat tool aslinya; baris konfirmasinya dikembalikan sebagai summary.
+func (s *ChatbotService) ConfirmDraft(userID uint, draftID uint, edits []entity.TransactionItemAI) ([]entity.SavedTransaction, string, error) {
+	if s.draftSvc == nil {
+		return nil, "", ErrDraftNotFound
+	}
+	items, err := s.draftSvc.Resolve(userID, draftID, entity.AIDraftConfirmed, edits)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := s.NewToolSession(userID, "", false)
+	var errs []string
+	for _, item := range items {
+		if item.Amount <= 0 && strings.ToLower(item.Action) != "delete" {
+			continue
+		}
+		if err := session.replay(item); err != nil {
+			errs = append(errs, fmt.Sprintf("- '%s': %v", item.Description, err))
+		}
+	}
+	saved := session.Finish()
+
+	if len(errs) > 0 {
+		return saved, session.Summary(), fmt.Errorf("Beberapa transaksi gagal diproses:\n%s", strings.Join(errs, "\n"))
+	}
+	return saved, session.Summary(), nil
+}
+
+// RejectDraft membuang draft tanpa menyimpan apa pun.
+func (s *ChatbotService) RejectDraft(userID uint, draftID uint) error {
+	if s.draftSvc == nil {
+		return ErrDraftNotFound
+	}
+	_, err := s.draftSvc.Resolve(userID, draftID, entity.AIDraftRejected, nil)
+	return err
+}
+
+// validateDraftItem memeriksa item editan user terhadap item draft asli dengan indeks yang sama
+// (kosong jika user menambah item baru).
+func validateDraftItem(item, original entity.TransactionItemAI) error {
+	if item.Attachment != "" && item.Attachment != original.Attachment {
+		return fmt.Errorf("%w: lampiran tidak bisa diubah lewat draft", ErrInvalidDraftItem)
+	}
+	if original.Action == entity.DraftToolAction {
+		if item.Action != original.Action || item.Tool != original.Tool || item.Amount != original.Amount {
+			return fmt.Errorf("%w: aksi '%s' tidak bisa diedit, konfirmasi atau tolak draft", ErrInvalidDraftItem, original.Tool)
+		}
+		return nil
+	}
+
+	args := transactionToolArgs{Type: item.Type, Amount: item.Amount, Description: item.Description}
+	switch strings.ToLower(item.Action) {
+	case "delete":
+		if item.ID == 0 {
+			return fmt.Errorf("%w: id transaksi wajib diisi untuk delete", ErrInvalidDraftItem)
+		}
+		return nil
+	case "update":
+		if item.ID == 0 {
+			return fmt.Errorf("%w: id transaksi wajib diisi untuk update", ErrInvalidDraftItem)
+		}
+	case "", "create":
+	case entity.DraftToolAction:
+		return fmt.Errorf("%w: aksi '%s' tidak bisa diedit, konfirmasi atau tolak draft", ErrInvalidDraftItem, item.Tool)
+	default:
+		return fmt.Errorf("%w: action '%s' tidak dikenal", ErrInvalidDraftItem, item.Action)
+	}
+	if _, err := transactionDate(item.Date, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDraftItem, err)
+	}
+	if err := args.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDraftItem, err)
+	}
+	return nil
+}
+
+// FormatDateLabel memberi keterangan tanggal untuk ringkasan transaksi yang tidak dicatat hari ini
+// (WIB), misal " 📅 kemarin" atau " 📅 03/10/2025"; kosong untuk hari ini.
+func FormatDateLabel(date string) string {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now := time.Now().In(wib)
+	switch date {
+	case "", now.Format("2006-01-02"):
+		return ""
+	case now.AddDate(0, 0, -1).Format("2006-01-02"):
+		return " 📅 kemarin"
+	}
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return ""
+	}
+	return " 📅 " + d.Format("02/01/2006")
+}
+
+// FormatUndoSummary menyusun balasan untuk batch yang baru saja dibatalkan.
+func FormatUndoSummary(batch *entity.AIBatch) string {
+	summary := "↩️ Aksi AI terakhir dibatalkan:"
+	for _, item := range batch.Items {
+		var verb string
+		switch item.Action {
+		case "update":
+			verb = "Perubahan dikembalikan"
+		case "delete":
+			verb = "Dipulihkan"
+		default:
+			verb = "Dihapus"
+		}
+		summary += fmt.Sprintf("\n• %s: %s — %s", verb, item.Description, formatRupiah(item.Amount))
+	}
+	return summary
+}
+
 func (s *ChatbotService) saveOne(userID uint, tx *entity.TransactionItemAI) (*entity.SavedTransaction, error) {
 	walletID, walletName, err := s.resolveWallet(userID, tx.WalletName)
 	if err != nil {
@@ -427,13 +749,19 @@ func (s *ChatbotService) saveOne(userID uint, tx *entity.TransactionItemAI) (*en
 		return nil, fmt.Errorf("kategori '%s' tidak ditemukan: %w", tx.CategoryName, err)
 	}
 
+	date, err := transactionDate(tx.Date, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	input := CreateTransactionInput{
 		WalletID:    walletID,
 		CategoryID:  categoryID,
 		Amount:      tx.Amount,
 		Type:        tx.Type,
 		Description: tx.Description,
-		Date:        time.Now(),
+		Attachment:  tx.Attachment,
+		Date:        date,
 	}
 
 	created, err := s.transactionSvc.CreateTransaction(userID, input)
@@ -448,6 +776,7 @@ func (s *ChatbotService) saveOne(userID uint, tx *entity.TransactionItemAI) (*en
 		Type:         tx.Type,
 		CategoryName: categoryName,
 		WalletName:   walletName,
+		Date:         date.Format("2006-01-02"),
 	}, nil
 }
 
@@ -471,13 +800,18 @@ func (s *ChatbotService) updateOne(userID uint, tx *entity.TransactionItemAI) (*
 		return nil, fmt.Errorf("transaksi tidak ditemukan: %w", err)
 	}
 
+	date, err := transactionDate(tx.Date, existingTx.Date)
+	if err != nil {
+		return nil, err
+	}
+
 	input := CreateTransactionInput{
 		WalletID:    walletID,
 		CategoryID:  categoryID,
 		Amount:      tx.Amount,
 		Type:        tx.Type,
 		Description: tx.Description,
-		Date:        existingTx.Date,
+		Date:        date,
 	}
 
 	updated, err := s.transactionSvc.UpdateTransaction(tx.ID, userID, input)
@@ -492,9 +826,25 @@ func (s *ChatbotService) updateOne(userID uint, tx *entity.TransactionItemAI) (*
 		Type:         tx.Type,
 		CategoryName: categoryName,
 		WalletName:   walletName,
+		Date:         date.Format("2006-01-02"),
 	}, nil
 }
 
+// transactionDate memakai tanggal YYYY-MM-DD dari AI dengan jam dari base (WIB), agar urutan
+// transaksi di hari yang sama tetap wajar. Tanpa tanggal, base dipakai apa adanya.
+func transactionDate(date string, base time.Time) (time.Time, error) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	if date == "" {
+		return base.In(wib), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, wib)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("tanggal '%s' harus berformat YYYY-MM-DD", date)
+	}
+	clock := base.In(wib)
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, wib), nil
+}
+
 func (s *ChatbotService) deleteOne(userID uint, tx *entity.TransactionItemAI) (*entity.SavedTransaction, error) {
 	if tx.ID == 0 {
 		return nil, errors.New("ID transaksi tidak valid untuk delete")
@@ -551,6 +901,14 @@ func resolveWalletFromList(wallets []entity.Wallet, name string) (uint, string,
 		}
 	}
 
+	names := make([]string, len(wallets))
+	for i, w := range wallets {
+		names[i] = w.Name
+	}
+	if i, _ := fuzzyMatchName(names, nameLower); i >= 0 {
+		return wallets[i].ID, wallets[i].Name, nil
+	}
+
 	if len(wallets) > 0 {
 		return wallets[0].ID, wallets[0].Name, nil
 	}
@@ -597,9 +955,104 @@ func resolveCategoryFromList(categories []entity.Category, name string, txType s
 		}
 	}
 
+	names := make([]string, len(filtered))
+	for i, c := range filtered {
+		names[i] = c.Name
+	}
+	if i, _ := fuzzyMatchName(names, nameLower); i >= 0 {
+		return filtered[i].ID, filtered[i].Name, nil
+	}
+
 	if len(filtered) > 0 {
 		return filtered[0].ID, filtered[0].Name, nil
 	}
 
 	return 0, "", fmt.Errorf("user has no categories")
 }
+
+// fuzzyMatchThreshold adalah skor minimal fuzzyMatchName: "gopy" → "GoPay" (0.8) lolos,
+// "bri" → "BNI" (0.67) tidak.
+const fuzzyMatchThreshold = 0.75
+
+// fuzzyMatchName mencari nama yang paling mirip setelah dinormalisasi (huruf kecil, tanpa spasi
+// dan tanda baca), untuk salah ketik seperti "go pay", "gopy" atau "mandri". Nama yang memuat
+// query (atau sebaliknya) hanya dihitung per kata utuh: "jago" cocok dengan "Jago Utama", tapi
+// "cashback" tidak cocok dengan "Cash". Mengembalikan -1 jika tidak ada yang mencapai
+// fuzzyMatchThreshold.
+func fuzzyMatchName(names []string, query string) (int, float64) {
+	q, qWords := normalizeName(query), nameWords(query)
+	best, bestScore := -1, 0.0
+	if q == "" {
+		return best, bestScore
+	}
+	for i, name := range names {
+		n := normalizeName(name)
+		if n == "" {
+			continue
+		}
+		var score float64
+		switch {
+		case n == q:
+			score = 1
+		case len(q) >= 3 && len(n) >= 3 && (containsWords(nameWords(name), qWords) || containsWords(qWords, nameWords(name))):
+			score = 0.9
+		default:
+			score = 1 - float64(levenshtein(n, q))/math.Max(float64(len(n)), float64(len(q)))
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if bestScore < fuzzyMatchThreshold {
+		return -1, bestScore
+	}
+	return best, bestScore
+}
+
+func normalizeName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// nameWords memecah nama menjadi kata huruf kecil; tanda baca dan spasi menjadi pemisah.
+func nameWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords melaporkan apakah sub muncul sebagai deret kata berurutan di dalam words.
+func containsWords(words, sub []string) bool {
+	for i := 0; len(sub) > 0 && i+len(sub) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(sub)], sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/repository"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -120,6 +123,7 @@ func (m *mockSavingGoalRepository) WithTx(tx *gorm.DB) repository.SavingGoalRepo
 
 type mockTransactionService struct{ mock.Mock }
 
+func (m *mockTransactionService) WithContext(ctx context.Context) TransactionService { return m }
 func (m *mockTransactionService) CreateTransaction(userID uint, input CreateTransactionInput) (*entity.Transaction, error) {
 	return nil, nil
 }
@@ -169,6 +173,7 @@ func (m *mockUserRepository) Create(user *entity.User) error { return nil }
 func (m *mockUserRepository) FindByEmail(email string) (*entity.User, error) { return nil, nil }
 func (m *mockUserRepository) FindByPhone(phone string) (*entity.User, error) { return nil, nil }
 func (m *mockUserRepository) Update(user *entity.User) error                 { return nil }
+func (m *mockUserRepository) FindAll() ([]entity.User, error)                { return nil, nil }
 func (m *mockUserRepository) FindByID(id uint) (*entity.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
@@ -191,7 +196,7 @@ func TestChatbotService_GetUserContext(t *testing.T) {
 	mockUserRepo.On("FindByID", uint(1)).Return((*entity.User)(nil), fmt.Errorf("not found"))
 
 	service := NewChatbotService(
-		mockWalletRepo, mockCategoryRepo, mockTxSvc, mockTransactionRepo, mockDebtRepo, mockGoalRepo, mockDashSvc, mockHealthSvc, mockUserRepo,
+		mockWalletRepo, mockCategoryRepo, mockTxSvc, mockTransactionRepo, mockDebtRepo, mockGoalRepo, mockDashSvc, mockHealthSvc, mockUserRepo, nil, nil, nil, nil, nil,
 	)
 
 	mockDashSvc.On("GetDashboardData", uint(1)).Return(&entity.DashboardData{TotalBalance: 1000}, nil)
@@ -211,3 +216,81 @@ func TestChatbotService_GetUserContext(t *testing.T) {
 	assert.Contains(t, contextStr, "Cash")
 	assert.Contains(t, contextStr, "85/100 (Good)")
 }
+
+type stubTransactionSearch struct {
+	result *entity.TransactionSearchResult
+	query  string
+}
+
+func (s *stubTransactionSearch) IndexPending(ctx context.Context, userID uint, limit int) (int, error) {
+	return 0, nil
+}
+
+func (s *stubTransactionSearch) Search(ctx context.Context, userID uint, query string, now time.Time) (*entity.TransactionSearchResult, error) {
+	s.query = query
+	return s.result, nil
+}
+
+func TestChatbotService_GetUserContext_RelatedTransactions(t *testing.T) {
+	mockWalletRepo := new(mockWalletRepository)
+	mockTransactionRepo := new(mockTransactionRepository)
+	mockDashSvc := new(mockDashboardService)
+	mockUserRepo := &mockUserRepository{}
+	mockUserRepo.On("FindByID", uint(1)).Return((*entity.User)(nil), fmt.Errorf("not found"))
+	mockDashSvc.On("GetDashboardData", uint(1)).Return(&entity.DashboardData{TotalBalance: 1000}, nil)
+	mockWalletRepo.On("FindByUserID", uint(1)).Return([]entity.Wallet{{ID: 1, Name: "Cash"}}, nil)
+	mockTransactionRepo.On("GetRecentTransactions", uint(1), MaxRecentTxns).Return([]entity.Transaction{}, nil)
+	mockTransactionRepo.On("FindSummaryByDateRange", uint(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]entity.TransactionSummary{}, nil)
+
+	result := &entity.TransactionSearchResult{
+		Total:   120,
+		Monthly: []entity.TransactionMatchMonth{{Month: "2026-09", Count: 12, Expense: 240000}},
+	}
+	for i := 0; i < 120; i++ {
+		result.Matches = append(result.Matches, entity.TransactionMatch{Transaction: entity.Transaction{
+			ID: uint(i + 1), Description: "Kopi susu gula aren ukuran besar", Amount: 20000, Type: "expense",
+			Date: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), Wallet: entity.Wallet{Name: "Cash"}, Category: entity.Category{Name: "Makan"},
+		}})
+	}
+	search := &stubTransactionSearch{result: result}
+
+	svc := NewChatbotService(
+		mockWalletRepo, new(mockCategoryRepository), new(mockTransactionService), mockTransactionRepo, new(mockDebtRepository), new(mockSavingGoalRepository), mockDashSvc, new(mockFinancialHealthService), mockUserRepo, nil, nil, nil, nil, nil,
+	).WithIntentClassifier(fixedIntentClassifier(NewIntentSet(IntentReport))).WithTransactionSearch(search)
+
+	contextStr := svc.GetUserContext(1, "berapa kali beli kopi bulan lalu?")
+
+	assert.Equal(t, "berapa kali beli kopi bulan lalu?", search.query)
+	assert.Contains(t, contextStr, "Transaksi Mirip dengan Pertanyaan (120 cocok")
+	assert.Contains(t, contextStr, "2026-09: 12x, pengeluaran Rp240.000")
+	assert.Contains(t, contextStr, "[ID: 1] 2026-09-01 Kopi susu gula aren ukuran besar: Rp20.000 (expense, Cash, Makan)")
+	// Daftar dipotong per baris sebelum melewati batas, bukan di tengah baris.
+	assert.LessOrEqual(t, len(contextStr), MaxContextChars)
+	assert.NotContains(t, contextStr, "context dipotong")
+	assert.True(t, strings.HasSuffix(contextStr, ")\n--- AKHIR DATA ---"))
+
+	// Tanpa intent laporan/umum, pencarian tidak dijalankan.
+	search.query = ""
+	svc.WithIntentClassifier(fixedIntentClassifier(NewIntentSet(IntentTransaction))).GetUserContext(1, "beli kopi 20rb")
+	assert.Empty(t, search.query)
+}
+
+func TestFuzzyMatchName(t *testing.T) {
+	names := []string{"Cash", "Jago Utama", "GoPay", "BCA"}
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"cash", 0},
+		{"cashback", -1},
+		{"jago", 1},
+		{"go pay", 2},
+		{"gopy", 2},
+		{"bca syariah", 3},
+		{"bcaxyz", -1},
+	}
+	for _, tc := range cases {
+		got, _ := fuzzyMatchName(names, tc.query)
+		assert.Equal(t, tc.want, got, tc.query)
+	}
+}
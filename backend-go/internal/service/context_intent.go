@@ -32,115 +32,118 @@ const (
 	MaxGoalsInContext = 5
 )
 
-// intentKeywords mendefinisikan kata kunci per intent.
-// Urutan di dalam slice tidak berpengaruh; semua dicek dengan strings.Contains.
-var intentKeywords = map[ContextIntent][]string{
-	IntentTransaction: {
-		"beli", "bayar", "beli", "catat", "masuk", "keluar", "transfer",
-		"makan", "belanja", "jajan", "isi", "topup", "top up", "tagihan",
-		"listrik", "air", "cicilan", "debit", "kredit", "saldo",
-	},
-	IntentDebt: {
-		"utang", "piutang", "pinjam", "pinjaman", "bayar utang", "cicil",
-		"minjem", "ngutang", "hutang", "receivable",
-	},
-	IntentReport: {
-		"rekap", "laporan", "statistik", "ringkasan", "summary",
-		"pengeluaran", "pemasukan", "minggu ini", "bulan ini",
-		"berapa total", "berapa habis", "analisis", "analisa",
-	},
-	IntentGoal: {
-		"target", "tabungan", "nabung", "saving", "goal", "tujuan",
-		"impian", "rencana nabung",
-	},
-	IntentHealth: {
-		"skor", "score", "kesehatan keuangan", "financial health",
-		"kondisi keuangan", "evaluasi keuangan",
-	},
-	IntentSmallTalk: {
-		"halo", "hai", "hi", "hello", "apa kabar", "selamat pagi",
-		"selamat siang", "selamat malam", "terima kasih", "makasih",
-		"thanks", "siapa kamu", "apa itu", "kamu bisa apa",
-	},
-}
-
-// DetectIntent menganalisis pesan user dan mengembalikan intent yang paling relevan.
-// Jika tidak ada kata kunci yang cocok, dikembalikan IntentGeneral.
-func DetectIntent(message string) ContextIntent {
-	msg := strings.ToLower(strings.TrimSpace(message))
-	if msg == "" {
-		return IntentGeneral
+// IntentSet adalah kumpulan intent dari satu pesan (multi-label), misal "bayar utang ke Budi
+// 200rb" sekaligus IntentTransaction dan IntentDebt.
+type IntentSet uint16
+
+// NewIntentSet membuat IntentSet dari daftar intent.
+func NewIntentSet(intents ...ContextIntent) IntentSet {
+	var set IntentSet
+	for _, intent := range intents {
+		set |= 1 << intent
 	}
+	return set
+}
+
+// Has mengembalikan true jika intent ada di set.
+func (s IntentSet) Has(intent ContextIntent) bool {
+	return s&(1<<intent) != 0
+}
 
-	// Small talk diprioritaskan lebih awal supaya tidak trigger context keuangan.
-	for _, kw := range intentKeywords[IntentSmallTalk] {
-		if strings.Contains(msg, kw) {
-			return IntentSmallTalk
+// Intents mengembalikan isi set berurutan sesuai konstanta ContextIntent.
+func (s IntentSet) Intents() []ContextIntent {
+	var out []ContextIntent
+	for intent := IntentGeneral; intent <= IntentSmallTalk; intent++ {
+		if s.Has(intent) {
+			out = append(out, intent)
 		}
 	}
+	return out
+}
 
-	// Cari intent dengan jumlah keyword match terbanyak untuk akurasi.
-	type scored struct {
-		intent ContextIntent
-		score  int
-	}
-	scores := make([]scored, 0, 5)
+var intentNames = map[ContextIntent]string{
+	IntentGeneral:     "general",
+	IntentTransaction: "transaction",
+	IntentDebt:        "debt",
+	IntentReport:      "report",
+	IntentGoal:        "goal",
+	IntentHealth:      "health",
+	IntentSmallTalk:   "small_talk",
+}
 
-	for intent, keywords := range intentKeywords {
-		if intent == IntentSmallTalk {
-			continue
-		}
-		count := 0
-		for _, kw := range keywords {
-			if strings.Contains(msg, kw) {
-				count++
-			}
-		}
-		if count > 0 {
-			scores = append(scores, scored{intent, count})
-		}
-	}
+func (i ContextIntent) String() string {
+	return intentNames[i]
+}
 
-	if len(scores) == 0 {
-		return IntentGeneral
+func (s IntentSet) String() string {
+	names := make([]string, 0, 2)
+	for _, intent := range s.Intents() {
+		names = append(names, intent.String())
 	}
+	return strings.Join(names, ",")
+}
 
-	best := scores[0]
-	for _, s := range scores[1:] {
-		if s.score > best.score {
-			best = s
-		}
+// DetectIntents mengklasifikasi pesan dengan classifier bawaan. Pesan kosong atau yang tidak
+// dikenali menjadi IntentGeneral (kirim semua context), dan small talk hanya berdiri sendiri:
+// "halo, saldo BCA berapa?" tetap butuh data keuangan.
+func DetectIntents(classifier IntentClassifier, message string) IntentSet {
+	set := classifier.Classify(message)
+	if set.Has(IntentSmallTalk) && set != NewIntentSet(IntentSmallTalk) {
+		set &^= NewIntentSet(IntentSmallTalk)
+	}
+	if set == 0 {
+		return NewIntentSet(IntentGeneral)
 	}
-	return best.intent
+	return set
+}
+
+// isSmallTalk mengembalikan true jika pesan hanya sapaan / obrolan tanpa kebutuhan data.
+func isSmallTalk(intents IntentSet) bool {
+	return intents == NewIntentSet(IntentSmallTalk)
 }
 
 // needsDebtContext mengembalikan true jika intent memerlukan data utang/piutang.
-func needsDebtContext(intent ContextIntent) bool {
-	return intent == IntentDebt || intent == IntentGeneral
+func needsDebtContext(intents IntentSet) bool {
+	return intents.Has(IntentDebt) || intents.Has(IntentGeneral)
 }
 
 // needsGoalContext mengembalikan true jika intent memerlukan data target tabungan.
-func needsGoalContext(intent ContextIntent) bool {
-	return intent == IntentGoal || intent == IntentGeneral
+func needsGoalContext(intents IntentSet) bool {
+	return intents.Has(IntentGoal) || intents.Has(IntentGeneral)
 }
 
 // needsHealthContext mengembalikan true jika intent memerlukan skor kesehatan keuangan.
-func needsHealthContext(intent ContextIntent) bool {
-	return intent == IntentHealth || intent == IntentGeneral
+func needsHealthContext(intents IntentSet) bool {
+	return intents.Has(IntentHealth) || intents.Has(IntentGeneral)
 }
 
 // needsReportContext mengembalikan true jika intent memerlukan ringkasan harian/mingguan.
-func needsReportContext(intent ContextIntent) bool {
-	return intent == IntentReport || intent == IntentGeneral || intent == IntentTransaction
+func needsReportContext(intents IntentSet) bool {
+	return intents.Has(IntentReport) || intents.Has(IntentGeneral) || intents.Has(IntentTransaction)
+}
+
+// needsRecentTransactions mengembalikan true jika intent memerlukan transaksi terakhir.
+func needsRecentTransactions(intents IntentSet) bool {
+	return intents.Has(IntentTransaction) || intents.Has(IntentReport) || intents.Has(IntentGeneral)
+}
+
+// needsMonthlyBreakdown mengembalikan true jika intent memerlukan breakdown per-hari bulan ini.
+func needsMonthlyBreakdown(intents IntentSet) bool {
+	return intents.Has(IntentReport)
+}
+
+// needsHistorySearch mengembalikan true jika pertanyaan perlu dicari di riwayat transaksi lama.
+func needsHistorySearch(intents IntentSet) bool {
+	return intents.Has(IntentReport) || intents.Has(IntentGeneral)
 }
 
 // needsWalletContext mengembalikan true jika intent memerlukan daftar wallet.
 // Wallet SELALU disertakan kecuali small talk supaya AI tahu ke mana transaksi disimpan.
-func needsWalletContext(intent ContextIntent) bool {
-	return intent != IntentSmallTalk
+func needsWalletContext(intents IntentSet) bool {
+	return !isSmallTalk(intents)
 }
 
 // needsDashboardContext mengembalikan true jika intent memerlukan ringkasan dashboard.
-func needsDashboardContext(intent ContextIntent) bool {
-	return intent != IntentSmallTalk
+func needsDashboardContext(intents IntentSet) bool {
+	return !isSmallTalk(intents)
 }
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// ConversationTokenBudget adalah batas token (perkiraan) untuk ringkasan + giliran chat
+	// yang dikirim ulang ke LLM, di luar system prompt dan pesan saat ini.
+	ConversationTokenBudget = 1200
+
+	// conversationFetchLimit adalah jumlah pesan terbaru yang dibaca dari DB per request.
+	conversationFetchLimit = 40
+
+	// summarizeThreshold adalah jumlah pesan lama di luar jendela sebelum diringkas.
+	summarizeThreshold = 4
+
+	// maxTurnChars memotong satu pesan yang sangat panjang (mis. hasil OCR struk).
+	maxTurnChars = 1200
+
+	charsPerToken = 4
+)
+
+// Conversation adalah jendela riwayat chat yang dikirim ulang ke LLM: ringkasan giliran lama
+// plus giliran terbaru yang muat dalam ConversationTokenBudget.
+type Conversation struct {
+	Summary string
+	Turns   []aiprovider.Message
+}
+
+// EstimateTokens memperkirakan jumlah token (~4 karakter per token) tanpa tokenizer model.
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + charsPerToken - 1) / charsPerToken
+}
+
+// buildConversation menyusun jendela percakapan dari pesan (urut lama → baru) dan mengembalikan
+// pesan lama di luar jendela yang belum masuk ringkasan.
+func buildConversation(messages []entity.ChatMessage, current string, summary *entity.ChatSummary, budget int) (Conversation, []entity.ChatMessage) {
+	var conv Conversation
+	var lastSummarized uint
+	if summary != nil {
+		conv.Summary = summary.Content
+		lastSummarized = summary.LastMessageID
+	}
+
+	var pending []entity.ChatMessage
+	for _, m := range messages {
+		if m.ID > lastSummarized {
+			pending = append(pending, m)
+		}
+	}
+
+	// Pesan user saat ini sudah disimpan sebelum AI dipanggil; jangan dikirim dua kali.
+	if n := len(pending); n > 0 && pending[n-1].Role == "user" &&
+		strings.TrimSpace(pending[n-1].Content) == strings.TrimSpace(current) {
+		pending = pending[:n-1]
+	}
+	// Pesan user tanpa balasan (mis. AI gagal) akan membuat dua giliran user berurutan.
+	for len(pending) > 0 && pending[len(pending)-1].Role != "assistant" {
+		pending = pending[:len(pending)-1]
+	}
+
+	remaining := budget - EstimateTokens(conv.Summary)
+	start := len(pending)
+	for start > 0 {
+		cost := EstimateTokens(turnContent(pending[start-1]))
+		if cost > remaining {
+			break
+		}
+		remaining -= cost
+		start--
+	}
+	// Jendela harus diawali giliran user agar urutan role tetap user/assistant bergantian.
+	for start < len(pending) && pending[start].Role != "user" {
+		start++
+	}
+
+	for _, m := range pending[start:] {
+		content := turnContent(m)
+		if n := len(conv.Turns); n > 0 && conv.Turns[n-1].Role == m.Role {
+			conv.Turns[n-1].Content += "\n" + content
+			continue
+		}
+		conv.Turns = append(conv.Turns, aiprovider.Message{Role: m.Role, Content: content})
+	}
+
+	return conv, pending[:start]
+}
+
+// turnContent adalah isi pesan seperti dikirim ke LLM. Balasan assistant diberi catatan ID
+// transaksi yang disimpan, supaya koreksi seperti "eh salah, yang tadi 20rb" bisa di-update.
+func turnContent(m entity.ChatMessage) string {
+	content := m.Content
+	if utf8.RuneCountInString(content) > maxTurnChars {
+		content = string([]rune(content)[:maxTurnChars]) + "…"
+	}
+	if m.Role != "assistant" || m.Transactions == "" {
+		return content
+	}
+
+	var saved []entity.SavedTransaction
+	if err := json.Unmarshal([]byte(m.Transactions), &saved); err != nil || len(saved) == 0 {
+		return content
+	}
+	refs := make([]string, 0, len(saved))
+	for _, t := range saved {
+		refs = append(refs, fmt.Sprintf("ID %d %s %s %s %.0f (%s)", t.ID, t.Action, t.Type, t.Description, t.Amount, t.WalletName))
+	}
+	return content + "\n[transaksi: " + strings.Join(refs, "; ") + "]"
+}
+
+// composeSystemPrompt menambahkan ringkasan percakapan lama ke system prompt.
+func composeSystemPrompt(prompt ChatPrompt, conv Conversation) string {
+	systemPrompt := prompt.Text
+	if conv.Summary != "" {
+		systemPrompt += "\n\nRINGKASAN PERCAKAPAN SEBELUMNYA:\n" + conv.Summary
+	}
+	return systemPrompt
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func chatTurns(contents ...string) []entity.ChatMessage {
+	messages := make([]entity.ChatMessage, len(contents))
+	for i, c := range contents {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages[i] = entity.ChatMessage{ID: uint(i + 1), Role: role, Content: c}
+	}
+	return messages
+}
+
+func TestBuildConversation_DropsCurrentMessageAndKeepsAlternation(t *testing.T) {
+	messages := chatTurns("beli kopi 15rb", "Dicatat!", "eh salah, yang tadi 20rb")
+
+	conv, overflow := buildConversation(messages, "eh salah, yang tadi 20rb", nil, ConversationTokenBudget)
+
+	assert.Empty(t, overflow)
+	require.Len(t, conv.Turns, 2)
+	assert.Equal(t, aiprovider.Message{Role: "user", Content: "beli kopi 15rb"}, conv.Turns[0])
+	assert.Equal(t, "assistant", conv.Turns[1].Role)
+}
+
+func TestBuildConversation_AnnotatesSavedTransactions(t *testing.T) {
+	messages := chatTurns("beli nasi goreng 15rb", "Dicatat! ✅")
+	messages[1].Transactions = `[{"id":45,"action":"create","description":"Nasi Goreng","amount":15000,"type":"expense","wallet_name":"BCA"}]`
+
+	conv, _ := buildConversation(messages, "yang tadi 20rb", nil, ConversationTokenBudget)
+
+	require.Len(t, conv.Turns, 2)
+	assert.Contains(t, conv.Turns[1].Content, "ID 45 create expense Nasi Goreng 15000 (BCA)")
+}
+
+func TestBuildConversation_RespectsBudgetAndSummary(t *testing.T) {
+	long := strings.Repeat("a", 400) // ~100 token
+	messages := chatTurns(long, long, long, long, long, long, "halo", "hai")
+
+	conv, overflow := buildConversation(messages, "apa kabar", nil, 210)
+
+	// Hanya dua giliran terakhir + satu pasang panjang yang muat; sisanya overflow.
+	assert.Equal(t, "hai", conv.Turns[len(conv.Turns)-1].Content)
+	assert.Equal(t, "user", conv.Turns[0].Role)
+	total := 0
+	for _, turn := range conv.Turns {
+		total += EstimateTokens(turn.Content)
+	}
+	assert.LessOrEqual(t, total, 210)
+	assert.NotEmpty(t, overflow)
+	assert.Equal(t, uint(1), overflow[0].ID)
+
+	summary := &entity.ChatSummary{Content: "- user beli kopi", LastMessageID: overflow[len(overflow)-1].ID}
+	conv2, overflow2 := buildConversation(messages, "apa kabar", summary, 210)
+	assert.Equal(t, "- user beli kopi", conv2.Summary)
+	assert.Empty(t, overflow2)
+}
+
+type stubChatRepository struct {
+	messages []entity.ChatMessage
+	summary  *entity.ChatSummary
+	saved    chan *entity.ChatSummary
+}
+
+func (r *stubChatRepository) Save(msg *entity.ChatMessage) error {
+	msg.ID = uint(len(r.messages) + 1)
+	r.messages = append(r.messages, *msg)
+	return nil
+}
+
+func (r *stubChatRepository) FindByUserID(_ uint, _ int) ([]entity.ChatMessage, error) {
+	return r.messages, nil
+}
+
+func (r *stubChatRepository) FindRecentByUserID(_ uint, _ int) ([]entity.ChatMessage, error) {
+	return r.messages, nil
+}
+
+func (r *stubChatRepository) DeleteByUserID(_ uint) error {
+	r.messages = nil
+	r.summary = nil
+	return nil
+}
+
+func (r *stubChatRepository) FindPage(_ uint, _ uint, _ int, _ string) ([]entity.ChatMessage, error) {
+	return r.messages, nil
+}
+
+func (r *stubChatRepository) DeleteMessage(_ uint, _ uint) (*entity.ChatMessage, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *stubChatRepository) DeleteOlderThan(_ uint, _ time.Time) ([]entity.ChatMessage, error) {
+	return nil, nil
+}
+
+func (r *stubChatRepository) DeleteSummary(_ uint) error {
+	r.summary = nil
+	return nil
+}
+
+func (r *stubChatRepository) FindSummary(_ uint) (*entity.ChatSummary, error) {
+	if r.summary == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.summary, nil
+}
+
+func (r *stubChatRepository) SaveSummary(summary *entity.ChatSummary) error {
+	r.saved <- summary
+	return nil
+}
+
+type stubSummarizer struct {
+	previous string
+	turns    []aiprovider.Message
+}
+
+func (s *stubSummarizer) Summarize(userID uint, previous string, turns []aiprovider.Message) (string, error) {
+	s.previous, s.turns = previous, turns
+	return "- ringkasan baru", nil
+}
+
+func TestChatHistoryService_BuildConversation_SummarizesOverflow(t *testing.T) {
+	repo := &stubChatRepository{saved: make(chan *entity.ChatSummary, 1)}
+	summarizer := &stubSummarizer{}
+	svc := NewChatHistoryService(repo, summarizer, nil, nil)
+
+	long := strings.Repeat("b", 2000)
+	for i := 0; i < 6; i++ {
+		_ = svc.SaveMessage(1, "user", long, "", "", nil)
+		_ = svc.SaveReply(1, "oke", nil, "", "")
+	}
+	_ = svc.SaveMessage(1, "user", "terakhir", "", "", nil)
+
+	conv := svc.BuildConversation(1, "terakhir")
+	assert.NotEmpty(t, conv.Turns)
+
+	select {
+	case summary := <-repo.saved:
+		assert.Equal(t, "- ringkasan baru", summary.Content)
+		assert.Equal(t, uint(1), summary.UserID)
+		assert.NotZero(t, summary.LastMessageID)
+		assert.NotEmpty(t, summarizer.turns)
+	case <-time.After(2 * time.Second):
+		t.Fatal("overflow turns were not summarized")
+	}
+}
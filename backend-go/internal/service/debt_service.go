@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/repository"
 	"errors"
@@ -19,6 +20,16 @@ type UpdateDebtInput struct {
 	DueDate     *time.Time `json:"due_date"`
 }
 
+func (s *debtService) WithContext(ctx context.Context) DebtService {
+	db := s.db.WithContext(ctx)
+	return &debtService{
+		debtRepo:        s.debtRepo.WithTx(db),
+		transactionRepo: s.transactionRepo.WithTx(db),
+		walletRepo:      s.walletRepo.WithTx(db),
+		db:              db,
+	}
+}
+
 func (s *debtService) UpdateDebt(id uint, userID uint, input UpdateDebtInput) (*entity.Debt, error) {
 	tx := s.db.Begin()
 	defer func() {
@@ -137,6 +148,8 @@ type DebtService interface {
 	UpdateDebt(id uint, userID uint, input UpdateDebtInput) (*entity.Debt, error)
 	DeleteDebt(id uint, userID uint) error
 	DeletePayment(id uint, userID uint) error
+	// WithContext: salinan service yang mencatat actor dari ctx pada audit utang dan pembayarannya.
+	WithContext(ctx context.Context) DebtService
 }
 
 type debtService struct {
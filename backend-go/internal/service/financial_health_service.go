@@ -65,34 +65,7 @@ func (s *financialHealthService) GetFinancialHealth(userID uint) (entity.Financi
 		totalExpenseMonth += item.Expense
 	}
 
-	savingsRate := 0.0
-	if totalIncomeMonth > 0 {
-		savingsRate = (totalIncomeMonth - totalExpenseMonth) / totalIncomeMonth
-		// PERBAIKAN: Batasi persentase minimal di -100% agar tidak muncul -9340%
-		if savingsRate < -1.0 {
-			savingsRate = -1.0
-		}
-	} else if totalExpenseMonth > 0 {
-		savingsRate = -1.0
-	}
-
-	savingsRatio := entity.FinancialHealthRatio{
-		Name:           "Rasio Tabungan",
-		Value:          savingsRate,
-		Target:         "> 20%",
-		FormattedValue: fmt.Sprintf("%.1f%%", savingsRate*100),
-	}
-
-	if savingsRate >= 0.20 {
-		savingsRatio.Status = entity.StatusHealthy
-		savingsRatio.Description = "Hebat! Anda menabung dengan porsi yang sehat."
-	} else if savingsRate >= 0.10 {
-		savingsRatio.Status = entity.StatusWarning
-		savingsRatio.Description = "Cukup baik, tapi coba tingkatkan lagi tabungan Anda."
-	} else {
-		savingsRatio.Status = entity.StatusDanger
-		savingsRatio.Description = "Hati-hati, pengeluaran Anda melebihi pendapatan di siklus ini."
-	}
+	savingsRatio := newSavingsRatio(savingsRate(totalIncomeMonth, totalExpenseMonth))
 
 	// 2. LIQUIDITY RATIO & TOTAL ASSETS
 	wallets, err := s.walletRepo.FindByUserID(userID)
@@ -254,3 +227,40 @@ func (s *financialHealthService) GetFinancialHealth(userID uint) (entity.Financi
 		},
 	}, nil
 }
+
+// savingsRate adalah (pemasukan - pengeluaran) / pemasukan, dibatasi minimal -100%.
+func savingsRate(income, expense float64) float64 {
+	rate := 0.0
+	if income > 0 {
+		rate = (income - expense) / income
+		// PERBAIKAN: Batasi persentase minimal di -100% agar tidak muncul -9340%
+		if rate < -1.0 {
+			rate = -1.0
+		}
+	} else if expense > 0 {
+		rate = -1.0
+	}
+	return rate
+}
+
+// newSavingsRatio menilai rasio tabungan dengan target yang sama untuk kesehatan keuangan dan insight.
+func newSavingsRatio(rate float64) entity.FinancialHealthRatio {
+	ratio := entity.FinancialHealthRatio{
+		Name:           "Rasio Tabungan",
+		Value:          rate,
+		Target:         "> 20%",
+		FormattedValue: fmt.Sprintf("%.1f%%", rate*100),
+	}
+
+	if rate >= 0.20 {
+		ratio.Status = entity.StatusHealthy
+		ratio.Description = "Hebat! Anda menabung dengan porsi yang sehat."
+	} else if rate >= 0.10 {
+		ratio.Status = entity.StatusWarning
+		ratio.Description = "Cukup baik, tapi coba tingkatkan lagi tabungan Anda."
+	} else {
+		ratio.Status = entity.StatusDanger
+		ratio.Description = "Hati-hati, pengeluaran Anda melebihi pendapatan di siklus ini."
+	}
+	return ratio
+}
@@ -0,0 +1,522 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/provider/whatsapp"
+	"cuan-backend/internal/repository"
+	pkgutils "cuan-backend/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// InsightCatchUpDays adalah berapa hari setelah siklus baru dimulai insight siklus lalu
+	// masih dibuat, untuk menutup hari saat server mati.
+	InsightCatchUpDays = 3
+
+	// InsightMinIncrease dan InsightMinIncreasePercent adalah kenaikan pengeluaran kategori
+	// minimal (rupiah dan persen) yang layak dijadikan insight.
+	InsightMinIncrease        = 50000
+	InsightMinIncreasePercent = 20
+
+	// InsightTopCategories adalah jumlah kenaikan kategori terbesar yang ditampilkan.
+	InsightTopCategories = 3
+
+	// InsightMaxDebts adalah jumlah utang/piutang jatuh tempo yang ditampilkan.
+	InsightMaxDebts = 5
+)
+
+var ErrInsightNotFound = errors.New("insight tidak ditemukan")
+
+// InsightWriter merangkai fakta insight menjadi kalimat, satu per fakta dengan urutan yang sama.
+type InsightWriter interface {
+	PhraseInsights(userID uint, facts []entity.InsightFact) ([]string, error)
+}
+
+type InsightService interface {
+	GetFeed(userID uint, unreadOnly bool, limit int) (*entity.InsightFeed, error)
+	MarkRead(id uint, userID uint) error
+	MarkAllRead(userID uint) error
+	// Generate membuat insight untuk siklus tagihan terakhir yang sudah selesai. Siklus yang
+	// sudah punya insight tidak dibuat ulang.
+	Generate(userID uint, now time.Time) ([]entity.Insight, error)
+	RunDue(now time.Time)
+}
+
+type insightService struct {
+	insightRepo     repository.InsightRepository
+	userRepo        repository.UserRepository
+	transactionRepo repository.TransactionRepository
+	savingGoalRepo  repository.SavingGoalRepository
+	debtRepo        repository.DebtRepository
+	scheduleRepo    repository.ReportScheduleRepository
+	writer          InsightWriter
+	waClient        whatsapp.Client
+}
+
+func NewInsightService(
+	insightRepo repository.InsightRepository,
+	userRepo repository.UserRepository,
+	transactionRepo repository.TransactionRepository,
+	savingGoalRepo repository.SavingGoalRepository,
+	debtRepo repository.DebtRepository,
+	scheduleRepo repository.ReportScheduleRepository,
+	writer InsightWriter,
+	waClient whatsapp.Client,
+) InsightService {
+	return &insightService{
+		insightRepo:     insightRepo,
+		userRepo:        userRepo,
+		transactionRepo: transactionRepo,
+		savingGoalRepo:  savingGoalRepo,
+		debtRepo:        debtRepo,
+		scheduleRepo:    scheduleRepo,
+		writer:          writer,
+		waClient:        waClient,
+	}
+}
+
+func (s *insightService) GetFeed(userID uint, unreadOnly bool, limit int) (*entity.InsightFeed, error) {
+	insights, err := s.insightRepo.FindByUserID(userID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.insightRepo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.InsightFeed{Insights: insights, Unread: unread}, nil
+}
+
+func (s *insightService) MarkRead(id uint, userID uint) error {
+	err := s.insightRepo.MarkRead(id, userID, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInsightNotFound
+	}
+	return err
+}
+
+func (s *insightService) MarkAllRead(userID uint) error {
+	return s.insightRepo.MarkAllRead(userID, time.Now())
+}
+
+// insightCycles mengembalikan siklus yang baru selesai, siklus sebelumnya sebagai pembanding,
+// dan siklus yang sedang berjalan.
+func insightCycles(now time.Time, payday int) (closed, previous, current [2]time.Time) {
+	current[0], current[1] = pkgutils.GetBillingCycle(now, payday)
+	closed[0], closed[1] = pkgutils.GetBillingCycle(current[0].AddDate(0, 0, -1), payday)
+	previous[0], previous[1] = pkgutils.GetBillingCycle(closed[0].AddDate(0, 0, -1), payday)
+	return closed, previous, current
+}
+
+// isInsightDue true di hari-hari awal siklus baru, mulai jam DigestSendHour.
+func isInsightDue(now time.Time, payday int) bool {
+	if now.Hour() < DigestSendHour {
+		return false
+	}
+	start, _ := pkgutils.GetBillingCycle(now, payday)
+	return now.Sub(start) < InsightCatchUpDays*24*time.Hour
+}
+
+func (s *insightService) Generate(userID uint, now time.Time) ([]entity.Insight, error) {
+	payday := 1
+	if user, err := s.userRepo.FindByID(userID); err == nil && user.Payday != nil {
+		payday = *user.Payday
+	}
+
+	closed, previous, current := insightCycles(now, payday)
+	periodStart := closed[0].Format("2006-01-02")
+	periodEnd := closed[1].Format("2006-01-02")
+
+	exists, err := s.insightRepo.HasPeriod(userID, periodStart)
+	if err != nil || exists {
+		return nil, err
+	}
+
+	input := insightInput{PeriodStart: closed[0], PeriodEnd: closed[1], NextEnd: current[1]}
+	input.Current, err = s.transactionRepo.GetCategoryBreakdown(userID, periodStart, periodEnd+" 23:59:59", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil laporan siklus: %w", err)
+	}
+	input.Previous, err = s.transactionRepo.GetCategoryBreakdown(userID, previous[0].Format("2006-01-02"), previous[1].Format("2006-01-02")+" 23:59:59", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil laporan siklus sebelumnya: %w", err)
+	}
+	if s.savingGoalRepo != nil {
+		if input.Goals, err = s.savingGoalRepo.FindAll(userID); err != nil {
+			log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal mengambil target menabung untuk insight")
+		}
+	}
+	if s.debtRepo != nil {
+		if input.Debts, err = s.debtRepo.FindByUserID(userID, ""); err != nil {
+			log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal mengambil utang untuk insight")
+		}
+	}
+
+	facts := buildInsightFacts(input)
+	if len(facts) == 0 {
+		return nil, nil
+	}
+
+	messages := s.phrase(userID, facts)
+	insights := make([]entity.Insight, len(facts))
+	for i, f := range facts {
+		data, _ := json.Marshal(f)
+		insights[i] = entity.Insight{
+			UserID:      userID,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+			Kind:        f.Kind,
+			Subject:     f.Subject,
+			Severity:    f.Severity,
+			Message:     messages[i],
+			Fact:        entity.JSONText(data),
+		}
+	}
+	// HasPeriod di atas hanya jalan pintas; dua run yang bersamaan tetap dicegah oleh
+	// idx_insight_fact. Run yang kalah tidak menyisipkan apa pun dan tidak mengirim ulang.
+	created, err := s.insightRepo.CreateBatch(insights)
+	if err != nil {
+		return nil, err
+	}
+	if created == 0 {
+		return nil, nil
+	}
+
+	log.Info().Uint("user_id", userID).Str("period_start", periodStart).Int("count", len(insights)).Msg("Insights generated")
+	return insights, nil
+}
+
+// phrase memakai kalimat dari LLM jika tersedia, selain itu Summary deterministik tiap fakta.
+func (s *insightService) phrase(userID uint, facts []entity.InsightFact) []string {
+	messages := make([]string, len(facts))
+	for i, f := range facts {
+		messages[i] = f.Summary
+	}
+	if s.writer == nil {
+		return messages
+	}
+	phrased, err := s.writer.PhraseInsights(userID, facts)
+	if err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal merangkai insight, memakai ringkasan fakta")
+		return messages
+	}
+	for i, text := range phrased {
+		if i < len(messages) && text != "" {
+			messages[i] = text
+		}
+	}
+	return messages
+}
+
+// RunDue membuat insight siklus lalu untuk semua user yang siklusnya baru berganti, lalu
+// mengirimnya ke WhatsApp bagi yang mengaktifkan push_insights. Dipanggil oleh scheduler.
+func (s *insightService) RunDue(now time.Time) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now = now.In(wib)
+
+	users, err := s.userRepo.FindAll()
+	if err != nil {
+		return
+	}
+
+	for i := range users {
+		user := users[i]
+		payday := 1
+		if user.Payday != nil {
+			payday = *user.Payday
+		}
+		if !isInsightDue(now, payday) {
+			continue
+		}
+
+		insights, err := s.Generate(user.ID, now)
+		if err != nil {
+			log.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to generate insights")
+			continue
+		}
+		if len(insights) == 0 {
+			continue
+		}
+
+		if err := s.push(&user, insights); err != nil {
+			log.Warn().Err(err).Uint("user_id", user.ID).Msg("Failed to push insights")
+		}
+	}
+}
+
+func (s *insightService) push(user *entity.User, insights []entity.Insight) error {
+	if s.scheduleRepo == nil {
+		return nil
+	}
+	schedule, err := s.scheduleRepo.FindByUserID(user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !schedule.PushInsights {
+		return nil
+	}
+	if s.waClient == nil {
+		return errors.New("wa-gateway belum dikonfigurasi")
+	}
+	if user.Phone == nil || *user.Phone == "" {
+		return errors.New("nomor WhatsApp belum diisi di profil")
+	}
+	return s.waClient.SendMessage(*user.Phone+"@s.whatsapp.net", FormatInsightText(insights))
+}
+
+var insightIcons = map[string]string{
+	entity.InsightPositive: "✅",
+	entity.InsightInfo:     "💡",
+	entity.InsightWarning:  "⚠️",
+}
+
+// FormatInsightText menyusun insight satu siklus menjadi teks yang ramah WhatsApp.
+func FormatInsightText(insights []entity.Insight) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💡 *Insight Keuangan %s s/d %s*\n", insights[0].PeriodStart, insights[0].PeriodEnd))
+	for _, in := range insights {
+		sb.WriteString(fmt.Sprintf("\n%s %s", insightIcons[in.Severity], in.Message))
+	}
+	return sb.String()
+}
+
+// insightInput adalah data mentah siklus yang baru selesai untuk buildInsightFacts.
+type insightInput struct {
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	NextEnd     time.Time // akhir siklus berjalan: batas utang yang dianggap segera jatuh tempo
+	Current     []entity.CategoryBreakdown
+	Previous    []entity.CategoryBreakdown
+	Goals       []entity.SavingGoal
+	Debts       []entity.Debt
+}
+
+// buildInsightFacts menghitung fakta insight tanpa LLM: rasio tabungan, kenaikan kategori
+// terbesar, laju target menabung, dan utang/piutang yang jatuh tempo.
+func buildInsightFacts(in insightInput) []entity.InsightFact {
+	var facts []entity.InsightFact
+	if f, ok := savingsRateFact(in); ok {
+		facts = append(facts, f)
+	}
+	facts = append(facts, categoryIncreaseFacts(in)...)
+	facts = append(facts, goalPaceFacts(in)...)
+	facts = append(facts, debtDueFacts(in)...)
+	return facts
+}
+
+func cycleTotals(items []entity.CategoryBreakdown) (income, expense float64) {
+	for _, item := range items {
+		switch item.Type {
+		case "income":
+			income += item.TotalAmount
+		case "expense":
+			expense += item.TotalAmount
+		}
+	}
+	return income, expense
+}
+
+// savingsRateFact membandingkan rasio tabungan siklus dengan siklus sebelumnya, memakai target
+// dan status yang sama dengan rasio tabungan di FinancialHealthResponse.
+func savingsRateFact(in insightInput) (entity.InsightFact, bool) {
+	income, expense := cycleTotals(in.Current)
+	if income == 0 && expense == 0 {
+		return entity.InsightFact{}, false
+	}
+	ratio := newSavingsRatio(savingsRate(income, expense))
+
+	f := entity.InsightFact{
+		Kind:    entity.InsightSavingsRate,
+		Current: ratio.Value,
+		Target:  0.20,
+		Status:  string(ratio.Status),
+	}
+	switch ratio.Status {
+	case entity.StatusHealthy:
+		f.Severity = entity.InsightPositive
+	case entity.StatusWarning:
+		f.Severity = entity.InsightInfo
+	default:
+		f.Severity = entity.InsightWarning
+	}
+	f.Summary = fmt.Sprintf("Rasio tabungan siklus ini %s (target %s, status %s)", ratio.FormattedValue, ratio.Target, ratio.Status)
+
+	prevIncome, prevExpense := cycleTotals(in.Previous)
+	if prevIncome > 0 || prevExpense > 0 {
+		f.Previous = savingsRate(prevIncome, prevExpense)
+		f.Change = math.Round((f.Current-f.Previous)*1000) / 10
+		direction := "naik"
+		if f.Change < 0 {
+			direction = "turun"
+		}
+		if f.Change == 0 {
+			f.Summary += ", sama dengan siklus sebelumnya"
+		} else {
+			f.Summary += fmt.Sprintf(", %s %.1f poin dari %.1f%% di siklus sebelumnya", direction, math.Abs(f.Change), f.Previous*100)
+		}
+	}
+	f.Summary += "."
+	return f, true
+}
+
+func categoryIncreaseFacts(in insightInput) []entity.InsightFact {
+	previous := map[string]float64{}
+	for _, item := range in.Previous {
+		if item.Type == "expense" {
+			previous[item.CategoryName] += item.TotalAmount
+		}
+	}
+
+	var facts []entity.InsightFact
+	for _, item := range in.Current {
+		if item.Type != "expense" {
+			continue
+		}
+		prev := previous[item.CategoryName]
+		increase := item.TotalAmount - prev
+		if increase < InsightMinIncrease {
+			continue
+		}
+		f := entity.InsightFact{
+			Kind:     entity.InsightCategoryIncrease,
+			Subject:  item.CategoryName,
+			Severity: entity.InsightInfo,
+			Current:  item.TotalAmount,
+			Previous: prev,
+			Target:   item.BudgetLimit,
+		}
+		if prev > 0 {
+			f.Change = math.Round(increase / prev * 100)
+			if f.Change < InsightMinIncreasePercent {
+				continue
+			}
+			f.Summary = fmt.Sprintf("Pengeluaran %s naik %s (%.0f%%) menjadi %s, dari %s di siklus sebelumnya.",
+				item.CategoryName, formatRupiah(increase), f.Change, formatRupiah(item.TotalAmount), formatRupiah(prev))
+		} else {
+			f.Summary = fmt.Sprintf("Ada pengeluaran %s sebesar %s, padahal siklus sebelumnya tidak ada.",
+				item.CategoryName, formatRupiah(item.TotalAmount))
+		}
+		if item.IsOverBudget {
+			f.Severity = entity.InsightWarning
+			f.Summary = strings.TrimSuffix(f.Summary, ".") + fmt.Sprintf(" dan melewati budget %s.", formatRupiah(item.BudgetLimit))
+		}
+		facts = append(facts, f)
+	}
+
+	sort.SliceStable(facts, func(i, j int) bool {
+		return facts[i].Current-facts[i].Previous > facts[j].Current-facts[j].Previous
+	})
+	if len(facts) > InsightTopCategories {
+		facts = facts[:InsightTopCategories]
+	}
+	return facts
+}
+
+// goalPaceFacts membandingkan setoran tiap target menabung di siklus ini dengan setoran per
+// siklus yang dibutuhkan agar tercapai sebelum tenggat.
+func goalPaceFacts(in insightInput) []entity.InsightFact {
+	var facts []entity.InsightFact
+	for _, g := range in.Goals {
+		remaining := g.TargetAmount - g.CurrentAmount
+		if g.IsAchieved || g.IsFinished || g.Deadline == nil || remaining <= 0 {
+			continue
+		}
+
+		contributed := 0.0
+		for _, c := range g.Contributions {
+			if !c.Date.Before(in.PeriodStart) && !c.Date.After(in.PeriodEnd) {
+				contributed += c.Amount
+			}
+		}
+
+		deadline := g.Deadline.Format("2006-01-02")
+		f := entity.InsightFact{
+			Kind:     entity.InsightGoalPace,
+			Subject:  g.Name,
+			Severity: entity.InsightWarning,
+			Current:  contributed,
+			DueDate:  deadline,
+		}
+		if g.Deadline.Before(in.PeriodEnd) {
+			f.Target = remaining
+			f.Summary = fmt.Sprintf("Target %s sudah lewat tenggat %s dan masih kurang %s.", g.Name, deadline, formatRupiah(remaining))
+			facts = append(facts, f)
+			continue
+		}
+
+		f.Target = math.Ceil(remaining / float64(cyclesUntil(in.PeriodEnd, *g.Deadline)))
+		if contributed >= f.Target {
+			f.Severity = entity.InsightPositive
+			f.Summary = fmt.Sprintf("Target %s on track: disetor %s siklus ini, di atas kebutuhan %s per siklus untuk tenggat %s.",
+				g.Name, formatRupiah(contributed), formatRupiah(f.Target), deadline)
+		} else {
+			f.Summary = fmt.Sprintf("Target %s tertinggal: disetor %s siklus ini, butuh %s per siklus agar sisa %s tercapai sebelum %s.",
+				g.Name, formatRupiah(contributed), formatRupiah(f.Target), formatRupiah(remaining), deadline)
+		}
+		facts = append(facts, f)
+	}
+	return facts
+}
+
+// cyclesUntil menghitung sisa siklus (bulan) dari from sampai to, dibulatkan ke atas dan minimal 1.
+func cyclesUntil(from, to time.Time) int {
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	if to.Day() > from.Day() {
+		months++
+	}
+	if months < 1 {
+		return 1
+	}
+	return months
+}
+
+func debtDueFacts(in insightInput) []entity.InsightFact {
+	debts := make([]entity.Debt, 0, len(in.Debts))
+	for _, d := range in.Debts {
+		if !d.IsPaid && d.DueDate != nil && !d.DueDate.After(in.NextEnd) {
+			debts = append(debts, d)
+		}
+	}
+	sort.SliceStable(debts, func(i, j int) bool {
+		return debts[i].DueDate.Before(*debts[j].DueDate)
+	})
+	if len(debts) > InsightMaxDebts {
+		debts = debts[:InsightMaxDebts]
+	}
+
+	facts := make([]entity.InsightFact, 0, len(debts))
+	for _, d := range debts {
+		label := "Utang"
+		if d.Type == entity.DebtTypeReceivable {
+			label = "Piutang"
+		}
+		due := d.DueDate.Format("2006-01-02")
+		f := entity.InsightFact{
+			Kind:     entity.InsightDebtDue,
+			Subject:  d.Name,
+			Severity: entity.InsightInfo,
+			Current:  d.Remaining,
+			DueDate:  due,
+		}
+		if d.DueDate.Before(in.PeriodEnd) {
+			f.Severity = entity.InsightWarning
+			f.Summary = fmt.Sprintf("%s %s sisa %s sudah lewat jatuh tempo %s.", label, d.Name, formatRupiah(d.Remaining), due)
+		} else {
+			f.Summary = fmt.Sprintf("%s %s sisa %s jatuh tempo %s.", label, d.Name, formatRupiah(d.Remaining), due)
+		}
+		facts = append(facts, f)
+	}
+	return facts
+}
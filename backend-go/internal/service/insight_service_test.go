@@ -0,0 +1,155 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type stubInsightWriter struct {
+	lines []string
+	err   error
+}
+
+func (w *stubInsightWriter) PhraseInsights(userID uint, facts []entity.InsightFact) ([]string, error) {
+	return w.lines, w.err
+}
+
+func TestInsightCycles(t *testing.T) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now := time.Date(2026, 10, 25, 8, 0, 0, 0, wib)
+
+	closed, previous, current := insightCycles(now, 25)
+	assert.Equal(t, "2026-09-25", closed[0].Format("2006-01-02"))
+	assert.Equal(t, "2026-10-24", closed[1].Format("2006-01-02"))
+	assert.Equal(t, "2026-08-25", previous[0].Format("2006-01-02"))
+	assert.Equal(t, "2026-11-24", current[1].Format("2006-01-02"))
+
+	assert.True(t, isInsightDue(now, 25))
+	assert.False(t, isInsightDue(now.Add(-2*time.Hour), 25), "before send hour")
+	assert.True(t, isInsightDue(now.AddDate(0, 0, 2), 25))
+	assert.False(t, isInsightDue(now.AddDate(0, 0, 3), 25), "catch-up window over")
+}
+
+func TestBuildInsightFacts(t *testing.T) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	start := time.Date(2026, 9, 25, 0, 0, 0, 0, wib)
+	end := time.Date(2026, 10, 24, 23, 59, 59, 0, wib)
+	deadline := time.Date(2027, 1, 24, 0, 0, 0, 0, wib)
+	dueSoon := time.Date(2026, 11, 1, 0, 0, 0, 0, wib)
+	overdue := time.Date(2026, 10, 10, 0, 0, 0, 0, wib)
+	dueLater := time.Date(2027, 3, 1, 0, 0, 0, 0, wib)
+
+	facts := buildInsightFacts(insightInput{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		NextEnd:     time.Date(2026, 11, 24, 23, 59, 59, 0, wib),
+		Current: []entity.CategoryBreakdown{
+			{CategoryName: "Gaji", Type: "income", TotalAmount: 10000000},
+			{CategoryName: "Makan", Type: "expense", TotalAmount: 3000000, BudgetLimit: 2500000, IsOverBudget: true},
+			{CategoryName: "Transport", Type: "expense", TotalAmount: 1030000},
+			{CategoryName: "Hiburan", Type: "expense", TotalAmount: 500000},
+		},
+		Previous: []entity.CategoryBreakdown{
+			{CategoryName: "Gaji", Type: "income", TotalAmount: 10000000},
+			{CategoryName: "Makan", Type: "expense", TotalAmount: 2000000},
+			{CategoryName: "Transport", Type: "expense", TotalAmount: 1000000},
+		},
+		Goals: []entity.SavingGoal{
+			{Name: "Liburan", TargetAmount: 9000000, CurrentAmount: 3000000, Deadline: &deadline, Contributions: []entity.SavingContribution{
+				{Amount: 1000000, Date: start.AddDate(0, 0, 5)},
+				{Amount: 500000, Date: start.AddDate(0, 0, -5)},
+			}},
+			{Name: "Laptop", TargetAmount: 5000000, CurrentAmount: 5000000, Deadline: &deadline},
+		},
+		Debts: []entity.Debt{
+			{Name: "Budi", Type: entity.DebtTypeReceivable, Remaining: 200000, DueDate: &dueSoon},
+			{Name: "Paylater", Type: entity.DebtTypePayable, Remaining: 750000, DueDate: &overdue},
+			{Name: "KPR", Type: entity.DebtTypePayable, Remaining: 1000000, DueDate: &dueLater},
+			{Name: "Lunas", Type: entity.DebtTypePayable, IsPaid: true, DueDate: &overdue},
+		},
+	})
+
+	require.Len(t, facts, 6)
+
+	// Rasio tabungan 54.7% vs 70% siklus lalu, dinilai dengan target FinancialHealth.
+	assert.Equal(t, entity.InsightSavingsRate, facts[0].Kind)
+	assert.Equal(t, string(entity.StatusHealthy), facts[0].Status)
+	assert.Equal(t, -15.3, facts[0].Change)
+	assert.Equal(t, "Rasio tabungan siklus ini 54.7% (target > 20%, status Sehat), turun 15.3 poin dari 70.0% di siklus sebelumnya.", facts[0].Summary)
+
+	// Transport hanya naik 3% dan di bawah Rp50.000; Hiburan kategori baru.
+	assert.Equal(t, entity.InsightCategoryIncrease, facts[1].Kind)
+	assert.Equal(t, "Makan", facts[1].Subject)
+	assert.Equal(t, entity.InsightWarning, facts[1].Severity)
+	assert.Equal(t, 50.0, facts[1].Change)
+	assert.Equal(t, "Pengeluaran Makan naik Rp1.000.000 (50%) menjadi Rp3.000.000, dari Rp2.000.000 di siklus sebelumnya dan melewati budget Rp2.500.000.", facts[1].Summary)
+	assert.Equal(t, "Hiburan", facts[2].Subject)
+
+	// Sisa Rp6.000.000 dalam 3 siklus = Rp2.000.000 per siklus; baru disetor Rp1.000.000.
+	assert.Equal(t, entity.InsightGoalPace, facts[3].Kind)
+	assert.Equal(t, "Liburan", facts[3].Subject)
+	assert.Equal(t, 1000000.0, facts[3].Current)
+	assert.Equal(t, 2000000.0, facts[3].Target)
+	assert.Equal(t, entity.InsightWarning, facts[3].Severity)
+
+	assert.Equal(t, "Paylater", facts[4].Subject)
+	assert.Equal(t, entity.InsightWarning, facts[4].Severity)
+	assert.Equal(t, "Utang Paylater sisa Rp750.000 sudah lewat jatuh tempo 2026-10-10.", facts[4].Summary)
+	assert.Equal(t, "Piutang Budi sisa Rp200.000 jatuh tempo 2026-11-01.", facts[5].Summary)
+}
+
+func TestInsightService_PhraseFallsBackToSummary(t *testing.T) {
+	facts := []entity.InsightFact{{Summary: "Fakta satu."}, {Summary: "Fakta dua."}}
+
+	svc := &insightService{writer: &stubInsightWriter{err: errors.New("provider down")}}
+	assert.Equal(t, []string{"Fakta satu.", "Fakta dua."}, svc.phrase(1, facts))
+
+	svc.writer = &stubInsightWriter{lines: []string{"🎉 Kalimat satu.", ""}}
+	assert.Equal(t, []string{"🎉 Kalimat satu.", "Fakta dua."}, svc.phrase(1, facts))
+}
+
+// racingInsightRepo meniru run lain yang lolos HasPeriod sebelum batch pertama tersimpan.
+type racingInsightRepo struct{ repository.InsightRepository }
+
+func (r racingInsightRepo) HasPeriod(userID uint, periodStart string) (bool, error) {
+	return false, nil
+}
+
+func TestInsightService_GenerateConcurrentRunsInsertOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.Insight{}))
+
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now := time.Date(2026, 10, 3, 10, 0, 0, 0, wib)
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, wib)
+	userRepo := new(mockUserRepository)
+	userRepo.On("FindByID", uint(1)).Return(&entity.User{ID: 1}, nil)
+	debtRepo := new(mockDebtRepository)
+	debtRepo.On("FindByUserID", uint(1), "").Return([]entity.Debt{
+		{Name: "Paylater", Type: entity.DebtTypePayable, Remaining: 750000, DueDate: &due},
+	}, nil)
+
+	repo := racingInsightRepo{repository.NewInsightRepository(db)}
+	svc := NewInsightService(repo, userRepo, new(mockTransactionRepository), nil, debtRepo, nil, nil, nil)
+
+	first, err := svc.Generate(1, now)
+	require.NoError(t, err)
+	assert.Len(t, first, 1)
+
+	second, err := svc.Generate(1, now)
+	require.NoError(t, err)
+	assert.Empty(t, second, "run yang kalah tidak boleh mengirim insight lagi")
+
+	var count int64
+	require.NoError(t, db.Model(&entity.Insight{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// IntentClassifier menentukan intent (bisa lebih dari satu) dari pesan user. Set kosong
+// berarti classifier tidak yakin; DetectIntents menjadikannya IntentGeneral.
+type IntentClassifier interface {
+	Classify(message string) IntentSet
+}
+
+// IntentExample adalah satu contoh data latih. Intents kosong berarti pesan umum yang bukan
+// milik intent mana pun.
+type IntentExample struct {
+	Text    string
+	Intents []ContextIntent
+}
+
+// intentThreshold: probabilitas minimal agar sebuah intent ikut dipilih.
+const intentThreshold = 0.5
+
+// Parameter latihan regresi logistik. Data latihnya kecil, jadi latihan penuh (batch) dengan
+// urutan tetap cukup cepat dan hasilnya selalu sama.
+const (
+	intentEpochs       = 300
+	intentLearningRate = 0.5
+	intentL2           = 0.001
+)
+
+// classifiedIntents adalah label yang dilatih; IntentGeneral adalah fallback, bukan label.
+var classifiedIntents = []ContextIntent{
+	IntentTransaction, IntentDebt, IntentReport, IntentGoal, IntentHealth, IntentSmallTalk,
+}
+
+// LogisticIntentClassifier adalah regresi logistik one-vs-rest per intent di atas kata utuh dan
+// bigram, sehingga "hi" tidak lagi cocok di dalam "hiburan". Setiap intent diputuskan sendiri,
+// jadi satu pesan bisa punya beberapa intent. Kata yang tidak pernah dilihat saat latihan tidak
+// dipakai, sehingga pesan asing jatuh ke IntentGeneral.
+type LogisticIntentClassifier struct {
+	vocab  map[string]int // fitur -> indeks bobot
+	models map[ContextIntent]*intentModel
+}
+
+type intentModel struct {
+	bias    float64
+	weights []float64
+}
+
+// NewLogisticIntentClassifier melatih classifier dari examples. Contoh positif diberi bobot
+// sebanding jumlah contoh negatif agar intent yang contohnya sedikit tetap terdeteksi.
+func NewLogisticIntentClassifier(examples []IntentExample) *LogisticIntentClassifier {
+	c := &LogisticIntentClassifier{vocab: map[string]int{}, models: map[ContextIntent]*intentModel{}}
+	features := make([][]int, len(examples))
+	labels := make([]IntentSet, len(examples))
+	for i, ex := range examples {
+		for _, f := range uniqueFeatures(intentFeatures(ex.Text)) {
+			idx, ok := c.vocab[f]
+			if !ok {
+				idx = len(c.vocab)
+				c.vocab[f] = idx
+			}
+			features[i] = append(features[i], idx)
+		}
+		labels[i] = NewIntentSet(ex.Intents...)
+	}
+
+	n := float64(len(examples))
+	grads := make([]float64, len(c.vocab))
+	for _, intent := range classifiedIntents {
+		positives := 0
+		for _, l := range labels {
+			if l.Has(intent) {
+				positives++
+			}
+		}
+		posWeight := 1.0
+		if positives > 0 {
+			posWeight = float64(len(examples)-positives) / float64(positives)
+		}
+
+		m := &intentModel{weights: make([]float64, len(c.vocab))}
+		for epoch := 0; epoch < intentEpochs; epoch++ {
+			biasGrad := 0.0
+			for f := range grads {
+				grads[f] = 0
+			}
+			for i, feats := range features {
+				y, w := 0.0, 1.0
+				if labels[i].Has(intent) {
+					y, w = 1, posWeight
+				}
+				diff := w * (m.score(feats) - y)
+				biasGrad += diff
+				for _, f := range feats {
+					grads[f] += diff
+				}
+			}
+			m.bias -= intentLearningRate * biasGrad / n
+			for f, g := range grads {
+				m.weights[f] -= intentLearningRate * (g/n + intentL2*m.weights[f])
+			}
+		}
+		c.models[intent] = m
+	}
+	return c
+}
+
+// Classify mengembalikan semua intent dengan probabilitas minimal intentThreshold. Pesan tanpa
+// satu pun kata yang dikenal menghasilkan set kosong.
+func (c *LogisticIntentClassifier) Classify(message string) IntentSet {
+	var feats []int
+	for _, f := range uniqueFeatures(intentFeatures(message)) {
+		if idx, ok := c.vocab[f]; ok {
+			feats = append(feats, idx)
+		}
+	}
+	if len(feats) == 0 {
+		return 0
+	}
+	var set IntentSet
+	for _, intent := range classifiedIntents {
+		if c.models[intent].score(feats) >= intentThreshold {
+			set |= NewIntentSet(intent)
+		}
+	}
+	return set
+}
+
+func (m *intentModel) score(features []int) float64 {
+	z := m.bias
+	for _, f := range features {
+		z += m.weights[f]
+	}
+	return 1 / (1 + math.Exp(-z))
+}
+
+func uniqueFeatures(features []string) []string {
+	seen := make(map[string]bool, len(features))
+	out := features[:0]
+	for _, f := range features {
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// intentFeatures memecah pesan menjadi kata utuh dan bigram. Angka dan nominal ("15rb", "2jt",
+// "100.000") dinormalisasi menjadi satu token supaya model belajar polanya, bukan nilainya.
+func intentFeatures(message string) []string {
+	words := strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+	tokens := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.Trim(w, ".,")
+		if w == "" {
+			continue
+		}
+		if unicode.IsDigit(rune(w[0])) {
+			w = "<angka>"
+		}
+		tokens = append(tokens, w)
+	}
+
+	features := make([]string, 0, 2*len(tokens))
+	features = append(features, tokens...)
+	for i := 1; i < len(tokens); i++ {
+		features = append(features, tokens[i-1]+"_"+tokens[i])
+	}
+	return features
+}
+
+var (
+	defaultIntentClassifierOnce sync.Once
+	defaultIntentClassifier     *LogisticIntentClassifier
+)
+
+// DefaultIntentClassifier mengembalikan classifier yang dilatih dari intentTrainingData. Model
+// dilatih sekali saat pertama dipakai.
+func DefaultIntentClassifier() IntentClassifier {
+	defaultIntentClassifierOnce.Do(func() {
+		defaultIntentClassifier = NewLogisticIntentClassifier(intentTrainingData)
+	})
+	return defaultIntentClassifier
+}
+
+// intentTrainingData adalah data latih classifier bawaan. Tambahkan contoh di sini jika ada pesan
+// yang salah diklasifikasi, lalu jalankan TestDefaultIntentClassifier.
+var intentTrainingData = []IntentExample{
+	// Transaksi
+	{"beli kopi 15rb", []ContextIntent{IntentTransaction}},
+	{"makan siang 25000 pakai gopay", []ContextIntent{IntentTransaction}},
+	{"catat pengeluaran bensin 50rb", []ContextIntent{IntentTransaction}},
+	{"bayar listrik 350rb dari bca", []ContextIntent{IntentTransaction}},
+	{"bayar tagihan air pdam 120rb", []ContextIntent{IntentTransaction}},
+	{"gajian masuk 8jt ke mandiri", []ContextIntent{IntentTransaction}},
+	{"terima gaji 5 juta", []ContextIntent{IntentTransaction}},
+	{"transfer 500rb dari bca ke dana", []ContextIntent{IntentTransaction}},
+	{"topup gopay 100rb", []ContextIntent{IntentTransaction}},
+	{"top up ovo 50rb pakai bca", []ContextIntent{IntentTransaction}},
+	{"isi pulsa 20rb", []ContextIntent{IntentTransaction}},
+	{"jajan bakso 15 ribu", []ContextIntent{IntentTransaction}},
+	{"belanja bulanan di indomaret 230rb", []ContextIntent{IntentTransaction}},
+	{"parkir 5rb", []ContextIntent{IntentTransaction}},
+	{"ongkos grab ke kantor 32rb", []ContextIntent{IntentTransaction}},
+	{"hapus transaksi terakhir", []ContextIntent{IntentTransaction}},
+	{"ubah transaksi kopi tadi jadi 18rb", []ContextIntent{IntentTransaction}},
+	{"tadi salah catat, harusnya 40rb", []ContextIntent{IntentTransaction}},
+	{"kemarin beli sepatu 450rb pakai kartu kredit", []ContextIntent{IntentTransaction}},
+	{"dapat bonus 1jt", []ContextIntent{IntentTransaction}},
+	{"saldo bca berapa", []ContextIntent{IntentTransaction}},
+	{"sisa saldo dompet tunai", []ContextIntent{IntentTransaction}},
+	{"uang di gopay tinggal berapa", []ContextIntent{IntentTransaction}},
+	{"bayar netflix 54rb debit jenius", []ContextIntent{IntentTransaction}},
+	{"beli tiket bioskop buat hiburan 60rb", []ContextIntent{IntentTransaction}},
+	{"ngopi di kairos 38rb", []ContextIntent{IntentTransaction}},
+	{"jual hp lama dapat 1,5jt", []ContextIntent{IntentTransaction}},
+
+	// Utang / piutang
+	{"utang saya ke siapa saja", []ContextIntent{IntentDebt}},
+	{"daftar utang yang belum lunas", []ContextIntent{IntentDebt}},
+	{"siapa yang masih punya piutang ke saya", []ContextIntent{IntentDebt}},
+	{"budi pinjam uang 300rb", []ContextIntent{IntentDebt}},
+	{"catat utang ke andi 1jt jatuh tempo bulan depan", []ContextIntent{IntentDebt}},
+	{"aku minjem ke kakak 2jt", []ContextIntent{IntentDebt}},
+	{"sisa hutang kpr berapa lagi", []ContextIntent{IntentDebt}},
+	{"pinjaman online saya sudah lunas belum", []ContextIntent{IntentDebt}},
+	{"tandai utang ke rina lunas", []ContextIntent{IntentDebt}},
+	{"ngutang di warung 50rb", []ContextIntent{IntentDebt}},
+	{"kapan jatuh tempo cicilan motor", []ContextIntent{IntentDebt}},
+	{"piutang yang belum dibayar", []ContextIntent{IntentDebt}},
+	{"tagih piutang ke dodi", []ContextIntent{IntentDebt}},
+	{"bayar utang ke budi 200rb", []ContextIntent{IntentDebt, IntentTransaction}},
+	{"cicil utang ke andi 500rb dari bca", []ContextIntent{IntentDebt, IntentTransaction}},
+	{"dodi bayar piutang 100rb masuk ke dana", []ContextIntent{IntentDebt, IntentTransaction}},
+	{"bayar cicilan pinjaman 750rb", []ContextIntent{IntentDebt, IntentTransaction}},
+
+	// Laporan
+	{"rekap pengeluaran bulan ini", []ContextIntent{IntentReport}},
+	{"laporan keuangan minggu ini", []ContextIntent{IntentReport}},
+	{"berapa total pengeluaran minggu ini", []ContextIntent{IntentReport}},
+	{"berapa habis buat makan bulan ini", []ContextIntent{IntentReport}},
+	{"pemasukan bulan ini berapa", []ContextIntent{IntentReport}},
+	{"statistik pengeluaran per kategori", []ContextIntent{IntentReport}},
+	{"ringkasan transaksi hari ini", []ContextIntent{IntentReport}},
+	{"analisis pengeluaran saya", []ContextIntent{IntentReport}},
+	{"analisa belanja bulan lalu", []ContextIntent{IntentReport}},
+	{"hari apa pengeluaran terbanyak bulan ini", []ContextIntent{IntentReport}},
+	{"kategori paling boros apa", []ContextIntent{IntentReport}},
+	{"bandingkan pengeluaran bulan ini dengan bulan lalu", []ContextIntent{IntentReport}},
+	{"pengeluaran hiburan bulan ini berapa", []ContextIntent{IntentReport}},
+	{"summary keuangan minggu lalu", []ContextIntent{IntentReport}},
+	{"rata rata pengeluaran harian", []ContextIntent{IntentReport}},
+	{"total jajan minggu ini", []ContextIntent{IntentReport}},
+	{"berapa kali saya beli kopi bulan lalu", []ContextIntent{IntentReport}},
+	{"kapan terakhir bayar listrik", []ContextIntent{IntentReport}},
+	{"sering banget jajan boba ya aku", []ContextIntent{IntentReport}},
+	{"pengeluaran grab bulan lalu berapa", []ContextIntent{IntentReport}},
+
+	// Target tabungan
+	{"progress tabungan saya", []ContextIntent{IntentGoal}},
+	{"target nabung buat liburan", []ContextIntent{IntentGoal}},
+	{"buat target tabungan beli laptop 15jt", []ContextIntent{IntentGoal}},
+	{"berapa lagi biar target rumah tercapai", []ContextIntent{IntentGoal}},
+	{"saving goal saya gimana", []ContextIntent{IntentGoal}},
+	{"impian beli motor kapan tercapai", []ContextIntent{IntentGoal}},
+	{"rencana nabung dana darurat", []ContextIntent{IntentGoal}},
+	{"tujuan keuangan saya apa saja", []ContextIntent{IntentGoal}},
+	{"nabung 500rb ke tabungan nikah", []ContextIntent{IntentGoal, IntentTransaction}},
+	{"setor 1jt ke target liburan", []ContextIntent{IntentGoal, IntentTransaction}},
+	{"tarik tabungan dana darurat 2jt", []ContextIntent{IntentGoal, IntentTransaction}},
+	{"daftar target yang sudah tercapai", []ContextIntent{IntentGoal}},
+
+	// Kesehatan keuangan
+	{"skor keuangan saya berapa", []ContextIntent{IntentHealth}},
+	{"bagaimana kesehatan keuangan saya", []ContextIntent{IntentHealth}},
+	{"kondisi keuangan saya sehat tidak", []ContextIntent{IntentHealth}},
+	{"evaluasi keuangan saya dong", []ContextIntent{IntentHealth}},
+	{"financial health score", []ContextIntent{IntentHealth}},
+	{"rasio utang saya aman tidak", []ContextIntent{IntentHealth, IntentDebt}},
+	{"dana darurat saya cukup berapa bulan", []ContextIntent{IntentHealth}},
+	{"apakah saya terlalu boros", []ContextIntent{IntentHealth, IntentReport}},
+	{"nilai keuangan saya bulan ini", []ContextIntent{IntentHealth}},
+	{"cara memperbaiki skor keuangan", []ContextIntent{IntentHealth}},
+	{"kesehatan keuangan aku gimana", []ContextIntent{IntentHealth}},
+	{"skor kesehatan finansial saya turun kenapa", []ContextIntent{IntentHealth}},
+
+	// Small talk
+	{"halo", []ContextIntent{IntentSmallTalk}},
+	{"hai", []ContextIntent{IntentSmallTalk}},
+	{"hi", []ContextIntent{IntentSmallTalk}},
+	{"hello", []ContextIntent{IntentSmallTalk}},
+	{"pagi", []ContextIntent{IntentSmallTalk}},
+	{"selamat pagi", []ContextIntent{IntentSmallTalk}},
+	{"selamat siang", []ContextIntent{IntentSmallTalk}},
+	{"selamat malam", []ContextIntent{IntentSmallTalk}},
+	{"apa kabar", []ContextIntent{IntentSmallTalk}},
+	{"terima kasih", []ContextIntent{IntentSmallTalk}},
+	{"makasih ya", []ContextIntent{IntentSmallTalk}},
+	{"thanks", []ContextIntent{IntentSmallTalk}},
+	{"oke siap", []ContextIntent{IntentSmallTalk}},
+	{"siapa kamu", []ContextIntent{IntentSmallTalk}},
+	{"kamu bisa apa", []ContextIntent{IntentSmallTalk}},
+	{"apa itu cuan bot", []ContextIntent{IntentSmallTalk}},
+	{"wkwk lucu", []ContextIntent{IntentSmallTalk}},
+	{"mantap", []ContextIntent{IntentSmallTalk}},
+
+	// Umum: butuh gambaran lengkap, bukan satu intent tertentu.
+	{"cek data keuangan saya", nil},
+	{"gimana keuangan saya", nil},
+	{"kasih saran keuangan dong", nil},
+	{"tolong bantu atur keuangan", nil},
+	{"apa yang harus saya lakukan dengan uang saya", nil},
+	{"ceritakan kondisi saya secara umum", nil},
+}
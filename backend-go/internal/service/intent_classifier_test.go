@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"cuan-backend/internal/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDefaultIntentClassifier(t *testing.T) {
+	classifier := DefaultIntentClassifier()
+	tests := []struct {
+		message string
+		want    IntentSet
+	}{
+		// Kata utuh, bukan substring: "hi" di "hiburan" dan "air" di "kairos".
+		{"pengeluaran hiburan bulan ini", NewIntentSet(IntentReport)},
+		{"ngopi di kairos 40rb", NewIntentSet(IntentTransaction)},
+		{"hi", NewIntentSet(IntentSmallTalk)},
+		{"halo apa kabar", NewIntentSet(IntentSmallTalk)},
+		// Small talk tidak mengalahkan pertanyaan keuangan.
+		{"halo, saldo bca berapa?", NewIntentSet(IntentTransaction)},
+		// Multi-label.
+		{"bayar utang ke budi 200rb", NewIntentSet(IntentTransaction, IntentDebt)},
+		{"nabung 300rb buat liburan", NewIntentSet(IntentTransaction, IntentGoal)},
+		{"beli bensin 30rb", NewIntentSet(IntentTransaction)},
+		{"utang ke sari masih berapa", NewIntentSet(IntentDebt)},
+		{"rekap bulan ini", NewIntentSet(IntentReport)},
+		{"target tabungan laptop", NewIntentSet(IntentGoal)},
+		{"skor keuangan", NewIntentSet(IntentHealth)},
+		// Pesan umum atau asing: kirim semua context.
+		{"cek data keuangan saya", NewIntentSet(IntentGeneral)},
+		{"asdf qwerty", NewIntentSet(IntentGeneral)},
+		{"", NewIntentSet(IntentGeneral)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			assert.Equal(t, tt.want.String(), DetectIntents(classifier, tt.message).String())
+		})
+	}
+}
+
+func TestDefaultIntentClassifier_FitsTrainingData(t *testing.T) {
+	classifier := DefaultIntentClassifier()
+	wrong := 0
+	for _, ex := range intentTrainingData {
+		if got := classifier.Classify(ex.Text); got != NewIntentSet(ex.Intents...) {
+			wrong++
+			t.Logf("%q: got %s, want %s", ex.Text, got, NewIntentSet(ex.Intents...))
+		}
+	}
+	assert.LessOrEqual(t, wrong, len(intentTrainingData)/20)
+}
+
+type fixedIntentClassifier IntentSet
+
+func (c fixedIntentClassifier) Classify(string) IntentSet { return IntentSet(c) }
+
+func TestChatbotService_GetUserContext_FetchesUnionOfIntents(t *testing.T) {
+	mockWalletRepo := new(mockWalletRepository)
+	mockTransactionRepo := new(mockTransactionRepository)
+	mockDebtRepo := new(mockDebtRepository)
+	mockGoalRepo := new(mockSavingGoalRepository)
+	mockDashSvc := new(mockDashboardService)
+	mockHealthSvc := new(mockFinancialHealthService)
+	mockUserRepo := &mockUserRepository{}
+	mockUserRepo.On("FindByID", uint(1)).Return((*entity.User)(nil), fmt.Errorf("not found"))
+
+	svc := NewChatbotService(
+		mockWalletRepo, new(mockCategoryRepository), new(mockTransactionService), mockTransactionRepo, mockDebtRepo, mockGoalRepo, mockDashSvc, mockHealthSvc, mockUserRepo, nil, nil, nil, nil, nil,
+	).WithIntentClassifier(fixedIntentClassifier(NewIntentSet(IntentTransaction, IntentDebt)))
+
+	mockDashSvc.On("GetDashboardData", uint(1)).Return(&entity.DashboardData{TotalBalance: 1000}, nil)
+	mockWalletRepo.On("FindByUserID", uint(1)).Return([]entity.Wallet{{ID: 1, Name: "Cash"}}, nil)
+	mockTransactionRepo.On("GetRecentTransactions", uint(1), MaxRecentTxns).Return([]entity.Transaction{}, nil)
+	mockTransactionRepo.On("FindSummaryByDateRange", uint(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]entity.TransactionSummary{}, nil)
+	mockDebtRepo.On("FindByUserID", uint(1), "").Return([]entity.Debt{{Name: "Budi", Amount: 500000, Remaining: 200000}}, nil)
+
+	contextStr := svc.GetUserContext(1, "bayar utang ke budi 200rb")
+
+	assert.Contains(t, contextStr, "Cash")
+	assert.Contains(t, contextStr, "Budi [Utang]")
+	mockTransactionRepo.AssertCalled(t, "GetRecentTransactions", uint(1), MaxRecentTxns)
+	// Hari ini dan minggu ini saja; breakdown bulanan hanya untuk laporan.
+	mockTransactionRepo.AssertNumberOfCalls(t, "FindSummaryByDateRange", 2)
+	mockGoalRepo.AssertNotCalled(t, "FindAll", uint(1))
+	mockHealthSvc.AssertNotCalled(t, "GetFinancialHealth", uint(1))
+
+	smallTalk := svc.WithIntentClassifier(fixedIntentClassifier(NewIntentSet(IntentSmallTalk)))
+	assert.Empty(t, smallTalk.GetUserContext(1, "makasih"))
+}
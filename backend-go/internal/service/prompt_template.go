@@ -0,0 +1,137 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Template prompt chat disimpan sebagai prompts/chat-vN.tmpl. Versi lama tidak diubah lagi:
+// perubahan prompt ditulis sebagai versi baru sehingga ChatMessage.PromptVersion tetap bisa
+// dilacak ke teks yang dipakai.
+//
+//go:embed prompts/chat-v*.tmpl
+var promptFiles embed.FS
+
+// defaultPromptCategories dipakai jika user belum punya kategori.
+var defaultPromptCategories = []string{"Makan", "Transport", "Belanja", "Hiburan", "Tagihan", "Kesehatan", "Pendidikan", "Gaji", "Lainnya"}
+
+// ChatPrompt adalah system prompt chat yang sudah dirender untuk satu user.
+type ChatPrompt struct {
+	Version string
+	Text    string
+}
+
+// ChatPromptData adalah variabel template prompt chat.
+type ChatPromptData struct {
+	Wallets       []string
+	DefaultWallet string // dompet yang dipakai resolveWallet jika user tidak menyebut dompet
+	Categories    []string
+	Aliases       []entity.UserAlias
+	Context       string // blok DATA KEUANGAN dari GetUserContext
+}
+
+type PromptTemplates struct {
+	templates map[string]*template.Template
+	sources   map[string]string
+	versions  []string // urut dari versi terlama
+	active    string
+}
+
+var (
+	defaultPrompts     *PromptTemplates
+	defaultPromptsOnce sync.Once
+)
+
+// DefaultPromptTemplates memuat template bawaan dengan versi terbaru sebagai versi aktif.
+func DefaultPromptTemplates() *PromptTemplates {
+	defaultPromptsOnce.Do(func() {
+		p, err := LoadPromptTemplates("")
+		if err != nil {
+			panic(err) // template tertanam di binary; gagal parse berarti bug saat build
+		}
+		defaultPrompts = p
+	})
+	return defaultPrompts
+}
+
+// LoadPromptTemplates memuat semua versi template chat. active kosong berarti versi terbaru.
+func LoadPromptTemplates(active string) (*PromptTemplates, error) {
+	paths, err := fs.Glob(promptFiles, "prompts/chat-v*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	p := &PromptTemplates{templates: map[string]*template.Template{}, sources: map[string]string{}}
+	funcs := template.FuncMap{"join": func(names []string) string { return strings.Join(names, ", ") }}
+	for _, path := range paths {
+		version := strings.TrimSuffix(strings.TrimPrefix(path, "prompts/"), ".tmpl")
+		data, err := promptFiles.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(version).Funcs(funcs).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", version, err)
+		}
+		p.templates[version] = tmpl
+		p.sources[version] = string(data)
+		p.versions = append(p.versions, version)
+	}
+	if len(p.versions) == 0 {
+		return nil, errors.New("no chat prompt templates")
+	}
+	sort.Slice(p.versions, func(i, j int) bool { return promptVersionNumber(p.versions[i]) < promptVersionNumber(p.versions[j]) })
+
+	p.active = p.versions[len(p.versions)-1]
+	if active != "" {
+		if _, ok := p.templates[active]; !ok {
+			return nil, fmt.Errorf("unknown chat prompt version %q (available: %s)", active, strings.Join(p.versions, ", "))
+		}
+		p.active = active
+	}
+	return p, nil
+}
+
+func promptVersionNumber(version string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(version, "chat-v"))
+	return n
+}
+
+// Active adalah versi yang dipakai RenderChat.
+func (p *PromptTemplates) Active() string {
+	return p.active
+}
+
+func (p *PromptTemplates) Versions() []string {
+	return append([]string(nil), p.versions...)
+}
+
+// Source mengembalikan teks mentah template versi aktif.
+func (p *PromptTemplates) Source() string {
+	return p.sources[p.active]
+}
+
+// RenderChat merender template aktif. Tanpa kategori, dipakai kategori bawaan aplikasi.
+func (p *PromptTemplates) RenderChat(data ChatPromptData) (ChatPrompt, error) {
+	if len(data.Categories) == 0 {
+		data.Categories = defaultPromptCategories
+	}
+	if len(data.Wallets) == 0 {
+		data.Wallets = []string{"Tunai"}
+	}
+	if data.DefaultWallet == "" {
+		data.DefaultWallet = data.Wallets[0]
+	}
+	var sb strings.Builder
+	if err := p.templates[p.active].Execute(&sb, data); err != nil {
+		return ChatPrompt{Version: p.active}, fmt.Errorf("prompt %s: %w", p.active, err)
+	}
+	return ChatPrompt{Version: p.active, Text: sb.String()}, nil
+}
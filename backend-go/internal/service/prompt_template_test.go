@@ -0,0 +1,90 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptTemplates_RenderChat(t *testing.T) {
+	prompts := DefaultPromptTemplates()
+	assert.Equal(t, "chat-v2", prompts.Active())
+	assert.Equal(t, []string{"chat-v1", "chat-v2"}, prompts.Versions())
+
+	prompt, err := prompts.RenderChat(ChatPromptData{
+		Wallets:    []string{"Tunai", "Jago Utama"},
+		Categories: []string{"Makan", "Ngopi"},
+		Aliases:    []entity.UserAlias{{Alias: "jago", Target: "Jago Utama"}},
+		Context:    "\n--- DATA KEUANGAN USER ---\n--- AKHIR DATA ---",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "chat-v2", prompt.Version)
+	assert.Contains(t, prompt.Text, "- Dompet: Tunai, Jago Utama\n")
+	assert.Contains(t, prompt.Text, "- Kategori: Makan, Ngopi\n")
+	assert.Contains(t, prompt.Text, `  - "jago" → Jago Utama`)
+	assert.Contains(t, prompt.Text, "--- DATA KEUANGAN USER ---")
+	assert.Contains(t, prompt.Text, `Default wallet = "Tunai"`)
+	assert.NotContains(t, prompt.Text, "Pendidikan")
+	// Koreksi dompet hanya dari dompet user sendiri, bukan daftar bank global.
+	assert.NotContains(t, prompt.Text, "BGA/VGA/DCA")
+	assert.NotContains(t, prompt.Text, `"gopek"`)
+
+	// Tanpa alias, bagian sebutan khusus tidak muncul; tanpa kategori, dipakai kategori bawaan.
+	prompt, err = prompts.RenderChat(ChatPromptData{})
+	require.NoError(t, err)
+	assert.NotContains(t, prompt.Text, "Sebutan khusus")
+	assert.Contains(t, prompt.Text, "- Dompet: Tunai\n")
+	assert.Contains(t, prompt.Text, "- Kategori: Makan, Transport, Belanja")
+}
+
+func TestLoadPromptTemplates_PinnedVersion(t *testing.T) {
+	prompts, err := LoadPromptTemplates("chat-v1")
+	require.NoError(t, err)
+
+	prompt, err := prompts.RenderChat(ChatPromptData{Wallets: []string{"Jago Utama"}, Context: "DATA-USER"})
+	require.NoError(t, err)
+	assert.Equal(t, "chat-v1", prompt.Version)
+	assert.Contains(t, prompt.Text, "DATA-USER")
+	assert.NotContains(t, prompt.Text, "Jago Utama")
+	assert.NotEqual(t, DefaultPromptTemplates().Source(), prompts.Source())
+
+	_, err = LoadPromptTemplates("chat-v99")
+	assert.ErrorContains(t, err, "chat-v1, chat-v2")
+}
+
+type stubAliasRepository struct {
+	aliases []entity.UserAlias
+}
+
+func (r *stubAliasRepository) FindByUserID(userID uint) ([]entity.UserAlias, error) {
+	return r.aliases, nil
+}
+func (r *stubAliasRepository) Upsert(alias *entity.UserAlias) error { return nil }
+func (r *stubAliasRepository) Delete(id uint, userID uint) error    { return nil }
+
+func TestChatbotService_ChatPrompt(t *testing.T) {
+	walletRepo := new(mockWalletRepository)
+	walletRepo.On("FindByUserID", uint(1)).Return([]entity.Wallet{{ID: 1, Name: "BCA"}, {ID: 2, Name: "Jago Utama"}}, nil)
+	categoryRepo := new(mockCategoryRepository)
+	categoryRepo.On("FindAll", uint(1)).Return([]entity.Category{{ID: 1, Name: "Makan"}, {ID: 2, Name: "Ngopi"}}, nil)
+	aliasRepo := &stubAliasRepository{aliases: []entity.UserAlias{{Alias: "jago", Target: "Jago Utama"}}}
+
+	svc := NewChatbotService(
+		walletRepo, categoryRepo, new(mockTransactionService), new(mockTransactionRepository), new(mockDebtRepository), new(mockSavingGoalRepository), new(mockDashboardService), new(mockFinancialHealthService), &mockUserRepository{}, nil, nil, nil, nil, nil,
+	).WithIntentClassifier(fixedIntentClassifier(NewIntentSet(IntentSmallTalk))).WithUserAliases(aliasRepo)
+
+	prompt := svc.ChatPrompt(1, "halo")
+	assert.Equal(t, "chat-v2", prompt.Version)
+	assert.Contains(t, prompt.Text, "- Dompet: BCA, Jago Utama\n")
+	assert.Contains(t, prompt.Text, "- Kategori: Makan, Ngopi\n")
+	assert.Contains(t, prompt.Text, `"jago" → Jago Utama`)
+	// User tanpa dompet Tunai: default diambil dari dompetnya sendiri.
+	assert.Contains(t, prompt.Text, `Default wallet = "BCA"`)
+	assert.NotContains(t, prompt.Text, "Tunai")
+
+	v1, err := LoadPromptTemplates("chat-v1")
+	require.NoError(t, err)
+	assert.Equal(t, "chat-v1", svc.WithPromptTemplates(v1).ChatPrompt(1, "halo").Version)
+}
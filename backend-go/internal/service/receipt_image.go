@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	receiptJPEGQuality = 85
+
+	// Sudut miring yang dicoba saat deskew, dalam derajat.
+	receiptMaxSkew  = 10.0
+	receiptSkewStep = 0.5
+	// Lebar gambar kecil yang dipakai untuk mengukur kemiringan.
+	receiptSkewSampleWidth = 400
+)
+
+// preprocessReceiptImage menyiapkan foto struk untuk dibaca model: diperkecil, dijadikan
+// grayscale, dipotong ke area kertas, kontrasnya diregangkan, lalu diluruskan. Kontras
+// diregangkan sebelum rotasi agar sudut kosong yang diisi putih tidak ikut dihitung.
+func preprocessReceiptImage(data []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	gray := toGray(limitSize(src, attachmentMaxImageDim))
+	gray = cropToPaper(gray)
+	stretchContrast(gray)
+	if angle := detectSkew(gray); math.Abs(angle) >= receiptSkewStep {
+		gray = rotateGray(gray, -angle)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, gray, &jpeg.Options{Quality: receiptJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func limitSize(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxDim && h <= maxDim {
+		return img
+	}
+	if w > h {
+		w, h = maxDim, h*maxDim/w
+	} else {
+		w, h = w*maxDim/h, maxDim
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+func toGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Src)
+	return gray
+}
+
+// otsuThreshold memisahkan piksel gelap (tinta) dan terang (kertas).
+func otsuThreshold(gray *image.Gray) uint8 {
+	var hist [256]int
+	for _, p := range gray.Pix {
+		hist[p]++
+	}
+	total := len(gray.Pix)
+	var sum float64
+	for i, n := range hist {
+		sum += float64(i * n)
+	}
+
+	var sumB, best float64
+	var weightB int
+	threshold := uint8(128)
+	for t := 0; t < 256; t++ {
+		weightB += hist[t]
+		if weightB == 0 {
+			continue
+		}
+		weightF := total - weightB
+		if weightF == 0 {
+			break
+		}
+		sumB += float64(t * hist[t])
+		meanB := sumB / float64(weightB)
+		meanF := (sum - sumB) / float64(weightF)
+		between := float64(weightB) * float64(weightF) * (meanB - meanF) * (meanB - meanF)
+		if between > best {
+			best, threshold = between, uint8(t)
+		}
+	}
+	return threshold
+}
+
+// cropToPaper memotong latar (meja, tangan) di sekitar struk: baris dan kolom yang jumlah
+// piksel terangnya mendekati baris/kolom paling terang dianggap kertas. Jika hasilnya tidak masuk akal, gambar tidak diubah.
+func cropToPaper(gray *image.Gray) *image.Gray {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	threshold := otsuThreshold(gray)
+
+	rowBright := make([]int, h)
+	colBright := make([]int, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if gray.Pix[y*gray.Stride+x] > threshold {
+				rowBright[y]++
+				colBright[x]++
+			}
+		}
+	}
+
+	top, bottom := paperSpan(rowBright)
+	left, right := paperSpan(colBright)
+	if top < 0 || left < 0 {
+		return gray
+	}
+	cw, ch := right-left+1, bottom-top+1
+	if cw*ch < w*h/5 || (cw >= w*98/100 && ch >= h*98/100) {
+		return gray
+	}
+
+	margin := 8
+	rect := image.Rect(max(left-margin, 0), max(top-margin, 0), min(right+margin+1, w), min(bottom+margin+1, h))
+	cropped := image.NewGray(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), gray, rect.Min, draw.Src)
+	return cropped
+}
+
+// paperSpan mencari baris/kolom pertama dan terakhir yang piksel terangnya minimal separuh
+// dari baris/kolom paling terang. Struk yang sempit di tengah foto tetap terdeteksi.
+func paperSpan(bright []int) (int, int) {
+	peak := 0
+	for _, n := range bright {
+		peak = max(peak, n)
+	}
+	first, last := -1, -1
+	if peak == 0 {
+		return first, last
+	}
+	for i, n := range bright {
+		if n*2 >= peak {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last
+}
+
+// detectSkew mengukur kemiringan teks dengan profil proyeksi: pada sudut yang benar, piksel
+// tinta menumpuk di baris-baris teks sehingga jumlah kuadrat per baris paling besar.
+func detectSkew(gray *image.Gray) float64 {
+	sample := gray
+	if w := gray.Bounds().Dx(); w > receiptSkewSampleWidth {
+		h := gray.Bounds().Dy() * receiptSkewSampleWidth / w
+		sample = image.NewGray(image.Rect(0, 0, receiptSkewSampleWidth, max(h, 1)))
+		draw.ApproxBiLinear.Scale(sample, sample.Bounds(), gray, gray.Bounds(), draw.Src, nil)
+	}
+
+	threshold := otsuThreshold(sample)
+	b := sample.Bounds()
+	var xs, ys []float64
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if sample.Pix[y*sample.Stride+x] <= threshold {
+				xs = append(xs, float64(x))
+				ys = append(ys, float64(y))
+			}
+		}
+	}
+	if len(xs) == 0 {
+		return 0
+	}
+
+	diag := int(math.Hypot(float64(b.Dx()), float64(b.Dy()))) + 1
+	bins := make([]float64, 2*diag+1)
+	bestAngle, bestScore := 0.0, -1.0
+	for angle := -receiptMaxSkew; angle <= receiptMaxSkew+1e-9; angle += receiptSkewStep {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		for i := range bins {
+			bins[i] = 0
+		}
+		for i := range xs {
+			// Posisi baris setelah titik diputar -angle.
+			bins[int(ys[i]*cos-xs[i]*sin)+diag]++
+		}
+		var score float64
+		for _, n := range bins {
+			score += n * n
+		}
+		if score > bestScore+1e-9 || (math.Abs(score-bestScore) <= 1e-9 && math.Abs(angle) < math.Abs(bestAngle)) {
+			bestAngle, bestScore = angle, score
+		}
+	}
+	return bestAngle
+}
+
+// rotateGray memutar gambar sebesar angle derajat (positif = searah jarum jam pada koordinat
+// layar) dengan ukuran tetap; area kosong diisi putih.
+func rotateGray(gray *image.Gray, angle float64) *image.Gray {
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	cx, cy := float64(w)/2, float64(h)/2
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := int(math.Round(dx*cos + dy*sin + cx))
+			sy := int(math.Round(-dx*sin + dy*cos + cy))
+			if sx < 0 || sy < 0 || sx >= w || sy >= h {
+				dst.SetGray(x, y, color.Gray{Y: 255})
+				continue
+			}
+			dst.Pix[y*dst.Stride+x] = gray.Pix[sy*gray.Stride+sx]
+		}
+	}
+	return dst
+}
+
+// stretchContrast meregangkan histogram sehingga persentil 1% menjadi hitam dan 99% putih.
+func stretchContrast(gray *image.Gray) {
+	var hist [256]int
+	for _, p := range gray.Pix {
+		hist[p]++
+	}
+	cut := len(gray.Pix) / 100
+	low, high := 0, 255
+	for n := 0; low < 255 && n+hist[low] <= cut; low++ {
+		n += hist[low]
+	}
+	for n := 0; high > 0 && n+hist[high] <= cut; high-- {
+		n += hist[high]
+	}
+	if high-low < 16 {
+		return
+	}
+
+	var lut [256]uint8
+	for i := range lut {
+		v := (i - low) * 255 / (high - low)
+		lut[i] = uint8(min(max(v, 0), 255))
+	}
+	for i, p := range gray.Pix {
+		gray.Pix[i] = lut[p]
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticReceipt menggambar kertas putih berisi baris-baris "teks" hitam.
+func syntheticReceipt(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 235
+	}
+	for y := 30; y < h-30; y += 24 {
+		for dy := 0; dy < 6; dy++ {
+			for x := 30; x < w-30; x++ {
+				if (x/12)%4 != 3 {
+					img.SetGray(x, y+dy, color.Gray{Y: 30})
+				}
+			}
+		}
+	}
+	return img
+}
+
+func TestDetectSkew(t *testing.T) {
+	straight := syntheticReceipt(400, 500)
+	assert.Equal(t, 0.0, detectSkew(straight))
+
+	for _, angle := range []float64{4, -6} {
+		skewed := rotateGray(straight, angle)
+		assert.InDelta(t, angle, detectSkew(skewed), receiptSkewStep, "angle %v", angle)
+		assert.InDelta(t, 0, detectSkew(rotateGray(skewed, -detectSkew(skewed))), receiptSkewStep)
+	}
+}
+
+func TestCropToPaper(t *testing.T) {
+	// Struk 200x400 di atas meja gelap 500x600.
+	img := image.NewGray(image.Rect(0, 0, 500, 600))
+	for i := range img.Pix {
+		img.Pix[i] = 40
+	}
+	for y := 100; y < 500; y++ {
+		for x := 150; x < 350; x++ {
+			img.SetGray(x, y, color.Gray{Y: 240})
+		}
+	}
+
+	cropped := cropToPaper(img)
+	assert.InDelta(t, 200, cropped.Bounds().Dx(), 20)
+	assert.InDelta(t, 400, cropped.Bounds().Dy(), 20)
+
+	// Foto yang sudah penuh kertas tidak dipotong.
+	full := syntheticReceipt(300, 300)
+	assert.Equal(t, full.Bounds(), cropToPaper(full).Bounds())
+}
+
+func TestPreprocessReceiptImage(t *testing.T) {
+	skewed := rotateGray(syntheticReceipt(600, 800), 5)
+	for i, p := range skewed.Pix {
+		// Sudut hasil rotasi ikut berwarna kertas, seperti foto asli.
+		if p == 255 {
+			skewed.Pix[i] = 235
+		}
+	}
+	var src bytes.Buffer
+	require.NoError(t, png.Encode(&src, skewed))
+
+	out, err := preprocessReceiptImage(src.Bytes())
+	require.NoError(t, err)
+	decoded, err := jpeg.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	gray := toGray(decoded)
+	assert.Less(t, math.Abs(detectSkew(gray)), 1.0)
+
+	// Kontras diregangkan: kertas menjadi putih penuh.
+	var hist [256]int
+	for _, p := range gray.Pix {
+		hist[p]++
+	}
+	assert.Greater(t, hist[255]+hist[254]+hist[253], len(gray.Pix)/4)
+
+	_, err = preprocessReceiptImage([]byte("bukan gambar"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,419 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/provider/mail"
+	"cuan-backend/internal/provider/whatsapp"
+	"cuan-backend/internal/repository"
+	pkgutils "cuan-backend/pkg/utils"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// DigestSendHour adalah jam (WIB) paling awal digest boleh dikirim pada hari jadwal.
+	DigestSendHour = 7
+
+	// DigestTopCategories adalah jumlah kategori pengeluaran terbesar yang ditampilkan.
+	DigestTopCategories = 5
+
+	// DigestDebtHorizonDays adalah rentang hari ke depan untuk utang yang akan jatuh tempo.
+	DigestDebtHorizonDays = 14
+)
+
+type UpdateReportScheduleInput struct {
+	Weekly      bool   `json:"weekly"`
+	OnPayday    bool   `json:"on_payday"`
+	ViaWhatsApp bool   `json:"via_whatsapp"`
+	ViaEmail    bool   `json:"via_email"`
+	Email       string `json:"email"`
+	// PushInsights mengirim insight akhir siklus ke WhatsApp.
+	PushInsights bool `json:"push_insights"`
+}
+
+type ReportDigestService interface {
+	GetSchedule(userID uint) (*entity.ReportSchedule, error)
+	UpdateSchedule(userID uint, input UpdateReportScheduleInput) (*entity.ReportSchedule, error)
+	BuildDigest(userID uint, now time.Time) (*entity.ReportDigest, error)
+	// SendDigest mengirim digest sekarang. Error hanya jika tidak ada channel yang berhasil;
+	// kegagalan sebagian terlihat di status per channel.
+	SendDigest(userID uint) ([]entity.DigestDelivery, error)
+	RunDue(now time.Time)
+}
+
+type reportDigestService struct {
+	scheduleRepo   repository.ReportScheduleRepository
+	userRepo       repository.UserRepository
+	debtRepo       repository.DebtRepository
+	dashboardSvc   DashboardService
+	transactionSvc TransactionService
+	waClient       whatsapp.Client
+	mailer         mail.Mailer
+}
+
+func NewReportDigestService(
+	scheduleRepo repository.ReportScheduleRepository,
+	userRepo repository.UserRepository,
+	debtRepo repository.DebtRepository,
+	dashboardSvc DashboardService,
+	transactionSvc TransactionService,
+	waClient whatsapp.Client,
+	mailer mail.Mailer,
+) ReportDigestService {
+	return &reportDigestService{
+		scheduleRepo:   scheduleRepo,
+		userRepo:       userRepo,
+		debtRepo:       debtRepo,
+		dashboardSvc:   dashboardSvc,
+		transactionSvc: transactionSvc,
+		waClient:       waClient,
+		mailer:         mailer,
+	}
+}
+
+func (s *reportDigestService) GetSchedule(userID uint) (*entity.ReportSchedule, error) {
+	schedule, err := s.scheduleRepo.FindByUserID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &entity.ReportSchedule{UserID: userID, ViaWhatsApp: true}, nil
+	}
+	return schedule, err
+}
+
+func (s *reportDigestService) UpdateSchedule(userID uint, input UpdateReportScheduleInput) (*entity.ReportSchedule, error) {
+	schedule, err := s.GetSchedule(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Weekly = input.Weekly
+	schedule.OnPayday = input.OnPayday
+	schedule.ViaWhatsApp = input.ViaWhatsApp
+	schedule.ViaEmail = input.ViaEmail
+	schedule.Email = strings.TrimSpace(input.Email)
+	schedule.PushInsights = input.PushInsights
+
+	if err := s.scheduleRepo.Save(schedule); err != nil {
+		return nil, err
+	}
+
+	log.Info().Uint("user_id", userID).Bool("weekly", schedule.Weekly).Bool("on_payday", schedule.OnPayday).Msg("Report schedule updated")
+	return schedule, nil
+}
+
+// digestPeriod mengembalikan siklus tagihan yang berisi hari kemarin, sehingga
+// digest yang dikirim tepat di hari gajian merangkum siklus yang baru selesai.
+func digestPeriod(now time.Time, payday int) (time.Time, time.Time) {
+	start, end := pkgutils.GetBillingCycle(now.AddDate(0, 0, -1), payday)
+	if end.After(now) {
+		end = now
+	}
+	return start, end
+}
+
+func (s *reportDigestService) BuildDigest(userID uint, now time.Time) (*entity.ReportDigest, error) {
+	payday := 1
+	if user, err := s.userRepo.FindByID(userID); err == nil && user.Payday != nil {
+		payday = *user.Payday
+	}
+
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now = now.In(wib)
+	start, end := digestPeriod(now, payday)
+	startDate := start.Format("2006-01-02")
+	endDate := end.Format("2006-01-02") + " 23:59:59"
+
+	dashboard, err := s.dashboardSvc.GetDashboardData(userID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil dashboard: %w", err)
+	}
+
+	report, err := s.transactionSvc.GetReport(userID, startDate, endDate, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil laporan: %w", err)
+	}
+
+	digest := &entity.ReportDigest{
+		PeriodStart:  startDate,
+		PeriodEnd:    end.Format("2006-01-02"),
+		TotalBalance: dashboard.TotalBalance,
+	}
+
+	var expenses []entity.CategoryBreakdown
+	for _, item := range report {
+		switch item.Type {
+		case "income":
+			digest.TotalIncome += item.TotalAmount
+		case "expense":
+			digest.TotalExpense += item.TotalAmount
+			expenses = append(expenses, item)
+			if item.BudgetLimit > 0 {
+				digest.BudgetStatus = append(digest.BudgetStatus, item)
+			}
+		}
+	}
+
+	sort.SliceStable(expenses, func(i, j int) bool {
+		return expenses[i].TotalAmount > expenses[j].TotalAmount
+	})
+	if len(expenses) > DigestTopCategories {
+		expenses = expenses[:DigestTopCategories]
+	}
+	for i := range expenses {
+		if digest.TotalExpense > 0 {
+			expenses[i].Percentage = expenses[i].TotalAmount / digest.TotalExpense * 100
+		}
+	}
+	digest.TopCategories = expenses
+
+	sort.SliceStable(digest.BudgetStatus, func(i, j int) bool {
+		return budgetUsage(digest.BudgetStatus[i]) > budgetUsage(digest.BudgetStatus[j])
+	})
+
+	if debts, err := s.debtRepo.FindByUserID(userID, ""); err == nil {
+		horizon := now.AddDate(0, 0, DigestDebtHorizonDays)
+		for _, d := range debts {
+			if d.IsPaid || d.DueDate == nil || d.DueDate.After(horizon) {
+				continue
+			}
+			digest.UpcomingDebts = append(digest.UpcomingDebts, d)
+		}
+		sort.SliceStable(digest.UpcomingDebts, func(i, j int) bool {
+			return digest.UpcomingDebts[i].DueDate.Before(*digest.UpcomingDebts[j].DueDate)
+		})
+	}
+
+	return digest, nil
+}
+
+func budgetUsage(item entity.CategoryBreakdown) float64 {
+	if item.BudgetLimit <= 0 {
+		return 0
+	}
+	return item.TotalAmount / item.BudgetLimit * 100
+}
+
+// FormatDigestText menyusun digest menjadi teks yang ramah WhatsApp.
+func FormatDigestText(userName string, d *entity.ReportDigest) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 *Ringkasan Keuangan %s*\n", userName))
+	sb.WriteString(fmt.Sprintf("Periode %s s/d %s\n\n", d.PeriodStart, d.PeriodEnd))
+	sb.WriteString(fmt.Sprintf("💰 Total Saldo: %s\n", formatRupiah(d.TotalBalance)))
+	sb.WriteString(fmt.Sprintf("⬆️ Pemasukan: %s\n", formatRupiah(d.TotalIncome)))
+	sb.WriteString(fmt.Sprintf("⬇️ Pengeluaran: %s\n", formatRupiah(d.TotalExpense)))
+
+	if len(d.TopCategories) > 0 {
+		sb.WriteString("\n*Kategori Terbesar:*\n")
+		for i, c := range d.TopCategories {
+			sb.WriteString(fmt.Sprintf("%d. %s — %s (%.0f%%)\n", i+1, c.CategoryName, formatRupiah(c.TotalAmount), c.Percentage))
+		}
+	}
+
+	if len(d.BudgetStatus) > 0 {
+		sb.WriteString("\n*Status Budget:*\n")
+		for _, c := range d.BudgetStatus {
+			icon := "✅"
+			if c.IsOverBudget {
+				icon = "🚨"
+			} else if budgetUsage(c) >= 80 {
+				icon = "⚠️"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s: %s / %s (%.0f%%)\n", icon, c.CategoryName,
+				formatRupiah(c.TotalAmount), formatRupiah(c.BudgetLimit), budgetUsage(c)))
+		}
+	}
+
+	if len(d.UpcomingDebts) > 0 {
+		sb.WriteString("\n*Utang/Piutang Jatuh Tempo:*\n")
+		for _, debt := range d.UpcomingDebts {
+			typeLabel := "Utang"
+			if debt.Type == entity.DebtTypeReceivable {
+				typeLabel = "Piutang"
+			}
+			sb.WriteString(fmt.Sprintf("📅 %s — %s [%s] sisa %s\n",
+				debt.DueDate.Format("2006-01-02"), debt.Name, typeLabel, formatRupiah(debt.Remaining)))
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (s *reportDigestService) SendDigest(userID uint) ([]entity.DigestDelivery, error) {
+	return s.deliver(userID, time.Now())
+}
+
+// deliver mengirim digest ke semua channel aktif dan mengembalikan status per channel. Channel
+// yang gagal dicatat di log; err hanya diisi jika tidak ada satu pun channel yang berhasil.
+func (s *reportDigestService) deliver(userID uint, now time.Time) ([]entity.DigestDelivery, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user tidak ditemukan: %w", err)
+	}
+
+	schedule, err := s.GetSchedule(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := s.BuildDigest(userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	text := FormatDigestText(user.Name, digest)
+	filename := fmt.Sprintf("laporan_%s_%s.xlsx", digest.PeriodStart, digest.PeriodEnd)
+
+	var attachment []byte
+	if buf, err := s.transactionSvc.ExportReport(userID, digest.PeriodStart, digest.PeriodEnd+" 23:59:59", nil, nil); err == nil {
+		attachment = buf.Bytes()
+	} else {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Failed to export digest attachment")
+	}
+
+	var deliveries []entity.DigestDelivery
+	var errs []error
+	sent := false
+	record := func(channel string, err error) {
+		delivery := entity.DigestDelivery{Channel: channel, Sent: err == nil}
+		if err != nil {
+			delivery.Error = err.Error()
+			errs = append(errs, err)
+			log.Warn().Err(err).Uint("user_id", userID).Str("channel", channel).Msg("Failed to deliver report digest")
+		} else {
+			sent = true
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if schedule.ViaWhatsApp {
+		record("whatsapp", s.sendWhatsApp(user, text, filename, attachment))
+	}
+	if schedule.ViaEmail {
+		record("email", s.sendEmail(user, schedule.Email, text, filename, attachment))
+	}
+
+	if len(deliveries) == 0 {
+		return nil, errors.New("tidak ada channel pengiriman yang aktif")
+	}
+	if !sent {
+		return deliveries, errors.Join(errs...)
+	}
+	log.Info().Uint("user_id", userID).Int("channels", len(deliveries)).Int("failed", len(errs)).Msg("Report digest sent")
+	return deliveries, nil
+}
+
+func (s *reportDigestService) sendWhatsApp(user *entity.User, text, filename string, attachment []byte) error {
+	if s.waClient == nil {
+		return errors.New("wa-gateway belum dikonfigurasi")
+	}
+	if user.Phone == nil || *user.Phone == "" {
+		return errors.New("nomor WhatsApp belum diisi di profil")
+	}
+
+	chatID := *user.Phone + "@s.whatsapp.net"
+	if err := s.waClient.SendMessage(chatID, text); err != nil {
+		return fmt.Errorf("gagal mengirim digest WhatsApp: %w", err)
+	}
+	if len(attachment) > 0 {
+		if err := s.waClient.SendFile(chatID, "Detail laporan per kategori", filename, attachment); err != nil {
+			return fmt.Errorf("gagal mengirim lampiran WhatsApp: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *reportDigestService) sendEmail(user *entity.User, email, text, filename string, attachment []byte) error {
+	if s.mailer == nil {
+		return errors.New("SMTP belum dikonfigurasi")
+	}
+
+	to := email
+	if to == "" {
+		to = user.Email
+	}
+
+	var attachments []mail.Attachment
+	if len(attachment) > 0 {
+		attachments = append(attachments, mail.Attachment{
+			Filename:    filename,
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Data:        attachment,
+		})
+	}
+
+	body := strings.NewReplacer("*", "").Replace(text)
+	if err := s.mailer.Send(to, "Ringkasan Keuangan Petualangan Cuan", body, attachments...); err != nil {
+		return fmt.Errorf("gagal mengirim digest email: %w", err)
+	}
+	return nil
+}
+
+// isDigestDue menentukan apakah digest harus dikirim pada waktu now.
+// Digest dikirim maksimal sekali per hari, mulai jam DigestSendHour.
+func isDigestDue(schedule entity.ReportSchedule, payday int, now time.Time) bool {
+	if now.Hour() < DigestSendHour {
+		return false
+	}
+
+	if schedule.LastSentAt != nil {
+		last := schedule.LastSentAt.In(now.Location())
+		if last.Year() == now.Year() && last.YearDay() == now.YearDay() {
+			return false
+		}
+	}
+
+	if schedule.Weekly && now.Weekday() == time.Monday {
+		return true
+	}
+
+	if schedule.OnPayday {
+		start, _ := pkgutils.GetBillingCycle(now, payday)
+		if start.Year() == now.Year() && start.YearDay() == now.YearDay() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RunDue mengirim digest untuk semua jadwal yang jatuh tempo. Dipanggil oleh scheduler.
+func (s *reportDigestService) RunDue(now time.Time) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now = now.In(wib)
+
+	schedules, err := s.scheduleRepo.FindActive()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load active digest schedules")
+		return
+	}
+
+	for i := range schedules {
+		schedule := schedules[i]
+		payday := 1
+		if schedule.User.Payday != nil {
+			payday = *schedule.User.Payday
+		}
+
+		if !isDigestDue(schedule, payday, now) {
+			continue
+		}
+
+		// Jika satu channel gagal tapi channel lain terkirim, digest tetap dianggap terkirim agar
+		// channel yang berhasil tidak menerima digest yang sama di setiap tick berikutnya.
+		if _, err := s.deliver(schedule.UserID, now); err != nil {
+			log.Error().Err(err).Uint("user_id", schedule.UserID).Msg("Failed to send scheduled digest")
+			continue
+		}
+
+		sentAt := now
+		schedule.LastSentAt = &sentAt
+		if err := s.scheduleRepo.Save(&schedule); err != nil {
+			log.Warn().Err(err).Uint("user_id", schedule.UserID).Msg("Failed to update digest last_sent_at")
+		}
+	}
+}
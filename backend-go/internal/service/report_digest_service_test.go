@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"cuan-backend/internal/entity"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type digestTransactionService struct {
+	mockTransactionService
+	report []entity.CategoryBreakdown
+}
+
+func (m *digestTransactionService) GetReport(userID uint, startDate, endDate string, walletIDs []uint, filterType *string) ([]entity.CategoryBreakdown, error) {
+	return m.report, nil
+}
+
+func TestIsDigestDue(t *testing.T) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	monday := time.Date(2026, 10, 19, 8, 0, 0, 0, wib)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	weekly := entity.ReportSchedule{Weekly: true}
+	assert.True(t, isDigestDue(weekly, 1, monday))
+	assert.False(t, isDigestDue(weekly, 1, tuesday))
+	assert.False(t, isDigestDue(weekly, 1, monday.Add(-3*time.Hour)), "before send hour")
+
+	sent := monday.Add(-time.Hour)
+	weekly.LastSentAt = &sent
+	assert.False(t, isDigestDue(weekly, 1, monday), "already sent today")
+
+	payday := entity.ReportSchedule{OnPayday: true}
+	assert.True(t, isDigestDue(payday, 20, tuesday))
+	assert.False(t, isDigestDue(payday, 25, tuesday))
+
+	// Payday 31 di bulan 30 hari di-clamp ke tanggal terakhir.
+	endOfNovember := time.Date(2026, 11, 30, 9, 0, 0, 0, wib)
+	assert.True(t, isDigestDue(payday, 31, endOfNovember))
+}
+
+func TestDigestPeriod_OnPaydayCoversPreviousCycle(t *testing.T) {
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now := time.Date(2026, 10, 25, 8, 0, 0, 0, wib)
+
+	start, end := digestPeriod(now, 25)
+	assert.Equal(t, "2026-09-25", start.Format("2006-01-02"))
+	assert.Equal(t, "2026-10-24", end.Format("2006-01-02"))
+
+	start, end = digestPeriod(now, 1)
+	assert.Equal(t, "2026-10-01", start.Format("2006-01-02"))
+	assert.Equal(t, now, end)
+}
+
+func TestReportDigestService_BuildDigest(t *testing.T) {
+	mockUserRepo := &mockUserRepository{}
+	mockUserRepo.On("FindByID", uint(1)).Return((*entity.User)(nil), fmt.Errorf("not found"))
+
+	mockDashSvc := new(mockDashboardService)
+	mockDashSvc.On("GetDashboardData", uint(1)).Return(&entity.DashboardData{TotalBalance: 2500000}, nil)
+
+	txSvc := &digestTransactionService{report: []entity.CategoryBreakdown{
+		{CategoryName: "Gaji", Type: "income", TotalAmount: 5000000},
+		{CategoryName: "Makan", Type: "expense", TotalAmount: 900000, BudgetLimit: 800000, IsOverBudget: true},
+		{CategoryName: "Transport", Type: "expense", TotalAmount: 300000, BudgetLimit: 500000},
+		{CategoryName: "Hiburan", Type: "expense", TotalAmount: 800000},
+	}}
+
+	soon := time.Now().AddDate(0, 0, 3)
+	later := time.Now().AddDate(0, 2, 0)
+	mockDebtRepo := new(mockDebtRepository)
+	mockDebtRepo.On("FindByUserID", uint(1), "").Return([]entity.Debt{
+		{Name: "Pinjam Budi", Remaining: 100000, DueDate: &soon},
+		{Name: "Cicilan Motor", Remaining: 700000, DueDate: &later},
+		{Name: "Lunas", IsPaid: true, DueDate: &soon},
+	}, nil)
+
+	svc := NewReportDigestService(nil, mockUserRepo, mockDebtRepo, mockDashSvc, txSvc, nil, nil)
+
+	digest, err := svc.BuildDigest(1, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 2500000.0, digest.TotalBalance)
+	assert.Equal(t, 5000000.0, digest.TotalIncome)
+	assert.Equal(t, 2000000.0, digest.TotalExpense)
+
+	assert.Len(t, digest.TopCategories, 3)
+	assert.Equal(t, "Makan", digest.TopCategories[0].CategoryName)
+	assert.Equal(t, 45.0, digest.TopCategories[0].Percentage)
+
+	assert.Len(t, digest.BudgetStatus, 2)
+	assert.Equal(t, "Makan", digest.BudgetStatus[0].CategoryName)
+
+	assert.Len(t, digest.UpcomingDebts, 1)
+	assert.Equal(t, "Pinjam Budi", digest.UpcomingDebts[0].Name)
+
+	text := FormatDigestText("Andi", digest)
+	assert.Contains(t, text, "Ringkasan Keuangan Andi")
+	assert.Contains(t, text, "🚨 Makan")
+	assert.Contains(t, text, "Pinjam Budi")
+}
+
+type stubScheduleRepository struct {
+	schedules []entity.ReportSchedule
+	saved     []entity.ReportSchedule
+}
+
+func (r *stubScheduleRepository) FindByUserID(userID uint) (*entity.ReportSchedule, error) {
+	for i := range r.schedules {
+		if r.schedules[i].UserID == userID {
+			return &r.schedules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+func (r *stubScheduleRepository) FindActive() ([]entity.ReportSchedule, error) {
+	return r.schedules, nil
+}
+
+func (r *stubScheduleRepository) Save(schedule *entity.ReportSchedule) error {
+	r.saved = append(r.saved, *schedule)
+	for i := range r.schedules {
+		if r.schedules[i].UserID == schedule.UserID {
+			r.schedules[i] = *schedule
+		}
+	}
+	return nil
+}
+
+type stubWAClient struct {
+	sent     []string
+	audio    []string // filename voice note yang dikirim
+	audioErr error
+}
+
+func (c *stubWAClient) SendMessage(phone, text string) error {
+	c.sent = append(c.sent, phone)
+	return nil
+}
+func (c *stubWAClient) SendFile(phone, caption, filename string, data []byte) error { return nil }
+func (c *stubWAClient) SendAudio(phone, filename string, data []byte) error {
+	if c.audioErr != nil {
+		return c.audioErr
+	}
+	c.audio = append(c.audio, phone+"/"+filename)
+	return nil
+}
+func (c *stubWAClient) DownloadMedia(mediaPath string) ([]byte, error) { return nil, nil }
+
+func (m *digestTransactionService) ExportReport(userID uint, startDate, endDate string, walletIDs []uint, transactionType *string) (*bytes.Buffer, error) {
+	return new(bytes.Buffer), nil
+}
+
+func TestReportDigestService_RunDue_PartialDeliveryMarksSent(t *testing.T) {
+	phone := "628123"
+	mockUserRepo := &mockUserRepository{}
+	mockUserRepo.On("FindByID", uint(1)).Return(&entity.User{ID: 1, Name: "Andi", Phone: &phone}, nil)
+	mockDashSvc := new(mockDashboardService)
+	mockDashSvc.On("GetDashboardData", uint(1)).Return(&entity.DashboardData{}, nil)
+	mockDebtRepo := new(mockDebtRepository)
+	mockDebtRepo.On("FindByUserID", uint(1), "").Return([]entity.Debt{}, nil)
+
+	// Email gagal karena SMTP tidak dikonfigurasi, WhatsApp berhasil.
+	schedules := &stubScheduleRepository{schedules: []entity.ReportSchedule{
+		{UserID: 1, Weekly: true, ViaWhatsApp: true, ViaEmail: true},
+	}}
+	wa := &stubWAClient{}
+	svc := NewReportDigestService(schedules, mockUserRepo, mockDebtRepo, mockDashSvc, &digestTransactionService{}, wa, nil)
+
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	monday := time.Date(2026, 10, 19, 8, 0, 0, 0, wib)
+	svc.RunDue(monday)
+	svc.RunDue(monday.Add(time.Hour))
+
+	assert.Equal(t, []string{"628123@s.whatsapp.net"}, wa.sent)
+	if assert.Len(t, schedules.saved, 1) {
+		assert.Equal(t, monday, *schedules.saved[0].LastSentAt)
+	}
+
+	// Kirim manual memakai aturan yang sama: sukses dengan status per channel.
+	deliveries, err := svc.SendDigest(1)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.DigestDelivery{
+		{Channel: "whatsapp", Sent: true},
+		{Channel: "email", Sent: false, Error: "SMTP belum dikonfigurasi"},
+	}, deliveries)
+}
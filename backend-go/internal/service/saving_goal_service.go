@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/repository"
 	"errors"
@@ -18,6 +19,8 @@ type SavingGoalService interface {
 	DeleteGoal(userID uint, goalID uint) error
 	DeleteContribution(userID uint, contributionID uint) error
 	FinishGoal(userID uint, goalID uint) error
+	// WithContext juga meneruskan ctx ke TransactionService, jadi setoran dan penarikan tercatat atas actor yang sama.
+	WithContext(ctx context.Context) SavingGoalService
 }
 
 type savingGoalService struct {
@@ -51,6 +54,20 @@ type ContributionInput struct {
 	Description string `json:"description"`
 }
 
+func (s *savingGoalService) WithContext(ctx context.Context) SavingGoalService {
+	db := s.db.WithContext(ctx)
+	transactionService := s.transactionService
+	if transactionService != nil {
+		transactionService = transactionService.WithContext(ctx)
+	}
+	return &savingGoalService{
+		repo:               s.repo.WithTx(db),
+		walletRepo:         s.walletRepo.WithTx(db),
+		transactionService: transactionService,
+		db:                 db,
+	}
+}
+
 func (s *savingGoalService) CreateGoal(userID uint, input CreateGoalInput) (*entity.SavingGoal, error) {
 	goal := &entity.SavingGoal{
 		UserID:       userID,
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrSpeechDisabled dikembalikan jika balasan suara (TTS) tidak dikonfigurasi.
+var ErrSpeechDisabled = errors.New("balasan suara belum dikonfigurasi")
+
+// opusArgs mengubah audio dari stdin menjadi OGG/Opus mono di stdout, format yang diterima
+// WhatsApp sebagai voice note.
+var opusArgs = []string{"-hide_banner", "-loglevel", "error", "-i", "pipe:0",
+	"-ac", "1", "-ar", "48000", "-c:a", "libopus", "-b:a", "24k", "-application", "voip", "-f", "ogg", "pipe:1"}
+
+type SpeechConfig struct {
+	// MaxChars membatasi panjang teks yang dibacakan; sisanya dipotong di akhir kalimat.
+	MaxChars int
+	// FFmpeg adalah binary ffmpeg untuk konversi ke OGG/Opus; kosong berarti synthesizer harus
+	// sudah menghasilkan OGG.
+	FFmpeg string
+}
+
+func DefaultSpeechConfig() SpeechConfig {
+	return SpeechConfig{
+		MaxChars: 600,
+		FFmpeg:   "ffmpeg",
+	}
+}
+
+// SpeechPipeline merapikan balasan untuk dibacakan, mensintesisnya, lalu memastikan hasilnya OGG/Opus.
+type SpeechPipeline struct {
+	synth aiprovider.Synthesizer
+	cfg   SpeechConfig
+}
+
+func NewSpeechPipeline(synth aiprovider.Synthesizer, cfg SpeechConfig) *SpeechPipeline {
+	return &SpeechPipeline{synth: synth, cfg: cfg}
+}
+
+// Speak mengembalikan audio OGG/Opus untuk text.
+func (p *SpeechPipeline) Speak(ctx context.Context, text string) ([]byte, error) {
+	text = SpeechText(text, p.cfg.MaxChars)
+	if text == "" {
+		return nil, errors.New("tidak ada teks untuk dibacakan")
+	}
+
+	speech, err := p.synth.Synthesize(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if speech.IsOggOpus() {
+		return speech.Audio, nil
+	}
+	if p.cfg.FFmpeg == "" {
+		return nil, fmt.Errorf("audio TTS berformat %s, ffmpeg dibutuhkan untuk konversi ke OGG/Opus", speech.ContentType)
+	}
+	return p.toOpus(ctx, speech.Audio)
+}
+
+func (p *SpeechPipeline) toOpus(ctx context.Context, audio []byte) ([]byte, error) {
+	bin, err := exec.LookPath(p.cfg.FFmpeg)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg tidak tersedia: %w", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, opusArgs...)
+	cmd.Stdin = bytes.NewReader(audio)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+var (
+	rupiahPattern   = regexp.MustCompile(`Rp\s?(\d+(?:[.,]\d+)*)`)
+	markdownPattern = strings.NewReplacer("*", "", "_", " ", "~", "", "`", "", "#", "")
+)
+
+// SpeechText membuang format yang tidak enak dibacakan (markdown, emoji), membaca "Rp20.000"
+// sebagai "20.000 rupiah", dan memotong teks di batas kalimat terakhir sebelum maxChars.
+func SpeechText(text string, maxChars int) string {
+	text = rupiahPattern.ReplaceAllString(text, "$1 rupiah")
+	text = markdownPattern.Replace(text)
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.So, r), r == '\u200d', r == '\ufe0f':
+			return -1
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, text)
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return text
+	}
+	cut := string(runes[:maxChars])
+	if i := strings.LastIndexAny(cut, ".!?"); i > 0 {
+		return cut[:i+1]
+	}
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		return cut[:i] + "..."
+	}
+	return cut
+}
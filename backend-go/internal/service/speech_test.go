@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"context"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type wavSynthesizer struct{}
+
+func (wavSynthesizer) Synthesize(ctx context.Context, text string) (*aiprovider.Speech, error) {
+	return &aiprovider.Speech{Audio: []byte("RIFF....WAVE"), ContentType: "audio/wav"}, nil
+}
+
+func TestSpeechPipeline_SpeaksCleanedReply(t *testing.T) {
+	stub := aiprovider.NewStubSynthesizer()
+	pipeline := service.NewSpeechPipeline(stub, service.SpeechConfig{MaxChars: 600})
+
+	audio, err := pipeline.Speak(context.Background(), "✅ *Kopi susu* Rp20.000 sudah dicatat.\n\n📂 Makanan & Minuman")
+	require.NoError(t, err)
+
+	assert.Equal(t, "OggS", string(audio[:4]))
+	assert.Equal(t, []string{"Kopi susu 20.000 rupiah sudah dicatat. Makanan & Minuman"}, stub.Texts())
+}
+
+func TestSpeechPipeline_RequiresFFmpegForWAV(t *testing.T) {
+	pipeline := service.NewSpeechPipeline(wavSynthesizer{}, service.SpeechConfig{MaxChars: 600})
+
+	_, err := pipeline.Speak(context.Background(), "halo")
+	assert.ErrorContains(t, err, "ffmpeg dibutuhkan")
+}
+
+func TestSpeechText_TruncatesAtSentence(t *testing.T) {
+	text := "Pengeluaran bulan ini 1,2 juta. Terbesar untuk makan. Sisanya transport dan hiburan."
+
+	assert.Equal(t, "Pengeluaran bulan ini 1,2 juta. Terbesar untuk makan.", service.SpeechText(text, 60))
+	assert.Equal(t, "Pengeluaran bulan...", service.SpeechText("Pengeluaran bulan ini", 19))
+}
+
+func TestAIService_SpeakDisabled(t *testing.T) {
+	svc := service.NewAIServiceWithVoice(nil, nil, nil)
+
+	_, err := svc.Speak("halo")
+	assert.ErrorIs(t, err, service.ErrSpeechDisabled)
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/repository"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// searchLookbackMonths: transaksi yang lebih lama tidak dicari.
+	searchLookbackMonths = 12
+	// searchMaxMatches adalah jumlah maksimal transaksi mirip yang ditampilkan satu per satu;
+	// agregat bulanan tetap menghitung semua yang cocok.
+	searchMaxMatches = 8
+	// embedBatchSize adalah jumlah transaksi per request ke server embedding.
+	embedBatchSize = 32
+)
+
+// searchStopwords dibuang dari pertanyaan sebelum di-embed supaya vektornya mewakili barang atau
+// kegiatan yang ditanyakan ("kopi"), bukan kalimat tanyanya.
+var searchStopwords = map[string]bool{
+	"berapa": true, "kali": true, "saya": true, "aku": true, "gue": true, "gw": true,
+	"bulan": true, "minggu": true, "tahun": true, "hari": true, "ini": true, "lalu": true,
+	"kemarin": true, "total": true, "habis": true, "untuk": true, "buat": true, "sudah": true,
+	"udah": true, "di": true, "ke": true, "dari": true, "yang": true, "apa": true, "aja": true,
+	"saja": true, "pernah": true, "sering": true, "banyak": true, "terakhir": true, "kapan": true,
+	"dong": true, "sih": true, "ya": true, "tolong": true, "cek": true, "lihat": true,
+	"beli": true, "bayar": true, "banget": true, "nih": true, "kok": true, "gak": true,
+}
+
+// TransactionSearchService mencari transaksi lama yang mirip dengan pertanyaan user lewat
+// embedding deskripsi transaksi.
+type TransactionSearchService interface {
+	// IndexPending meng-embed transaksi yang belum punya embedding atau sudah diubah. userID 0
+	// berarti semua user. Mengembalikan jumlah transaksi yang di-embed.
+	IndexPending(ctx context.Context, userID uint, limit int) (int, error)
+	Search(ctx context.Context, userID uint, query string, now time.Time) (*entity.TransactionSearchResult, error)
+}
+
+type transactionSearchService struct {
+	repo     repository.TransactionEmbeddingRepository
+	embedder aiprovider.Embedder
+	minScore float64
+}
+
+// NewTransactionSearchService membuat service pencarian. minScore adalah cosine similarity
+// minimal agar transaksi dianggap cocok; nilainya bergantung pada model embedding.
+func NewTransactionSearchService(repo repository.TransactionEmbeddingRepository, embedder aiprovider.Embedder, minScore float64) TransactionSearchService {
+	return &transactionSearchService{repo: repo, embedder: embedder, minScore: minScore}
+}
+
+func (s *transactionSearchService) IndexPending(ctx context.Context, userID uint, limit int) (int, error) {
+	model := s.embedder.Model()
+	indexed := 0
+	for indexed < limit {
+		txns, err := s.repo.FindPending(userID, model, min(embedBatchSize, limit-indexed))
+		if err != nil {
+			return indexed, err
+		}
+		if len(txns) == 0 {
+			return indexed, nil
+		}
+
+		texts := make([]string, len(txns))
+		for i, t := range txns {
+			texts[i] = embeddingText(t)
+		}
+		vectors, err := s.embedder.Embed(ctx, texts)
+		if err != nil {
+			return indexed, fmt.Errorf("embedding failed: %w", err)
+		}
+
+		now := time.Now()
+		rows := make([]entity.TransactionEmbedding, len(txns))
+		for i, t := range txns {
+			rows[i] = entity.TransactionEmbedding{
+				TransactionID:   t.ID,
+				UserID:          t.UserID,
+				Model:           model,
+				Vector:          encodeVector(vectors[i]),
+				SourceUpdatedAt: t.UpdatedAt,
+				UpdatedAt:       now,
+			}
+		}
+		if err := s.repo.Upsert(rows); err != nil {
+			return indexed, err
+		}
+		indexed += len(txns)
+		if len(txns) < embedBatchSize {
+			return indexed, nil
+		}
+	}
+	return indexed, nil
+}
+
+// Search meng-embed transaksi user yang belum terindeks, lalu mengembalikan transaksi paling mirip
+// dengan query dan agregat per bulan dari semua transaksi yang cocok. Hasil nil berarti query
+// tidak menyebut apa pun yang bisa dicari.
+func (s *transactionSearchService) Search(ctx context.Context, userID uint, query string, now time.Time) (*entity.TransactionSearchResult, error) {
+	text := searchQueryText(query)
+	if text == "" {
+		return nil, nil
+	}
+
+	// Transaksi yang baru dicatat ikut tercari tanpa menunggu scheduler.
+	if _, err := s.IndexPending(ctx, userID, embedBatchSize); err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("Gagal meng-embed transaksi baru")
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	queryVector := vectors[0]
+
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -searchLookbackMonths, 0)
+	rows, err := s.repo.FindByUserSince(userID, s.embedder.Model(), since)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []entity.TransactionMatch
+	for _, row := range rows {
+		score := cosineSimilarity(queryVector, decodeVector(row.Vector))
+		if score >= s.minScore {
+			matches = append(matches, entity.TransactionMatch{Transaction: row.Transaction, Score: score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Transaction.Date.After(matches[j].Transaction.Date)
+	})
+
+	result := &entity.TransactionSearchResult{Total: len(matches), Monthly: monthlyMatches(matches, now.Location())}
+	result.Matches = matches[:min(len(matches), searchMaxMatches)]
+	return result, nil
+}
+
+// monthlyMatches menjumlahkan transaksi yang cocok per bulan kalender, bulan terbaru dulu.
+func monthlyMatches(matches []entity.TransactionMatch, loc *time.Location) []entity.TransactionMatchMonth {
+	byMonth := map[string]*entity.TransactionMatchMonth{}
+	for _, m := range matches {
+		key := m.Transaction.Date.In(loc).Format("2006-01")
+		month, ok := byMonth[key]
+		if !ok {
+			month = &entity.TransactionMatchMonth{Month: key}
+			byMonth[key] = month
+		}
+		month.Count++
+		if m.Transaction.Type == "income" {
+			month.Income += m.Transaction.Amount
+		} else {
+			month.Expense += m.Transaction.Amount
+		}
+	}
+	out := make([]entity.TransactionMatchMonth, 0, len(byMonth))
+	for _, m := range byMonth {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Month > out[j].Month })
+	return out
+}
+
+// embeddingText adalah teks transaksi yang di-embed: deskripsi dan kategorinya.
+func embeddingText(t entity.Transaction) string {
+	text := strings.TrimSpace(t.Description)
+	if t.Category.Name != "" {
+		text += " " + t.Category.Name
+	}
+	return text
+}
+
+// searchQueryText membuang kata tanya, kata waktu dan nominal dari pertanyaan.
+func searchQueryText(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, w := range words {
+		if searchStopwords[w] || monthNamesID[w] || unicode.IsDigit(rune(w[0])) {
+			continue
+		}
+		kept = append(kept, w)
+	}
+	return strings.Join(kept, " ")
+}
+
+// monthNamesID dipakai untuk membuang nama bulan dari query pencarian.
+var monthNamesID = map[string]bool{
+	"januari": true, "februari": true, "maret": true, "april": true, "mei": true, "juni": true,
+	"juli": true, "agustus": true, "september": true, "oktober": true, "november": true, "desember": true,
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
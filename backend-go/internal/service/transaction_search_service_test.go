@@ -0,0 +1,80 @@
+package service_test
+
+import (
+	"context"
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/repository"
+	"cuan-backend/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTransactionSearchTest(t *testing.T) (*gorm.DB, service.TransactionSearchService) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}, &entity.Wallet{}, &entity.Category{}, &entity.Transaction{}, &entity.TransactionEmbedding{}))
+
+	require.NoError(t, db.Create(&entity.User{ID: 1, Email: "search@test.com"}).Error)
+	require.NoError(t, db.Create(&entity.User{ID: 2, Email: "other@test.com"}).Error)
+	require.NoError(t, db.Create(&entity.Wallet{ID: 1, UserID: 1, Name: "Tunai"}).Error)
+	require.NoError(t, db.Create(&entity.Category{ID: 1, UserID: 1, Name: "Makan", Type: "expense"}).Error)
+	require.NoError(t, db.Create(&entity.Category{ID: 2, UserID: 1, Name: "Transport", Type: "expense"}).Error)
+
+	repo := repository.NewTransactionEmbeddingRepository(db)
+	return db, service.NewTransactionSearchService(repo, aiprovider.NewHashEmbedder(256), 0.5)
+}
+
+func TestTransactionSearch_AggregatesSimilarTransactions(t *testing.T) {
+	db, search := setupTransactionSearchTest(t)
+	wib, _ := time.LoadLocation("Asia/Jakarta")
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, wib)
+	lastMonth := time.Date(2026, 9, 10, 8, 0, 0, 0, wib)
+
+	txns := []entity.Transaction{
+		{ID: 1, UserID: 1, WalletID: 1, CategoryID: 1, Amount: 18000, Type: "expense", Description: "Kopi susu", Date: lastMonth},
+		{ID: 2, UserID: 1, WalletID: 1, CategoryID: 1, Amount: 22000, Type: "expense", Description: "Kopi", Date: lastMonth.AddDate(0, 0, 5)},
+		{ID: 3, UserID: 1, WalletID: 1, CategoryID: 1, Amount: 25000, Type: "expense", Description: "Kopi gula aren", Date: now.AddDate(0, 0, -2)},
+		{ID: 4, UserID: 1, WalletID: 1, CategoryID: 2, Amount: 30000, Type: "expense", Description: "Bensin", Date: lastMonth},
+		// Di luar jendela 12 bulan dan milik user lain: tidak ikut.
+		{ID: 5, UserID: 1, WalletID: 1, CategoryID: 1, Amount: 15000, Type: "expense", Description: "Kopi", Date: now.AddDate(-2, 0, 0)},
+		{ID: 6, UserID: 2, WalletID: 1, CategoryID: 1, Amount: 15000, Type: "expense", Description: "Kopi", Date: lastMonth},
+	}
+	require.NoError(t, db.Create(&txns).Error)
+
+	indexed, err := search.IndexPending(context.Background(), 0, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 6, indexed)
+	indexed, err = search.IndexPending(context.Background(), 0, 100)
+	require.NoError(t, err)
+	assert.Zero(t, indexed, "already embedded")
+
+	result, err := search.Search(context.Background(), 1, "berapa kali saya beli kopi bulan lalu?", now)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, 3, result.Total)
+	assert.Equal(t, []entity.TransactionMatchMonth{
+		{Month: "2026-10", Count: 1, Expense: 25000},
+		{Month: "2026-09", Count: 2, Expense: 40000},
+	}, result.Monthly)
+	require.NotEmpty(t, result.Matches)
+	assert.Equal(t, uint(2), result.Matches[0].Transaction.ID, "closest match first")
+	assert.Equal(t, "Tunai", result.Matches[0].Transaction.Wallet.Name)
+
+	// Transaksi yang diubah di-embed ulang saat pencarian berikutnya.
+	require.NoError(t, db.Model(&entity.Transaction{}).Where("id = ?", 4).
+		Updates(map[string]interface{}{"description": "Kopi tubruk", "category_id": 1, "updated_at": time.Now().Add(time.Hour)}).Error)
+	result, err = search.Search(context.Background(), 1, "kopi", now)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Total)
+
+	result, err = search.Search(context.Background(), 1, "berapa total bulan ini?", now)
+	require.NoError(t, err)
+	assert.Nil(t, result, "nothing left to search for")
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/repository"
 	"errors"
@@ -25,6 +26,8 @@ type TransactionService interface {
 	GetReport(userID uint, startDate, endDate string, walletIDs []uint, filterType *string) ([]entity.CategoryBreakdown, error)
 	ExportTransactions(userID uint, params entity.TransactionFilterParams) (*bytes.Buffer, error)
 	ExportReport(userID uint, startDate, endDate string, walletIDs []uint, filterType *string) (*bytes.Buffer, error)
+	// WithContext mengembalikan salinan yang menulis transaksi dan saldo dengan actor/request_id dari ctx.
+	WithContext(ctx context.Context) TransactionService
 }
 
 type transactionService struct {
@@ -60,6 +63,15 @@ type TransferTransactionInput struct {
 	Date         time.Time `json:"date" binding:"required"`
 }
 
+func (s *transactionService) WithContext(ctx context.Context) TransactionService {
+	db := s.db.WithContext(ctx)
+	return &transactionService{
+		repo:       s.repo.WithTx(db),
+		walletRepo: s.walletRepo.WithTx(db),
+		db:         db,
+	}
+}
+
 func (s *transactionService) CreateTransaction(userID uint, input CreateTransactionInput) (*entity.Transaction, error) {
 	log.Info().Uint("user_id", userID).Str("type", input.Type).Msg("Starting transaction creation")
 	tx := s.db.Begin()
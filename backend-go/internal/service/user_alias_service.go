@@ -0,0 +1,107 @@
+package service
+
+import (
+	"cuan-backend/internal/entity"
+	"cuan-backend/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAlias  = errors.New("alias tidak valid")
+	ErrAliasNotFound = errors.New("alias tidak ditemukan")
+)
+
+// UserAliasService mengelola sebutan khusus user untuk dompet/kategori (mis. "jago" → "Jago Utama").
+// Alias disisipkan ke prompt chat supaya model memakai nama yang benar.
+type UserAliasService interface {
+	List(userID uint) ([]entity.UserAlias, error)
+	Set(userID uint, req *SetAliasRequest) (*entity.UserAlias, error)
+	Delete(id uint, userID uint) error
+}
+
+type userAliasService struct {
+	aliasRepo    repository.UserAliasRepository
+	walletRepo   repository.WalletRepository
+	categoryRepo repository.CategoryRepository
+}
+
+func NewUserAliasService(aliasRepo repository.UserAliasRepository, walletRepo repository.WalletRepository, categoryRepo repository.CategoryRepository) UserAliasService {
+	return &userAliasService{aliasRepo, walletRepo, categoryRepo}
+}
+
+type SetAliasRequest struct {
+	Alias  string `json:"alias" validate:"required"`
+	Target string `json:"target" validate:"required"` // nama dompet atau kategori
+}
+
+func (s *userAliasService) List(userID uint) ([]entity.UserAlias, error) {
+	return s.aliasRepo.FindByUserID(userID)
+}
+
+func (s *userAliasService) Set(userID uint, req *SetAliasRequest) (*entity.UserAlias, error) {
+	alias := strings.ToLower(strings.Join(strings.Fields(req.Alias), " "))
+	if alias == "" || len(alias) > 50 {
+		return nil, fmt.Errorf("%w: alias wajib diisi, maksimal 50 karakter", ErrInvalidAlias)
+	}
+
+	target, err := s.resolveTarget(userID, req.Target)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(alias, target) {
+		return nil, fmt.Errorf("%w: alias sama dengan nama aslinya", ErrInvalidAlias)
+	}
+
+	item := &entity.UserAlias{UserID: userID, Alias: alias, Target: target}
+	if err := s.aliasRepo.Upsert(item); err != nil {
+		log.Error().Err(err).Uint("user_id", userID).Msg("Failed to save user alias")
+		return nil, err
+	}
+	log.Info().Uint("user_id", userID).Str("alias", alias).Str("target", target).Msg("User alias saved")
+	return item, nil
+}
+
+// resolveTarget mencocokkan target dengan nama dompet atau kategori user (tanpa beda huruf besar)
+// dan mengembalikan penulisan aslinya.
+func (s *userAliasService) resolveTarget(userID uint, target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", fmt.Errorf("%w: target wajib diisi", ErrInvalidAlias)
+	}
+
+	var names []string
+	wallets, err := s.walletRepo.FindByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, w := range wallets {
+		names = append(names, w.Name)
+	}
+	categories, err := s.categoryRepo.FindAll(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range categories {
+		names = append(names, c.Name)
+	}
+
+	for _, name := range names {
+		if strings.EqualFold(name, target) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: '%s' bukan nama dompet atau kategori kamu", ErrInvalidAlias, target)
+}
+
+func (s *userAliasService) Delete(id uint, userID uint) error {
+	err := s.aliasRepo.Delete(id, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAliasNotFound
+	}
+	return err
+}
@@ -50,6 +50,10 @@ type UpdateProfileInput struct {
 	Email  string  `json:"email"`
 	Phone  *string `json:"phone"`
 	Payday *int    `json:"payday"` // Tanggal gajian (1-28), nil = tidak diubah
+
+	AIAutoCommitLimit *float64 `json:"ai_auto_commit_limit"` // nil = tidak diubah, 0 = selalu konfirmasi
+	AIConfirmReceipts *bool    `json:"ai_confirm_receipts"`  // nil = tidak diubah
+	ChatRetentionDays *int     `json:"chat_retention_days"`  // nil = tidak diubah, 0 = simpan selamanya
 }
 
 type ChangePasswordInput struct {
@@ -204,6 +208,22 @@ func (s *userService) UpdateProfile(id uint, input UpdateProfileInput) (*entity.
 		}
 		user.Payday = &p
 	}
+	// AIAutoCommitLimit: nilai negatif dianggap 0 (selalu konfirmasi)
+	if input.AIAutoCommitLimit != nil {
+		limit := *input.AIAutoCommitLimit
+		if limit < 0 {
+			limit = 0
+		}
+		user.AIAutoCommitLimit = &limit
+	}
+	if input.AIConfirmReceipts != nil {
+		user.AIConfirmReceipts = input.AIConfirmReceipts
+	}
+	// ChatRetentionDays: nilai negatif dianggap 0 (simpan selamanya)
+	if input.ChatRetentionDays != nil {
+		days := max(*input.ChatRetentionDays, 0)
+		user.ChatRetentionDays = &days
+	}
 
 	err = s.userRepository.Update(user)
 	if err != nil {
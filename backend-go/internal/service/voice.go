@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"cuan-backend/internal/entity"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoSpeech dikembalikan jika audio hanya berisi hening sehingga tidak ada yang bisa ditranskripsi.
+var ErrNoSpeech = errors.New("tidak ada suara yang terdeteksi di pesan suara")
+
+// voiceFilter memangkas hening di awal dan jeda panjang di tengah (disisakan 0,3 detik), lalu
+// menyamakan kerasnya suara. Whisper sering berhalusinasi pada hening panjang.
+const voiceFilter = "silenceremove=start_periods=1:start_silence=0.1:start_threshold=-45dB:" +
+	"stop_periods=-1:stop_duration=0.7:stop_silence=0.3:stop_threshold=-45dB," +
+	"loudnorm=I=-16:TP=-1.5:LRA=11"
+
+// minSpeechBytes: WAV 16 kHz mono 16-bit di bawah 0,3 detik dianggap tidak berisi suara.
+const minSpeechBytes = 44 + 16000*2*3/10
+
+// maxNoSpeechProb: segmen yang hampir pasti bukan ucapan dan confidence-nya rendah dibuang.
+const maxNoSpeechProb = 0.8
+
+type VoiceConfig struct {
+	// Languages adalah petunjuk bahasa (ISO-639-1) urut prioritas. Jika bahasa yang terdeteksi
+	// di luar daftar, audio ditranskripsi ulang dengan bahasa pertama.
+	Languages []string
+	// MinWordConfidence: kata dengan probabilitas di bawah ini dianggap kurang yakin.
+	MinWordConfidence float64
+	// FFmpeg adalah binary ffmpeg untuk normalisasi; kosong mematikan normalisasi.
+	FFmpeg string
+}
+
+func DefaultVoiceConfig() VoiceConfig {
+	return VoiceConfig{
+		Languages:         []string{"id", "en", "jv"},
+		MinWordConfidence: 0.5,
+		FFmpeg:            "ffmpeg",
+	}
+}
+
+// VoicePipeline menormalisasi audio, mentranskripsi dengan petunjuk bahasa, dan menandai
+// kata-kata yang kurang yakin.
+type VoicePipeline struct {
+	transcriber aiprovider.Transcriber
+	cfg         VoiceConfig
+}
+
+func NewVoicePipeline(transcriber aiprovider.Transcriber, cfg VoiceConfig) *VoicePipeline {
+	return &VoicePipeline{transcriber: transcriber, cfg: cfg}
+}
+
+func (p *VoicePipeline) Transcribe(ctx context.Context, path string) (*entity.VoiceTranscript, error) {
+	input, normalized := path, false
+	if p.cfg.FFmpeg != "" {
+		out, err := p.normalize(ctx, path)
+		switch {
+		case errors.Is(err, ErrNoSpeech):
+			return nil, err
+		case err != nil:
+			log.Warn().Err(err).Msg("Normalisasi audio gagal, memakai audio asli")
+		default:
+			defer os.Remove(out)
+			input, normalized = out, true
+		}
+	}
+
+	opts := aiprovider.TranscribeOptions{VAD: true}
+	if len(p.cfg.Languages) == 1 {
+		opts.Language = p.cfg.Languages[0]
+	}
+	t, err := p.transcriber.Transcribe(ctx, input, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.cfg.Languages) > 1 && t.Language != "" && !containsString(p.cfg.Languages, t.Language) {
+		log.Debug().Str("detected", t.Language).Str("retry", p.cfg.Languages[0]).Msg("Bahasa di luar petunjuk, transkripsi ulang")
+		opts.Language = p.cfg.Languages[0]
+		if retry, err := p.transcriber.Transcribe(ctx, input, opts); err == nil {
+			t = retry
+		} else {
+			log.Warn().Err(err).Msg("Transkripsi ulang gagal, memakai hasil pertama")
+		}
+	}
+
+	result := summarizeTranscript(t, p.cfg.MinWordConfidence)
+	result.Normalized = normalized
+	if result.Text == "" {
+		return nil, ErrNoSpeech
+	}
+	return result, nil
+}
+
+// normalize mengubah audio menjadi WAV 16 kHz mono yang sudah dipangkas heningnya.
+func (p *VoicePipeline) normalize(ctx context.Context, path string) (string, error) {
+	bin, err := exec.LookPath(p.cfg.FFmpeg)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg tidak tersedia: %w", err)
+	}
+	out := strings.TrimSuffix(path, filepath.Ext(path)) + "_norm.wav"
+	cmd := exec.CommandContext(ctx, bin, "-hide_banner", "-loglevel", "error", "-y",
+		"-i", path, "-af", voiceFilter, "-ac", "1", "-ar", "16000", out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(out)
+		return "", fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	info, err := os.Stat(out)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() < minSpeechBytes {
+		os.Remove(out)
+		return "", ErrNoSpeech
+	}
+	return out, nil
+}
+
+// summarizeTranscript membuang segmen yang bukan ucapan dan mengumpulkan kata yang kurang yakin.
+// Server yang tidak mengirim probabilitas per kata dinilai per segmen.
+func summarizeTranscript(t *aiprovider.Transcript, minConfidence float64) *entity.VoiceTranscript {
+	result := &entity.VoiceTranscript{Language: t.Language, Duration: t.Duration}
+	if len(t.Segments) == 0 {
+		result.Text = strings.TrimSpace(t.Text)
+		return result
+	}
+
+	hasWordProbs := false
+	for _, s := range t.Segments {
+		for _, w := range s.Words {
+			if w.Probability > 0 {
+				hasWordProbs = true
+			}
+		}
+	}
+
+	var texts []string
+	var total float64
+	seen := map[string]bool{}
+	flag := func(word string) {
+		word = strings.TrimFunc(word, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) })
+		if word != "" && !seen[strings.ToLower(word)] {
+			seen[strings.ToLower(word)] = true
+			result.LowConfidence = append(result.LowConfidence, word)
+		}
+	}
+	for _, s := range t.Segments {
+		if s.Text == "" || (s.NoSpeechProb >= maxNoSpeechProb && s.Confidence < minConfidence) {
+			continue
+		}
+		texts = append(texts, s.Text)
+		total += s.Confidence
+		result.Segments = append(result.Segments, entity.VoiceSegment{Start: s.Start, End: s.End, Text: s.Text, Confidence: s.Confidence})
+
+		if !hasWordProbs {
+			if s.Confidence < minConfidence {
+				flag(s.Text)
+			}
+			continue
+		}
+		for _, w := range s.Words {
+			if w.Probability < minConfidence {
+				flag(w.Word)
+			}
+		}
+	}
+	result.Text = strings.Join(texts, " ")
+	if len(result.Segments) > 0 {
+		result.Confidence = total / float64(len(result.Segments))
+	}
+	return result
+}
+
+// voiceMessagePrefix menandai transkripsi di pesan ke LLM agar model mengoreksi salah dengar.
+const voiceMessagePrefix = "[TRANSKRIPSI SUARA - mungkin ada kesalahan fonetik, tolong koreksi]: "
+
+// AppendTranscript menambahkan transkripsi pesan suara ke pesan teks (jika ada), beserta daftar
+// kata yang kurang jelas supaya model bisa menanyakannya.
+func AppendTranscript(message string, t *entity.VoiceTranscript) string {
+	text := voiceMessagePrefix + t.Text
+	if t.NeedsConfirmation() {
+		text += "\n[Kata yang kurang jelas: " + quoteWords(t.LowConfidence) + "]"
+	}
+	if message == "" {
+		return text
+	}
+	return message + "\n\n" + text
+}
+
+// VoiceConfirmationReason adalah alasan draft untuk transaksi dari pesan suara yang kurang jelas.
+func VoiceConfirmationReason(t *entity.VoiceTranscript) string {
+	return "suara kurang jelas: " + quoteWords(t.LowConfidence)
+}
+
+func quoteWords(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = `"` + w + `"`
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,83 @@
+package service_test
+
+import (
+	"context"
+	aiprovider "cuan-backend/internal/provider/ai"
+	"cuan-backend/internal/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTranscriber struct {
+	results []*aiprovider.Transcript
+	calls   []aiprovider.TranscribeOptions
+}
+
+func (s *stubTranscriber) Transcribe(ctx context.Context, path string, opts aiprovider.TranscribeOptions) (*aiprovider.Transcript, error) {
+	s.calls = append(s.calls, opts)
+	return s.results[len(s.calls)-1], nil
+}
+
+func TestVoicePipeline_FlagsLowConfidenceWords(t *testing.T) {
+	stub := &stubTranscriber{results: []*aiprovider.Transcript{{
+		Language: "id",
+		Duration: 4,
+		Segments: []aiprovider.TranscriptSegment{
+			{Start: 0, End: 3, Text: "beli kopi pakai gopek", Confidence: 0.8, Words: []aiprovider.TranscriptWord{
+				{Word: " beli", Probability: 0.97}, {Word: " kopi", Probability: 0.9}, {Word: " pakai", Probability: 0.85}, {Word: " gopek,", Probability: 0.3},
+			}},
+			{Start: 3, End: 4, Text: "Gopek.", Confidence: 0.6, Words: []aiprovider.TranscriptWord{{Word: " Gopek.", Probability: 0.4}}},
+			// Hening yang dihalusinasi Whisper.
+			{Start: 4, End: 5, Text: "Terima kasih.", Confidence: 0.2, NoSpeechProb: 0.9},
+		},
+	}}}
+	cfg := service.DefaultVoiceConfig()
+	cfg.FFmpeg = ""
+
+	tr, err := service.NewVoicePipeline(stub, cfg).Transcribe(context.Background(), "voice.ogg")
+	require.NoError(t, err)
+
+	require.Len(t, stub.calls, 1)
+	assert.Equal(t, "", stub.calls[0].Language)
+	assert.True(t, stub.calls[0].VAD)
+	assert.Equal(t, "beli kopi pakai gopek Gopek.", tr.Text)
+	assert.Equal(t, []string{"gopek"}, tr.LowConfidence)
+	assert.Len(t, tr.Segments, 2)
+	assert.InDelta(t, 0.7, tr.Confidence, 1e-9)
+	assert.False(t, tr.Normalized)
+	assert.True(t, tr.NeedsConfirmation())
+
+	msg := service.AppendTranscript("", tr)
+	assert.Equal(t, "[TRANSKRIPSI SUARA - mungkin ada kesalahan fonetik, tolong koreksi]: beli kopi pakai gopek Gopek.\n[Kata yang kurang jelas: \"gopek\"]", msg)
+}
+
+func TestVoicePipeline_RetriesOutsideLanguageHints(t *testing.T) {
+	stub := &stubTranscriber{results: []*aiprovider.Transcript{
+		{Language: "ms", Segments: []aiprovider.TranscriptSegment{{Text: "beli kopi", Confidence: 0.3}}},
+		{Language: "id", Segments: []aiprovider.TranscriptSegment{{Text: "beli kopi", Confidence: 0.9}}},
+	}}
+	cfg := service.VoiceConfig{Languages: []string{"id", "en", "jv"}, MinWordConfidence: 0.5}
+
+	tr, err := service.NewVoicePipeline(stub, cfg).Transcribe(context.Background(), "voice.ogg")
+	require.NoError(t, err)
+
+	require.Len(t, stub.calls, 2)
+	assert.Equal(t, "id", stub.calls[1].Language)
+	assert.Equal(t, "id", tr.Language)
+	// Tanpa probabilitas per kata, segmen yang yakin tidak ditandai.
+	assert.Empty(t, tr.LowConfidence)
+}
+
+func TestVoicePipeline_NoSpeech(t *testing.T) {
+	stub := &stubTranscriber{results: []*aiprovider.Transcript{{
+		Language: "id",
+		Segments: []aiprovider.TranscriptSegment{{Text: "Terima kasih.", Confidence: 0.1, NoSpeechProb: 0.95}},
+	}}}
+	cfg := service.VoiceConfig{Languages: []string{"id"}, MinWordConfidence: 0.5}
+
+	_, err := service.NewVoicePipeline(stub, cfg).Transcribe(context.Background(), "voice.ogg")
+	assert.ErrorIs(t, err, service.ErrNoSpeech)
+	assert.Equal(t, "id", stub.calls[0].Language)
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
 	"cuan-backend/internal/entity"
 	"cuan-backend/internal/repository"
 
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 type CreateWalletInput struct {
@@ -28,15 +30,23 @@ type WalletService interface {
 	GetUserWallets(userID uint) ([]entity.Wallet, error)
 	UpdateWallet(id uint, userID uint, input UpdateWalletInput) (*entity.Wallet, error)
 	DeleteWallet(id uint, userID uint) error
+	// WithContext mengikat ctx ke perubahan dompet agar tercatat di audit.
+	WithContext(ctx context.Context) WalletService
 }
 
 type walletService struct {
 	walletRepository repository.WalletRepository
 	savingGoalRepo   repository.SavingGoalRepository
+	db               *gorm.DB
 }
 
-func NewWalletService(walletRepository repository.WalletRepository, savingGoalRepo repository.SavingGoalRepository) WalletService {
-	return &walletService{walletRepository, savingGoalRepo}
+func NewWalletService(walletRepository repository.WalletRepository, savingGoalRepo repository.SavingGoalRepository, db *gorm.DB) WalletService {
+	return &walletService{walletRepository, savingGoalRepo, db}
+}
+
+func (s *walletService) WithContext(ctx context.Context) WalletService {
+	db := s.db.WithContext(ctx)
+	return &walletService{s.walletRepository.WithTx(db), s.savingGoalRepo.WithTx(db), db}
 }
 
 func (s *walletService) CreateWallet(input CreateWalletInput) (*entity.Wallet, error) {
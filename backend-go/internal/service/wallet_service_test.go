@@ -16,7 +16,7 @@ import (
 func TestCreateWallet(t *testing.T) {
 	mockRepo := new(mock.WalletRepositoryMock)
 	mockSavingRepo := new(mock.SavingGoalRepositoryMock)
-	walletService := service.NewWalletService(mockRepo, mockSavingRepo)
+	walletService := service.NewWalletService(mockRepo, mockSavingRepo, nil)
 
 	input := service.CreateWalletInput{
 		UserID:  1,
@@ -39,7 +39,7 @@ func TestCreateWallet(t *testing.T) {
 func TestGetWalletByID_Success(t *testing.T) {
 	mockRepo := new(mock.WalletRepositoryMock)
 	mockSavingRepo := new(mock.SavingGoalRepositoryMock)
-	walletService := service.NewWalletService(mockRepo, mockSavingRepo)
+	walletService := service.NewWalletService(mockRepo, mockSavingRepo, nil)
 
 	wallet := &entity.Wallet{ID: 1, UserID: 1, Name: "My Wallet"}
 
@@ -55,7 +55,7 @@ func TestGetWalletByID_Success(t *testing.T) {
 func TestGetWalletByID_NotFound(t *testing.T) {
 	mockRepo := new(mock.WalletRepositoryMock)
 	mockSavingRepo := new(mock.SavingGoalRepositoryMock)
-	walletService := service.NewWalletService(mockRepo, mockSavingRepo)
+	walletService := service.NewWalletService(mockRepo, mockSavingRepo, nil)
 
 	mockRepo.On("FindByID", uint(1), uint(1)).Return(nil, errors.New("record not found"))
 
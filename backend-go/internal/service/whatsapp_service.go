@@ -1,16 +1,14 @@
 package service
 
 import (
-	"bytes"
+	"context"
 	"cuan-backend/internal/entity"
+	"cuan-backend/internal/provider/whatsapp"
 	"cuan-backend/internal/repository"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -23,7 +21,7 @@ func writeFile(path string, data []byte) error {
 
 
 type WhatsAppService interface {
-	ProcessMessage(event entity.WAWebhookEvent) error
+	ProcessMessage(ctx context.Context, event entity.WAWebhookEvent) error
 }
 
 type whatsAppService struct {
@@ -31,9 +29,8 @@ type whatsAppService struct {
 	aiSvc          AIService
 	chatbotSvc     *ChatbotService
 	chatHistSvc    ChatHistoryService
-	waGatewayURL  string
-	waGatewayUser string
-	waGatewayPass string
+	gateway        whatsapp.Client
+	attachments    AttachmentService
 }
 
 func NewWhatsAppService(
@@ -41,20 +38,20 @@ func NewWhatsAppService(
 	aiSvc AIService,
 	chatbotSvc *ChatbotService,
 	chatHistSvc ChatHistoryService,
-	waGatewayURL string,
+	gateway whatsapp.Client,
+	attachments AttachmentService,
 ) WhatsAppService {
 	return &whatsAppService{
-		userRepo:      userRepo,
-		aiSvc:         aiSvc,
-		chatbotSvc:    chatbotSvc,
-		chatHistSvc:   chatHistSvc,
-		waGatewayURL:  waGatewayURL,
-		waGatewayUser: os.Getenv("WA_GATEWAY_USERNAME"),
-		waGatewayPass: os.Getenv("WA_GATEWAY_PASSWORD"),
+		userRepo:    userRepo,
+		aiSvc:       aiSvc,
+		chatbotSvc:  chatbotSvc,
+		chatHistSvc: chatHistSvc,
+		gateway:     gateway,
+		attachments: attachments,
 	}
 }
 
-func (s *whatsAppService) ProcessMessage(event entity.WAWebhookEvent) error {
+func (s *whatsAppService) ProcessMessage(ctx context.Context, event entity.WAWebhookEvent) error {
 	msg := event.Payload
 
 	if msg.IsFromMe {
@@ -73,6 +70,26 @@ func (s *whatsAppService) ProcessMessage(event entity.WAWebhookEvent) error {
 				"Silakan login ke aplikasi web, lalu masuk ke menu *Profil* dan isi kolom *Nomor HP* dengan nomor ini: "+phone)
 	}
 
+	if msg.Audio == "" && msg.Image == "" && isUndoKeyword(msg.Body) {
+		return s.undoLastBatch(ctx, user.ID, msg.ChatID, event.DeviceID)
+	}
+
+	if msg.Audio == "" && msg.Image == "" {
+		if confirm, ok := draftReply(msg.Body); ok {
+			if handled, err := s.resolveLatestDraft(ctx, user.ID, confirm, msg.Body, msg.ChatID, event.DeviceID); handled {
+				return err
+			}
+		}
+	}
+
+	// Cek batas sebelum mengunduh, mentranskripsi dan menyimpan pesan agar pesan yang pasti
+	// ditolak tidak memakai waktu Whisper atau meninggalkan giliran user tanpa jawaban.
+	scope := AIScope{UserID: user.ID, Channel: entity.AIChannelWhatsApp, Voice: msg.Audio != ""}
+	if err := s.aiSvc.WithScope(scope).CheckRateLimit(); err != nil {
+		log.Info().Uint("user_id", user.ID).Msg("[WA] Rate limit AI tercapai")
+		return s.sendWAMessage(msg.ChatID, event.DeviceID, "⏳ "+err.Error())
+	}
+
 	var processingMsg string
 	switch {
 	case msg.Audio != "":
@@ -86,7 +103,7 @@ func (s *whatsAppService) ProcessMessage(event entity.WAWebhookEvent) error {
 
 	text := strings.TrimSpace(msg.Body)
 	var imageBase64 string
-	var audioTranscription string
+	var transcript *entity.VoiceTranscript
 
 	var savedAudioURL, savedImageURL string
 
@@ -98,23 +115,22 @@ func (s *whatsAppService) ProcessMessage(event entity.WAWebhookEvent) error {
 			return err
 		}
 		
-		// Simpan audio ke file sistem
-		audioFilename := fmt.Sprintf("wa_audio_%d_%d.ogg", user.ID, time.Now().UnixNano())
-		audioPath := filepath.Join("uploads", "audio", audioFilename)
-		_ = os.MkdirAll(filepath.Dir(audioPath), 0755)
-		if err := os.WriteFile(audioPath, audioData, 0644); err == nil {
-			savedAudioURL = "/uploads/audio/" + audioFilename
+		// Simpan audio ke blob storage
+		if key, err := s.attachments.SaveBytes(user.ID, "wa_audio.ogg", "audio/ogg", audioData); err == nil {
+			savedAudioURL = key
 		} else {
-			log.Warn().Err(err).Msg("[WA] Gagal menyimpan audio WA ke disk")
+			log.Warn().Err(err).Msg("[WA] Gagal menyimpan audio WA")
 		}
 
-		transcription, err := s.transcribeAudio(audioData, msg.Audio)
+		transcript, err = s.transcribeAudio(user.ID, audioData, msg.Audio)
+		if errors.Is(err, ErrNoSpeech) {
+			return s.sendWAMessage(msg.ChatID, event.DeviceID, "🔇 Pesan suaranya tidak terdengar. Coba rekam ulang lebih dekat ke mikrofon.")
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("[WA] Gagal transkripsi audio")
 			_ = s.sendWAMessage(msg.ChatID, event.DeviceID, "❌ Gagal membaca pesan suara. Coba kirim ulang atau ketik pesannya.")
 			return err
 		}
-		audioTranscription = transcription
 	}
 
 	if msg.Image != "" {
@@ -125,14 +141,11 @@ func (s *whatsAppService) ProcessMessage(event entity.WAWebhookEvent) error {
 			return err
 		}
 		
-		// Simpan gambar ke file sistem
-		imageFilename := fmt.Sprintf("wa_image_%d_%d.jpg", user.ID, time.Now().UnixNano())
-		imagePath := filepath.Join("uploads", "images", imageFilename)
-		_ = os.MkdirAll(filepath.Dir(imagePath), 0755)
-		if err := os.WriteFile(imagePath, imgData, 0644); err == nil {
-			savedImageURL = "/uploads/images/" + imageFilename
+		// Simpan gambar ke blob storage
+		if key, err := s.attachments.SaveBytes(user.ID, "wa_image.jpg", "image/jpeg", imgData); err == nil {
+			savedImageURL = key
 		} else {
-			log.Warn().Err(err).Msg("[WA] Gagal menyimpan gambar WA ke disk")
+			log.Warn().Err(err).Msg("[WA] Gagal menyimpan gambar WA")
 		}
 
 		imageBase64 = base64.StdEncoding.EncodeToString(imgData)
@@ -141,26 +154,36 @@ func (s *whatsAppService) ProcessMessage(event entity.WAWebhookEvent) error {
 		}
 	}
 
-	if audioTranscription != "" {
-		prefix := "[TRANSKRIPSI SUARA - mungkin ada kesalahan fonetik, tolong koreksi]: "
-		if text == "" {
-			text = prefix + audioTranscription
-		} else {
-			text = text + "\n\n" + prefix + audioTranscription
-		}
+	if transcript != nil {
+		text = AppendTranscript(text, transcript)
 	}
 
 	if text == "" {
 		return nil
 	}
 
-	if err := s.chatHistSvc.SaveMessage(user.ID, "user", text, savedAudioURL, savedImageURL); err != nil {
+	if err := s.chatHistSvc.SaveMessage(user.ID, "user", text, savedAudioURL, savedImageURL, transcript); err != nil {
 		log.Warn().Err(err).Msg("[WA] Gagal simpan pesan user")
 	}
 
-	userContext := s.chatbotSvc.GetUserContext(user.ID, text)
+	prompt := s.chatbotSvc.ChatPrompt(user.ID, text)
+	conv := s.chatHistSvc.BuildConversation(user.ID, text)
 
-	aiResp, err := s.aiSvc.Chat(text, imageBase64, userContext)
+	tools := s.chatbotSvc.WithContext(ctx).NewToolSession(user.ID, text, imageBase64 != "").AttachImage(savedImageURL)
+	if transcript.NeedsConfirmation() {
+		tools.RequireConfirmation(VoiceConfirmationReason(transcript))
+	}
+	aiResp, err := s.aiSvc.WithScope(scope).Chat(text, imageBase64, prompt, conv, tools)
+	savedTxs := tools.Finish()
+	if err != nil && tools.Committed() {
+		// Transaksi sudah tersimpan: kirim ringkasannya agar user tidak mengirim ulang.
+		log.Warn().Err(err).Uint("user_id", user.ID).Int("saved", len(savedTxs)).Msg("[WA] AI Chat gagal setelah tool call")
+		aiResp, err = &entity.ChatAIResponse{Reply: InterruptedReply(err)}, nil
+	}
+	if errors.Is(err, ErrAIRateLimited) {
+		log.Info().Uint("user_id", user.ID).Msg("[WA] Rate limit AI tercapai")
+		return s.sendWAMessage(msg.ChatID, event.DeviceID, "⏳ "+err.Error())
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("[WA] AI Chat gagal")
 		_ = s.sendWAMessage(msg.ChatID, event.DeviceID,
@@ -169,62 +192,184 @@ func (s *whatsAppService) ProcessMessage(event entity.WAWebhookEvent) error {
 	}
 
 	replyText := aiResp.Reply
-
-	if aiResp.IsTransaction && len(aiResp.Transactions) > 0 {
-		saved, err := s.chatbotSvc.SaveTransactions(user.ID, aiResp.Transactions)
-		if err != nil {
-			log.Error().Err(err).Msg("[WA] SaveTransactions gagal")
-			replyText += "\n\n⚠️ Transaksi terdeteksi tapi gagal disimpan."
-		} else if len(saved) > 0 {
-			summary := "\n\n✅ Transaksi berhasil dicatat!"
-			for _, s := range saved {
-				summary += fmt.Sprintf("\n📝 %s — Rp%.0f (%s) | 🏦 %s | 📂 %s",
-					s.Description, s.Amount, s.Type, s.WalletName, s.CategoryName)
-			}
-			replyText += summary
+	if len(savedTxs) > 0 {
+		summary := "\n\n✅ Transaksi berhasil dicatat!"
+		for _, s := range savedTxs {
+			summary += fmt.Sprintf("\n📝 %s — Rp%.0f (%s) | 🏦 %s | 📂 %s%s",
+				s.Description, s.Amount, s.Type, s.WalletName, s.CategoryName, FormatDateLabel(s.Date))
 		}
+		replyText += summary
+	}
+	replyText += tools.Summary()
+	if tools.Draft() != nil {
+		replyText += "\n\nBalas *ya* untuk menyimpan atau *tidak* untuk membatalkan."
 	}
 
-	if err := s.chatHistSvc.SaveMessage(user.ID, "assistant", replyText, "", ""); err != nil {
+	sendErr := s.sendWAMessage(msg.ChatID, event.DeviceID, replyText)
+
+	// Pesan suara dibalas juga dengan voice note.
+	var replyAudio string
+	if msg.Audio != "" {
+		replyAudio = s.sendVoiceReply(user.ID, msg.ChatID, aiResp.Reply)
+	}
+
+	if err := s.chatHistSvc.SaveReply(user.ID, replyText, savedTxs, prompt.Version, replyAudio); err != nil {
 		log.Warn().Err(err).Msg("[WA] Gagal simpan balasan AI")
 	}
-	return s.sendWAMessage(msg.ChatID, event.DeviceID, replyText)
+	return sendErr
 }
 
-func extractPhone(jid string) string {
-	parts := strings.Split(jid, "@")
-	if len(parts) == 0 {
+// sendVoiceReply membacakan balasan AI dan mengirimnya sebagai voice note lewat wa-gateway.
+// Mengembalikan key audio yang disimpan, atau kosong jika TTS tidak aktif atau gagal.
+func (s *whatsAppService) sendVoiceReply(userID uint, chatID, reply string) string {
+	audio, err := s.aiSvc.Speak(reply)
+	if errors.Is(err, ErrSpeechDisabled) {
 		return ""
 	}
-	return parts[0]
+	if err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("[WA] Gagal membuat balasan suara")
+		return ""
+	}
+	if err := s.gateway.SendAudio(chatID, "balasan.ogg", audio); err != nil {
+		log.Warn().Err(err).Uint("user_id", userID).Msg("[WA] Gagal mengirim balasan suara")
+		return ""
+	}
+	key, err := s.attachments.SaveBytes(userID, "wa_reply.ogg", "audio/ogg", audio)
+	if err != nil {
+		log.Warn().Err(err).Msg("[WA] Gagal menyimpan balasan suara")
+		return ""
+	}
+	return key
 }
 
-func (s *whatsAppService) downloadMediaFromGateway(mediaPath string) ([]byte, error) {
-	url := s.waGatewayURL + "/" + strings.TrimPrefix(mediaPath, "/")
-	client := &http.Client{Timeout: 30 * time.Second}
+// undoKeywords adalah pesan WA yang membatalkan aksi AI terakhir tanpa melewati LLM.
+var undoKeywords = map[string]bool{
+	"batal":    true,
+	"batalkan": true,
+	"batalin":  true,
+	"undo":     true,
+	"/batal":   true,
+	"/undo":    true,
+}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("gagal membuat request: %w", err)
+func isUndoKeyword(body string) bool {
+	normalized := strings.ToLower(strings.TrimSpace(body))
+	normalized = strings.TrimRight(normalized, ".!")
+	return undoKeywords[normalized]
+}
+
+func (s *whatsAppService) undoLastBatch(ctx context.Context, userID uint, chatID, deviceID string) error {
+	batch, err := s.chatbotSvc.WithContext(ctx).UndoLastBatch(userID)
+	var reply string
+	switch {
+	case errors.Is(err, ErrNothingToUndo):
+		reply = "ℹ️ Tidak ada transaksi dari AI yang bisa dibatalkan."
+	case errors.Is(err, ErrNotUndoable):
+		reply = "ℹ️ " + err.Error() + ". Hapus atau koreksi lewat menu terkait di aplikasi."
+	case err != nil:
+		log.Error().Err(err).Uint("user_id", userID).Msg("[WA] Undo batch AI gagal")
+		reply = "❌ Gagal membatalkan aksi terakhir: " + err.Error()
+	default:
+		reply = FormatUndoSummary(batch)
 	}
-	if s.waGatewayUser != "" {
-		req.SetBasicAuth(s.waGatewayUser, s.waGatewayPass)
+
+	if err := s.chatHistSvc.SaveReply(userID, reply, nil, "", ""); err != nil {
+		log.Warn().Err(err).Msg("[WA] Gagal simpan balasan undo")
 	}
+	return s.sendWAMessage(chatID, deviceID, reply)
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("gagal mengunduh media: %w", err)
+// draftReplies adalah jawaban WA untuk draft AI yang menunggu konfirmasi: true = simpan, false = buang.
+var draftReplies = map[string]bool{
+	"ya":     true,
+	"iya":    true,
+	"y":      true,
+	"yes":    true,
+	"ok":     true,
+	"oke":    true,
+	"simpan": true,
+	"tidak":  false,
+	"gak":    false,
+	"nggak":  false,
+	"enggak": false,
+	"no":     false,
+	"n":      false,
+	"jangan": false,
+}
+
+func draftReply(body string) (confirm bool, ok bool) {
+	normalized := strings.ToLower(strings.TrimSpace(body))
+	normalized = strings.TrimRight(normalized, ".!")
+	confirm, ok = draftReplies[normalized]
+	return confirm, ok
+}
+
+// resolveLatestDraft mengonfirmasi atau membuang draft pending terbaru. handled bernilai false jika
+// user tidak punya draft pending, sehingga pesan diteruskan ke AI seperti biasa.
+func (s *whatsAppService) resolveLatestDraft(ctx context.Context, userID uint, confirm bool, body, chatID, deviceID string) (handled bool, err error) {
+	drafts, err := s.chatbotSvc.PendingDrafts(userID)
+	if err != nil || len(drafts) == 0 {
+		return false, nil
 	}
-	defer resp.Body.Close()
+	draft := drafts[0]
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("wa-gateway mengembalikan status %d untuk %s", resp.StatusCode, mediaPath)
+	if err := s.chatHistSvc.SaveMessage(userID, "user", strings.TrimSpace(body), "", "", nil); err != nil {
+		log.Warn().Err(err).Msg("[WA] Gagal simpan pesan user")
+	}
+
+	var reply string
+	var saved []entity.SavedTransaction
+	if confirm {
+		var summary string
+		saved, summary, err = s.chatbotSvc.WithContext(ctx).ConfirmDraft(userID, draft.ID, nil)
+		switch {
+		case errors.Is(err, ErrDraftNotFound):
+			reply = "ℹ️ Draft sudah diproses atau kedaluwarsa."
+		case err != nil && len(saved) == 0 && summary == "":
+			log.Error().Err(err).Uint("user_id", userID).Uint("draft_id", draft.ID).Msg("[WA] Konfirmasi draft gagal")
+			reply = "❌ Gagal menyimpan draft: " + err.Error()
+		default:
+			reply = fmt.Sprintf("✅ Draft #%d disimpan!", draft.ID)
+			for _, t := range saved {
+				reply += fmt.Sprintf("\n📝 %s — Rp%.0f (%s) | 🏦 %s | 📂 %s",
+					t.Description, t.Amount, t.Type, t.WalletName, t.CategoryName)
+			}
+			reply += summary
+			if err != nil {
+				reply += "\n\n⚠️ " + err.Error()
+			}
+		}
+	} else {
+		if err := s.chatbotSvc.RejectDraft(userID, draft.ID); err != nil && !errors.Is(err, ErrDraftNotFound) {
+			log.Error().Err(err).Uint("user_id", userID).Uint("draft_id", draft.ID).Msg("[WA] Pembatalan draft gagal")
+			reply = "❌ Gagal membatalkan draft: " + err.Error()
+		} else {
+			reply = fmt.Sprintf("🗑️ Draft #%d dibatalkan, tidak ada yang disimpan.", draft.ID)
+		}
+	}
+	if len(drafts) > 1 {
+		reply += fmt.Sprintf("\n\nMasih ada %d draft lain yang menunggu konfirmasi:\n%s\nBalas *ya* atau *tidak*.", len(drafts)-1, FormatDraftSummary(&drafts[1]))
+	}
+
+	if err := s.chatHistSvc.SaveReply(userID, reply, saved, "", ""); err != nil {
+		log.Warn().Err(err).Msg("[WA] Gagal simpan balasan draft")
+	}
+	return true, s.sendWAMessage(chatID, deviceID, reply)
+}
+
+func extractPhone(jid string) string {
+	parts := strings.Split(jid, "@")
+	if len(parts) == 0 {
+		return ""
 	}
+	return parts[0]
+}
 
-	return io.ReadAll(resp.Body)
+func (s *whatsAppService) downloadMediaFromGateway(mediaPath string) ([]byte, error) {
+	return s.gateway.DownloadMedia(mediaPath)
 }
 
-func (s *whatsAppService) transcribeAudio(data []byte, originalPath string) (string, error) {
+func (s *whatsAppService) transcribeAudio(userID uint, data []byte, originalPath string) (*entity.VoiceTranscript, error) {
 	ext := ".ogg"
 	lower := strings.ToLower(originalPath)
 	for _, candidate := range []string{".ogg", ".mp4", ".webm", ".m4a", ".aac", ".wav"} {
@@ -236,46 +381,14 @@ func (s *whatsAppService) transcribeAudio(data []byte, originalPath string) (str
 
 	tmpPath := fmt.Sprintf("/tmp/wa_audio_%d%s", time.Now().UnixNano(), ext)
 	if err := writeFile(tmpPath, data); err != nil {
-		return "", fmt.Errorf("gagal tulis file temp: %w", err)
+		return nil, fmt.Errorf("gagal tulis file temp: %w", err)
 	}
+	defer os.Remove(tmpPath)
 
-	return s.aiSvc.ProcessVoice(tmpPath)
+	return s.aiSvc.WithScope(AIScope{UserID: userID, Channel: entity.AIChannelWhatsApp, Voice: true}).ProcessVoice(tmpPath)
 }
 
 func (s *whatsAppService) sendWAMessage(chatID, deviceID, text string) error {
-	payload := map[string]interface{}{
-		"phone":   chatID,
-		"message": text,
-	}
-
-	url := s.waGatewayURL + "/send/message"
 	log.Debug().Str("chatID", chatID).Str("deviceID", deviceID).Msg("[WA] sendWAMessage")
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("gagal marshal payload: %w", err)
-	}
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
-	if err != nil {
-		return fmt.Errorf("gagal membuat request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if s.waGatewayUser != "" {
-		req.SetBasicAuth(s.waGatewayUser, s.waGatewayPass)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("gagal mengirim pesan WA: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("wa-gateway error %d: %s", resp.StatusCode, string(body))
-	}
-
-	return nil
+	return s.gateway.SendMessage(chatID, text)
 }
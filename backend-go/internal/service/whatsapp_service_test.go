@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type speakingAIService struct {
+	AIService
+	audio []byte
+	err   error
+}
+
+func (s *speakingAIService) Speak(text string) ([]byte, error) { return s.audio, s.err }
+
+type savedAttachments struct {
+	AttachmentService
+	saved []string
+}
+
+func (a *savedAttachments) SaveBytes(userID uint, filename, contentType string, data []byte) (string, error) {
+	a.saved = append(a.saved, filename)
+	return "audio/1/" + filename, nil
+}
+
+func TestWhatsAppService_SendVoiceReply(t *testing.T) {
+	gateway := &stubWAClient{}
+	attachments := &savedAttachments{}
+	svc := &whatsAppService{aiSvc: &speakingAIService{audio: []byte("OggS")}, gateway: gateway, attachments: attachments}
+
+	key := svc.sendVoiceReply(1, "628123@s.whatsapp.net", "Kopi Rp20.000 sudah dicatat.")
+	assert.Equal(t, "audio/1/wa_reply.ogg", key)
+	assert.Equal(t, []string{"628123@s.whatsapp.net/balasan.ogg"}, gateway.audio)
+	assert.Equal(t, []string{"wa_reply.ogg"}, attachments.saved)
+}
+
+func TestWhatsAppService_SendVoiceReplySkipsWhenSpeechUnavailable(t *testing.T) {
+	gateway := &stubWAClient{}
+	attachments := &savedAttachments{}
+	svc := &whatsAppService{aiSvc: &speakingAIService{err: ErrSpeechDisabled}, gateway: gateway, attachments: attachments}
+
+	assert.Empty(t, svc.sendVoiceReply(1, "628123", "halo"))
+	assert.Empty(t, gateway.audio)
+
+	// Voice note yang gagal terkirim tidak disimpan sebagai balasan audio.
+	svc.aiSvc = &speakingAIService{audio: []byte("OggS")}
+	gateway.audioErr = errors.New("device offline")
+	assert.Empty(t, svc.sendVoiceReply(1, "628123", "halo"))
+	assert.Empty(t, attachments.saved)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ParseAdminIDs reads a comma-separated list of user IDs such as ADMIN_USER_IDS; invalid entries
+// are skipped.
+func ParseAdminIDs(value string) map[uint]bool {
+	ids := map[uint]bool{}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err == nil && id > 0 {
+			ids[uint(id)] = true
+		}
+	}
+	return ids
+}
+
+// AdminOnly lets through only the given users. It must run after Protected, which sets userID;
+// with an empty list every request is forbidden.
+func AdminOnly(adminIDs map[uint]bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || !adminIDs[userID] {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Admin access required",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"os"
 	"strings"
 	"time"
@@ -29,43 +30,68 @@ func GenerateToken(userID uint, expiry time.Duration) (string, error) {
 	return token.SignedString(getSecretKey())
 }
 
-func Protected() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Missing or malformed JWT",
-			})
-		}
+var (
+	errMissingToken = errors.New("Missing or malformed JWT")
+	errTokenFormat  = errors.New("Invalid token format")
+	errInvalidToken = errors.New("Invalid or expired token")
+)
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Invalid token format",
-			})
+// parseToken validates the Bearer token in the Authorization header and returns its claims.
+// The returned error's message is safe to send back to the client.
+func parseToken(c *fiber.Ctx) (jwt.MapClaims, error) {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return nil, errMissingToken
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, errTokenFormat
+	}
+
+	token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fiber.ErrUnauthorized
 		}
+		return getSecretKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
 
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.ErrUnauthorized
-			}
-			return getSecretKey(), nil
-		})
+	claims, _ := token.Claims.(jwt.MapClaims)
+	return claims, nil
+}
+
+// setUserID stores the user_id claim in c.Locals when present. A valid token without
+// the claim is left to the handlers, which treat a missing userID as unauthenticated.
+func setUserID(c *fiber.Ctx, claims jwt.MapClaims) {
+	if userID, ok := claims["user_id"].(float64); ok {
+		c.Locals("userID", uint(userID))
+	}
+}
 
-		if err != nil || !token.Valid {
+func Protected() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, err := parseToken(c)
+		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Invalid or expired token",
+				"message": err.Error(),
 			})
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if ok && token.Valid {
-			if userID, ok := claims["user_id"].(float64); ok {
-				c.Locals("userID", uint(userID))
-			}
-		}
+		setUserID(c, claims)
+		return c.Next()
+	}
+}
 
+// OptionalAuth sets userID when a valid Bearer token is present but never rejects
+// the request, so handlers can fall back to other credentials (e.g. signed URLs).
+func OptionalAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if claims, err := parseToken(c); err == nil {
+			setUserID(c, claims)
+		}
 		return c.Next()
 	}
 }
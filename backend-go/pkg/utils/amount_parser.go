@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AmountMatch is one rupiah amount found in a message. Start and End are byte offsets into
+// strings.ToLower(text).
+type AmountMatch struct {
+	Value float64
+	Start int
+	End   int
+
+	scale float64 // largest unit used (1000 for "rb"), to join "1jt 200rb"
+}
+
+var amountSuffixes = map[string]float64{
+	"rb": 1e3, "ribu": 1e3, "rebu": 1e3, "k": 1e3,
+	"jt": 1e6, "juta": 1e6,
+	"miliar": 1e9, "milyar": 1e9,
+}
+
+// Amount words; "puluh", "belas" and "ratus" multiply the preceding digit word.
+var amountWords = map[string]float64{
+	"nol": 0, "satu": 1, "dua": 2, "tiga": 3, "empat": 4, "lima": 5,
+	"enam": 6, "tujuh": 7, "delapan": 8, "sembilan": 9,
+	"sepuluh": 10, "sebelas": 11, "seratus": 100, "setengah": 0.5,
+	"belas": 0, "puluh": 0, "ratus": 0,
+	"ribu": 1e3, "rebu": 1e3, "juta": 1e6, "miliar": 1e9, "milyar": 1e9,
+	"seribu": 1e3, "sejuta": 1e6,
+}
+
+// Betawi/Hokkien slang that is common in chat.
+var amountSlang = map[string]float64{
+	"cepek": 100, "gopek": 500, "seceng": 1e3, "goceng": 5e3, "ceban": 1e4, "gocap": 5e4,
+}
+
+var (
+	numericAmountRe = regexp.MustCompile(`\b(rp\.?\s*)?(\d+(?:[.,]\d+)*)(?:\s*(rb|ribu|rebu|k|jt|juta|miliar|milyar)(\d{1,3})?)?\b`)
+	wordRe          = regexp.MustCompile(`[a-z]+`)
+)
+
+// FindAmounts returns the rupiah amounts in an Indonesian message in the order they appear:
+// "18rb", "18k", "1,5jt", "2jt500", "Rp15.000", "lima belas ribu", "setengah juta", "goceng".
+// Bare numbers without a unit or "Rp" are only taken from 1000 upward, so quantities like
+// "2 porsi" are not amounts, and never when they look like a year ("kalender 2024"); likewise
+// spelled-out numbers need a unit word ("dua ribu").
+func FindAmounts(text string) []AmountMatch {
+	text = strings.ToLower(text)
+	var matches []AmountMatch
+	claimed := make([]bool, len(text))
+
+	for _, idx := range numericAmountRe.FindAllStringSubmatchIndex(text, -1) {
+		group := func(i int) string {
+			if idx[2*i] < 0 {
+				return ""
+			}
+			return text[idx[2*i]:idx[2*i+1]]
+		}
+		value, ok := parseNumber(group(2), group(3) != "")
+		if !ok {
+			continue
+		}
+		scale := 1.0
+		if suffix := group(3); suffix != "" {
+			scale = amountSuffixes[suffix]
+			if frac := group(4); frac != "" {
+				// "2jt500" = 2,5 juta, "20rb5" = 20.500
+				f, _ := strconv.ParseFloat("0."+frac, 64)
+				value += f
+			}
+			value *= scale
+		} else if group(1) == "" && (value < 1000 || isYear(group(2))) {
+			continue
+		}
+		matches = append(matches, AmountMatch{Value: value, Start: idx[0], End: idx[1], scale: scale})
+		for i := idx[0]; i < idx[1]; i++ {
+			claimed[i] = true
+		}
+	}
+
+	matches = append(matches, findWordAmounts(text, claimed)...)
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return joinCompoundAmounts(text, matches)
+}
+
+// isYear reports whether a bare number is written like a year: four digits, 1900-2100.
+func isYear(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1900 && n <= 2100
+}
+
+// parseNumber reads "15.000", "2.500.000", "15.000,00" and, with a unit, "1,5" or "1.5".
+// A separator followed by exactly three digits groups thousands; otherwise it is decimal.
+func parseNumber(s string, hasUnit bool) (float64, bool) {
+	last := strings.LastIndexAny(s, ".,")
+	if last < 0 {
+		n, err := strconv.ParseFloat(s, 64)
+		return n, err == nil
+	}
+	intPart, fracPart := s, ""
+	if len(s)-last-1 != 3 || (hasUnit && strings.Count(s, ".")+strings.Count(s, ",") == 1) {
+		intPart, fracPart = s[:last], s[last+1:]
+	}
+	intPart = strings.NewReplacer(".", "", ",", "").Replace(intPart)
+	n, err := strconv.ParseFloat(intPart+"."+fracPart, 64)
+	if fracPart == "" {
+		n, err = strconv.ParseFloat(intPart, 64)
+	}
+	return n, err == nil
+}
+
+// findWordAmounts reads runs of number words separated only by spaces.
+func findWordAmounts(text string, claimed []bool) []AmountMatch {
+	var matches []AmountMatch
+	words := wordRe.FindAllStringIndex(text, -1)
+
+	for i := 0; i < len(words); {
+		start := i
+		for i < len(words) && isAmountWord(text, words[i], claimed) &&
+			(i == start || strings.TrimSpace(text[words[i-1][1]:words[i][0]]) == "") {
+			i++
+		}
+		if i == start {
+			i++
+			continue
+		}
+		if value, scale, ok := evalAmountWords(text, words[start:i]); ok {
+			matches = append(matches, AmountMatch{Value: value, Start: words[start][0], End: words[i-1][1], scale: scale})
+		}
+	}
+	return matches
+}
+
+func isAmountWord(text string, span []int, claimed []bool) bool {
+	if claimed[span[0]] {
+		return false
+	}
+	w := text[span[0]:span[1]]
+	_, isWord := amountWords[w]
+	_, isSlang := amountSlang[w]
+	return isWord || isSlang
+}
+
+func evalAmountWords(text string, spans [][]int) (value float64, scale float64, ok bool) {
+	var total, current, last float64
+	scale = 1
+	for _, span := range spans {
+		w := text[span[0]:span[1]]
+		if v, slang := amountSlang[w]; slang {
+			total += v
+			scale = math.Max(scale, 100)
+			continue
+		}
+		switch w {
+		case "belas":
+			current += 10
+		case "puluh":
+			current += last * 9
+		case "ratus":
+			current += last * 99
+			scale = math.Max(scale, 100)
+		case "seratus":
+			current += 100
+			scale = math.Max(scale, 100)
+		case "seribu", "sejuta":
+			total += amountWords[w]
+			scale = math.Max(scale, amountWords[w])
+		case "ribu", "rebu", "juta", "miliar", "milyar":
+			if current == 0 {
+				current = 1
+			}
+			total += current * amountWords[w]
+			current = 0
+			scale = math.Max(scale, amountWords[w])
+		default:
+			last = amountWords[w]
+			current += last
+		}
+	}
+	total += current
+	return total, scale, scale >= 100 && total > 0
+}
+
+// joinCompoundAmounts merges "1jt 200rb" or "satu juta dua ratus ribu" split across matches.
+func joinCompoundAmounts(text string, matches []AmountMatch) []AmountMatch {
+	var out []AmountMatch
+	for _, m := range matches {
+		if n := len(out); n > 0 {
+			prev := &out[n-1]
+			if prev.scale > 1 && m.scale > 1 && m.Value < prev.scale && strings.TrimSpace(text[prev.End:m.Start]) == "" {
+				prev.Value += m.Value
+				prev.End = m.End
+				continue
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package utils
+
+import "testing"
+
+func TestFindAmounts(t *testing.T) {
+	cases := []struct {
+		text string
+		want []float64
+	}{
+		{"kopi 18rb gopay", []float64{18000}},
+		{"kopi 18 rb", []float64{18000}},
+		{"bensin 25k", []float64{25000}},
+		{"laptop 7,5jt", []float64{7500000}},
+		{"hp 2jt500", []float64{2500000}},
+		{"sewa 1jt 200rb", []float64{1200000}},
+		{"makan Rp15.000", []float64{15000}},
+		{"makan rp 15.000,00", []float64{15000}},
+		{"parkir rp 500", []float64{500}},
+		{"listrik 350000", []float64{350000}},
+		{"tv 2.500.000", []float64{2500000}},
+		{"nasi 2 porsi", nil},
+		{"beli kalender tahun 2024", nil},
+		{"kalender 2024 35rb", []float64{35000}},
+		{"parkir rp 2000", []float64{2000}},
+		{"parkir 2.000", []float64{2000}},
+		{"sewa 2024rb", []float64{2024000}},
+		{"lima belas ribu buat bakso", []float64{15000}},
+		{"dua puluh lima ribu", []float64{25000}},
+		{"seratus lima puluh ribu", []float64{150000}},
+		{"setengah juta buat kos", []float64{500000}},
+		{"satu setengah juta", []float64{1500000}},
+		{"satu juta dua ratus ribu", []float64{1200000}},
+		{"seribu lima ratus", []float64{1500}},
+		{"nasi dua bungkus", nil},
+		{"goceng buat parkir", []float64{5000}},
+		{"kopi 18rb, roti 12rb", []float64{18000, 12000}},
+	}
+
+	for _, tc := range cases {
+		got := FindAmounts(tc.text)
+		if len(got) != len(tc.want) {
+			t.Errorf("FindAmounts(%q) = %+v, want %v", tc.text, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Value != tc.want[i] {
+				t.Errorf("FindAmounts(%q)[%d] = %v, want %v", tc.text, i, got[i].Value, tc.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var monthNames = map[string]time.Month{
+	"januari": 1, "jan": 1,
+	"februari": 2, "feb": 2, "pebruari": 2,
+	"maret": 3, "mar": 3,
+	"april": 4, "apr": 4,
+	"mei":  5,
+	"juni": 6, "jun": 6,
+	"juli": 7, "jul": 7,
+	"agustus": 8, "agu": 8, "agt": 8, "ags": 8,
+	"september": 9, "sep": 9, "sept": 9,
+	"oktober": 10, "okt": 10,
+	"november": 11, "nov": 11, "nopember": 11,
+	"desember": 12, "des": 12,
+}
+
+var weekdays = map[string]time.Weekday{
+	"minggu": time.Sunday, "ahad": time.Sunday,
+	"senin":  time.Monday,
+	"selasa": time.Tuesday,
+	"rabu":   time.Wednesday,
+	"kamis":  time.Thursday,
+	"jumat":  time.Friday, "jum'at": time.Friday,
+	"sabtu": time.Saturday,
+}
+
+var numberWords = map[string]int{
+	"se": 1, "satu": 1, "dua": 2, "tiga": 3, "empat": 4, "lima": 5,
+	"enam": 6, "tujuh": 7, "delapan": 8, "sembilan": 9, "sepuluh": 10,
+}
+
+const (
+	monthPattern   = `(januari|februari|pebruari|maret|april|mei|juni|juli|agustus|september|oktober|november|nopember|desember|jan|feb|mar|apr|jun|jul|agu|agt|ags|sept|sep|okt|nov|des)`
+	yesterdayWords = `(?:kemarin|kemaren|kmrn|kmren|kmarin)`
+	pastWords      = `(?:lalu|kemarin|kemaren|kmrn)`
+)
+
+// dateRule matches one kind of expression; resolve returns false when the match is not a real date
+// (e.g. "tgl 31" in a 30-day month).
+type dateRule struct {
+	re      *regexp.Regexp
+	resolve func(m []string, today time.Time) (time.Time, bool)
+}
+
+// Rules are tried in order and a later rule never matches text already claimed by an earlier one,
+// so "senin kemarin" is a weekday and not "kemarin", and "kemarin lusa" is not "lusa".
+var dateRules = []dateRule{
+	{regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`), func(m []string, today time.Time) (time.Time, bool) {
+		return exactDate(atoi(m[1]), atoi(m[2]), atoi(m[3]), today.Location())
+	}},
+	{regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})(?:/(\d{2}|\d{4}))?\b`), func(m []string, today time.Time) (time.Time, bool) {
+		return dayMonthYear(atoi(m[1]), time.Month(atoi(m[2])), m[3], today)
+	}},
+	{regexp.MustCompile(`\b(?:tgl|tanggal)\.?\s*(\d{1,2})(?:\s+` + monthPattern + `)?(?:\s+(\d{4}))?\b`), func(m []string, today time.Time) (time.Time, bool) {
+		if m[2] == "" {
+			return pastDayOfMonth(atoi(m[1]), today)
+		}
+		return dayMonthYear(atoi(m[1]), monthNames[m[2]], m[3], today)
+	}},
+	{regexp.MustCompile(`\b(\d{1,2})\s+` + monthPattern + `(?:\s+(\d{4}))?\b`), func(m []string, today time.Time) (time.Time, bool) {
+		return dayMonthYear(atoi(m[1]), monthNames[m[2]], m[3], today)
+	}},
+	// "minggu" alone means "week", so Sunday needs the "hari" prefix.
+	{regexp.MustCompile(`\b(?:hari\s+(senin|selasa|rabu|kamis|jumat|jum'at|sabtu|minggu|ahad)|(senin|selasa|rabu|kamis|jumat|jum'at|sabtu|ahad))(\s+` + pastWords + `)?\b`), func(m []string, today time.Time) (time.Time, bool) {
+		name := m[1] + m[2]
+		diff := (int(today.Weekday()) - int(weekdays[name]) + 7) % 7
+		if diff == 0 && m[3] != "" {
+			diff = 7
+		}
+		return today.AddDate(0, 0, -diff), true
+	}},
+	{regexp.MustCompile(`\b(\d+|satu|dua|tiga|empat|lima|enam|tujuh|delapan|sembilan|sepuluh|se)\s*(hari|minggu|bulan)\s+(?:yang\s+|yg\s+)?lalu\b`), func(m []string, today time.Time) (time.Time, bool) {
+		n, ok := numberWords[m[1]]
+		if !ok {
+			n = atoi(m[1])
+		}
+		return shift(today, m[2], n), true
+	}},
+	{regexp.MustCompile(`\b(minggu|bulan)\s+` + pastWords + `\b`), func(m []string, today time.Time) (time.Time, bool) {
+		return shift(today, m[1], 1), true
+	}},
+	{regexp.MustCompile(`\b` + yesterdayWords + `\s+(?:lusa|dulu)\b`), func(_ []string, today time.Time) (time.Time, bool) {
+		return today.AddDate(0, 0, -2), true
+	}},
+	{regexp.MustCompile(`\b` + yesterdayWords + `\b`), func(_ []string, today time.Time) (time.Time, bool) {
+		return today.AddDate(0, 0, -1), true
+	}},
+	{regexp.MustCompile(`\blusa\b`), func(_ []string, today time.Time) (time.Time, bool) {
+		return today.AddDate(0, 0, 2), true
+	}},
+	{regexp.MustCompile(`\bbesok\b`), func(_ []string, today time.Time) (time.Time, bool) {
+		return today.AddDate(0, 0, 1), true
+	}},
+	{regexp.MustCompile(`\bhari\s+ini\b`), func(_ []string, today time.Time) (time.Time, bool) {
+		return today, true
+	}},
+}
+
+// FindDates returns every distinct date mentioned in an Indonesian message ("kemarin",
+// "lusa", "minggu lalu", "tgl 3", "senin kemarin", "3 maret", "2025-03-03", ...), in the
+// order they appear. Dates are midnight in now's location; callers pass now in Asia/Jakarta.
+// Dates without a year, or a bare "tgl N", resolve to the most recent such day not after today.
+func FindDates(text string, now time.Time) []time.Time {
+	text = strings.ToLower(text)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	type found struct {
+		pos  int
+		date time.Time
+	}
+	var matches []found
+	claimed := make([]bool, len(text))
+
+	for _, rule := range dateRules {
+		for _, idx := range rule.re.FindAllStringSubmatchIndex(text, -1) {
+			if overlaps(claimed, idx[0], idx[1]) {
+				continue
+			}
+			m := make([]string, len(idx)/2)
+			for i := range m {
+				if idx[2*i] >= 0 {
+					m[i] = text[idx[2*i]:idx[2*i+1]]
+				}
+			}
+			date, ok := rule.resolve(m, today)
+			if !ok {
+				continue
+			}
+			for i := idx[0]; i < idx[1]; i++ {
+				claimed[i] = true
+			}
+			matches = append(matches, found{idx[0], date})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].pos < matches[j].pos })
+	dates := make([]time.Time, 0, len(matches))
+	for _, f := range matches {
+		duplicate := false
+		for _, d := range dates {
+			if d.Equal(f.date) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			dates = append(dates, f.date)
+		}
+	}
+	return dates
+}
+
+func overlaps(claimed []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if claimed[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func shift(today time.Time, unit string, n int) time.Time {
+	switch unit {
+	case "minggu":
+		return today.AddDate(0, 0, -7*n)
+	case "bulan":
+		return today.AddDate(0, -n, 0)
+	default:
+		return today.AddDate(0, 0, -n)
+	}
+}
+
+// exactDate rejects overflowing dates instead of letting time.Date normalise them (31/4 → 1/5).
+func exactDate(year, month, day int, loc *time.Location) (time.Time, bool) {
+	if month < 1 || month > 12 || day < 1 || day > lastDayOfMonth(year, time.Month(month), loc) {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc), true
+}
+
+func dayMonthYear(day int, month time.Month, year string, today time.Time) (time.Time, bool) {
+	if year != "" {
+		y := atoi(year)
+		if y < 100 {
+			y += 2000
+		}
+		return exactDate(y, int(month), day, today.Location())
+	}
+	date, ok := exactDate(today.Year(), int(month), day, today.Location())
+	if ok && date.After(today) {
+		return exactDate(today.Year()-1, int(month), day, today.Location())
+	}
+	return date, ok
+}
+
+// pastDayOfMonth finds the most recent month, starting with the current one, whose day-th day
+// is not after today; "tgl 31" in early March is 31 January.
+func pastDayOfMonth(day int, today time.Time) (time.Time, bool) {
+	year, month := today.Year(), today.Month()
+	for i := 0; i < 12; i++ {
+		if date, ok := exactDate(year, int(month), day, today.Location()); ok && !date.After(today) {
+			return date, true
+		}
+		if month--; month == 0 {
+			year, month = year-1, time.December
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindDates(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	// Rabu, 5 Maret 2025 pukul 00:30 WIB — masih 4 Maret di UTC.
+	now := time.Date(2025, time.March, 5, 0, 30, 0, 0, wib)
+	day := func(y int, m time.Month, d int) string {
+		return time.Date(y, m, d, 0, 0, 0, 0, wib).Format("2006-01-02")
+	}
+
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"beli kopi 15rb", nil},
+		{"kemarin makan bakso 20rb", []string{day(2025, 3, 4)}},
+		{"kmrn isi bensin", []string{day(2025, 3, 4)}},
+		{"kemarin lusa nonton", []string{day(2025, 3, 3)}},
+		{"lusa bayar kos", []string{day(2025, 3, 7)}},
+		{"besok servis motor", []string{day(2025, 3, 6)}},
+		{"minggu lalu beli sepatu", []string{day(2025, 2, 26)}},
+		{"bulan lalu bayar asuransi", []string{day(2025, 2, 5)}},
+		{"3 hari yang lalu parkir", []string{day(2025, 3, 2)}},
+		{"dua minggu lalu servis", []string{day(2025, 2, 19)}},
+		{"tanggal 3 bayar listrik", []string{day(2025, 3, 3)}},
+		{"tgl 20 bayar internet", []string{day(2025, 2, 20)}},
+		{"tgl 31 beli pulsa", []string{day(2025, 1, 31)}},
+		{"senin kemarin beli buku", []string{day(2025, 3, 3)}},
+		{"rabu kemarin makan siang", []string{day(2025, 2, 26)}},
+		{"rabu makan siang", []string{day(2025, 3, 5)}},
+		{"hari minggu belanja", []string{day(2025, 3, 2)}},
+		{"12 desember beli jaket", []string{day(2024, 12, 12)}},
+		{"tgl 1 maret 2024 gajian", []string{day(2024, 3, 1)}},
+		{"struk 02/03/2025", []string{day(2025, 3, 2)}},
+		{"2025-02-28 bayar pajak", []string{day(2025, 2, 28)}},
+		{"31/02 tidak valid", nil},
+		{"kemarin kopi, hari ini roti", []string{day(2025, 3, 4), day(2025, 3, 5)}},
+		{"kemarin kopi, kemarin juga roti", []string{day(2025, 3, 4)}},
+		{"Kemarin Makan Bakso", []string{day(2025, 3, 4)}},
+	}
+
+	for _, tc := range cases {
+		got := FindDates(tc.text, now)
+		if len(got) != len(tc.want) {
+			t.Errorf("FindDates(%q) = %v, want %v", tc.text, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Format("2006-01-02") != tc.want[i] {
+				t.Errorf("FindDates(%q)[%d] = %s, want %s", tc.text, i, got[i].Format("2006-01-02"), tc.want[i])
+			}
+		}
+	}
+}